@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	budgetService "github.com/SergeyShpak/gopher-corp-backend/pkg/budget/service"
+	budgetStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+const (
+	budgetAlertVarNameThresholds = "BUDGET_ALERT_THRESHOLDS"
+	budgetAlertVarNameInterval   = "BUDGET_ALERT_INTERVAL"
+	budgetAlertVarNameWebhookURL = "BUDGET_ALERT_WEBHOOK_URL"
+)
+
+const defaultBudgetAlertInterval = time.Hour
+
+func startBudgetAlerter(connStr *database.ConnString) (*budgetService.Alerter, error) {
+	thresholds := budgetService.DefaultAlertThresholds
+	if val, ok := os.LookupEnv(budgetAlertVarNameThresholds); ok {
+		var err error
+		thresholds, err = budgetService.ParseAlertThresholds(val)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", budgetAlertVarNameThresholds, err)
+		}
+	}
+	interval := defaultBudgetAlertInterval
+	if val, ok := os.LookupEnv(budgetAlertVarNameInterval); ok {
+		var err error
+		interval, err = time.ParseDuration(val)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", budgetAlertVarNameInterval, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("%s must be positive", budgetAlertVarNameInterval)
+		}
+	}
+	notifiers := []budgetService.Notifier{budgetService.LogNotifier{}}
+	if val, ok := os.LookupEnv(budgetAlertVarNameWebhookURL); ok && len(val) != 0 {
+		notifiers = append(notifiers, budgetService.NewWebhookNotifier(val))
+	}
+
+	alerter := budgetService.NewAlerter(func() (budgetStorage.DB, error) {
+		return budgetStorage.NewDB(connStr, auditStorage.System("budget-alerter"))
+	}, thresholds, notifiers)
+	go alerter.Run(context.Background(), interval)
+	alerter.Trigger()
+	return alerter, nil
+}
+
+// createTriggerAlertsMiddleware triggers the budget alerts evaluation after
+// every successful request that may have changed salaries or budgets.
+func createTriggerAlertsMiddleware(alerter *budgetService.Alerter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" || r.Method == "HEAD" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			sw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r)
+			if sw.status < 300 {
+				alerter.Trigger()
+			}
+		})
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	apiKeysService "github.com/SergeyShpak/gopher-corp-backend/pkg/apikeys/service"
+	apiKeysStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/apikeys/storage"
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+// apiKeysCommand manages the API keys instead of starting the server:
+//
+//	service api-keys create -name payroll-bot -scopes directory:read,budget:read -expires-in 2160h
+//	service api-keys list
+//	service api-keys revoke -id 7
+const apiKeysCommand = "api-keys"
+
+const apiKeysUsage = "usage: api-keys create -name NAME -scopes SCOPE[,SCOPE] [-expires-in DURATION] | list | revoke -id ID"
+
+func newAPIKeyAuthenticator(connStr *database.ConnString) *apiKeysService.Authenticator {
+	return apiKeysService.NewAuthenticator(func() (apiKeysStorage.DB, error) {
+		return apiKeysStorage.NewDB(connStr, nil)
+	})
+}
+
+func runAPIKeysCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(apiKeysUsage)
+	}
+	connStr, err := getConnString()
+	if err != nil {
+		return fmt.Errorf("failed to get connection string info for DB connection: %w", err)
+	}
+	db, err := apiKeysStorage.NewDB(connStr, auditStorage.System("api-keys-cli"))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "create":
+		return createAPIKey(db, args[1:])
+	case "list":
+		return listAPIKeys(db)
+	case "revoke":
+		return revokeAPIKey(db, args[1:])
+	}
+	return fmt.Errorf("unknown command %q, %s", args[0], apiKeysUsage)
+}
+
+func createAPIKey(db apiKeysStorage.DB, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	name := flags.String("name", "", "the name of the service using the key")
+	scopes := flags.String("scopes", "", "comma-separated scopes, e.g. directory:read,budget:read")
+	expiresIn := flags.Duration("expires-in", 0, "how long the key is valid, forever if zero")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	created, key, err := apiKeysService.CreateKey(db, *name, strings.Split(*scopes, ","), *expiresIn)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created API key %d %q with scopes %s\n", created.ID, created.Name, strings.Join(created.Scopes, " "))
+	fmt.Println("the key is shown only once:")
+	fmt.Println(key)
+	return nil
+}
+
+func listAPIKeys(db apiKeysStorage.DB) error {
+	keys, err := apiKeysService.ListKeys(db)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPREFIX\tSCOPES\tCREATED\tEXPIRES\tLAST USED\tREVOKED")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			k.ID, k.Name, k.Prefix, strings.Join(k.Scopes, " "), formatTime(&k.CreatedAt),
+			formatTime(k.ExpiresAt), formatTime(k.LastUsedAt), formatTime(k.RevokedAt),
+		)
+	}
+	return w.Flush()
+}
+
+func revokeAPIKey(db apiKeysStorage.DB, args []string) error {
+	flags := flag.NewFlagSet("revoke", flag.ContinueOnError)
+	id := flags.String("id", "", "the ID of the key")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	keyID, err := strconv.ParseInt(*id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("incorrect API key ID %q: %w", *id, err)
+	}
+	revoked, err := apiKeysService.RevokeKey(db, keyID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("revoked API key %d %q at %s\n", revoked.ID, revoked.Name, formatTime(revoked.RevokedAt))
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	audit "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/http"
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+func registerAuditRoutes(r *mux.Router, connStr *database.ConnString, limits *rateLimits) {
+	s := limits.subrouter(r, "/audit", rateLimitRouteAudit)
+	s.Use(auth.RequireScope(auth.ResourceAudit))
+	s.Use(policy.RequireByMethod(policy.EntityAudit))
+	s.HandleFunc("", audit.ListEntries).Methods("GET")
+	s.Use(createAddDBMiddleware(auditStorage.ContextKeyDB, func(r *http.Request) (closer, error) {
+		return auditStorage.NewDB(requestConnString(connStr, r))
+	}))
+}
+
+// requestIDMiddleware tags every request with the ID from the X-Request-ID
+// header or a random one and returns the ID in the same response header, so
+// that the audit log entries can be traced back to the request.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if len(id) == 0 {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("[ERR]: failed to generate a request ID: %v", err)
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// auditSource tells the audit log who makes the changes in the request.
+func auditSource(r *http.Request) *auditStorage.Source {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return &auditStorage.Source{
+		Actor:     auth.Subject(r.Context()),
+		RequestID: id,
+	}
+}
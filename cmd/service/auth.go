@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+)
+
+const (
+	authVarNameHS256Secret = "AUTH_JWT_HS256_SECRET"
+	authVarNameJWKSFile    = "AUTH_JWKS_FILE"
+	authVarNameIssuer      = "AUTH_JWT_ISSUER"
+	authVarNameAudience    = "AUTH_JWT_AUDIENCE"
+	authVarNameLeeway      = "AUTH_JWT_LEEWAY"
+)
+
+// healthPath is the only route open to the requests that are not
+// authenticated.
+const healthPath = "/health"
+
+// newTokenVerifier configures the verification of the bearer tokens, HS256
+// ones with the secret and RS256 and ES256 ones with the keys of the JWKS file.
+// At least one of them is required.
+func newTokenVerifier() (*auth.Verifier, error) {
+	c := auth.VerifierConfig{
+		HS256Secret: []byte(os.Getenv(authVarNameHS256Secret)),
+		Issuer:      os.Getenv(authVarNameIssuer),
+		Audience:    os.Getenv(authVarNameAudience),
+		Leeway:      auth.DefaultLeeway,
+	}
+	if path, ok := os.LookupEnv(authVarNameJWKSFile); ok && len(path) != 0 {
+		var err error
+		c.KeySet, err = auth.LoadKeySet(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", authVarNameJWKSFile, err)
+		}
+	}
+	if val, ok := os.LookupEnv(authVarNameLeeway); ok {
+		var err error
+		c.Leeway, err = time.ParseDuration(val)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", authVarNameLeeway, err)
+		}
+	}
+	return auth.NewVerifier(c)
+}
+
+func health(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	employeesStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/encryption"
+)
+
+// contactsVarNameKeyringFile is the JSON keyring sealing the contacts of the
+// employees, see encryption.LoadKeyring.
+const contactsVarNameKeyringFile = "CONTACTS_KEYRING_FILE"
+
+// contactsCommand manages the sealed contacts instead of starting the server:
+//
+//	service contacts new-key
+//	service contacts reencrypt [-reindex]
+//	service contacts decrypt
+//
+// A key is rotated by adding a new key to the keyring, making it primary,
+// restarting the service and running reencrypt. The old key can be removed
+// from the keyring once reencrypt is done.
+const contactsCommand = "contacts"
+
+const contactsUsage = "usage: contacts new-key | reencrypt [-reindex] | decrypt"
+
+// setContactsKeyring loads the keyring the storages seal the contacts with.
+func setContactsKeyring() error {
+	path, ok := os.LookupEnv(contactsVarNameKeyringFile)
+	if !ok || len(path) == 0 {
+		return fmt.Errorf("variable %s is not defined", contactsVarNameKeyringFile)
+	}
+	k, err := encryption.LoadKeyring(path)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", contactsVarNameKeyringFile, err)
+	}
+	encryption.SetKeyring(k)
+	return nil
+}
+
+// checkContactsIndexed refuses to start the server while some contacts are
+// not indexed, e.g. right after the migration that sealed them: the lookups
+// would not find them.
+func checkContactsIndexed(connStr *database.ConnString) error {
+	db, err := database.OpenGorm(connStr)
+	if err != nil {
+		return err
+	}
+	defer database.CloseGorm(db)
+	n, err := employeesStorage.CountUnindexedContacts(context.Background(), db)
+	if err != nil {
+		return err
+	}
+	if n != 0 {
+		return fmt.Errorf("%d contacts are not indexed, run \"service contacts reencrypt\" first", n)
+	}
+	return nil
+}
+
+func runContactsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(contactsUsage)
+	}
+	if args[0] == "new-key" {
+		key, err := encryption.NewKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	}
+	if err := setContactsKeyring(); err != nil {
+		return err
+	}
+	connStr, err := getConnString()
+	if err != nil {
+		return fmt.Errorf("failed to get connection string info for DB connection: %w", err)
+	}
+	db, err := database.OpenGorm(connStr)
+	if err != nil {
+		return err
+	}
+	defer database.CloseGorm(db)
+
+	switch args[0] {
+	case "reencrypt":
+		flags := flag.NewFlagSet("reencrypt", flag.ContinueOnError)
+		reindex := flags.Bool("reindex", false, "index all the contacts again, e.g. after the index key has changed")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		n, err := employeesStorage.ReencryptContacts(context.Background(), db, *reindex)
+		if err != nil {
+			return fmt.Errorf("failed to reencrypt the contacts, %d rewritten: %w", n, err)
+		}
+		fmt.Printf("reencrypted %d contacts\n", n)
+		return nil
+	case "decrypt":
+		n, err := employeesStorage.DecryptContacts(context.Background(), db)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt the contacts, %d rewritten: %w", n, err)
+		}
+		fmt.Printf("decrypted %d contacts\n", n)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q, %s", args[0], contactsUsage)
+}
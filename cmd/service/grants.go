@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	grants "github.com/SergeyShpak/gopher-corp-backend/pkg/grants/http"
+	grantsService "github.com/SergeyShpak/gopher-corp-backend/pkg/grants/service"
+	grantsStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/grants/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+)
+
+// newGrantsMiddleware adds to the authenticated callers the roles granted to
+// them in the department subtrees.
+func newGrantsMiddleware(connStr *database.ConnString) mux.MiddlewareFunc {
+	return grants.NewMiddleware(grantsService.NewLoader(func() (grantsStorage.DB, error) {
+		return grantsStorage.NewDB(connStr, nil)
+	}))
+}
+
+func registerGrantsRoutes(r *mux.Router, connStr *database.ConnString, limits *rateLimits) {
+	s := limits.subrouter(r, "/grants", rateLimitRouteGrants)
+	s.Handle("", requireAnywhere(policy.EntityRoleGrant, policy.ActionRead, grants.ListGrants)).Methods("GET")
+	s.Handle("", requireAnywhere(policy.EntityRoleGrant, policy.ActionWrite, grants.CreateGrant)).Methods("POST")
+	s.Handle("/{grantID}", requireAnywhere(policy.EntityRoleGrant, policy.ActionWrite, func(w http.ResponseWriter, r *http.Request) {
+		grants.RevokeGrant(w, r, mux.Vars(r)["grantID"])
+	})).Methods("DELETE")
+	s.Use(createAddDBMiddleware(grantsStorage.ContextKeyDB, func(r *http.Request) (closer, error) {
+		return grantsStorage.NewDB(requestConnString(connStr, r), auditSource(r))
+	}))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	emailHintService "github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/service"
+	emailHintStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/storage"
+)
+
+const (
+	phoneLookupsVarNameRetention = "PHONE_LOOKUPS_RETENTION"
+	phoneLookupsVarNameInterval  = "PHONE_LOOKUPS_CLEANUP_INTERVAL"
+)
+
+const defaultPhoneLookupsCleanupInterval = 24 * time.Hour
+
+// startLookupLogCleaner deletes the phone lookups older than the retention
+// period.
+func startLookupLogCleaner(connStr *database.ConnString) error {
+	retention := emailHintService.DefaultLookupRetention
+	if val, ok := os.LookupEnv(phoneLookupsVarNameRetention); ok {
+		var err error
+		retention, err = time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", phoneLookupsVarNameRetention, err)
+		}
+		if retention <= 0 {
+			return fmt.Errorf("%s must be positive", phoneLookupsVarNameRetention)
+		}
+	}
+	interval := defaultPhoneLookupsCleanupInterval
+	if val, ok := os.LookupEnv(phoneLookupsVarNameInterval); ok {
+		var err error
+		interval, err = time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", phoneLookupsVarNameInterval, err)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("%s must be positive", phoneLookupsVarNameInterval)
+		}
+	}
+	cleaner := emailHintService.NewLookupLogCleaner(func() (emailHintStorage.DB, error) {
+		return emailHintStorage.NewDB(connStr)
+	}, retention)
+	go cleaner.Run(context.Background(), interval)
+	return nil
+}
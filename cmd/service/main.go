@@ -9,11 +9,40 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	budget "github.com/SergeyShpak/gopher-corp-backend/pkg/budget/http"
+	budgetService "github.com/SergeyShpak/gopher-corp-backend/pkg/budget/service"
+	budgetStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
 	emailHint "github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/http"
-	"github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/storage"
+	emailHintService "github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/service"
+	emailHintStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/storage"
+	employees "github.com/SergeyShpak/gopher-corp-backend/pkg/employees/http"
+	employeesStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	org "github.com/SergeyShpak/gopher-corp-backend/pkg/org/http"
+	orgStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/org/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+	positions "github.com/SergeyShpak/gopher-corp-backend/pkg/positions/http"
+	positionsStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/positions/storage"
+	rates "github.com/SergeyShpak/gopher-corp-backend/pkg/rates/http"
+	ratesStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/rates/storage"
+	reorg "github.com/SergeyShpak/gopher-corp-backend/pkg/reorg/http"
+	reorgStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/reorg/storage"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == apiKeysCommand {
+		if err := runAPIKeysCommand(os.Args[2:]); err != nil {
+			log.Fatalf("[ERR]: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == contactsCommand {
+		if err := runContactsCommand(os.Args[2:]); err != nil {
+			log.Fatalf("[ERR]: %v", err)
+		}
+		return
+	}
 	srv, err := initServer()
 	if err != nil {
 		log.Fatalf("[ERR]: failed to initialize server: %v", err)
@@ -25,50 +54,293 @@ func main() {
 }
 
 func initServer() (*http.Server, error) {
-	handler, err := registerRoutes()
+	connStr, err := getConnString()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection string info for DB connection: %w", err)
+	}
+	if err := setDefaultVisibility(); err != nil {
+		return nil, fmt.Errorf("failed to set the default contact visibility: %w", err)
+	}
+	if err := setContactsKeyring(); err != nil {
+		return nil, fmt.Errorf("failed to set the contacts keyring: %w", err)
+	}
+	if err := checkContactsIndexed(connStr); err != nil {
+		return nil, fmt.Errorf("failed to check the contacts: %w", err)
+	}
+	alerter, err := startBudgetAlerter(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the budget alerter: %w", err)
+	}
+	if err := startSalaryChangeApplier(connStr, alerter); err != nil {
+		return nil, fmt.Errorf("failed to start the salary change applier: %w", err)
+	}
+	if err := startLookupLogCleaner(connStr); err != nil {
+		return nil, fmt.Errorf("failed to start the phone lookup log cleaner: %w", err)
+	}
+	guard, err := newScrapingGuard()
 	if err != nil {
-		return nil, fmt.Errorf("failed to register routes: %w", err)
+		return nil, fmt.Errorf("failed to create the phone lookup scraping guard: %w", err)
+	}
+	verifier, err := newTokenVerifier()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure the authentication: %w", err)
+	}
+	limits, err := newRateLimits(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure the rate limits: %w", err)
 	}
 	srv := &http.Server{
 		Addr:    ":8080",
-		Handler: handler,
+		Handler: registerRoutes(connStr, verifier, limits, alerter, guard),
 	}
 	return srv, nil
 }
 
-func registerRoutes() (http.Handler, error) {
+func registerRoutes(connStr *database.ConnString, verifier *auth.Verifier, limits *rateLimits, alerter *budgetService.Alerter, guard *emailHintService.ScrapingGuard) http.Handler {
 	r := mux.NewRouter()
-	r.HandleFunc("/phone/{emailPrefix}", func(w http.ResponseWriter, r *http.Request) {
-		emailHint.GetPhonesByEmailPrefix(w, r, mux.Vars(r)["emailPrefix"])
+	r.HandleFunc(healthPath, health).Methods("GET")
+	registerEmailHintRoutes(r, connStr, limits, guard)
+	registerPositionsRoutes(r, connStr, limits)
+	registerEmployeesRoutes(r, connStr, limits, alerter)
+	registerBudgetRoutes(r, connStr, limits, alerter)
+	registerRatesRoutes(r, connStr, limits, alerter)
+	registerOrgRoutes(r, connStr, limits)
+	registerReorgRoutes(r, connStr, limits, alerter)
+	registerAuditRoutes(r, connStr, limits)
+	registerGrantsRoutes(r, connStr, limits)
+	r.Use(requestIDMiddleware)
+	r.Use(limits.ipMiddleware)
+	r.Use(auth.NewMiddleware(verifier, newAPIKeyAuthenticator(connStr), healthPath))
+	r.Use(newGrantsMiddleware(connStr))
+	return r
+}
+
+func registerEmailHintRoutes(r *mux.Router, connStr *database.ConnString, limits *rateLimits, guard *emailHintService.ScrapingGuard) {
+	addDBMiddleware := createAddDBMiddleware(emailHintStorage.ContextKeyDB, func(r *http.Request) (closer, error) {
+		return emailHintStorage.NewDB(requestConnString(connStr, r))
+	})
+
+	s := limits.subrouter(r, "/phone", rateLimitRoutePhone)
+	s.Use(auth.RequireScope(auth.ResourceDirectory))
+	s.Use(policy.RequireByMethod(policy.EntityDirectory))
+	s.HandleFunc("/numbers/{number}", func(w http.ResponseWriter, r *http.Request) {
+		emailHint.GetPhonesByNumber(w, r, mux.Vars(r)["number"])
 	}).Methods("GET")
-	addDBMiddleware, err := createAddDBMiddleware()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create AddDBMiddleware: %w", err)
-	}
-	r.Use(addDBMiddleware)
-	return r, err
+	s.HandleFunc("/{emailPrefix}", func(w http.ResponseWriter, r *http.Request) {
+		emailHint.GetPhonesByEmailPrefix(w, r, guard, mux.Vars(r)["emailPrefix"])
+	}).Methods("GET")
+	s.Use(addDBMiddleware)
+
+	s = limits.subrouter(r, "/employees/{employeeID}/phone-lookups", rateLimitRoutePhoneLookups)
+	s.Use(auth.RequireScope(auth.ResourceAudit))
+	s.HandleFunc("", func(w http.ResponseWriter, r *http.Request) {
+		emailHint.ListLookups(w, r, mux.Vars(r)["employeeID"])
+	}).Methods("GET")
+	s.Use(addDBMiddleware)
 }
 
-func createAddDBMiddleware() (func(next http.Handler) http.Handler, error) {
-	connStr, err := getConnString()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get connection string info for DB connection: %w", err)
-	}
+func registerPositionsRoutes(r *mux.Router, connStr *database.ConnString, limits *rateLimits) {
+	s := limits.subrouter(r, "/positions", rateLimitRoutePositions)
+	s.Use(auth.RequireScope(auth.ResourcePositions))
+	s.Use(policy.RequireByMethod(policy.EntityPosition))
+	s.HandleFunc("", positions.ListPositions).Methods("GET")
+	s.HandleFunc("", positions.CreatePosition).Methods("POST")
+	s.HandleFunc("/{positionID}", func(w http.ResponseWriter, r *http.Request) {
+		positions.GetPosition(w, r, mux.Vars(r)["positionID"])
+	}).Methods("GET")
+	s.HandleFunc("/{positionID}", func(w http.ResponseWriter, r *http.Request) {
+		positions.UpdatePosition(w, r, mux.Vars(r)["positionID"])
+	}).Methods("PUT")
+	s.HandleFunc("/{positionID}", func(w http.ResponseWriter, r *http.Request) {
+		positions.DeletePosition(w, r, mux.Vars(r)["positionID"])
+	}).Methods("DELETE")
+	s.Use(createAddDBMiddleware(positionsStorage.ContextKeyDB, func(r *http.Request) (closer, error) {
+		return positionsStorage.NewDB(requestConnString(connStr, r), auditSource(r))
+	}))
+}
+
+func registerEmployeesRoutes(r *mux.Router, connStr *database.ConnString, limits *rateLimits, alerter *budgetService.Alerter) {
+	s := limits.subrouter(r, "/employees", rateLimitRouteEmployees)
+	s.Use(auth.RequireScope(auth.ResourceEmployees))
+	s.Handle("", require(policy.EntityAssignment, policy.ActionRead, employees.ListDepartmentAssignments)).Queries("department", "{department}").Methods("GET")
+	s.Handle("", requireAnywhere(policy.EntityEmployee, policy.ActionWrite, employees.CreateEmployee)).Methods("POST")
+	s.HandleFunc("/{employeeID}", func(w http.ResponseWriter, r *http.Request) {
+		employees.GetEmployee(w, r, mux.Vars(r)["employeeID"])
+	}).Methods("GET")
+	s.Handle("/{employeeID}", requireAnywhere(policy.EntityEmployee, policy.ActionWrite, func(w http.ResponseWriter, r *http.Request) {
+		employees.UpdateEmployee(w, r, mux.Vars(r)["employeeID"])
+	})).Methods("PUT")
+	s.Handle("/{employeeID}", requireAnywhere(policy.EntityTermination, policy.ActionWrite, func(w http.ResponseWriter, r *http.Request) {
+		employees.TerminateEmployee(w, r, mux.Vars(r)["employeeID"])
+	})).Methods("DELETE")
+	s.Handle("/{employeeID}/termination", requireAnywhere(policy.EntityTermination, policy.ActionWrite, func(w http.ResponseWriter, r *http.Request) {
+		employees.TerminateEmployee(w, r, mux.Vars(r)["employeeID"])
+	})).Methods("POST")
+	s.Handle("/{employeeID}/terminations", require(policy.EntityTermination, policy.ActionRead, func(w http.ResponseWriter, r *http.Request) {
+		employees.ListTerminations(w, r, mux.Vars(r)["employeeID"])
+	})).Methods("GET")
+	s.Handle("/{employeeID}/rehire", requireAnywhere(policy.EntityTermination, policy.ActionWrite, func(w http.ResponseWriter, r *http.Request) {
+		employees.RehireEmployee(w, r, mux.Vars(r)["employeeID"])
+	})).Methods("POST")
+	s.HandleFunc("/{employeeID}/contact-visibility", func(w http.ResponseWriter, r *http.Request) {
+		employees.SetContactVisibility(w, r, mux.Vars(r)["employeeID"])
+	}).Methods("PUT")
+	s.Handle("/{employeeID}/assignments", require(policy.EntityAssignment, policy.ActionRead, func(w http.ResponseWriter, r *http.Request) {
+		employees.ListAssignments(w, r, mux.Vars(r)["employeeID"])
+	})).Methods("GET")
+	s.Handle("/{employeeID}/transfers", requireAnywhere(policy.EntityAssignment, policy.ActionWrite, func(w http.ResponseWriter, r *http.Request) {
+		employees.Transfer(w, r, mux.Vars(r)["employeeID"])
+	})).Methods("POST")
+	s.HandleFunc("/{employeeID}/salary-history", func(w http.ResponseWriter, r *http.Request) {
+		employees.ListSalaryChanges(w, r, mux.Vars(r)["employeeID"])
+	}).Methods("GET")
+	s.Handle("/{employeeID}/salary-changes", requireAnywhere(policy.EntitySalary, policy.ActionWrite, func(w http.ResponseWriter, r *http.Request) {
+		employees.ScheduleSalaryChange(w, r, mux.Vars(r)["employeeID"])
+	})).Methods("POST")
+	s.Handle("/{employeeID}/salary-changes/{changeID}", requireAnywhere(policy.EntitySalary, policy.ActionWrite, func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		employees.CancelSalaryChange(w, r, vars["employeeID"], vars["changeID"])
+	})).Methods("DELETE")
+	s.Use(createAddDBMiddleware(employeesStorage.ContextKeyDB, func(r *http.Request) (closer, error) {
+		return employeesStorage.NewDB(requestConnString(connStr, r), auditSource(r))
+	}))
+	s.Use(createTriggerAlertsMiddleware(alerter))
+}
 
+func registerBudgetRoutes(r *mux.Router, connStr *database.ConnString, limits *rateLimits, alerter *budgetService.Alerter) {
+	addDBMiddleware := createAddDBMiddleware(budgetStorage.ContextKeyDB, func(r *http.Request) (closer, error) {
+		return budgetStorage.NewDB(requestConnString(connStr, r), auditSource(r))
+	})
+
+	s := limits.subrouter(r, "/budgets", rateLimitRouteBudgets)
+	s.Use(auth.RequireScope(auth.ResourceBudget))
+	s.Use(policy.RequireByMethod(policy.EntityBudget))
+	s.HandleFunc("", budget.ListBudgets).Methods("GET")
+	s.HandleFunc("/alerts", budget.ListAlerts).Methods("GET")
+	s.HandleFunc("/{period}/copy-forward", func(w http.ResponseWriter, r *http.Request) {
+		budget.CopyForward(w, r, mux.Vars(r)["period"])
+	}).Methods("POST")
+	s.Use(addDBMiddleware)
+	s.Use(createTriggerAlertsMiddleware(alerter))
+
+	s = limits.subrouter(r, "/departments/{departmentID}", rateLimitRouteDepartments)
+	s.Use(auth.RequireScope(auth.ResourceBudget))
+	s.Handle("/budget", require(policy.EntityBudget, policy.ActionRead, func(w http.ResponseWriter, r *http.Request) {
+		budget.GetBudget(w, r, mux.Vars(r)["departmentID"], "")
+	})).Methods("GET")
+	s.Handle("/budget", requireAnywhere(policy.EntityBudget, policy.ActionWrite, func(w http.ResponseWriter, r *http.Request) {
+		budget.SetBudget(w, r, mux.Vars(r)["departmentID"], "")
+	})).Methods("PUT")
+	s.Handle("/budgets", require(policy.EntityBudget, policy.ActionRead, func(w http.ResponseWriter, r *http.Request) {
+		budget.GetBudgetEvolution(w, r, mux.Vars(r)["departmentID"])
+	})).Methods("GET")
+	s.Handle("/budgets/{period}", require(policy.EntityBudget, policy.ActionRead, func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		budget.GetBudget(w, r, vars["departmentID"], vars["period"])
+	})).Methods("GET")
+	s.Handle("/budgets/{period}", requireAnywhere(policy.EntityBudget, policy.ActionWrite, func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		budget.SetBudget(w, r, vars["departmentID"], vars["period"])
+	})).Methods("PUT")
+	s.Use(addDBMiddleware)
+	s.Use(createTriggerAlertsMiddleware(alerter))
+}
+
+func registerRatesRoutes(r *mux.Router, connStr *database.ConnString, limits *rateLimits, alerter *budgetService.Alerter) {
+	s := limits.subrouter(r, "/exchange-rates", rateLimitRouteExchangeRates)
+	s.Use(auth.RequireScope(auth.ResourceRates))
+	s.Use(policy.RequireByMethod(policy.EntityRates))
+	s.HandleFunc("", rates.ListRates).Methods("GET")
+	s.HandleFunc("", rates.UploadRates).Methods("POST")
+	s.Use(createAddDBMiddleware(ratesStorage.ContextKeyDB, func(r *http.Request) (closer, error) {
+		return ratesStorage.NewDB(requestConnString(connStr, r), auditSource(r))
+	}))
+	s.Use(createTriggerAlertsMiddleware(alerter))
+}
+
+func registerOrgRoutes(r *mux.Router, connStr *database.ConnString, limits *rateLimits) {
+	s := limits.subrouter(r, "/org", rateLimitRouteOrg)
+	s.Use(auth.RequireScope(auth.ResourceOrg))
+	s.Use(policy.RequireByMethod(policy.EntityOrg))
+	s.HandleFunc("", org.GetSnapshot).Methods("GET")
+	s.HandleFunc("/directory", org.GetDirectory).Methods("GET")
+	s.HandleFunc("/diff", org.GetDiff).Methods("GET")
+	s.Use(createAddDBMiddleware(orgStorage.ContextKeyDB, func(r *http.Request) (closer, error) {
+		return orgStorage.NewDB(requestConnString(connStr, r))
+	}))
+}
+
+func registerReorgRoutes(r *mux.Router, connStr *database.ConnString, limits *rateLimits, alerter *budgetService.Alerter) {
+	s := limits.subrouter(r, "/reorgs", rateLimitRouteReorgs)
+	s.Use(auth.RequireScope(auth.ResourceReorgs))
+	s.Use(policy.RequireByMethod(policy.EntityReorg))
+	s.HandleFunc("", reorg.ListReorgs).Methods("GET")
+	s.HandleFunc("", reorg.CreateReorg).Methods("POST")
+	s.HandleFunc("/{reorgID}", func(w http.ResponseWriter, r *http.Request) {
+		reorg.GetReorg(w, r, mux.Vars(r)["reorgID"])
+	}).Methods("GET")
+	s.HandleFunc("/{reorgID}/moves/{employeeID}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		reorg.StageMove(w, r, vars["reorgID"], vars["employeeID"])
+	}).Methods("PUT")
+	s.HandleFunc("/{reorgID}/moves/{employeeID}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		reorg.UnstageMove(w, r, vars["reorgID"], vars["employeeID"])
+	}).Methods("DELETE")
+	s.HandleFunc("/{reorgID}/departments/{departmentID}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		reorg.StageDepartmentChange(w, r, vars["reorgID"], vars["departmentID"])
+	}).Methods("PUT")
+	s.HandleFunc("/{reorgID}/departments/{departmentID}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		reorg.UnstageDepartmentChange(w, r, vars["reorgID"], vars["departmentID"])
+	}).Methods("DELETE")
+	s.HandleFunc("/{reorgID}/preview", func(w http.ResponseWriter, r *http.Request) {
+		reorg.PreviewReorg(w, r, mux.Vars(r)["reorgID"])
+	}).Methods("GET")
+	s.HandleFunc("/{reorgID}/commit", func(w http.ResponseWriter, r *http.Request) {
+		reorg.CommitReorg(w, r, mux.Vars(r)["reorgID"])
+	}).Methods("POST")
+	s.HandleFunc("/{reorgID}/discard", func(w http.ResponseWriter, r *http.Request) {
+		reorg.DiscardReorg(w, r, mux.Vars(r)["reorgID"])
+	}).Methods("POST")
+	s.Use(createAddDBMiddleware(reorgStorage.ContextKeyDB, func(r *http.Request) (closer, error) {
+		return reorgStorage.NewDB(requestConnString(connStr, r), auditSource(r))
+	}))
+	s.Use(createTriggerAlertsMiddleware(alerter))
+}
+
+// require lets the handler serve only the requests of the callers whose roles
+// allow the access to the object.
+func require(object string, action string, h http.HandlerFunc) http.Handler {
+	return policy.Require(object, action)(h)
+}
+
+// requireAnywhere is require that also lets through the callers who hold the
+// roles only in some departments, the handler checks the departments.
+func requireAnywhere(object string, action string, h http.HandlerFunc) http.Handler {
+	return policy.RequireAnywhere(object, action)(h)
+}
+
+type closer interface {
+	Close()
+}
+
+func createAddDBMiddleware(key interface{}, newDB func(r *http.Request) (closer, error)) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			db, err := storage.NewDB(connStr)
+			db, err := newDB(r)
 			if err != nil {
 				log.Println("[ERR]: ", err)
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
 			defer db.Close()
-			r = r.WithContext(context.WithValue(r.Context(), storage.ContextKeyDB, db))
+			r = r.WithContext(context.WithValue(r.Context(), key, db))
 
 			next.ServeHTTP(w, r)
 		})
-	}, nil
+	}
 }
 
 const (
@@ -79,7 +351,7 @@ const (
 	dbConnVarNameDBName   = "DB_NAME"
 )
 
-func getConnString() (*storage.ConnString, error) {
+func getConnString() (*database.ConnString, error) {
 	fnLookupVar := func(varName string) (string, error) {
 		val, ok := os.LookupEnv(varName)
 		if !ok {
@@ -87,7 +359,7 @@ func getConnString() (*storage.ConnString, error) {
 		}
 		return val, nil
 	}
-	connStr := &storage.ConnString{}
+	connStr := &database.ConnString{}
 	var err error
 	connStr.Host, err = fnLookupVar(dbConnVarNameHost)
 	if err != nil {
@@ -111,3 +383,10 @@ func getConnString() (*storage.ConnString, error) {
 	}
 	return connStr, nil
 }
+
+// requestConnString returns the connection string for the request: its
+// connections run as policy.AppRole and set the caller for the row-level
+// security.
+func requestConnString(connStr *database.ConnString, r *http.Request) *database.ConnString {
+	return connStr.WithSession(policy.SessionParams(auth.FromContext(r.Context())))
+}
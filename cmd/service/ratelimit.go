@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/ratelimit"
+)
+
+const (
+	rateLimitVarNameStore           = "RATE_LIMIT_STORE"
+	rateLimitVarNameCleanupInterval = "RATE_LIMIT_CLEANUP_INTERVAL"
+	// rateLimitVarPrefix starts the limits of the routes, e.g.
+	// RATE_LIMIT_PHONE_USER=60/m limits the phone lookups of every user. The
+	// DEFAULT route applies to the routes with no limit of their own, and
+	// "off" disables a limit.
+	rateLimitVarPrefix = "RATE_LIMIT_"
+)
+
+const (
+	rateLimitStoreMemory   = "memory"
+	rateLimitStorePostgres = "postgres"
+)
+
+const rateLimitOff = "off"
+
+const defaultRateLimitCleanupInterval = 10 * time.Minute
+
+// The routes limited separately.
+const (
+	rateLimitRouteDefault       = "default"
+	rateLimitRoutePhone         = "phone"
+	rateLimitRoutePhoneLookups  = "phone_lookups"
+	rateLimitRoutePositions     = "positions"
+	rateLimitRouteEmployees     = "employees"
+	rateLimitRouteBudgets       = "budgets"
+	rateLimitRouteDepartments   = "departments"
+	rateLimitRouteExchangeRates = "exchange_rates"
+	rateLimitRouteOrg           = "org"
+	rateLimitRouteReorgs        = "reorgs"
+	rateLimitRouteAudit         = "audit"
+	rateLimitRouteGrants        = "grants"
+)
+
+var rateLimitRoutes = []string{
+	rateLimitRoutePhone,
+	rateLimitRoutePhoneLookups,
+	rateLimitRoutePositions,
+	rateLimitRouteEmployees,
+	rateLimitRouteBudgets,
+	rateLimitRouteDepartments,
+	rateLimitRouteExchangeRates,
+	rateLimitRouteOrg,
+	rateLimitRouteReorgs,
+	rateLimitRouteAudit,
+	rateLimitRouteGrants,
+}
+
+// defaultRateLimits are the limits of the routes by the kind of the caller,
+// the phone lookups are limited tighter as they are scraped.
+var defaultRateLimits = map[string]map[string]string{
+	rateLimitRouteDefault: {
+		ratelimit.KindIP:   "600/m",
+		ratelimit.KindUser: "600/m",
+		ratelimit.KindKey:  "1200/m",
+	},
+	rateLimitRoutePhone: {
+		ratelimit.KindIP:   "60/m",
+		ratelimit.KindUser: "60/m",
+		ratelimit.KindKey:  "120/m",
+	},
+}
+
+var rateLimitCallers = map[string]func(r *http.Request) string{
+	ratelimit.KindIP:   ratelimit.ByIP,
+	ratelimit.KindUser: authenticatedSubject,
+	ratelimit.KindKey:  ratelimit.ByHeader(auth.APIKeyHeader),
+}
+
+// authenticatedSubject identifies the caller by the subject of the token.
+func authenticatedSubject(r *http.Request) string {
+	return auth.Subject(r.Context())
+}
+
+var rateLimitKinds = []string{ratelimit.KindIP, ratelimit.KindUser, ratelimit.KindKey}
+
+// rateLimits are the rate limiting rules of every route sharing a store. The
+// requests are limited by the IP of the caller before they are authenticated,
+// so that the rejected credentials are limited as well, and by the user and
+// the API key after.
+type rateLimits struct {
+	store   ratelimit.Store
+	ipRules map[string][]ratelimit.Rule
+	rules   map[string][]ratelimit.Rule
+	// prefixes are the path prefixes of the routes, see subrouter.
+	prefixes []rateLimitPrefix
+}
+
+type rateLimitPrefix struct {
+	prefix string
+	route  string
+}
+
+func newRateLimits(connStr *database.ConnString) (*rateLimits, error) {
+	store, err := newRateLimitStore(connStr)
+	if err != nil {
+		return nil, err
+	}
+	l := &rateLimits{
+		store:   store,
+		ipRules: make(map[string][]ratelimit.Rule, len(rateLimitRoutes)),
+		rules:   make(map[string][]ratelimit.Rule, len(rateLimitRoutes)),
+	}
+	for _, route := range rateLimitRoutes {
+		for _, kind := range rateLimitKinds {
+			limit, ok, err := lookupRateLimit(route, kind)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			rule := ratelimit.Rule{
+				Kind:   kind,
+				Limit:  limit,
+				Caller: rateLimitCallers[kind],
+			}
+			if kind == ratelimit.KindIP {
+				l.ipRules[route] = append(l.ipRules[route], rule)
+			} else {
+				l.rules[route] = append(l.rules[route], rule)
+			}
+		}
+	}
+	return l, nil
+}
+
+// subrouter returns the subrouter of the path prefix limiting the
+// authenticated requests to the route by the user and the API key. The prefix
+// is remembered, so that ipMiddleware limits the requests to it by the IP.
+func (l *rateLimits) subrouter(r *mux.Router, prefix string, route string) *mux.Router {
+	l.prefixes = append(l.prefixes, rateLimitPrefix{prefix: prefix, route: route})
+	s := r.PathPrefix(prefix).Subrouter()
+	s.Use(ratelimit.NewMiddleware(l.store, route, l.rules[route]))
+	return s
+}
+
+// ipMiddleware limits the requests by the IP of the caller. It is expected to
+// run before the authentication on the root router, after the subrouters are
+// registered. The route of a request is the one of the longest prefix of its
+// path template, the requests to the other paths are not limited.
+func (l *rateLimits) ipMiddleware(next http.Handler) http.Handler {
+	limited := make(map[string]http.Handler, len(l.ipRules))
+	for route, rules := range l.ipRules {
+		limited[route] = ratelimit.NewMiddleware(l.store, route, rules)(next)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h, ok := limited[l.routeOf(r)]; ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeOf returns the limited route of the request matched by the router or
+// an empty string.
+func (l *rateLimits) routeOf(r *http.Request) string {
+	current := mux.CurrentRoute(r)
+	if current == nil {
+		return ""
+	}
+	tpl, err := current.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	route, longest := "", -1
+	for _, p := range l.prefixes {
+		if len(p.prefix) <= longest {
+			continue
+		}
+		if tpl == p.prefix || strings.HasPrefix(tpl, p.prefix+"/") {
+			route, longest = p.route, len(p.prefix)
+		}
+	}
+	return route
+}
+
+func newRateLimitStore(connStr *database.ConnString) (ratelimit.Store, error) {
+	kind := rateLimitStoreMemory
+	if val, ok := os.LookupEnv(rateLimitVarNameStore); ok {
+		kind = val
+	}
+	switch kind {
+	case rateLimitStoreMemory:
+		return ratelimit.NewMemoryStore(), nil
+	case rateLimitStorePostgres:
+	default:
+		return nil, fmt.Errorf("%s must be %s or %s", rateLimitVarNameStore, rateLimitStoreMemory, rateLimitStorePostgres)
+	}
+	interval := defaultRateLimitCleanupInterval
+	if val, ok := os.LookupEnv(rateLimitVarNameCleanupInterval); ok {
+		var err error
+		interval, err = time.ParseDuration(val)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", rateLimitVarNameCleanupInterval, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("%s must be positive", rateLimitVarNameCleanupInterval)
+		}
+	}
+	db, err := database.OpenGorm(connStr)
+	if err != nil {
+		return nil, err
+	}
+	store := ratelimit.NewPostgresStore(db)
+	go store.Run(context.Background(), interval)
+	return store, nil
+}
+
+// lookupRateLimit returns the limit of the route for the kind of the callers
+// and false if the route is not limited for them. The variable of the route
+// comes first, then the built-in limit of the route, then the defaults.
+func lookupRateLimit(route string, kind string) (ratelimit.Limit, bool, error) {
+	for _, r := range []string{route, rateLimitRouteDefault} {
+		varName := rateLimitVarPrefix + strings.ToUpper(r+"_"+kind)
+		val, ok := os.LookupEnv(varName)
+		if !ok {
+			if val, ok = defaultRateLimits[r][kind]; !ok {
+				continue
+			}
+			varName = "the default of " + varName
+		}
+		if val == rateLimitOff {
+			return ratelimit.Limit{}, false, nil
+		}
+		limit, err := ratelimit.ParseLimit(val)
+		if err != nil {
+			return ratelimit.Limit{}, false, fmt.Errorf("failed to parse %s: %w", varName, err)
+		}
+		return limit, true, nil
+	}
+	return ratelimit.Limit{}, false, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+func TestRateLimitBeforeAuthentication(t *testing.T) {
+	setEnv(t, rateLimitVarPrefix+"POSITIONS_IP", "3/m")
+	connStr := &database.ConnString{}
+	limits, err := newRateLimits(connStr)
+	if err != nil {
+		t.Fatalf("failed to create the rate limits: %v", err)
+	}
+	verifier, err := auth.NewVerifier(auth.VerifierConfig{HS256Secret: []byte("0123456789abcdef0123456789abcdef")})
+	if err != nil {
+		t.Fatalf("failed to create the verifier: %v", err)
+	}
+	h := registerRoutes(connStr, verifier, limits, nil, nil)
+
+	cases := []struct {
+		Header         string
+		Value          string
+		ExpectedStatus int
+	}{
+		{Header: "Authorization", Value: "Bearer not-a-token", ExpectedStatus: http.StatusUnauthorized},
+		{Header: "Authorization", Value: "Bearer not-a-token", ExpectedStatus: http.StatusUnauthorized},
+		{ExpectedStatus: http.StatusUnauthorized},
+		{Header: "Authorization", Value: "Bearer not-a-token", ExpectedStatus: http.StatusTooManyRequests},
+		{ExpectedStatus: http.StatusTooManyRequests},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/positions", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			if len(tc.Header) != 0 {
+				req.Header.Set(tc.Header, tc.Value)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.ExpectedStatus {
+				t.Errorf("expected status %d, got %d", tc.ExpectedStatus, rec.Code)
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/positions", nil)
+	req.RemoteAddr = "192.0.2.2:1234"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the other IP to be authenticated, got status %d", rec.Code)
+	}
+	req = httptest.NewRequest("GET", healthPath, nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the health check not to be limited, got status %d", rec.Code)
+	}
+}
+
+func setEnv(t *testing.T, name string, value string) {
+	old, ok := os.LookupEnv(name)
+	if err := os.Setenv(name, value); err != nil {
+		t.Fatalf("failed to set %s: %v", name, err)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(name, old)
+			return
+		}
+		os.Unsetenv(name)
+	})
+}
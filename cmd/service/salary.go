@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	budgetService "github.com/SergeyShpak/gopher-corp-backend/pkg/budget/service"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	employeesService "github.com/SergeyShpak/gopher-corp-backend/pkg/employees/service"
+	employeesStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+)
+
+const salaryChangesVarNameInterval = "SALARY_CHANGES_INTERVAL"
+
+const defaultSalaryChangesInterval = time.Hour
+
+// startSalaryChangeApplier applies the scheduled salary changes as they become
+// effective. The budget alerts are reevaluated after every applied batch.
+func startSalaryChangeApplier(connStr *database.ConnString, alerter *budgetService.Alerter) error {
+	interval := defaultSalaryChangesInterval
+	if val, ok := os.LookupEnv(salaryChangesVarNameInterval); ok {
+		var err error
+		interval, err = time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", salaryChangesVarNameInterval, err)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("%s must be positive", salaryChangesVarNameInterval)
+		}
+	}
+	applier := employeesService.NewSalaryChangeApplier(func() (employeesStorage.DB, error) {
+		return employeesStorage.NewDB(connStr, auditStorage.System("salary-change-applier"))
+	}, func(applied []*employeesStorage.SalaryChange) {
+		alerter.Trigger()
+	})
+	go applier.Run(context.Background(), interval)
+	return nil
+}
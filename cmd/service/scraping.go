@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	emailHintService "github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/service"
+)
+
+const (
+	phoneScrapingVarNameBudget      = "PHONE_LOOKUP_BUDGET"
+	phoneScrapingVarNameWindow      = "PHONE_LOOKUP_WINDOW"
+	phoneScrapingVarNameMaxPrefixes = "PHONE_LOOKUP_MAX_PREFIXES"
+	phoneScrapingVarNameSweepLength = "PHONE_LOOKUP_SWEEP_LENGTH"
+	phoneScrapingVarNameBaseDelay   = "PHONE_LOOKUP_THROTTLE_BASE_DELAY"
+	phoneScrapingVarNameMaxDelay    = "PHONE_LOOKUP_THROTTLE_MAX_DELAY"
+)
+
+// newScrapingGuard creates the guard throttling the callers that enumerate
+// the directory through the phone lookup. The variables not set keep the
+// default policy.
+func newScrapingGuard() (*emailHintService.ScrapingGuard, error) {
+	policy := emailHintService.DefaultScrapingPolicy
+	ints := map[string]*int{
+		phoneScrapingVarNameBudget:      &policy.Budget,
+		phoneScrapingVarNameMaxPrefixes: &policy.MaxPrefixes,
+		phoneScrapingVarNameSweepLength: &policy.SweepLength,
+	}
+	for name, v := range ints {
+		if val, ok := os.LookupEnv(name); ok {
+			var err error
+			if *v, err = strconv.Atoi(val); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+			}
+		}
+	}
+	durations := map[string]*time.Duration{
+		phoneScrapingVarNameWindow:    &policy.Window,
+		phoneScrapingVarNameBaseDelay: &policy.BaseDelay,
+		phoneScrapingVarNameMaxDelay:  &policy.MaxDelay,
+	}
+	for name, v := range durations {
+		if val, ok := os.LookupEnv(name); ok {
+			var err error
+			if *v, err = time.ParseDuration(val); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+			}
+		}
+	}
+	return emailHintService.NewScrapingGuard(policy, []emailHintService.ScrapingNotifier{
+		emailHintService.LogScrapingNotifier{},
+	})
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
+)
+
+const directoryVarNameDefaultVisibility = "DIRECTORY_DEFAULT_VISIBILITY"
+
+// setDefaultVisibility sets the company-wide visibility of the contacts of the
+// employees who have not chosen one.
+func setDefaultVisibility() error {
+	val, ok := os.LookupEnv(directoryVarNameDefaultVisibility)
+	if !ok {
+		return nil
+	}
+	if err := visibility.SetDefault(val); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", directoryVarNameDefaultVisibility, err)
+	}
+	return nil
+}
@@ -4,11 +4,9 @@ go 1.16
 
 require (
 	github.com/gorilla/mux v1.8.0
-	github.com/jackc/fake v0.0.0-20150926172116-812a484cc733 // indirect
-	github.com/jackc/pgx v3.6.2+incompatible
+	github.com/jackc/pgconn v1.10.0
 	github.com/jackc/pgx/v4 v4.13.0
 	github.com/ory/dockertest/v3 v3.8.0
-	github.com/sirupsen/logrus v1.8.1
 	gorm.io/driver/postgres v1.1.2
 	gorm.io/gorm v1.21.15
 )
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE employee_assignments;
+
+COMMIT;
@@ -0,0 +1,31 @@
+BEGIN;
+
+-- Where an employee worked, in which position and under which manager. An
+-- assignment is valid from valid_from until valid_to exclusive, the current
+-- one has no valid_to.
+CREATE TABLE employee_assignments (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    employee_id INT NOT NULL REFERENCES employees(id),
+    department INT NOT NULL REFERENCES departments(id),
+    position INT NOT NULL REFERENCES positions(id),
+    manager_id INT NOT NULL REFERENCES employees(id),
+    valid_from DATE NOT NULL,
+    valid_to DATE,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT employee_assignments_validity_check CHECK (valid_to IS NULL OR valid_to > valid_from)
+);
+
+CREATE UNIQUE INDEX employee_assignments_current_idx
+    ON employee_assignments (employee_id)
+    WHERE valid_to IS NULL;
+
+CREATE INDEX employee_assignments_department_idx
+    ON employee_assignments (department, valid_from);
+
+INSERT INTO employee_assignments (employee_id, department, position, manager_id, valid_from, reason, changed_by)
+SELECT id, department, position, manager_id, entry_at, 'assignment at the history start', 'migration'
+FROM employees;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TRIGGER departments_history_trigger ON departments;
+DROP FUNCTION record_department_version();
+DROP TABLE department_history;
+
+COMMIT;
@@ -0,0 +1,46 @@
+BEGIN;
+
+-- The names and the parents departments had. A version is valid from
+-- valid_from until valid_to exclusive, the current one has no valid_to.
+CREATE TABLE department_history (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    department INT NOT NULL REFERENCES departments(id) ON DELETE CASCADE,
+    parent_id INT NOT NULL,
+    name VARCHAR(200) NOT NULL,
+    valid_from DATE NOT NULL,
+    valid_to DATE,
+    CONSTRAINT department_history_validity_check CHECK (valid_to IS NULL OR valid_to > valid_from)
+);
+
+CREATE UNIQUE INDEX department_history_current_idx
+    ON department_history (department)
+    WHERE valid_to IS NULL;
+
+-- The departments are changed by hand, so the history is kept by a trigger.
+-- Several changes of a day are folded into a single version.
+CREATE FUNCTION record_department_version() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.name = OLD.name AND NEW.parent_id = OLD.parent_id THEN
+        RETURN NEW;
+    END IF;
+    DELETE FROM department_history
+    WHERE department = NEW.id AND valid_to IS NULL AND valid_from = CURRENT_DATE;
+    UPDATE department_history SET valid_to = CURRENT_DATE
+    WHERE department = NEW.id AND valid_to IS NULL;
+    INSERT INTO department_history (department, parent_id, name, valid_from)
+    VALUES (NEW.id, NEW.parent_id, NEW.name, CURRENT_DATE);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER departments_history_trigger
+    AFTER INSERT OR UPDATE OF name, parent_id ON departments
+    FOR EACH ROW EXECUTE FUNCTION record_department_version();
+
+-- The departments existed before their history started, so their first
+-- versions are valid since the earliest known date.
+INSERT INTO department_history (department, parent_id, name, valid_from)
+SELECT id, parent_id, name, '1900-01-01'
+FROM departments;
+
+COMMIT;
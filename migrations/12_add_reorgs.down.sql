@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE reorg_department_changes;
+DROP TABLE reorg_moves;
+DROP TABLE reorgs;
+
+COMMIT;
@@ -0,0 +1,36 @@
+BEGIN;
+
+-- A reorganization is drafted as a set of staged moves and department changes
+-- which are applied together on commit.
+CREATE TABLE reorgs (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    name VARCHAR(200) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft',
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    committed_by TEXT,
+    committed_at TIMESTAMPTZ,
+    discarded_at TIMESTAMPTZ,
+    CONSTRAINT reorgs_status_check CHECK (status IN ('draft', 'committed', 'discarded'))
+);
+
+-- A NULL column keeps the employee's current value.
+CREATE TABLE reorg_moves (
+    reorg_id INT NOT NULL REFERENCES reorgs(id) ON DELETE CASCADE,
+    employee_id INT NOT NULL REFERENCES employees(id),
+    department INT REFERENCES departments(id),
+    position INT REFERENCES positions(id),
+    manager_id INT REFERENCES employees(id),
+    PRIMARY KEY (reorg_id, employee_id)
+);
+
+-- A NULL column keeps the department's current value.
+CREATE TABLE reorg_department_changes (
+    reorg_id INT NOT NULL REFERENCES reorgs(id) ON DELETE CASCADE,
+    department INT NOT NULL REFERENCES departments(id),
+    name VARCHAR(200),
+    parent_id INT REFERENCES departments(id),
+    PRIMARY KEY (reorg_id, department)
+);
+
+COMMIT;
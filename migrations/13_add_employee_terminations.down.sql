@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE employee_terminations;
+ALTER TABLE employees DROP COLUMN terminated_on;
+
+COMMIT;
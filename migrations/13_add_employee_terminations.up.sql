@@ -0,0 +1,25 @@
+BEGIN;
+
+-- The last working day of a terminated employee. The employee's row is kept,
+-- so that the manager_id references and the history stay intact.
+ALTER TABLE employees ADD COLUMN terminated_on DATE;
+
+CREATE TABLE employee_terminations (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    employee_id INT NOT NULL REFERENCES employees(id),
+    last_day DATE NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    -- the manager the direct reports of the employee were reassigned to
+    reports_manager_id INT REFERENCES employees(id),
+    terminated_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    rehired_on DATE,
+    rehired_by TEXT,
+    CONSTRAINT employee_terminations_rehire_check CHECK (rehired_on IS NULL OR rehired_on > last_day)
+);
+
+CREATE UNIQUE INDEX employee_terminations_current_idx
+    ON employee_terminations (employee_id)
+    WHERE rehired_on IS NULL;
+
+COMMIT;
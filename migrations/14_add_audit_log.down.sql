@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE audit_log;
+DROP FUNCTION forbid_audit_log_change();
+
+COMMIT;
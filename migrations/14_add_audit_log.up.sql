@@ -0,0 +1,38 @@
+BEGIN;
+
+-- Who changed which entity and how. An entry is written in the transaction of
+-- the change it describes.
+CREATE TABLE audit_log (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    actor TEXT NOT NULL DEFAULT '',
+    action VARCHAR(50) NOT NULL,
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id TEXT NOT NULL,
+    before JSONB,
+    after JSONB,
+    -- the top-level fields that differ between before and after
+    diff JSONB NOT NULL DEFAULT '{}',
+    request_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_log_entity_idx ON audit_log (entity_type, entity_id, created_at);
+CREATE INDEX audit_log_actor_idx ON audit_log (actor, created_at);
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+
+-- The log is append-only.
+CREATE FUNCTION forbid_audit_log_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only_trigger
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION forbid_audit_log_change();
+
+CREATE TRIGGER audit_log_no_truncate_trigger
+    BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION forbid_audit_log_change();
+
+COMMIT;
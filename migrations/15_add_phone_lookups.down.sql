@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE phone_lookup_results;
+DROP TABLE phone_lookups;
+
+COMMIT;
@@ -0,0 +1,22 @@
+BEGIN;
+
+-- Who looked up the phone numbers by an email prefix and whose numbers were
+-- returned. The entries older than the retention period are deleted.
+CREATE TABLE phone_lookups (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    caller TEXT NOT NULL DEFAULT '',
+    prefix TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX phone_lookups_created_at_idx ON phone_lookups (created_at);
+
+CREATE TABLE phone_lookup_results (
+    lookup_id BIGINT NOT NULL REFERENCES phone_lookups (id) ON DELETE CASCADE,
+    employee_id INT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    PRIMARY KEY (lookup_id, employee_id)
+);
+
+CREATE INDEX phone_lookup_results_employee_idx ON phone_lookup_results (employee_id);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE employees
+    DROP COLUMN phone_visibility,
+    DROP COLUMN email_visibility;
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+-- Who sees the employee's phone and email, NULL stands for the company-wide
+-- default.
+ALTER TABLE employees
+    ADD COLUMN phone_visibility VARCHAR(20)
+        CHECK (phone_visibility IN ('public', 'department', 'managers', 'hidden')),
+    ADD COLUMN email_visibility VARCHAR(20)
+        CHECK (email_visibility IN ('public', 'department', 'managers', 'hidden'));
+
+COMMIT;
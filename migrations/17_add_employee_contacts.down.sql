@@ -0,0 +1,21 @@
+BEGIN;
+
+-- Only the primary contacts are kept.
+ALTER TABLE employees
+    ADD COLUMN phone text,
+    ADD COLUMN email text;
+
+UPDATE employees e
+SET phone = p.number
+FROM employee_phones p
+WHERE p.employee_id = e.id AND p.is_primary;
+
+UPDATE employees e
+SET email = m.address
+FROM employee_emails m
+WHERE m.employee_id = e.id AND m.is_primary;
+
+DROP TABLE employee_emails;
+DROP TABLE employee_phones;
+
+COMMIT;
@@ -0,0 +1,44 @@
+BEGIN;
+
+-- The phone numbers and the email addresses of the employees. An employee has
+-- at most one primary contact of each kind, the contacts are listed by their
+-- ordinal.
+CREATE TABLE employee_phones (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    employee_id INT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('work', 'mobile', 'extension')),
+    number TEXT NOT NULL CHECK (number <> ''),
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    ordinal INT NOT NULL,
+    UNIQUE (employee_id, ordinal)
+);
+
+CREATE UNIQUE INDEX employee_phones_primary_idx ON employee_phones (employee_id) WHERE is_primary;
+
+CREATE TABLE employee_emails (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    employee_id INT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('work', 'alias')),
+    address TEXT NOT NULL CHECK (address <> ''),
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    ordinal INT NOT NULL,
+    UNIQUE (employee_id, ordinal)
+);
+
+CREATE UNIQUE INDEX employee_emails_primary_idx ON employee_emails (employee_id) WHERE is_primary;
+
+INSERT INTO employee_phones (employee_id, type, number, is_primary, ordinal)
+SELECT id, 'work', phone, TRUE, 0
+FROM employees
+WHERE phone IS NOT NULL AND phone <> '';
+
+INSERT INTO employee_emails (employee_id, type, address, is_primary, ordinal)
+SELECT id, 'work', email, TRUE, 0
+FROM employees
+WHERE email IS NOT NULL AND email <> '';
+
+ALTER TABLE employees
+    DROP COLUMN phone,
+    DROP COLUMN email;
+
+COMMIT;
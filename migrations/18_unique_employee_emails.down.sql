@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX employee_emails_address_key;
+
+ALTER TABLE employee_emails
+    DROP CONSTRAINT employee_emails_address_normalized_check;
+
+COMMIT;
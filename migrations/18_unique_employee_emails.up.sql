@@ -0,0 +1,35 @@
+BEGIN;
+
+-- The addresses are stored lowercased and trimmed, so that the uniqueness and
+-- the prefix search are case-insensitive. The duplicates must be resolved
+-- first, scripts/report_email_duplicates.sql lists them.
+UPDATE employee_emails
+SET address = LOWER(TRIM(address))
+WHERE address <> LOWER(TRIM(address));
+
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(address, ', ' ORDER BY address) INTO duplicates
+    FROM (
+        SELECT address
+        FROM employee_emails
+        GROUP BY address
+        HAVING COUNT(*) > 1
+    ) d;
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'duplicate employee emails: %', duplicates
+            USING HINT = 'run scripts/report_email_duplicates.sql and resolve the duplicates';
+    END IF;
+END
+$$;
+
+ALTER TABLE employee_emails
+    ADD CONSTRAINT employee_emails_address_normalized_check CHECK (address = LOWER(TRIM(address)));
+
+-- text_pattern_ops lets the prefix search use the index whatever the
+-- collation of the database is.
+CREATE UNIQUE INDEX employee_emails_address_key ON employee_emails (address text_pattern_ops);
+
+COMMIT;
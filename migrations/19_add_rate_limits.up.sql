@@ -0,0 +1,14 @@
+BEGIN;
+
+-- The token buckets of the rate limits shared by the instances of the
+-- service. The buckets that have refilled are deleted.
+CREATE TABLE rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    full_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX rate_limit_buckets_full_at_idx ON rate_limit_buckets (full_at);
+
+COMMIT;
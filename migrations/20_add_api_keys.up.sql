@@ -0,0 +1,18 @@
+BEGIN;
+
+-- The API keys of the services calling without user tokens. Only the SHA-256
+-- hashes of the keys are kept, the prefix tells the keys apart in listings.
+CREATE TABLE api_keys (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    name TEXT NOT NULL UNIQUE,
+    prefix TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    -- space-separated, e.g. "directory:read budget:read"
+    scopes TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+COMMIT;
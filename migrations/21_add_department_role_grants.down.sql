@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE department_role_grants;
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+-- The roles granted to the users only in a department and its descendants,
+-- e.g. to the HR partners of a division.
+CREATE TABLE department_role_grants (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    -- the subject of the user's tokens
+    subject TEXT NOT NULL,
+    role TEXT NOT NULL,
+    department INT NOT NULL REFERENCES departments(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (subject, role, department)
+);
+
+COMMIT;
@@ -0,0 +1,58 @@
+BEGIN;
+
+DROP FUNCTION department_payrolls(DATE, DATE);
+
+DROP POLICY departments_budget_write ON departments_budget;
+DROP POLICY departments_budget_select ON departments_budget;
+DROP POLICY salary_band_overrides_write ON salary_band_overrides;
+DROP POLICY salary_band_overrides_select ON salary_band_overrides;
+DROP POLICY salary_history_write ON salary_history;
+DROP POLICY salary_history_select ON salary_history;
+DROP POLICY employee_emails_write ON employee_emails;
+DROP POLICY employee_emails_select ON employee_emails;
+DROP POLICY employee_phones_write ON employee_phones;
+DROP POLICY employee_phones_select ON employee_phones;
+
+DROP VIEW employee_salaries;
+
+DROP POLICY employees_delete ON employees;
+DROP TRIGGER employees_self_update_trigger ON employees;
+DROP FUNCTION app_check_self_update();
+DROP POLICY employees_update ON employees;
+DROP POLICY employees_insert ON employees;
+DROP POLICY employees_select ON employees;
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY[
+        'employees', 'employee_phones', 'employee_emails', 'salary_history',
+        'salary_band_overrides', 'departments_budget'
+    ] LOOP
+        EXECUTE format('DROP POLICY %I ON %I', t || '_owner', t);
+        EXECUTE format('ALTER TABLE %I NO FORCE ROW LEVEL SECURITY', t);
+        EXECUTE format('ALTER TABLE %I DISABLE ROW LEVEL SECURITY', t);
+    END LOOP;
+END
+$$;
+
+DROP FUNCTION app_reads_payroll(INT);
+DROP FUNCTION app_reads_budget(INT);
+DROP FUNCTION app_reads_salary(INT, INT);
+DROP FUNCTION app_writes_employee(INT);
+DROP FUNCTION app_sees_contacts(INT, INT, TEXT);
+DROP FUNCTION app_viewer_department();
+DROP FUNCTION app_manages(INT);
+DROP FUNCTION app_has_role(TEXT[], INT);
+DROP FUNCTION app_is_employee(INT);
+DROP FUNCTION app_has_scope(TEXT, TEXT);
+DROP FUNCTION app_setting_has(TEXT, TEXT);
+DROP FUNCTION app_restricted();
+
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE USAGE, SELECT ON SEQUENCES FROM gopher_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE SELECT, INSERT, UPDATE, DELETE ON TABLES FROM gopher_app;
+REVOKE ALL ON ALL SEQUENCES IN SCHEMA public FROM gopher_app;
+REVOKE ALL ON ALL TABLES IN SCHEMA public FROM gopher_app;
+
+COMMIT;
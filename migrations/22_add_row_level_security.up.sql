@@ -0,0 +1,309 @@
+BEGIN;
+
+-- The row-level security restricts the employees, their contacts and
+-- salaries, and the budgets to the callers allowed to see and change them, as
+-- a second line behind the checks of the service. The service runs the
+-- connections of the requests as the gopher_app role, with no way to opt out,
+-- and sets the caller in the run-time parameters:
+--   app.user_id             the subject of the caller
+--   app.employee_id         the employee the caller is, 0 if none
+--   app.roles               the company-wide roles, comma-separated
+--   app.departments         the roles held in the departments, as
+--                           role:department, comma-separated
+--   app.scopes              the scopes of an API key, comma-separated
+--   app.default_visibility  the company-wide visibility of the contacts
+-- The policies of gopher_app mirror pkg/policy and pkg/visibility. The tables
+-- force the row-level security on their owner too, the owner keeps every row
+-- by its own policies: its sessions without a role set are the migrations, the
+-- background jobs and the commands. A superuser still bypasses the policies.
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'gopher_app') THEN
+        RAISE EXCEPTION 'role gopher_app does not exist'
+            USING HINT = format('CREATE ROLE gopher_app NOLOGIN; GRANT gopher_app TO %I;', current_user);
+    END IF;
+    IF NOT pg_has_role(current_user, 'gopher_app', 'MEMBER') THEN
+        RAISE EXCEPTION 'user % may not set role gopher_app', current_user
+            USING HINT = format('GRANT gopher_app TO %I;', current_user);
+    END IF;
+END
+$$;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO gopher_app;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO gopher_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO gopher_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO gopher_app;
+REVOKE ALL ON schema_migrations FROM gopher_app;
+
+-- gopher_app reads the salaries only through employee_salaries. A column
+-- added to employees later must be granted to it.
+REVOKE SELECT ON employees FROM gopher_app;
+GRANT SELECT (
+    id, first_name, last_name, manager_id, department, position, entry_at,
+    terminated_on, phone_visibility, email_visibility
+) ON employees TO gopher_app;
+
+-- app_restricted tells if the caller is restricted by the policies of
+-- gopher_app: any session of another user than the owner or one that has set
+-- a role, as the service does for the requests. It holds in the views and the
+-- functions running as the owner too.
+CREATE FUNCTION app_restricted() RETURNS BOOLEAN AS $$
+    SELECT current_setting('role') <> 'none'
+        OR NOT pg_has_role(session_user, (SELECT relowner FROM pg_class WHERE oid = 'employees'::regclass), 'MEMBER');
+$$ LANGUAGE sql STABLE;
+
+-- app_setting_has tells if the comma-separated setting has the value.
+CREATE FUNCTION app_setting_has(name TEXT, val TEXT) RETURNS BOOLEAN AS $$
+    SELECT val = ANY(string_to_array(COALESCE(current_setting(name, true), ''), ','));
+$$ LANGUAGE sql STABLE;
+
+-- app_has_scope tells if the API key may access the resource, the write
+-- access including the read one.
+CREATE FUNCTION app_has_scope(resource TEXT, access TEXT) RETURNS BOOLEAN AS $$
+    SELECT app_setting_has('app.scopes', resource || ':' || access)
+        OR app_setting_has('app.scopes', resource || ':write');
+$$ LANGUAGE sql STABLE;
+
+CREATE FUNCTION app_is_employee(employee INT) RETURNS BOOLEAN AS $$
+    SELECT COALESCE(NULLIF(current_setting('app.employee_id', true), ''), '0') = employee::TEXT;
+$$ LANGUAGE sql STABLE;
+
+-- app_has_role tells if the caller holds one of the roles in the department.
+CREATE FUNCTION app_has_role(roles TEXT[], department INT) RETURNS BOOLEAN AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM unnest(roles) r
+        WHERE app_setting_has('app.roles', r) OR app_setting_has('app.departments', r || ':' || department)
+    );
+$$ LANGUAGE sql STABLE;
+
+-- app_manages tells if the caller is a direct or indirect manager of the
+-- employee.
+CREATE FUNCTION app_manages(employee INT) RETURNS BOOLEAN AS $$
+    WITH RECURSIVE managers AS (
+        SELECT manager_id AS id FROM employees WHERE id = employee AND manager_id <> id
+        UNION
+        SELECT e.manager_id FROM employees e JOIN managers m ON e.id = m.id WHERE e.manager_id <> e.id
+    )
+    SELECT EXISTS (SELECT 1 FROM managers WHERE app_is_employee(id));
+$$ LANGUAGE sql STABLE;
+
+-- app_viewer_department returns the department of the caller, null for the
+-- callers who are no employees or have left.
+CREATE FUNCTION app_viewer_department() RETURNS INT AS $$
+    SELECT department FROM employees
+    WHERE app_is_employee(id) AND (terminated_on IS NULL OR terminated_on >= CURRENT_DATE);
+$$ LANGUAGE sql STABLE;
+
+-- app_sees_contacts tells if the caller sees the contacts of the employee
+-- with the visibility level, as visibility.Viewer.CanSee does.
+CREATE FUNCTION app_sees_contacts(employee INT, department INT, level TEXT) RETURNS BOOLEAN AS $$
+    SELECT app_is_employee(employee) OR CASE COALESCE(level, NULLIF(current_setting('app.default_visibility', true), ''), 'public')
+        WHEN 'public' THEN TRUE
+        WHEN 'department' THEN app_manages(employee) OR department = app_viewer_department()
+        WHEN 'managers' THEN app_manages(employee)
+        ELSE FALSE
+    END;
+$$ LANGUAGE sql STABLE;
+
+-- app_writes_employee tells if the caller may change the employee in the
+-- department, as policy.EntityEmployee.
+CREATE FUNCTION app_writes_employee(department INT) RETURNS BOOLEAN AS $$
+    SELECT app_has_role(ARRAY['hr', 'admin'], department) OR app_has_scope('employees', 'write');
+$$ LANGUAGE sql STABLE;
+
+-- app_reads_salary tells if the caller may read the salary of the employee in
+-- the department, as policy.FieldSalary.
+CREATE FUNCTION app_reads_salary(employee INT, department INT) RETURNS BOOLEAN AS $$
+    SELECT app_is_employee(employee) OR app_writes_employee(department) OR app_manages(employee);
+$$ LANGUAGE sql STABLE;
+
+-- app_reads_budget tells if the caller may read the budgets of the
+-- department, as policy.EntityBudget.
+CREATE FUNCTION app_reads_budget(department INT) RETURNS BOOLEAN AS $$
+    SELECT app_has_role(ARRAY['manager', 'hr', 'finance', 'admin'], department) OR app_has_scope('budget', 'read');
+$$ LANGUAGE sql STABLE;
+
+-- app_reads_payroll tells if the caller may read the payroll of the
+-- department, as policy.FieldPayroll.
+CREATE FUNCTION app_reads_payroll(department INT) RETURNS BOOLEAN AS $$
+    SELECT app_has_role(ARRAY['hr', 'finance', 'admin'], department) OR app_has_scope('budget', 'write');
+$$ LANGUAGE sql STABLE;
+
+-- The owner keeps every row of the restricted tables, gopher_app is not a
+-- member of it and gets only its own policies. The views and the functions
+-- running as the owner read the rows by the policies of the owner and filter
+-- them by app_restricted themselves.
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY[
+        'employees', 'employee_phones', 'employee_emails', 'salary_history',
+        'salary_band_overrides', 'departments_budget'
+    ] LOOP
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+        EXECUTE format(
+            'CREATE POLICY %I ON %I TO %I USING (TRUE) WITH CHECK (TRUE)',
+            t || '_owner', t, (SELECT tableowner FROM pg_tables WHERE schemaname = 'public' AND tablename = t)
+        );
+    END LOOP;
+END
+$$;
+
+-- The employees, the managers, HR, finance and admins read the rows without
+-- the salaries, so do the API keys, whose scopes the service checks.
+CREATE POLICY employees_select ON employees FOR SELECT TO gopher_app
+    USING (
+        app_has_role(ARRAY['employee', 'manager', 'hr', 'finance', 'admin'], department)
+        OR COALESCE(current_setting('app.scopes', true), '') <> ''
+    );
+
+CREATE POLICY employees_insert ON employees FOR INSERT TO gopher_app
+    WITH CHECK (app_writes_employee(department));
+
+-- The employees change their own contact visibility, the rest of their rows is
+-- guarded by employees_self_update_trigger.
+CREATE POLICY employees_update ON employees FOR UPDATE TO gopher_app
+    USING (app_writes_employee(department) OR app_is_employee(id))
+    WITH CHECK (app_writes_employee(department) OR app_is_employee(id));
+
+-- app_check_self_update lets the restricted callers who update a row only as
+-- the employee of the row change nothing but the contact visibility.
+CREATE FUNCTION app_check_self_update() RETURNS TRIGGER AS $$
+BEGIN
+    IF app_restricted()
+        AND NOT (app_writes_employee(OLD.department) AND app_writes_employee(NEW.department))
+        AND to_jsonb(NEW) - 'phone_visibility' - 'email_visibility' <> to_jsonb(OLD) - 'phone_visibility' - 'email_visibility'
+    THEN
+        RAISE EXCEPTION 'employee % may change only the contact visibility', OLD.id
+            USING ERRCODE = 'insufficient_privilege';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER employees_self_update_trigger
+    BEFORE UPDATE ON employees
+    FOR EACH ROW EXECUTE FUNCTION app_check_self_update();
+
+CREATE POLICY employees_delete ON employees FOR DELETE TO gopher_app
+    USING (app_writes_employee(department));
+
+-- employee_salaries shows the salaries the caller may read, the others are
+-- left out. It reads employees as its owner.
+CREATE VIEW employee_salaries WITH (security_barrier) AS
+    SELECT id AS employee_id, salary_amount, salary_currency
+    FROM employees
+    WHERE NOT app_restricted() OR app_reads_salary(id, department);
+
+GRANT SELECT ON employee_salaries TO gopher_app;
+
+-- The contacts are seen as the service shows them and changed by the
+-- employee, HR and admins.
+CREATE POLICY employee_phones_select ON employee_phones FOR SELECT TO gopher_app
+    USING (EXISTS (
+        SELECT 1 FROM employees e
+        WHERE e.id = employee_id AND app_sees_contacts(e.id, e.department, e.phone_visibility)
+    ));
+
+CREATE POLICY employee_phones_write ON employee_phones FOR ALL TO gopher_app
+    USING (app_is_employee(employee_id) OR EXISTS (
+        SELECT 1 FROM employees e WHERE e.id = employee_id AND app_writes_employee(e.department)
+    ))
+    WITH CHECK (app_is_employee(employee_id) OR EXISTS (
+        SELECT 1 FROM employees e WHERE e.id = employee_id AND app_writes_employee(e.department)
+    ));
+
+CREATE POLICY employee_emails_select ON employee_emails FOR SELECT TO gopher_app
+    USING (EXISTS (
+        SELECT 1 FROM employees e
+        WHERE e.id = employee_id AND app_sees_contacts(e.id, e.department, e.email_visibility)
+    ));
+
+CREATE POLICY employee_emails_write ON employee_emails FOR ALL TO gopher_app
+    USING (app_is_employee(employee_id) OR EXISTS (
+        SELECT 1 FROM employees e WHERE e.id = employee_id AND app_writes_employee(e.department)
+    ))
+    WITH CHECK (app_is_employee(employee_id) OR EXISTS (
+        SELECT 1 FROM employees e WHERE e.id = employee_id AND app_writes_employee(e.department)
+    ));
+
+-- The salary changes and the salary band overrides are read like the salary
+-- and changed by HR and admins.
+CREATE POLICY salary_history_select ON salary_history FOR SELECT TO gopher_app
+    USING (EXISTS (
+        SELECT 1 FROM employees e WHERE e.id = employee_id AND app_reads_salary(e.id, e.department)
+    ));
+
+CREATE POLICY salary_history_write ON salary_history FOR ALL TO gopher_app
+    USING (EXISTS (
+        SELECT 1 FROM employees e WHERE e.id = employee_id AND app_writes_employee(e.department)
+    ))
+    WITH CHECK (EXISTS (
+        SELECT 1 FROM employees e WHERE e.id = employee_id AND app_writes_employee(e.department)
+    ));
+
+CREATE POLICY salary_band_overrides_select ON salary_band_overrides FOR SELECT TO gopher_app
+    USING (EXISTS (
+        SELECT 1 FROM employees e WHERE e.id = employee_id AND app_reads_salary(e.id, e.department)
+    ));
+
+CREATE POLICY salary_band_overrides_write ON salary_band_overrides FOR ALL TO gopher_app
+    USING (EXISTS (
+        SELECT 1 FROM employees e WHERE e.id = employee_id AND app_writes_employee(e.department)
+    ))
+    WITH CHECK (EXISTS (
+        SELECT 1 FROM employees e WHERE e.id = employee_id AND app_writes_employee(e.department)
+    ));
+
+CREATE POLICY departments_budget_select ON departments_budget FOR SELECT TO gopher_app
+    USING (app_reads_budget(department));
+
+CREATE POLICY departments_budget_write ON departments_budget FOR ALL TO gopher_app
+    USING (app_has_role(ARRAY['finance', 'admin'], department) OR app_has_scope('budget', 'write'))
+    WITH CHECK (app_has_role(ARRAY['finance', 'admin'], department) OR app_has_scope('budget', 'write'));
+
+-- department_payrolls sums up the salaries of the employees who worked
+-- between the from and to dates, both included, by department and currency.
+-- Every employee is counted as of the to date or their last day if they left
+-- earlier: with the latest salary effective then and in the department they
+-- were assigned to then, the employees rows hold the current ones and are
+-- used when the history has nothing. It runs as the owner, so that the
+-- restricted callers get the payrolls they may read without reading the
+-- salaries.
+CREATE FUNCTION department_payrolls(period_from DATE, period_to DATE)
+RETURNS TABLE (department INT, total_currency CHAR(3), total_amount NUMERIC) AS $$
+    WITH staff AS (
+        SELECT id, department, salary_amount, salary_currency, LEAST(period_to, terminated_on) AS on_date
+        FROM employees
+        WHERE entry_at <= period_to AND (terminated_on IS NULL OR terminated_on >= period_from)
+    ), payrolls AS (
+        SELECT
+            COALESCE(a.department, s.department) AS department,
+            COALESCE(h.salary_currency, s.salary_currency) AS total_currency,
+            SUM(COALESCE(h.salary_amount, s.salary_amount)) AS total_amount
+        FROM staff s
+        LEFT JOIN LATERAL (
+            SELECT salary_amount, salary_currency
+            FROM salary_history
+            WHERE employee_id = s.id AND cancelled_at IS NULL AND effective_date <= s.on_date
+            ORDER BY effective_date DESC, id DESC
+            LIMIT 1
+        ) h ON TRUE
+        LEFT JOIN LATERAL (
+            SELECT department
+            FROM employee_assignments
+            WHERE employee_id = s.id AND valid_from <= s.on_date AND (valid_to IS NULL OR valid_to > s.on_date)
+            ORDER BY valid_from DESC, id DESC
+            LIMIT 1
+        ) a ON TRUE
+        GROUP BY 1, 2
+    )
+    SELECT p.department, p.total_currency, p.total_amount
+    FROM payrolls p
+    WHERE NOT app_restricted() OR app_reads_payroll(p.department);
+$$ LANGUAGE sql STABLE SECURITY DEFINER SET search_path = public;
+
+COMMIT;
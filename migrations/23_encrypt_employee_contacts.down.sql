@@ -0,0 +1,21 @@
+BEGIN;
+
+-- The sealed contacts must be decrypted first by
+--   service contacts decrypt
+
+DROP INDEX employee_emails_address_prefixes_idx;
+DROP INDEX employee_emails_address_index_key;
+
+ALTER TABLE employee_emails
+    DROP COLUMN address_prefixes,
+    DROP COLUMN address_index,
+    ADD CONSTRAINT employee_emails_address_normalized_check CHECK (address = LOWER(TRIM(address)));
+
+CREATE UNIQUE INDEX employee_emails_address_key ON employee_emails (address text_pattern_ops);
+
+DROP INDEX employee_phones_number_index_idx;
+
+ALTER TABLE employee_phones
+    DROP COLUMN number_index;
+
+COMMIT;
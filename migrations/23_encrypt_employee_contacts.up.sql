@@ -0,0 +1,31 @@
+BEGIN;
+
+-- The phone numbers and the email addresses are sealed by the service with
+-- the keys of its keyring and searched by their blind indexes: the keyed
+-- hashes of the numbers, of the addresses and of the address prefixes. The
+-- database cannot seal them, so the stored contacts stay in plaintext and are
+-- not found by the lookups until
+--   service contacts reencrypt
+-- seals and indexes them, it must be run right after the migration. The
+-- service refuses to start until then.
+ALTER TABLE employee_phones
+    ADD COLUMN number_index TEXT;
+
+CREATE INDEX employee_phones_number_index_idx ON employee_phones (number_index);
+
+-- The service lowercases and trims the addresses, the sealed ones cannot be
+-- checked or compared, their indexes keep them unique. This supersedes the
+-- unique text_pattern_ops index and the normalization check of migration 18:
+-- the uniqueness moves to address_index and the prefix search to
+-- address_prefixes.
+DROP INDEX employee_emails_address_key;
+
+ALTER TABLE employee_emails
+    DROP CONSTRAINT employee_emails_address_normalized_check,
+    ADD COLUMN address_index TEXT,
+    ADD COLUMN address_prefixes TEXT[];
+
+CREATE UNIQUE INDEX employee_emails_address_index_key ON employee_emails (address_index);
+CREATE INDEX employee_emails_address_prefixes_idx ON employee_emails USING GIN (address_prefixes);
+
+COMMIT;
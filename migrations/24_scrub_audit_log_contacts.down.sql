@@ -0,0 +1,11 @@
+BEGIN;
+
+-- The scrubbed contacts cannot be restored, the migration is irreversible.
+DO $$
+BEGIN
+    RAISE EXCEPTION 'the contacts scrubbed from the audit log cannot be restored'
+        USING HINT = 'restore the audit log from a backup taken before the migration, then force the version to 23';
+END
+$$;
+
+COMMIT;
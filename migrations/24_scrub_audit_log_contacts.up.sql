@@ -0,0 +1,24 @@
+BEGIN;
+
+-- The employees were recorded in the audit log with their phones and emails
+-- in plaintext. They are recorded with the blind index of the contacts now,
+-- the old entries lose the contacts. The append-only trigger is suspended for
+-- the scrub only. The scrub cannot be undone, the down migration fails.
+
+ALTER TABLE audit_log DISABLE TRIGGER audit_log_append_only_trigger;
+
+UPDATE audit_log
+SET
+    before = before - ARRAY['phone', 'email', 'phones', 'emails'],
+    after = after - ARRAY['phone', 'email', 'phones', 'emails'],
+    diff = diff - ARRAY['phone', 'email', 'phones', 'emails']
+WHERE entity_type = 'employee'
+    AND (
+        before ?| ARRAY['phone', 'email', 'phones', 'emails']
+        OR after ?| ARRAY['phone', 'email', 'phones', 'emails']
+        OR diff ?| ARRAY['phone', 'email', 'phones', 'emails']
+    );
+
+ALTER TABLE audit_log ENABLE TRIGGER audit_log_append_only_trigger;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP TABLE salary_band_overrides;
+DROP TABLE position_salary_bands;
+
+ALTER TABLE positions
+    DROP CONSTRAINT positions_grade_positive_check,
+    DROP COLUMN grade;
+
+COMMIT;
@@ -0,0 +1,27 @@
+BEGIN;
+
+ALTER TABLE positions
+    ADD COLUMN grade INT NOT NULL DEFAULT 1,
+    ADD CONSTRAINT positions_grade_positive_check CHECK (grade > 0);
+
+CREATE TABLE position_salary_bands (
+    position_id INT NOT NULL REFERENCES positions(id) ON DELETE CASCADE,
+    currency CHAR(3) NOT NULL,
+    min_salary NUMERIC(15, 2) NOT NULL,
+    max_salary NUMERIC(15, 2) NOT NULL,
+    PRIMARY KEY (position_id, currency),
+    CONSTRAINT position_salary_bands_min_positive_check CHECK (min_salary > 0),
+    CONSTRAINT position_salary_bands_min_max_check CHECK (min_salary <= max_salary)
+);
+
+CREATE TABLE salary_band_overrides (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    employee_id INT NOT NULL REFERENCES employees(id),
+    position_id INT NOT NULL REFERENCES positions(id),
+    salary NUMERIC(15, 2) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
@@ -0,0 +1,23 @@
+BEGIN;
+
+DELETE FROM departments_budget b
+WHERE EXISTS (
+    SELECT 1
+    FROM departments_budget newer
+    WHERE
+        newer.department = b.department
+        AND (newer.fiscal_year, newer.fiscal_quarter) > (b.fiscal_year, b.fiscal_quarter)
+);
+
+ALTER TABLE departments_budget
+    DROP CONSTRAINT departments_budget_pkey,
+    DROP CONSTRAINT departments_budget_fiscal_quarter_check,
+    DROP COLUMN fiscal_year,
+    DROP COLUMN fiscal_quarter,
+    DROP COLUMN updated_at;
+
+ALTER TABLE departments_budget RENAME COLUMN department TO id;
+
+ALTER TABLE departments_budget ADD PRIMARY KEY (id);
+
+COMMIT;
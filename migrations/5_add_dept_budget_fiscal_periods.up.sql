@@ -0,0 +1,22 @@
+BEGIN;
+
+ALTER TABLE departments_budget RENAME COLUMN id TO department;
+
+ALTER TABLE departments_budget
+    DROP CONSTRAINT departments_budget_pkey,
+    ADD COLUMN fiscal_year INT,
+    ADD COLUMN fiscal_quarter INT,
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+UPDATE departments_budget
+SET
+    fiscal_year = EXTRACT(YEAR FROM CURRENT_DATE),
+    fiscal_quarter = EXTRACT(QUARTER FROM CURRENT_DATE);
+
+ALTER TABLE departments_budget
+    ALTER COLUMN fiscal_year SET NOT NULL,
+    ALTER COLUMN fiscal_quarter SET NOT NULL,
+    ADD CONSTRAINT departments_budget_fiscal_quarter_check CHECK (fiscal_quarter BETWEEN 1 AND 4),
+    ADD PRIMARY KEY (department, fiscal_year, fiscal_quarter);
+
+COMMIT;
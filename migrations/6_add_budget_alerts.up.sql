@@ -0,0 +1,22 @@
+BEGIN;
+
+CREATE TABLE budget_alerts (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    department INT NOT NULL REFERENCES departments(id),
+    fiscal_year INT NOT NULL,
+    fiscal_quarter INT NOT NULL,
+    threshold INT NOT NULL,
+    budget NUMERIC(15, 2) NOT NULL,
+    payroll NUMERIC(15, 2) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ,
+    CONSTRAINT budget_alerts_threshold_positive_check CHECK (threshold > 0)
+);
+
+-- A breach is reported once: there is at most one unresolved alert per
+-- department, period and threshold.
+CREATE UNIQUE INDEX budget_alerts_active_idx
+    ON budget_alerts (department, fiscal_year, fiscal_quarter, threshold)
+    WHERE resolved_at IS NULL;
+
+COMMIT;
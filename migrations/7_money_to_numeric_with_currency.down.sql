@@ -0,0 +1,43 @@
+BEGIN;
+
+-- MONEY has no currency, only the amounts in rubles can be converted back.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM employees WHERE salary_currency <> 'RUB')
+        OR EXISTS (SELECT 1 FROM departments_budget WHERE budget_currency <> 'RUB')
+        OR EXISTS (SELECT 1 FROM budget_alerts WHERE currency <> 'RUB')
+    THEN
+        RAISE EXCEPTION 'salaries, budgets or budget alerts in currencies other than RUB cannot be converted to MONEY'
+            USING HINT = 'convert them to RUB or delete them first';
+    END IF;
+END
+$$;
+
+ALTER TABLE budget_alerts
+    DROP COLUMN currency;
+
+ALTER TABLE departments_budget
+    DROP CONSTRAINT departments_budget_amount_non_negative_check,
+    ADD COLUMN budget MONEY;
+
+UPDATE departments_budget
+SET budget = budget_amount::money;
+
+ALTER TABLE departments_budget
+    DROP COLUMN budget_amount,
+    DROP COLUMN budget_currency;
+
+ALTER TABLE employees
+    DROP CONSTRAINT employees_salary_positive_check,
+    ADD COLUMN salary MONEY;
+
+UPDATE employees
+SET salary = salary_amount::money;
+
+ALTER TABLE employees
+    ALTER COLUMN salary SET NOT NULL,
+    DROP COLUMN salary_amount,
+    DROP COLUMN salary_currency,
+    ADD CONSTRAINT employees_salary_positive_check CHECK (salary::numeric > 0);
+
+COMMIT;
@@ -0,0 +1,61 @@
+BEGIN;
+
+-- MONEY values are formatted according to lc_monetary, NUMERIC values are
+-- not. The existing amounts are all in rubles, the only currency paid so far,
+-- so they are marked 'RUB' whatever the locale of the server is.
+
+ALTER TABLE employees
+    DROP CONSTRAINT employees_salary_positive_check,
+    ADD COLUMN salary_amount NUMERIC(15, 2),
+    ADD COLUMN salary_currency CHAR(3);
+
+UPDATE employees
+SET
+    salary_amount = salary::numeric,
+    salary_currency = 'RUB';
+
+ALTER TABLE employees
+    ALTER COLUMN salary_amount SET NOT NULL,
+    ALTER COLUMN salary_currency SET NOT NULL,
+    DROP COLUMN salary,
+    ADD CONSTRAINT employees_salary_positive_check CHECK (salary_amount > 0);
+
+-- A budget without an amount cannot be converted, it must be set or removed
+-- by hand first rather than dropped here.
+DO $$
+DECLARE
+    missing TEXT;
+BEGIN
+    SELECT string_agg(format('%s %sQ%s', department, fiscal_year, fiscal_quarter), ', ' ORDER BY department, fiscal_year, fiscal_quarter)
+    INTO missing
+    FROM departments_budget
+    WHERE budget IS NULL;
+    IF missing IS NOT NULL THEN
+        RAISE EXCEPTION 'department budgets without an amount: %', missing
+            USING HINT = 'set the amounts of these budgets or delete them';
+    END IF;
+END
+$$;
+
+ALTER TABLE departments_budget
+    ADD COLUMN budget_amount NUMERIC(15, 2),
+    ADD COLUMN budget_currency CHAR(3);
+
+UPDATE departments_budget
+SET
+    budget_amount = budget::numeric,
+    budget_currency = 'RUB';
+
+ALTER TABLE departments_budget
+    ALTER COLUMN budget_amount SET NOT NULL,
+    ALTER COLUMN budget_currency SET NOT NULL,
+    DROP COLUMN budget,
+    ADD CONSTRAINT departments_budget_amount_non_negative_check CHECK (budget_amount >= 0);
+
+ALTER TABLE budget_alerts
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+ALTER TABLE budget_alerts
+    ALTER COLUMN currency DROP DEFAULT;
+
+COMMIT;
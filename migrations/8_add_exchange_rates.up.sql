@@ -0,0 +1,16 @@
+BEGIN;
+
+-- rate is the price of one unit of from_currency in to_currency, valid from
+-- effective_date until the next rate of the pair takes effect.
+CREATE TABLE exchange_rates (
+    from_currency CHAR(3) NOT NULL,
+    to_currency CHAR(3) NOT NULL,
+    effective_date DATE NOT NULL,
+    rate NUMERIC(18, 8) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (from_currency, to_currency, effective_date),
+    CONSTRAINT exchange_rates_rate_positive_check CHECK (rate > 0),
+    CONSTRAINT exchange_rates_distinct_currencies_check CHECK (from_currency <> to_currency)
+);
+
+COMMIT;
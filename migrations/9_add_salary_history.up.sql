@@ -0,0 +1,31 @@
+BEGIN;
+
+-- Every salary an employee had or is scheduled to have. A change is applied
+-- to employees.salary_* once its effective date comes, until then it may be
+-- cancelled.
+CREATE TABLE salary_history (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    employee_id INT NOT NULL REFERENCES employees(id),
+    salary_amount NUMERIC(15, 2) NOT NULL,
+    salary_currency CHAR(3) NOT NULL,
+    effective_date DATE NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_by TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    applied_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ,
+    CONSTRAINT salary_history_salary_positive_check CHECK (salary_amount > 0),
+    CONSTRAINT salary_history_applied_or_cancelled_check
+        CHECK (applied_at IS NULL OR cancelled_at IS NULL)
+);
+
+CREATE INDEX salary_history_employee_idx ON salary_history (employee_id, effective_date);
+
+CREATE INDEX salary_history_pending_idx ON salary_history (effective_date)
+    WHERE applied_at IS NULL AND cancelled_at IS NULL;
+
+INSERT INTO salary_history (employee_id, salary_amount, salary_currency, effective_date, reason, changed_by, applied_at)
+SELECT id, salary_amount, salary_currency, entry_at, 'salary at the history start', 'migration', NOW()
+FROM employees;
+
+COMMIT;
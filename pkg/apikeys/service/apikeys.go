@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/apikeys/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+)
+
+var (
+	ErrIncorrectKey    = fmt.Errorf("got an incorrect API key request")
+	ErrKeyNotFound     = fmt.Errorf("API key not found")
+	ErrKeyConflict     = fmt.Errorf("API key conflicts with the existing data")
+	ErrDBRequestFailed = fmt.Errorf("a request to DB failed")
+)
+
+const (
+	// keyPrefix starts every key, so that a leaked key is easy to recognize.
+	keyPrefix = "gck_"
+	keyBytes  = 32
+	// shownPrefixLen is the length of the start of a key kept to tell the
+	// keys apart.
+	shownPrefixLen = len(keyPrefix) + 8
+	maxNameLen     = 100
+)
+
+// lastUsedPrecision is how often the last usage of a key is updated, so that
+// not every request writes to the DB.
+const lastUsedPrecision = time.Minute
+
+var now = time.Now
+
+// CreateKey creates a key with the scopes, expiring in expiresIn or never if
+// it is zero. The key is returned only once, it is not stored.
+func CreateKey(db storage.DB, name string, scopes []string, expiresIn time.Duration) (*storage.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 || len(name) > maxNameLen {
+		return nil, "", fmt.Errorf("%w: the name must be between 1 and %d characters long", ErrIncorrectKey, maxNameLen)
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	if expiresIn < 0 {
+		return nil, "", fmt.Errorf("%w: the expiry must not be negative", ErrIncorrectKey)
+	}
+	key, err := generateKey()
+	if err != nil {
+		return nil, "", err
+	}
+	k := &storage.APIKey{
+		Name:   name,
+		Prefix: key[:shownPrefixLen],
+		Hash:   hashKey(key),
+		Scopes: scopes,
+	}
+	if expiresIn != 0 {
+		expiresAt := now().Add(expiresIn)
+		k.ExpiresAt = &expiresAt
+	}
+	created, err := db.CreateKey(context.Background(), k)
+	if err != nil {
+		return nil, "", wrapDBErr(err, "failed to create the API key")
+	}
+	return created, key, nil
+}
+
+func ListKeys(db storage.DB) ([]*storage.APIKey, error) {
+	keys, err := db.ListKeys(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list the API keys: %v", ErrDBRequestFailed, err)
+	}
+	return keys, nil
+}
+
+func RevokeKey(db storage.DB, id int64) (*storage.APIKey, error) {
+	revoked, err := db.RevokeKey(context.Background(), id, now())
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to revoke the API key")
+	}
+	return revoked, nil
+}
+
+// Authenticator authenticates the callers by their API keys.
+type Authenticator struct {
+	newDB func() (storage.DB, error)
+}
+
+func NewAuthenticator(newDB func() (storage.DB, error)) *Authenticator {
+	return &Authenticator{
+		newDB: newDB,
+	}
+}
+
+// Authenticate returns the principal of the key. The unknown, expired and
+// revoked keys are auth.ErrIncorrectAPIKey.
+func (a *Authenticator) Authenticate(ctx context.Context, key string) (*auth.Principal, error) {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return nil, fmt.Errorf("%w: unknown key format", auth.ErrIncorrectAPIKey)
+	}
+	db, err := a.newDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the DB: %w", err)
+	}
+	defer db.Close()
+	k, err := db.GetKeyByHash(ctx, hashKey(key))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%w: unknown key", auth.ErrIncorrectAPIKey)
+		}
+		return nil, fmt.Errorf("%w: failed to get the API key: %v", ErrDBRequestFailed, err)
+	}
+	at := now()
+	if k.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: key %d (%s) is revoked", auth.ErrIncorrectAPIKey, k.ID, k.Prefix)
+	}
+	if k.ExpiresAt != nil && !at.Before(*k.ExpiresAt) {
+		return nil, fmt.Errorf("%w: key %d (%s) has expired", auth.ErrIncorrectAPIKey, k.ID, k.Prefix)
+	}
+	if k.LastUsedAt == nil || at.Sub(*k.LastUsedAt) >= lastUsedPrecision {
+		if err := db.TouchKey(ctx, k.ID, at); err != nil {
+			log.Printf("failed to track the usage of API key %d: %v", k.ID, err)
+		}
+	}
+	return &auth.Principal{
+		Subject:  "api-key:" + k.Name,
+		APIKeyID: k.ID,
+		Scopes:   k.Scopes,
+	}, nil
+}
+
+// normalizeScopes checks, deduplicates and sorts the scopes.
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: no scopes", ErrIncorrectKey)
+	}
+	distinct := make(map[string]struct{}, len(scopes))
+	normalized := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if !auth.IsScope(s) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrIncorrectKey, s)
+		}
+		if _, ok := distinct[s]; ok {
+			continue
+		}
+		distinct[s] = struct{}{}
+		normalized = append(normalized, s)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+func generateKey() (string, error) {
+	b := make([]byte, keyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate the API key: %w", err)
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashKey hashes the key for the storage. The keys are random, so a plain
+// hash is enough to not reveal them.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func wrapDBErr(err error, msg string) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%w: %s: %v", ErrKeyNotFound, msg, err)
+	}
+	if errors.Is(err, storage.ErrConflict) {
+		return fmt.Errorf("%w: %s: %v", ErrKeyConflict, msg, err)
+	}
+	return fmt.Errorf("%w: %s: %v", ErrDBRequestFailed, msg, err)
+}
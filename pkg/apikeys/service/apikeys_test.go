@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/apikeys/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+)
+
+func TestCreateKey(t *testing.T) {
+	at := time.Date(2021, time.July, 10, 12, 0, 0, 0, time.UTC)
+	setNow(t, at)
+	cases := []struct {
+		Name           string
+		Scopes         []string
+		ExpiresIn      time.Duration
+		MockErr        error
+		ExpectedScopes []string
+		ExpectedErr    error
+	}{
+		{
+			Name:           " payroll-bot ",
+			Scopes:         []string{"budget:read", "Directory:read", "budget:read"},
+			ExpiresIn:      time.Hour,
+			ExpectedScopes: []string{"budget:read", "directory:read"},
+		},
+		{
+			Name:        "",
+			Scopes:      []string{"budget:read"},
+			ExpectedErr: ErrIncorrectKey,
+		},
+		{
+			Name:        "payroll-bot",
+			ExpectedErr: ErrIncorrectKey,
+		},
+		{
+			Name:        "payroll-bot",
+			Scopes:      []string{"budget:delete"},
+			ExpectedErr: ErrIncorrectKey,
+		},
+		{
+			Name:        "payroll-bot",
+			Scopes:      []string{"payroll:read"},
+			ExpectedErr: ErrIncorrectKey,
+		},
+		{
+			Name:        "payroll-bot",
+			Scopes:      []string{"budget:read"},
+			ExpiresIn:   -time.Hour,
+			ExpectedErr: ErrIncorrectKey,
+		},
+		{
+			Name:        "payroll-bot",
+			Scopes:      []string{"budget:read"},
+			MockErr:     fmt.Errorf("%w: duplicate name", storage.ErrConflict),
+			ExpectedErr: ErrKeyConflict,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{createErr: tc.MockErr}
+			created, key, err := CreateKey(mock, tc.Name, tc.Scopes, tc.ExpiresIn)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				return
+			}
+			if !strings.HasPrefix(key, keyPrefix) || created.Prefix != key[:shownPrefixLen] {
+				t.Errorf("unexpected key %q with prefix %q", key, created.Prefix)
+			}
+			if created.Hash != hashKey(key) || strings.Contains(created.Hash, key) {
+				t.Errorf("the key is not stored hashed")
+			}
+			if created.Name != "payroll-bot" || !reflect.DeepEqual([]string(created.Scopes), tc.ExpectedScopes) {
+				t.Errorf("unexpected key %+v", created)
+			}
+			if created.ExpiresAt == nil || !created.ExpiresAt.Equal(at.Add(tc.ExpiresIn)) {
+				t.Errorf("expected the key to expire at %v, got %v", at.Add(tc.ExpiresIn), created.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	at := time.Date(2021, time.July, 10, 12, 0, 0, 0, time.UTC)
+	setNow(t, at)
+	past, recently := at.Add(-time.Hour), at.Add(-time.Second)
+	cases := []struct {
+		Key               string
+		StoredKey         *storage.APIKey
+		ExpectedPrincipal *auth.Principal
+		ExpectedTouch     bool
+		ExpectedErr       error
+	}{
+		{
+			Key:               "gck_valid",
+			StoredKey:         &storage.APIKey{ID: 7, Name: "payroll-bot", Scopes: storage.Scopes{"budget:read"}},
+			ExpectedPrincipal: &auth.Principal{Subject: "api-key:payroll-bot", APIKeyID: 7, Scopes: []string{"budget:read"}},
+			ExpectedTouch:     true,
+		},
+		// the usage is not updated on every request
+		{
+			Key:               "gck_valid",
+			StoredKey:         &storage.APIKey{ID: 7, Name: "payroll-bot", Scopes: storage.Scopes{"budget:read"}, LastUsedAt: &recently},
+			ExpectedPrincipal: &auth.Principal{Subject: "api-key:payroll-bot", APIKeyID: 7, Scopes: []string{"budget:read"}},
+		},
+		{
+			Key:         "gck_valid",
+			StoredKey:   &storage.APIKey{ID: 7, Name: "payroll-bot", LastUsedAt: &past, ExpiresAt: &recently},
+			ExpectedErr: auth.ErrIncorrectAPIKey,
+		},
+		{
+			Key:         "gck_valid",
+			StoredKey:   &storage.APIKey{ID: 7, Name: "payroll-bot", RevokedAt: &past},
+			ExpectedErr: auth.ErrIncorrectAPIKey,
+		},
+		{
+			Key:         "gck_unknown",
+			ExpectedErr: auth.ErrIncorrectAPIKey,
+		},
+		{
+			Key:         "not a key",
+			ExpectedErr: auth.ErrIncorrectAPIKey,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{keys: map[string]*storage.APIKey{}}
+			if tc.StoredKey != nil {
+				mock.keys[hashKey("gck_valid")] = tc.StoredKey
+			}
+			a := NewAuthenticator(func() (storage.DB, error) {
+				return mock, nil
+			})
+			p, err := a.Authenticate(context.Background(), tc.Key)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if !reflect.DeepEqual(p, tc.ExpectedPrincipal) {
+				t.Errorf("expected principal %+v, got %+v", tc.ExpectedPrincipal, p)
+			}
+			if touched := mock.touched != nil; touched != tc.ExpectedTouch {
+				t.Errorf("expected the usage update %v, got %v", tc.ExpectedTouch, touched)
+			}
+		})
+	}
+}
+
+type dbMock struct {
+	createErr error
+	keys      map[string]*storage.APIKey
+	touched   *time.Time
+}
+
+func (db *dbMock) CreateKey(ctx context.Context, k *storage.APIKey) (*storage.APIKey, error) {
+	if db.createErr != nil {
+		return nil, db.createErr
+	}
+	created := *k
+	created.ID = 1
+	created.CreatedAt = now()
+	return &created, nil
+}
+
+func (db *dbMock) ListKeys(ctx context.Context) ([]*storage.APIKey, error) {
+	return nil, nil
+}
+
+func (db *dbMock) GetKeyByHash(ctx context.Context, hash string) (*storage.APIKey, error) {
+	k, ok := db.keys[hash]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return k, nil
+}
+
+func (db *dbMock) RevokeKey(ctx context.Context, id int64, at time.Time) (*storage.APIKey, error) {
+	return nil, storage.ErrNotFound
+}
+
+func (db *dbMock) TouchKey(ctx context.Context, id int64, at time.Time) error {
+	db.touched = &at
+	return nil
+}
+
+func (db *dbMock) Close() {}
+
+func compareErrs(expectedErr error, actualErr error) error {
+	if expectedErr == nil && actualErr == nil {
+		return nil
+	}
+	if actualErr == nil {
+		return fmt.Errorf("expected an error \"%v\", got nil", expectedErr)
+	}
+	if !errors.Is(actualErr, expectedErr) {
+		return fmt.Errorf("expected error \"%v\" and actual error \"%v\" are different", expectedErr, actualErr)
+	}
+	return nil
+}
+
+func setNow(t *testing.T, at time.Time) {
+	prev := now
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = prev })
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+type gormDB struct {
+	db  *gorm.DB
+	src *auditStorage.Source
+}
+
+func newGormDB(c *database.ConnString, src *auditStorage.Source) (*gormDB, error) {
+	db, err := database.OpenGorm(c)
+	if err != nil {
+		return nil, err
+	}
+	return &gormDB{
+		db:  db,
+		src: src,
+	}, nil
+}
+
+func (g *gormDB) CreateKey(ctx context.Context, k *APIKey) (*APIKey, error) {
+	created := &APIKey{}
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(
+			`INSERT INTO api_keys (name, prefix, hash, scopes, expires_at)
+			VALUES (?, ?, ?, ?, ?)
+			RETURNING *`,
+			k.Name, k.Prefix, k.Hash, k.Scopes, k.ExpiresAt,
+		).Scan(created).Error
+		if err != nil {
+			return wrapWriteErr(err, "failed to create the API key")
+		}
+		return auditStorage.Record(tx, g.src, &auditStorage.Change{
+			Action:     auditStorage.ActionCreate,
+			EntityType: auditStorage.EntityAPIKey,
+			EntityID:   created.ID,
+			After:      created,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (g *gormDB) ListKeys(ctx context.Context) ([]*APIKey, error) {
+	var keys []*APIKey
+	if err := g.db.WithContext(ctx).Order("id").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to query the API keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (g *gormDB) GetKeyByHash(ctx context.Context, hash string) (*APIKey, error) {
+	k := &APIKey{}
+	if err := g.db.WithContext(ctx).Where("hash = ?", hash).Take(k).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: no such API key", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to query the API key: %w", err)
+	}
+	return k, nil
+}
+
+func (g *gormDB) RevokeKey(ctx context.Context, id int64, at time.Time) (*APIKey, error) {
+	revoked := &APIKey{}
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before := &APIKey{}
+		if err := tx.Where("id = ?", id).Take(before).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: API key %d", ErrNotFound, id)
+			}
+			return fmt.Errorf("failed to query the API key: %w", err)
+		}
+		if before.RevokedAt != nil {
+			revoked = before
+			return nil
+		}
+		err := tx.Raw(
+			`UPDATE api_keys SET revoked_at = ? WHERE id = ? RETURNING *`,
+			at, id,
+		).Scan(revoked).Error
+		if err != nil {
+			return fmt.Errorf("failed to revoke the API key: %w", err)
+		}
+		return auditStorage.Record(tx, g.src, &auditStorage.Change{
+			Action:     auditStorage.ActionRevoke,
+			EntityType: auditStorage.EntityAPIKey,
+			EntityID:   id,
+			Before:     before,
+			After:      revoked,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
+
+func (g *gormDB) TouchKey(ctx context.Context, id int64, at time.Time) error {
+	err := g.db.WithContext(ctx).Model(&APIKey{}).Where("id = ?", id).Update("last_used_at", at).Error
+	if err != nil {
+		return fmt.Errorf("failed to update the API key usage: %w", err)
+	}
+	return nil
+}
+
+func (g *gormDB) Close() {
+	database.CloseGorm(g.db)
+}
+
+func wrapWriteErr(err error, msg string) error {
+	if database.IsUniqueViolation(err) {
+		return fmt.Errorf("%w: %s: %v", ErrConflict, msg, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
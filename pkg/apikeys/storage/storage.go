@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+var (
+	ErrNotFound = fmt.Errorf("not found")
+	ErrConflict = fmt.Errorf("conflicts with the existing data")
+)
+
+// APIKey is an API key of a service. The key itself is not stored, only its
+// hash.
+type APIKey struct {
+	ID         int64      `gorm:"column:id" json:"id"`
+	Name       string     `gorm:"column:name" json:"name"`
+	Prefix     string     `gorm:"column:prefix" json:"prefix"`
+	Hash       string     `gorm:"column:hash" json:"-"`
+	Scopes     Scopes     `gorm:"column:scopes" json:"scopes"`
+	CreatedAt  time.Time  `gorm:"column:created_at" json:"created_at"`
+	ExpiresAt  *time.Time `gorm:"column:expires_at" json:"expires_at"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revoked_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// Scopes are stored separated by spaces.
+type Scopes []string
+
+func (s *Scopes) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case string:
+		*s = strings.Fields(v)
+	case []byte:
+		*s = strings.Fields(string(v))
+	default:
+		return fmt.Errorf("cannot scan %T into scopes", src)
+	}
+	return nil
+}
+
+func (s Scopes) Value() (driver.Value, error) {
+	return strings.Join(s, " "), nil
+}
+
+type DB interface {
+	CreateKey(ctx context.Context, k *APIKey) (*APIKey, error)
+	// ListKeys returns the keys, the revoked ones included, by ID.
+	ListKeys(ctx context.Context) ([]*APIKey, error)
+	GetKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+	// RevokeKey revokes the key at the time, a revoked key stays revoked
+	// since the first time.
+	RevokeKey(ctx context.Context, id int64, at time.Time) (*APIKey, error)
+	// TouchKey sets the time the key was last used at.
+	TouchKey(ctx context.Context, id int64, at time.Time) error
+	Close()
+}
+
+// NewDB opens the storage, the changes made through it are recorded in the
+// audit log as made by src.
+func NewDB(connStr *database.ConnString, src *auditStorage.Source) (DB, error) {
+	gormDB, err := newGormDB(connStr, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a gorm connection: %w", err)
+	}
+	return gormDB, nil
+}
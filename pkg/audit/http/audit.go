@@ -0,0 +1,120 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/audit/service"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+)
+
+// ListEntries returns the audit log entries filtered by the "entity_type",
+// "entity_id", "actor", "from" and "to" query parameters. The times are given
+// as RFC 3339 timestamps or as dates like 2021-07-01. The "limit" and
+// "before_id" parameters page through the log.
+func ListEntries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := &storage.Filter{
+		EntityType: q.Get("entity_type"),
+		EntityID:   q.Get("entity_id"),
+		Actor:      q.Get("actor"),
+	}
+	var ok bool
+	if f.From, ok = parseTime(w, q.Get("from")); !ok {
+		return
+	}
+	if f.To, ok = parseTime(w, q.Get("to")); !ok {
+		return
+	}
+	if f.BeforeID, ok = parseInt(w, q.Get("before_id")); !ok {
+		return
+	}
+	limit, ok := parseInt(w, q.Get("limit"))
+	if !ok {
+		return
+	}
+	f.Limit = int(limit)
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	entries, err := service.ListEntries(db, f)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func parseTime(w http.ResponseWriter, s string) (time.Time, bool) {
+	if len(s) == 0 {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		log.Printf("incorrect time %q: %v", s, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func parseInt(w http.ResponseWriter, s string) (int64, bool) {
+	if len(s) == 0 {
+		return 0, true
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		log.Printf("incorrect number %q: %v", s, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return 0, false
+	}
+	return v, true
+}
+
+func getDB(w http.ResponseWriter, r *http.Request) (storage.DB, bool) {
+	dbIface := r.Context().Value(storage.ContextKeyDB)
+	if dbIface == nil {
+		log.Println("DB is not found in the request context")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	db, ok := dbIface.(storage.DB)
+	if !ok {
+		log.Println("DB in the request context is not of type storage.DB")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return db, true
+}
+
+func writeErr(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, service.ErrIncorrectFilter):
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("failed to serialize the response to JSON: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(resp); err != nil {
+		log.Printf("failed to write the response body: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+)
+
+var (
+	ErrIncorrectFilter = fmt.Errorf("got an incorrect audit log filter")
+	ErrDBRequestFailed = fmt.Errorf("a request to DB failed")
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+var entityTypes = map[string]struct{}{
+	storage.EntityEmployee:      {},
+	storage.EntitySalaryChange:  {},
+	storage.EntityDepartment:    {},
+	storage.EntityBudget:        {},
+	storage.EntityPosition:      {},
+	storage.EntityExchangeRates: {},
+	storage.EntityReorg:         {},
+}
+
+// ListEntries returns the audit log entries matching the filter, the latest
+// first. At most maxLimit entries are returned at once, the listing is
+// continued with f.BeforeID set to the ID of the last entry.
+func ListEntries(db storage.DB, f *storage.Filter) ([]*storage.Entry, error) {
+	if len(f.EntityType) != 0 {
+		if _, ok := entityTypes[f.EntityType]; !ok {
+			return nil, fmt.Errorf("%w: unknown entity type %q", ErrIncorrectFilter, f.EntityType)
+		}
+	}
+	if len(f.EntityID) != 0 && len(f.EntityType) == 0 {
+		return nil, fmt.Errorf("%w: an entity ID requires an entity type", ErrIncorrectFilter)
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return nil, fmt.Errorf("%w: the time range is empty", ErrIncorrectFilter)
+	}
+	if f.BeforeID < 0 {
+		return nil, fmt.Errorf("%w: incorrect entry ID %d", ErrIncorrectFilter, f.BeforeID)
+	}
+	switch {
+	case f.Limit == 0:
+		f.Limit = defaultLimit
+	case f.Limit < 0 || f.Limit > maxLimit:
+		return nil, fmt.Errorf("%w: the limit must be between 1 and %d", ErrIncorrectFilter, maxLimit)
+	}
+	entries, err := db.ListEntries(context.Background(), f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list the audit log entries: %v", ErrDBRequestFailed, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+)
+
+func TestListEntries(t *testing.T) {
+	day := time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		Filter        *storage.Filter
+		ExpectedLimit int
+		ExpectedErr   error
+	}{
+		{
+			Filter:        &storage.Filter{},
+			ExpectedLimit: defaultLimit,
+		},
+		{
+			Filter: &storage.Filter{
+				EntityType: storage.EntityEmployee,
+				EntityID:   "1",
+				Actor:      "alice",
+				From:       day,
+				To:         day.AddDate(0, 0, 1),
+				BeforeID:   42,
+				Limit:      maxLimit,
+			},
+			ExpectedLimit: maxLimit,
+		},
+		{
+			Filter:      &storage.Filter{EntityType: "salary"},
+			ExpectedErr: ErrIncorrectFilter,
+		},
+		{
+			Filter:      &storage.Filter{EntityID: "1"},
+			ExpectedErr: ErrIncorrectFilter,
+		},
+		{
+			Filter:      &storage.Filter{From: day, To: day},
+			ExpectedErr: ErrIncorrectFilter,
+		},
+		{
+			Filter:      &storage.Filter{BeforeID: -1},
+			ExpectedErr: ErrIncorrectFilter,
+		},
+		{
+			Filter:      &storage.Filter{Limit: -1},
+			ExpectedErr: ErrIncorrectFilter,
+		},
+		{
+			Filter:      &storage.Filter{Limit: maxLimit + 1},
+			ExpectedErr: ErrIncorrectFilter,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{}
+			_, err := ListEntries(mock, tc.Filter)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				if mock.filter != nil {
+					t.Errorf("the audit log was queried despite the error")
+				}
+				return
+			}
+			if mock.filter == nil {
+				t.Errorf("the audit log was not queried")
+				return
+			}
+			if mock.filter.Limit != tc.ExpectedLimit {
+				t.Errorf("expected limit %d, got %d", tc.ExpectedLimit, mock.filter.Limit)
+			}
+		})
+	}
+}
+
+func compareErrs(expectedErr error, actualErr error) error {
+	if expectedErr == nil && actualErr == nil {
+		return nil
+	}
+	if actualErr == nil {
+		return fmt.Errorf("expected an error \"%v\", got nil", expectedErr)
+	}
+	if !errors.Is(actualErr, expectedErr) {
+		return fmt.Errorf("expected error \"%v\" and actual error \"%v\" are different", expectedErr, actualErr)
+	}
+	return nil
+}
+
+type dbMock struct {
+	filter *storage.Filter
+}
+
+func (db *dbMock) ListEntries(ctx context.Context, f *storage.Filter) ([]*storage.Entry, error) {
+	db.filter = f
+	return nil, nil
+}
+
+func (db *dbMock) Close() {}
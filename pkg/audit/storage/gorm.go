@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+type gormDB struct {
+	db *gorm.DB
+}
+
+func newGormDB(c *database.ConnString) (*gormDB, error) {
+	db, err := database.OpenGorm(c)
+	if err != nil {
+		return nil, err
+	}
+	return &gormDB{
+		db: db,
+	}, nil
+}
+
+func (g *gormDB) ListEntries(ctx context.Context, f *Filter) ([]*Entry, error) {
+	req := g.db.WithContext(ctx).Model(&Entry{})
+	if len(f.EntityType) != 0 {
+		req = req.Where("entity_type = ?", f.EntityType)
+	}
+	if len(f.EntityID) != 0 {
+		req = req.Where("entity_id = ?", f.EntityID)
+	}
+	if len(f.Actor) != 0 {
+		req = req.Where("actor = ?", f.Actor)
+	}
+	if !f.From.IsZero() {
+		req = req.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		req = req.Where("created_at < ?", f.To)
+	}
+	if f.BeforeID != 0 {
+		req = req.Where("id < ?", f.BeforeID)
+	}
+	var entries []*Entry
+	if err := req.Order("id DESC").Limit(f.Limit).Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to query the audit log: %w", err)
+	}
+	return entries, nil
+}
+
+func (g *gormDB) Close() {
+	database.CloseGorm(g.db)
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// Change describes a change of an entity to be recorded. Before and After are
+// serialized to JSON, a nil Before marks a created entity and a nil After a
+// deleted one.
+type Change struct {
+	Action     string
+	EntityType string
+	EntityID   interface{}
+	Before     interface{}
+	After      interface{}
+}
+
+// Record appends the change made by src to the audit log. It runs in the tx
+// transaction, so that the entry is written if and only if the change is.
+func Record(tx *gorm.DB, src *Source, c *Change) error {
+	if src == nil {
+		src = &Source{}
+	}
+	before, err := marshal(c.Before)
+	if err != nil {
+		return fmt.Errorf("failed to serialize the %s before the change: %w", c.EntityType, err)
+	}
+	after, err := marshal(c.After)
+	if err != nil {
+		return fmt.Errorf("failed to serialize the %s after the change: %w", c.EntityType, err)
+	}
+	diff, err := Diff(before, after)
+	if err != nil {
+		return fmt.Errorf("failed to compare the %s versions: %w", c.EntityType, err)
+	}
+	err = tx.Exec(
+		`INSERT INTO audit_log (actor, action, entity_type, entity_id, before, after, diff, request_id)
+		VALUES (?, ?, ?, ?, ?::jsonb, ?::jsonb, ?::jsonb, ?)`,
+		src.Actor,
+		c.Action,
+		c.EntityType,
+		fmt.Sprint(c.EntityID),
+		nullableJSON(before),
+		nullableJSON(after),
+		string(diff),
+		src.RequestID,
+	).Error
+	if err != nil {
+		return fmt.Errorf("failed to record the %s %s in the audit log: %w", c.EntityType, c.Action, err)
+	}
+	return nil
+}
+
+// fieldChange is a value of a diff.
+type fieldChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// Diff maps the top-level fields that differ between two JSON objects to
+// their values before and after. A missing object has no fields, and the
+// values that are not objects are not compared.
+func Diff(before json.RawMessage, after json.RawMessage) (json.RawMessage, error) {
+	beforeFields, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(beforeFields)+len(afterFields))
+	for name := range beforeFields {
+		names = append(names, name)
+	}
+	for name := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	diff := make(map[string]*fieldChange)
+	for _, name := range names {
+		b, a := beforeFields[name], afterFields[name]
+		if bytes.Equal(b, a) {
+			continue
+		}
+		diff[name] = &fieldChange{
+			Before: orNull(b),
+			After:  orNull(a),
+		}
+	}
+	return json.Marshal(diff)
+}
+
+func marshal(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// a typed nil pointer stands for a missing entity as well
+	if string(data) == "null" {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// fields returns the compacted values of the object's fields, so that they
+// can be compared byte by byte.
+func fields(data json.RawMessage) (map[string]json.RawMessage, error) {
+	if len(data) == 0 || data[0] != '{' {
+		return nil, nil
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	compacted := make(map[string]json.RawMessage, len(raw))
+	for name, value := range raw {
+		buf := &bytes.Buffer{}
+		if err := json.Compact(buf, value); err != nil {
+			return nil, err
+		}
+		compacted[name] = buf.Bytes()
+	}
+	return compacted, nil
+}
+
+func orNull(v json.RawMessage) json.RawMessage {
+	if v == nil {
+		return json.RawMessage("null")
+	}
+	return v
+}
+
+func nullableJSON(data json.RawMessage) interface{} {
+	if data == nil {
+		return nil
+	}
+	return string(data)
+}
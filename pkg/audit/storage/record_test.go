@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	cases := []struct {
+		Before       string
+		After        string
+		ExpectedDiff string
+	}{
+		{
+			After:        `{"id": 1, "name": "Go"}`,
+			ExpectedDiff: `{"id":{"before":null,"after":1},"name":{"before":null,"after":"Go"}}`,
+		},
+		{
+			Before:       `{"id": 1, "name": "Go"}`,
+			ExpectedDiff: `{"id":{"before":1,"after":null},"name":{"before":"Go","after":null}}`,
+		},
+		{
+			Before:       `{"id": 1, "salary": {"amount": "100.00", "currency": "RUB"}, "phone": ""}`,
+			After:        `{"id":1,"salary":{"amount":"150.00","currency":"RUB"},"phone":""}`,
+			ExpectedDiff: `{"salary":{"before":{"amount":"100.00","currency":"RUB"},"after":{"amount":"150.00","currency":"RUB"}}}`,
+		},
+		{
+			Before:       `{"id": 1, "manager_id": 2}`,
+			After:        `{"id": 1}`,
+			ExpectedDiff: `{"manager_id":{"before":2,"after":null}}`,
+		},
+		{
+			Before:       `{"id": 1}`,
+			After:        `{"id":1}`,
+			ExpectedDiff: `{}`,
+		},
+		{
+			After:        `[{"from": "USD"}]`,
+			ExpectedDiff: `{}`,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			diff, err := Diff(raw(tc.Before), raw(tc.After))
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if string(diff) != tc.ExpectedDiff {
+				t.Errorf("expected diff %s, got %s", tc.ExpectedDiff, diff)
+			}
+		})
+	}
+}
+
+func TestMarshalMissingEntity(t *testing.T) {
+	var p *struct{ ID int }
+	for _, v := range []interface{}{nil, p} {
+		data, err := marshal(v)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+		if data != nil {
+			t.Errorf("expected a missing entity, got %s", data)
+		}
+	}
+}
+
+func raw(s string) json.RawMessage {
+	if len(s) == 0 {
+		return nil
+	}
+	return json.RawMessage(s)
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+type ContextKey int
+
+const ContextKeyDB ContextKey = iota + 1
+
+const (
+	EntityEmployee      = "employee"
+	EntitySalaryChange  = "salary_change"
+	EntityDepartment    = "department"
+	EntityBudget        = "budget"
+	EntityPosition      = "position"
+	EntityExchangeRates = "exchange_rates"
+	EntityReorg         = "reorg"
+	EntityAPIKey        = "api_key"
+	EntityRoleGrant     = "role_grant"
+)
+
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionDelete    = "delete"
+	ActionCancel    = "cancel"
+	ActionApply     = "apply"
+	ActionTransfer  = "transfer"
+	ActionTerminate = "terminate"
+	ActionRehire    = "rehire"
+	ActionReassign  = "reassign"
+	ActionCopy      = "copy"
+	ActionUpload    = "upload"
+	ActionStage     = "stage"
+	ActionUnstage   = "unstage"
+	ActionCommit    = "commit"
+	ActionDiscard   = "discard"
+	ActionRevoke    = "revoke"
+)
+
+// Source tells who makes the changes: the author of a request or a
+// background job.
+type Source struct {
+	Actor     string
+	RequestID string
+}
+
+// System is the source of the changes made by the named background job.
+func System(job string) *Source {
+	return &Source{
+		Actor: "system:" + job,
+	}
+}
+
+// Entry is a record of the audit log. Before is nil for a created entity and
+// After is nil for a deleted one.
+type Entry struct {
+	ID         int64           `gorm:"column:id" json:"id"`
+	Actor      string          `gorm:"column:actor" json:"actor"`
+	Action     string          `gorm:"column:action" json:"action"`
+	EntityType string          `gorm:"column:entity_type" json:"entity_type"`
+	EntityID   string          `gorm:"column:entity_id" json:"entity_id"`
+	Before     json.RawMessage `gorm:"column:before" json:"before"`
+	After      json.RawMessage `gorm:"column:after" json:"after"`
+	Diff       json.RawMessage `gorm:"column:diff" json:"diff"`
+	RequestID  string          `gorm:"column:request_id" json:"request_id"`
+	CreatedAt  time.Time       `gorm:"column:created_at" json:"created_at"`
+}
+
+func (Entry) TableName() string {
+	return "audit_log"
+}
+
+// Filter selects the entries of the audit log. The zero fields do not filter.
+type Filter struct {
+	EntityType string
+	EntityID   string
+	Actor      string
+	// From and To bound the creation time, From included and To excluded.
+	From time.Time
+	To   time.Time
+	// BeforeID continues the listing after the entry with the ID.
+	BeforeID int64
+	Limit    int
+}
+
+type DB interface {
+	// ListEntries returns the entries matching the filter, the latest first.
+	ListEntries(ctx context.Context, f *Filter) ([]*Entry, error)
+	Close()
+}
+
+func NewDB(connStr *database.ConnString) (DB, error) {
+	gormDB, err := newGormDB(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a gorm connection: %w", err)
+	}
+	return gormDB, nil
+}
@@ -0,0 +1,13 @@
+CREATE USER gopher
+WITH PASSWORD 'P@ssw0rd';
+
+CREATE DATABASE gopher_corp
+    WITH OWNER gopher
+    TEMPLATE = 'template0'
+    ENCODING = 'utf-8'
+    LC_COLLATE = 'C.UTF-8'
+    LC_CTYPE = 'C.UTF-8';
+
+CREATE ROLE gopher_app NOLOGIN;
+
+GRANT gopher_app TO gopher;
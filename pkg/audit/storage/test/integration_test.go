@@ -0,0 +1,410 @@
+//go:build integration_tests
+// +build integration_tests
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	employeesStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/encryption"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+const (
+	DB_HOST     = "127.0.0.1"
+	DB_USER     = "gopher"
+	DB_PASSWORD = "P@ssw0rd"
+	DB_NAME     = "gopher_corp"
+)
+
+var DB_PORT = ""
+
+func TestMain(m *testing.M) {
+	os.Exit(testMain(m))
+}
+
+func testMain(m *testing.M) int {
+	setupResult, err := setup()
+	if err != nil {
+		log.Println("setup err: ", err)
+		return -1
+	}
+	defer teardown(setupResult)
+	return m.Run()
+}
+
+type setupResult struct {
+	Pool              *dockertest.Pool
+	PostgresContainer *dockertest.Resource
+}
+
+const dockerMaxWait = time.Second * 5
+
+func setup() (r *setupResult, err error) {
+	testFileDir, err := getTestFileDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the script dir: %w", err)
+	}
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a new docketest pool: %w", err)
+	}
+	pool.MaxWait = dockerMaxWait
+
+	postgresContainer, err := runPostgresContainer(pool, testFileDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run the Postgres container: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if err := pool.Purge(postgresContainer); err != nil {
+				log.Println("failed to purge the postgres container: %w", err)
+			}
+		}
+	}()
+
+	migrationContainer, err := runMigrationContainer(pool, testFileDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run the migration container: %w", err)
+	}
+
+	defer func() {
+		if err := pool.Purge(migrationContainer); err != nil {
+			err = fmt.Errorf("failed to purge the migration container: %w", err)
+		}
+	}()
+
+	keyring, err := encryption.ParseKeyring([]byte(testKeyring))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the test keyring: %w", err)
+	}
+	encryption.SetKeyring(keyring)
+
+	if err := pool.Retry(func() error {
+		err := prepopulateDB(testFileDir)
+		if err != nil {
+			log.Printf("populate DB err: %v", err)
+		}
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to prepopulate the DB: %w", err)
+	}
+	if err := sealContacts(); err != nil {
+		return nil, fmt.Errorf("failed to seal the prepopulated contacts: %w", err)
+	}
+
+	return &setupResult{
+		Pool:              pool,
+		PostgresContainer: postgresContainer,
+	}, nil
+}
+
+func getTestFileDir() (string, error) {
+	_, fileName, _, ok := runtime.Caller(0)
+	if !ok {
+		return "", fmt.Errorf("failed to get the caller info")
+	}
+	fileDir := filepath.Dir(fileName)
+	dir, err := filepath.Abs(fileDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the absolute path to the directory %s: %w", dir, err)
+	}
+	return fileDir, nil
+}
+
+func runPostgresContainer(pool *dockertest.Pool, testFileDir string) (*dockertest.Resource, error) {
+	postgresContainer, err := pool.RunWithOptions(
+		&dockertest.RunOptions{
+			Repository: "postgres",
+			Tag:        "14.0",
+			Env: []string{
+				"POSTGRES_PASSWORD=P@ssw0rd",
+			},
+		},
+		func(config *docker.HostConfig) {
+			config.AutoRemove = false
+			config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+			config.Mounts = []docker.HostMount{
+				{
+					Target: "/docker-entrypoint-initdb.d",
+					Source: filepath.Join(testFileDir, "init"),
+					Type:   "bind",
+				},
+			}
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the postgres docker container: %w", err)
+	}
+	postgresContainer.Expire(120)
+
+	DB_PORT = postgresContainer.GetPort("5432/tcp")
+
+	// Wait for the DB to start
+	if err := pool.Retry(func() error {
+		db, err := getDBConnector()
+		if err != nil {
+			return fmt.Errorf("failed to get a DB connector: %w", err)
+		}
+		return db.Ping(context.Background())
+	}); err != nil {
+		pool.Purge(postgresContainer)
+		return nil, fmt.Errorf("failed to ping the created DB: %w", err)
+	}
+	return postgresContainer, nil
+}
+
+func runMigrationContainer(pool *dockertest.Pool, testFileDir string) (*dockertest.Resource, error) {
+	migrationsDir, err := filepath.Abs(filepath.Join(testFileDir, "../../../../migrations"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the absolute path of the migrations dir: %w", err)
+	}
+	migrationContainer, err := pool.RunWithOptions(
+		&dockertest.RunOptions{
+			Repository: "migrate/migrate",
+			Tag:        "v4.15.0",
+			Cmd: []string{
+				"-path=/migrations",
+				fmt.Sprintf(
+					"-database=%s",
+					composeConnectionString(),
+				),
+				"up",
+			},
+		},
+		func(config *docker.HostConfig) {
+			config.AutoRemove = false
+			config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+			config.Mounts = []docker.HostMount{
+				{
+					Target: "/migrations",
+					Source: migrationsDir,
+					Type:   "bind",
+				},
+			}
+			config.NetworkMode = "host"
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the migration container: %w", err)
+	}
+
+	return migrationContainer, err
+}
+
+func prepopulateDB(testFileDir string) error {
+	prepopulateScriptPath := filepath.Join(testFileDir, "prepopulate_db.sql")
+	scriptBytes, err := os.ReadFile(prepopulateScriptPath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", prepopulateScriptPath, err)
+	}
+	conn, err := getDBConnector()
+	if err != nil {
+		return fmt.Errorf("failed to get a DB connector: %w", err)
+	}
+	if _, err := conn.Exec(context.Background(), string(scriptBytes)); err != nil {
+		return fmt.Errorf("failed to execute the prepopulate script: %w", err)
+	}
+	return nil
+}
+
+func teardown(r *setupResult) {
+	if err := r.Pool.Purge(r.PostgresContainer); err != nil {
+		log.Printf("failed to purge the Postgres container: %v", err)
+	}
+}
+
+func TestListRecordedEntries(t *testing.T) {
+	gormDB, err := database.OpenGorm(getConnectionString())
+	if err != nil {
+		t.Fatalf("failed to open a gorm connection: %v", err)
+	}
+	defer database.CloseGorm(gormDB)
+	change := &storage.Change{
+		Action:     storage.ActionUpdate,
+		EntityType: storage.EntityPosition,
+		EntityID:   "test_list_recorded_entries",
+		Before:     map[string]string{"title": "QA"},
+		After:      map[string]string{"title": "QA Engineer"},
+	}
+	src := &storage.Source{Actor: "test_list_recorded_entries", RequestID: "42"}
+	if err := storage.Record(gormDB, src, change); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	db, err := storage.NewDB(getConnectionString())
+	if err != nil {
+		t.Fatalf("failed to create a DB object: %v", err)
+	}
+	defer db.Close()
+	entries, err := db.ListEntries(context.Background(), &storage.Filter{
+		EntityType: storage.EntityPosition,
+		EntityID:   "test_list_recorded_entries",
+	})
+	if err != nil {
+		t.Fatalf("ListEntries failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected the recorded entry to be listed, got %+v", entries)
+	}
+	e := entries[0]
+	if e.Actor != src.Actor || e.RequestID != src.RequestID || e.Action != storage.ActionUpdate {
+		t.Fatalf("expected the entry to be recorded by %+v, got %+v", src, e)
+	}
+	var diff map[string]map[string]string
+	if err := json.Unmarshal(e.Diff, &diff); err != nil {
+		t.Fatalf("failed to parse the diff: %v", err)
+	}
+	expectedDiff := map[string]map[string]string{"title": {"before": "QA", "after": "QA Engineer"}}
+	if !reflect.DeepEqual(diff, expectedDiff) {
+		t.Fatalf("expected the diff %v, got %s", expectedDiff, e.Diff)
+	}
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	_, err = conn.Exec(
+		context.Background(),
+		`INSERT INTO audit_log (actor, action, entity_type, entity_id) VALUES ('test_audit_log_is_append_only', 'create', 'position', '0')`,
+	)
+	if err != nil {
+		t.Fatalf("failed to append an entry: %v", err)
+	}
+	cases := []string{
+		`UPDATE audit_log SET actor = 'someone' WHERE actor = 'test_audit_log_is_append_only'`,
+		`DELETE FROM audit_log WHERE actor = 'test_audit_log_is_append_only'`,
+		`TRUNCATE audit_log`,
+	}
+
+	for i, query := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			_, err := conn.Exec(context.Background(), query)
+			if err == nil || !strings.Contains(err.Error(), "audit_log is append-only") {
+				t.Fatalf("expected %q to be forbidden, got %v", query, err)
+			}
+		})
+	}
+	var count int
+	err = conn.QueryRow(
+		context.Background(),
+		`SELECT COUNT(*) FROM audit_log WHERE actor = 'test_audit_log_is_append_only'`,
+	).Scan(&count)
+	if err != nil {
+		t.Fatalf("failed to query the audit log: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected the entry to be kept, got %d entries", count)
+	}
+}
+
+func TestAuditLogHasNoPlaintextContacts(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	var employeeID int
+	var phoneVisibility, emailVisibility *string
+	err = conn.QueryRow(
+		context.Background(),
+		`SELECT id, phone_visibility, email_visibility FROM employees WHERE first_name = 'Alice' AND last_name = 'Liddell'`,
+	).Scan(&employeeID, &phoneVisibility, &emailVisibility)
+	if err != nil {
+		t.Fatalf("failed to query the employee: %v", err)
+	}
+	db, err := employeesStorage.NewDB(getConnectionString(), storage.System("test"))
+	if err != nil {
+		t.Fatalf("failed to open the employees storage: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.SetContactVisibility(context.Background(), employeeID, phoneVisibility, emailVisibility); err != nil {
+		t.Fatalf("failed to set the contact visibility: %v", err)
+	}
+
+	var recorded, plaintext int
+	err = conn.QueryRow(
+		context.Background(),
+		`SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE concat(before::text, after::text, diff::text) ~ 'aliddell|79169008070')
+		FROM audit_log
+		WHERE entity_type = 'employee' AND entity_id = $1 AND after ? 'contacts'`,
+		fmt.Sprint(employeeID),
+	).Scan(&recorded, &plaintext)
+	if err != nil {
+		t.Fatalf("failed to query the audit log: %v", err)
+	}
+	if recorded == 0 {
+		t.Fatalf("expected the change to be recorded with the index of the contacts")
+	}
+	if plaintext != 0 {
+		t.Fatalf("expected no plaintext contacts in the audit log, got %d entries", plaintext)
+	}
+}
+
+// testKeyring seals the contacts of the tests.
+const testKeyring = `{
+	"primary": "test",
+	"keys": {"test": "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE="},
+	"index_key": "QUJDREVGR0hJSktMTU5PUFFSU1RVVldYWVpBQkNERUY="
+}`
+
+// sealContacts seals and indexes the contacts inserted in plaintext, as it is
+// done for the contacts stored before the encryption.
+func sealContacts() error {
+	db, err := database.OpenGorm(getConnectionString())
+	if err != nil {
+		return fmt.Errorf("failed to open a gorm connection: %w", err)
+	}
+	defer database.CloseGorm(db)
+	if _, err := employeesStorage.ReencryptContacts(context.Background(), db, false); err != nil {
+		return fmt.Errorf("failed to reencrypt the contacts: %w", err)
+	}
+	return nil
+}
+
+func getDBConnector() (*pgxpool.Pool, error) {
+	log.Println(composeConnectionString())
+	cfg, err := pgxpool.ParseConfig(composeConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the PGX pool config from connection string: %w", err)
+	}
+	cfg.ConnConfig.ConnectTimeout = time.Second * 1
+	db, err := pgxpool.ConnectConfig(context.Background(), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the postgres DB using a PGX connection pool: %w", err)
+	}
+	return db, nil
+}
+
+func getConnectionString() *database.ConnString {
+	return &database.ConnString{
+		Host:     DB_HOST,
+		Port:     DB_PORT,
+		User:     DB_USER,
+		Password: DB_PASSWORD,
+		DBName:   DB_NAME,
+	}
+}
+
+func composeConnectionString() string {
+	return fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=disable", DB_USER, url.QueryEscape(DB_PASSWORD), DB_HOST, DB_PORT, DB_NAME)
+}
@@ -0,0 +1,149 @@
+BEGIN;
+
+INSERT INTO positions (title)
+VALUES
+    ('CTO'),
+    ('CEO'),
+    ('CSO'),
+    ('Backend Dev'),
+    ('Frontend Dev'),
+    ('Fullstack Dev'),
+    ('QA'),
+    ('Technical writer')
+ON CONFLICT(title) DO NOTHING;
+
+INSERT INTO departments (id, parent_id, name)
+OVERRIDING SYSTEM VALUE
+VALUES
+    (0, 0, 'root') ON CONFLICT(id) DO NOTHING;
+
+INSERT INTO departments (parent_id, name)
+VALUES
+    (
+        (
+            SELECT id
+            FROM departments
+            WHERE name = 'root'
+        ),
+        'executives'
+    ),
+    (
+        (
+            SELECT id
+            FROM departments
+            WHERE name = 'root'
+        ),
+        'R&D'
+    ),
+    (
+        (
+            SELECT id
+            FROM departments
+            WHERE name = 'root'
+        ),
+        'Accounting'
+    ),
+    (
+        (
+            SELECT id
+            FROM departments
+            WHERE name = 'root'
+        ),
+        'Sales'
+    ) ON CONFLICT(name) DO NOTHING;
+
+COMMIT;
+
+BEGIN DEFERRABLE;
+    INSERT INTO employees (first_name, last_name, salary_amount, salary_currency, manager_id, department, position)
+    VALUES
+        (
+            'Bob',
+            'Morane',
+            500000,
+            'RUB',
+            42,
+            (SELECT id FROM departments WHERE name = 'executives'),
+            (SELECT id FROM positions WHERE title = 'CSO')
+        );
+    UPDATE employees
+    SET manager_id = (
+        SELECT id
+        FROM employees
+        WHERE
+            first_name = 'Bob'
+            AND last_name = 'Morane'
+        LIMIT 1
+    )
+    WHERE
+        first_name = 'Bob'
+        AND last_name = 'Morane';
+
+    INSERT INTO employees (first_name, last_name, salary_amount, salary_currency, manager_id, department, position)
+    VALUES
+        (
+            'Charley',
+            'Bucket',
+            1000000,
+            'RUB',
+            42,
+            (SELECT id FROM departments WHERE name = 'executives'),
+            (SELECT id FROM positions WHERE title = 'CEO')
+        );
+    UPDATE employees
+    SET manager_id = (
+        SELECT id
+        FROM employees
+        WHERE
+            first_name = 'Charley'
+            AND last_name = 'Bucket'
+        LIMIT 1
+    )
+    WHERE
+        first_name = 'Charley'
+        AND last_name = 'Bucket';
+
+    INSERT INTO employees (first_name, last_name, salary_amount, salary_currency, manager_id, department, position)
+    VALUES
+        (
+            'Alice',
+            'Liddell',
+            500000,
+            'RUB',
+            42,
+            (SELECT id FROM departments WHERE name = 'executives'),
+            (SELECT id FROM positions WHERE title = 'CTO')
+        );
+    UPDATE employees
+    SET manager_id = (
+        SELECT id
+        FROM employees
+        WHERE
+            first_name = 'Alice'
+            AND last_name = 'Liddell'
+        LIMIT 1
+    )
+    WHERE
+        first_name = 'Alice'
+        AND last_name = 'Liddell';
+
+    INSERT INTO employee_phones (employee_id, type, number, is_primary, ordinal)
+    SELECT e.id, 'work', c.phone, TRUE, 0
+    FROM employees e
+    JOIN (
+        VALUES
+            ('Bob', 'Morane', '+79231234567'),
+            ('Charley', 'Bucket', '+79159876543'),
+            ('Alice', 'Liddell', '+79169008070')
+    ) AS c (first_name, last_name, phone) USING (first_name, last_name);
+
+    INSERT INTO employee_emails (employee_id, type, address, is_primary, ordinal)
+    SELECT e.id, 'work', c.email, TRUE, 0
+    FROM employees e
+    JOIN (
+        VALUES
+            ('Bob', 'Morane', 'bmorane@gopher_corp.com'),
+            ('Charley', 'Bucket', 'cbucket@gopher_corp.com'),
+            ('Alice', 'Liddell', 'aliddell@gopher_corp.com')
+    ) AS c (first_name, last_name, email) USING (first_name, last_name);
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+)
+
+var (
+	ErrIncorrectToken    = fmt.Errorf("got an incorrect token")
+	ErrIncorrectKeySet   = fmt.Errorf("got an incorrect key set")
+	ErrIncorrectVerifier = fmt.Errorf("got an incorrect token verifier")
+	ErrIncorrectAPIKey   = fmt.Errorf("got an incorrect API key")
+)
+
+// Principal is the authenticated caller.
+type Principal struct {
+	Subject string
+	Roles   []string
+	// EmployeeID is the employee the caller is, zero if the caller is not
+	// an employee, e.g. a service.
+	EmployeeID int
+	// APIKeyID is the API key the caller has authenticated with, zero for
+	// the users. Only the API keys are restricted to their Scopes.
+	APIKeyID int64
+	Scopes   []string
+	// DepartmentRoles are the roles the caller holds only in some
+	// departments: by role, the departments of the grants with all their
+	// descendants.
+	DepartmentRoles map[string]map[int]struct{}
+}
+
+// HasRole tells if the principal has the role.
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of the context carrying the principal.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of the context, nil if there is none.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// Subject returns the subject of the principal of the context, an empty
+// string if there is none.
+func Subject(ctx context.Context) string {
+	if p := FromContext(ctx); p != nil {
+		return p.Subject
+	}
+	return ""
+}
+
+// EmployeeID returns the employee of the principal of the context, zero if
+// there is none.
+func EmployeeID(ctx context.Context) int {
+	if p := FromContext(ctx); p != nil {
+		return p.EmployeeID
+	}
+	return 0
+}
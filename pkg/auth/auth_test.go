@@ -0,0 +1,494 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestVerify(t *testing.T) {
+	at := time.Date(2021, time.July, 10, 12, 0, 0, 0, time.UTC)
+	setNow(t, at)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate an RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate an EC key: %v", err)
+	}
+	keySet, err := ParseKeySet(encodeKeySet(t, map[string]crypto.PublicKey{
+		"rsa": &rsaKey.PublicKey,
+		"ec":  &ecKey.PublicKey,
+	}))
+	if err != nil {
+		t.Fatalf("failed to parse the key set: %v", err)
+	}
+	v, err := NewVerifier(VerifierConfig{
+		HS256Secret: testSecret,
+		KeySet:      keySet,
+		Issuer:      "gopher-corp",
+		Audience:    "backend",
+		Leeway:      time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the verifier: %v", err)
+	}
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub":         "alice",
+			"iss":         "gopher-corp",
+			"aud":         []string{"backend", "frontend"},
+			"exp":         at.Add(time.Hour).Unix(),
+			"roles":       []string{"hr"},
+			"employee_id": 42,
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+	expected := &Principal{Subject: "alice", Roles: []string{"hr"}, EmployeeID: 42}
+	cases := []struct {
+		Token             string
+		ExpectedPrincipal *Principal
+		ExpectedErr       error
+	}{
+		{
+			Token:             signHS256(t, map[string]interface{}{"alg": "HS256"}, claims(nil), testSecret),
+			ExpectedPrincipal: expected,
+		},
+		{
+			Token:             signRS256(t, map[string]interface{}{"alg": "RS256", "kid": "rsa"}, claims(nil), rsaKey),
+			ExpectedPrincipal: expected,
+		},
+		{
+			Token:             signES256(t, map[string]interface{}{"alg": "ES256"}, claims(map[string]interface{}{"aud": "backend"}), ecKey),
+			ExpectedPrincipal: expected,
+		},
+		// within the leeway
+		{
+			Token:             signHS256(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"exp": at.Add(-30 * time.Second).Unix()}), testSecret),
+			ExpectedPrincipal: expected,
+		},
+		{
+			Token:       signHS256(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"exp": at.Add(-time.Hour).Unix()}), testSecret),
+			ExpectedErr: ErrIncorrectToken,
+		},
+		{
+			Token:       signHS256(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"exp": nil}), testSecret),
+			ExpectedErr: ErrIncorrectToken,
+		},
+		{
+			Token:       signHS256(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"nbf": at.Add(time.Hour).Unix()}), testSecret),
+			ExpectedErr: ErrIncorrectToken,
+		},
+		{
+			Token:       signHS256(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"sub": nil}), testSecret),
+			ExpectedErr: ErrIncorrectToken,
+		},
+		{
+			Token:       signHS256(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"iss": "someone"}), testSecret),
+			ExpectedErr: ErrIncorrectToken,
+		},
+		{
+			Token:       signHS256(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"aud": "frontend"}), testSecret),
+			ExpectedErr: ErrIncorrectToken,
+		},
+		{
+			Token:       signHS256(t, map[string]interface{}{"alg": "HS256"}, claims(nil), []byte("another secret of 32 bytes or so")),
+			ExpectedErr: ErrIncorrectToken,
+		},
+		// the key is of another algorithm
+		{
+			Token:       signRS256(t, map[string]interface{}{"alg": "RS256", "kid": "ec"}, claims(nil), rsaKey),
+			ExpectedErr: ErrIncorrectToken,
+		},
+		{
+			Token:       encodeToken(t, map[string]interface{}{"alg": "none"}, claims(nil), nil),
+			ExpectedErr: ErrIncorrectToken,
+		},
+		{
+			Token:       "not.a.token",
+			ExpectedErr: ErrIncorrectToken,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			p, err := v.Verify(tc.Token)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if !reflect.DeepEqual(p, tc.ExpectedPrincipal) {
+				t.Errorf("expected principal %+v, got %+v", tc.ExpectedPrincipal, p)
+			}
+		})
+	}
+}
+
+func TestVerifyHS256WithoutSecret(t *testing.T) {
+	setNow(t, time.Date(2021, time.July, 10, 12, 0, 0, 0, time.UTC))
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate an EC key: %v", err)
+	}
+	keySet, err := ParseKeySet(encodeKeySet(t, map[string]crypto.PublicKey{"ec": &ecKey.PublicKey}))
+	if err != nil {
+		t.Fatalf("failed to parse the key set: %v", err)
+	}
+	v, err := NewVerifier(VerifierConfig{KeySet: keySet})
+	if err != nil {
+		t.Fatalf("failed to create the verifier: %v", err)
+	}
+	// an HS256 token must not be verified with an empty secret
+	token := signHS256(t, map[string]interface{}{"alg": "HS256"}, map[string]interface{}{
+		"sub": "alice",
+		"exp": now().Add(time.Hour).Unix(),
+	}, nil)
+	if _, err := v.Verify(token); !errors.Is(err, ErrIncorrectToken) {
+		t.Errorf("expected an incorrect token error, got %v", err)
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	cases := []struct {
+		Config      VerifierConfig
+		ExpectedErr error
+	}{
+		{
+			Config: VerifierConfig{HS256Secret: testSecret},
+		},
+		{
+			Config:      VerifierConfig{},
+			ExpectedErr: ErrIncorrectVerifier,
+		},
+		{
+			Config:      VerifierConfig{HS256Secret: []byte("short")},
+			ExpectedErr: ErrIncorrectVerifier,
+		},
+		{
+			Config:      VerifierConfig{HS256Secret: testSecret, Leeway: -time.Second},
+			ExpectedErr: ErrIncorrectVerifier,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			_, err := NewVerifier(tc.Config)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestParseKeySet(t *testing.T) {
+	cases := []struct {
+		KeySet      string
+		ExpectedErr error
+	}{
+		{
+			KeySet:      `{"keys": []}`,
+			ExpectedErr: ErrIncorrectKeySet,
+		},
+		{
+			KeySet:      `{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`,
+			ExpectedErr: ErrIncorrectKeySet,
+		},
+		{
+			KeySet:      `{"keys": [{"kty": "RSA", "n": "AQAB", "e": "AQAB"}]}`,
+			ExpectedErr: ErrIncorrectKeySet,
+		},
+		{
+			KeySet:      `{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQAB", "y": "AQAB"}]}`,
+			ExpectedErr: ErrIncorrectKeySet,
+		},
+		{
+			KeySet:      `not json`,
+			ExpectedErr: ErrIncorrectKeySet,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			_, err := ParseKeySet([]byte(tc.KeySet))
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	setNow(t, time.Date(2021, time.July, 10, 12, 0, 0, 0, time.UTC))
+	v, err := NewVerifier(VerifierConfig{HS256Secret: testSecret})
+	if err != nil {
+		t.Fatalf("failed to create the verifier: %v", err)
+	}
+	token := signHS256(t, map[string]interface{}{"alg": "HS256"}, map[string]interface{}{
+		"sub": "alice",
+		"exp": now().Add(time.Hour).Unix(),
+	}, testSecret)
+	keys := keyAuthenticatorMock{
+		"gck_bot": {Subject: "api-key:bot", APIKeyID: 1},
+	}
+	handler := NewMiddleware(v, keys, "/health")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" && FromContext(r.Context()) == nil {
+			t.Errorf("expected the principal in the context")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	cases := []struct {
+		Path             string
+		Authorization    string
+		APIKey           string
+		ExpectedRespCode int
+	}{
+		{
+			Path:             "/employees/1",
+			Authorization:    "Bearer " + token,
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Path:             "/employees/1",
+			Authorization:    "bearer " + token,
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Path:             "/employees/1",
+			ExpectedRespCode: http.StatusUnauthorized,
+		},
+		{
+			Path:             "/employees/1",
+			Authorization:    "Basic YWxpY2U6c2VjcmV0",
+			ExpectedRespCode: http.StatusUnauthorized,
+		},
+		{
+			Path:             "/employees/1",
+			Authorization:    "Bearer " + token + "x",
+			ExpectedRespCode: http.StatusUnauthorized,
+		},
+		{
+			Path:             "/health",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Path:             "/employees/1",
+			APIKey:           "gck_bot",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Path:             "/employees/1",
+			APIKey:           "gck_unknown",
+			ExpectedRespCode: http.StatusUnauthorized,
+		},
+		{
+			Path:             "/employees/1",
+			APIKey:           "gck_failing",
+			ExpectedRespCode: http.StatusInternalServerError,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			req, err := http.NewRequest("GET", tc.Path, nil)
+			if err != nil {
+				t.Fatalf("failed to create an http request: %v", err)
+			}
+			if len(tc.Authorization) != 0 {
+				req.Header.Set("Authorization", tc.Authorization)
+			}
+			if len(tc.APIKey) != 0 {
+				req.Header.Set(APIKeyHeader, tc.APIKey)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tc.ExpectedRespCode {
+				t.Errorf("expected code: %d, got: %d", tc.ExpectedRespCode, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	handler := RequireScope(ResourceBudget)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	cases := []struct {
+		Principal        *Principal
+		Method           string
+		ExpectedRespCode int
+	}{
+		{
+			Principal:        &Principal{Subject: "alice"},
+			Method:           "PUT",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Principal:        &Principal{Subject: "api-key:bot", APIKeyID: 1, Scopes: []string{"budget:read"}},
+			Method:           "GET",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Principal:        &Principal{Subject: "api-key:bot", APIKeyID: 1, Scopes: []string{"budget:read"}},
+			Method:           "PUT",
+			ExpectedRespCode: http.StatusForbidden,
+		},
+		// the write access includes the read one
+		{
+			Principal:        &Principal{Subject: "api-key:bot", APIKeyID: 1, Scopes: []string{"budget:write"}},
+			Method:           "GET",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Principal:        &Principal{Subject: "api-key:bot", APIKeyID: 1, Scopes: []string{"directory:read"}},
+			Method:           "GET",
+			ExpectedRespCode: http.StatusForbidden,
+		},
+		{
+			Method:           "GET",
+			ExpectedRespCode: http.StatusForbidden,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			req, err := http.NewRequest(tc.Method, "/budgets", nil)
+			if err != nil {
+				t.Fatalf("failed to create an http request: %v", err)
+			}
+			if tc.Principal != nil {
+				req = req.WithContext(NewContext(req.Context(), tc.Principal))
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tc.ExpectedRespCode {
+				t.Errorf("expected code: %d, got: %d", tc.ExpectedRespCode, rr.Code)
+			}
+		})
+	}
+}
+
+type keyAuthenticatorMock map[string]*Principal
+
+func (m keyAuthenticatorMock) Authenticate(ctx context.Context, key string) (*Principal, error) {
+	if key == "gck_failing" {
+		return nil, fmt.Errorf("some err")
+	}
+	p, ok := m[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key", ErrIncorrectAPIKey)
+	}
+	return p, nil
+}
+
+func encodeToken(t *testing.T, header map[string]interface{}, claims map[string]interface{}, sig []byte) string {
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatalf("failed to encode the header: %v", err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to encode the claims: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c) + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func signingInput(t *testing.T, header map[string]interface{}, claims map[string]interface{}) string {
+	token := encodeToken(t, header, claims, nil)
+	return token[:len(token)-1]
+}
+
+func signHS256(t *testing.T, header map[string]interface{}, claims map[string]interface{}, secret []byte) string {
+	signed := signingInput(t, header, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, header map[string]interface{}, claims map[string]interface{}, key *rsa.PrivateKey) string {
+	signed := signingInput(t, header, claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign the token: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func signES256(t *testing.T, header map[string]interface{}, claims map[string]interface{}, key *ecdsa.PrivateKey) string {
+	signed := signingInput(t, header, claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign the token: %v", err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func encodeKeySet(t *testing.T, keys map[string]crypto.PublicKey) []byte {
+	enc := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+	var set struct {
+		Keys []*jwk `json:"keys"`
+	}
+	for kid, key := range keys {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, &jwk{Kty: "RSA", Kid: kid, Use: "sig", N: enc(k.N), E: enc(big.NewInt(int64(k.E)))})
+		case *ecdsa.PublicKey:
+			set.Keys = append(set.Keys, &jwk{Kty: "EC", Kid: kid, Crv: "P-256", X: enc(k.X), Y: enc(k.Y)})
+		}
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("failed to encode the key set: %v", err)
+	}
+	return data
+}
+
+func compareErrs(expectedErr error, actualErr error) error {
+	if expectedErr == nil && actualErr == nil {
+		return nil
+	}
+	if actualErr == nil {
+		return fmt.Errorf("expected an error \"%v\", got nil", expectedErr)
+	}
+	if !errors.Is(actualErr, expectedErr) {
+		return fmt.Errorf("expected error \"%v\" and actual error \"%v\" are different", expectedErr, actualErr)
+	}
+	return nil
+}
+
+func setNow(t *testing.T, at time.Time) {
+	prev := now
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = prev })
+}
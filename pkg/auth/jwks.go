@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+)
+
+// jwk is a public key of a JSON Web Key Set, RFC 7517. Only the RSA keys and
+// the EC keys on the P-256 curve are supported.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// N and E are the modulus and the exponent of an RSA key.
+	N string `json:"n"`
+	E string `json:"e"`
+	// Crv, X and Y are the curve and the coordinates of an EC key.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey is a key verifying the signatures of the algorithm.
+type publicKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// KeySet holds the public keys verifying the token signatures.
+type KeySet struct {
+	keys []*publicKey
+}
+
+// LoadKeySet reads the public keys from the JWKS file.
+func LoadKeySet(path string) (*KeySet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the key set: %w", err)
+	}
+	return ParseKeySet(data)
+}
+
+// ParseKeySet parses the public keys of the JWKS document. The keys not used
+// for signatures are skipped.
+func ParseKeySet(data []byte) (*KeySet, error) {
+	var set struct {
+		Keys []*jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIncorrectKeySet, err)
+	}
+	keys := make([]*publicKey, 0, len(set.Keys))
+	for i, k := range set.Keys {
+		if len(k.Use) != 0 && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%w: key #%d %q: %v", ErrIncorrectKeySet, i, k.Kid, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no signature keys", ErrIncorrectKeySet)
+	}
+	return &KeySet{keys: keys}, nil
+}
+
+func (k *jwk) publicKey() (*publicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		if len(k.Alg) != 0 && k.Alg != algRS256 {
+			return nil, fmt.Errorf("unsupported algorithm %q", k.Alg)
+		}
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect modulus: %v", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect exponent: %v", err)
+		}
+		if n.BitLen() < 2048 || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("the key is weak or malformed")
+		}
+		return &publicKey{
+			kid: k.Kid,
+			alg: algRS256,
+			key: &rsa.PublicKey{N: n, E: int(e.Int64())},
+		}, nil
+	case "EC":
+		if len(k.Alg) != 0 && k.Alg != algES256 {
+			return nil, fmt.Errorf("unsupported algorithm %q", k.Alg)
+		}
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect x: %v", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect y: %v", err)
+		}
+		curve := elliptic.P256()
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("the point is not on the curve")
+		}
+		return &publicKey{
+			kid: k.Kid,
+			alg: algES256,
+			key: &ecdsa.PublicKey{Curve: curve, X: x, Y: y},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// The supported signature algorithms, RFC 7518.
+const (
+	algHS256 = "HS256"
+	algRS256 = "RS256"
+	algES256 = "ES256"
+)
+
+// minHS256SecretLen is the shortest HS256 secret, as long as the hash.
+const minHS256SecretLen = 32
+
+// DefaultLeeway is the clock skew tolerated checking the token lifetime.
+const DefaultLeeway = time.Minute
+
+var now = time.Now
+
+// VerifierConfig tells which tokens are accepted. The HS256 tokens are
+// verified with the secret, the RS256 and ES256 ones with the key set.
+type VerifierConfig struct {
+	HS256Secret []byte
+	KeySet      *KeySet
+	// Issuer and Audience are checked against the iss and the aud claims if
+	// not empty.
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+// Verifier verifies the JWT bearer tokens.
+type Verifier struct {
+	config VerifierConfig
+}
+
+func NewVerifier(c VerifierConfig) (*Verifier, error) {
+	if len(c.HS256Secret) == 0 && c.KeySet == nil {
+		return nil, fmt.Errorf("%w: neither an HS256 secret nor a key set is given", ErrIncorrectVerifier)
+	}
+	if len(c.HS256Secret) != 0 && len(c.HS256Secret) < minHS256SecretLen {
+		return nil, fmt.Errorf("%w: the HS256 secret must be at least %d bytes long", ErrIncorrectVerifier, minHS256SecretLen)
+	}
+	if c.Leeway < 0 {
+		return nil, fmt.Errorf("%w: the leeway must not be negative", ErrIncorrectVerifier)
+	}
+	return &Verifier{
+		config: c,
+	}, nil
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type tokenClaims struct {
+	Subject    string          `json:"sub"`
+	Issuer     string          `json:"iss"`
+	Audience   json.RawMessage `json:"aud"`
+	ExpiresAt  *float64        `json:"exp"`
+	NotBefore  *float64        `json:"nbf"`
+	Roles      []string        `json:"roles"`
+	EmployeeID int             `json:"employee_id"`
+}
+
+// Verify checks the signature and the claims of the token and returns its
+// principal. The tokens must expire and name their subject.
+func (v *Verifier) Verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a signed JWT", ErrIncorrectToken)
+	}
+	header := &tokenHeader{}
+	if err := decodeSegment(parts[0], header); err != nil {
+		return nil, fmt.Errorf("%w: incorrect header: %v", ErrIncorrectToken, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: incorrect signature: %v", ErrIncorrectToken, err)
+	}
+	if err := v.verifySignature(header, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+	claims := &tokenClaims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, fmt.Errorf("%w: incorrect claims: %v", ErrIncorrectToken, err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return &Principal{
+		Subject:    claims.Subject,
+		Roles:      claims.Roles,
+		EmployeeID: claims.EmployeeID,
+	}, nil
+}
+
+func (v *Verifier) verifySignature(h *tokenHeader, signed []byte, sig []byte) error {
+	switch h.Alg {
+	case algHS256:
+		if len(v.config.HS256Secret) == 0 {
+			break
+		}
+		mac := hmac.New(sha256.New, v.config.HS256Secret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return fmt.Errorf("%w: the signature does not match", ErrIncorrectToken)
+		}
+		return nil
+	case algRS256, algES256:
+		if v.config.KeySet == nil {
+			break
+		}
+		digest := sha256.Sum256(signed)
+		for _, k := range v.config.KeySet.keys {
+			if k.alg != h.Alg || (len(h.Kid) != 0 && k.kid != h.Kid) {
+				continue
+			}
+			if verifyWithKey(k, digest[:], sig) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: no key %q matches the signature", ErrIncorrectToken, h.Kid)
+	}
+	return fmt.Errorf("%w: unsupported algorithm %q", ErrIncorrectToken, h.Alg)
+}
+
+func verifyWithKey(k *publicKey, digest []byte, sig []byte) bool {
+	switch key := k.key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		// the signature is r and s of 32 bytes each, RFC 7518 section 3.4
+		if len(sig) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+func (v *Verifier) checkClaims(c *tokenClaims) error {
+	at := now()
+	leeway := v.config.Leeway
+	if c.ExpiresAt == nil {
+		return fmt.Errorf("%w: the token does not expire", ErrIncorrectToken)
+	}
+	if !at.Before(numericDate(*c.ExpiresAt).Add(leeway)) {
+		return fmt.Errorf("%w: the token has expired", ErrIncorrectToken)
+	}
+	if c.NotBefore != nil && at.Add(leeway).Before(numericDate(*c.NotBefore)) {
+		return fmt.Errorf("%w: the token is not valid yet", ErrIncorrectToken)
+	}
+	if len(c.Subject) == 0 {
+		return fmt.Errorf("%w: the token has no subject", ErrIncorrectToken)
+	}
+	if len(v.config.Issuer) != 0 && c.Issuer != v.config.Issuer {
+		return fmt.Errorf("%w: unexpected issuer %q", ErrIncorrectToken, c.Issuer)
+	}
+	if len(v.config.Audience) != 0 && !hasAudience(c.Audience, v.config.Audience) {
+		return fmt.Errorf("%w: the token is not meant for %q", ErrIncorrectToken, v.config.Audience)
+	}
+	return nil
+}
+
+// hasAudience tells if the aud claim, a string or an array of them, holds
+// the audience.
+func hasAudience(claim json.RawMessage, audience string) bool {
+	var single string
+	if err := json.Unmarshal(claim, &single); err == nil {
+		return single == audience
+	}
+	var many []string
+	if err := json.Unmarshal(claim, &many); err != nil {
+		return false
+	}
+	for _, a := range many {
+		if a == audience {
+			return true
+		}
+	}
+	return false
+}
+
+func numericDate(seconds float64) time.Time {
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second)))
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
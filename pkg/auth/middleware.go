@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	bearerPrefix = "Bearer "
+	// APIKeyHeader holds the API key of a service calling without a token.
+	APIKeyHeader = "X-API-Key"
+)
+
+// KeyAuthenticator authenticates the callers by their API keys. An unknown,
+// expired or revoked key is ErrIncorrectAPIKey.
+type KeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (*Principal, error)
+}
+
+// NewMiddleware authenticates the requests by the bearer tokens of the
+// Authorization header or, if keys is not nil, by the API keys of the
+// X-API-Key header, and puts the principal into the request context. The
+// requests that are not authenticated get 401, except for the requests to the
+// public paths, e.g. the health checks.
+func NewMiddleware(v *Verifier, keys KeyAuthenticator, publicPaths ...string) func(http.Handler) http.Handler {
+	public := make(map[string]struct{}, len(publicPaths))
+	for _, p := range publicPaths {
+		public[p] = struct{}{}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := public[r.URL.Path]; ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if key := r.Header.Get(APIKeyHeader); len(key) != 0 && keys != nil {
+				p, err := keys.Authenticate(r.Context(), key)
+				if err != nil {
+					if !errors.Is(err, ErrIncorrectAPIKey) {
+						log.Println("[ERR]: ", err)
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					log.Printf("[WARN]: rejected an API key: %v", err)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+				return
+			}
+			token, ok := bearerToken(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			p, err := v.Verify(token)
+			if err != nil {
+				log.Printf("[WARN]: rejected a bearer token: %v", err)
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	if len(h) <= len(bearerPrefix) || !strings.EqualFold(h[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(h[len(bearerPrefix):]), true
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// The resources the API keys are scoped to, a scope is a resource and an
+// access, e.g. "directory:read".
+const (
+	ResourceDirectory = "directory"
+	ResourceEmployees = "employees"
+	ResourceBudget    = "budget"
+	ResourcePositions = "positions"
+	ResourceRates     = "rates"
+	ResourceOrg       = "org"
+	ResourceReorgs    = "reorgs"
+	ResourceAudit     = "audit"
+)
+
+// The accesses to a resource, the write access includes the read one.
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+var resources = map[string]struct{}{
+	ResourceDirectory: {},
+	ResourceEmployees: {},
+	ResourceBudget:    {},
+	ResourcePositions: {},
+	ResourceRates:     {},
+	ResourceOrg:       {},
+	ResourceReorgs:    {},
+	ResourceAudit:     {},
+}
+
+// Scope returns the scope of the access to the resource.
+func Scope(resource string, access string) string {
+	return resource + ":" + access
+}
+
+// IsScope tells if s is a known scope.
+func IsScope(s string) bool {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return false
+	}
+	if _, ok := resources[s[:i]]; !ok {
+		return false
+	}
+	access := s[i+1:]
+	return access == AccessRead || access == AccessWrite
+}
+
+// HasAccess tells if the principal may access the resource. The users are
+// not restricted by scopes.
+func (p *Principal) HasAccess(resource string, access string) bool {
+	if p.APIKeyID == 0 {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == Scope(resource, access) || s == Scope(resource, AccessWrite) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope rejects with 403 the requests of the API keys not scoped to
+// the resource, the GET and HEAD requests need the read access and the
+// others the write one.
+func RequireScope(resource string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			access := AccessWrite
+			if r.Method == "GET" || r.Method == "HEAD" {
+				access = AccessRead
+			}
+			if p := FromContext(r.Context()); p == nil || !p.HasAccess(resource, access) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
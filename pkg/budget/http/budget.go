@@ -0,0 +1,262 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/budget/service"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+)
+
+type setBudgetRequest struct {
+	Budget money.Money `json:"budget"`
+}
+
+type copyForwardResponse struct {
+	Period storage.Period `json:"period"`
+	Copied int            `json:"copied"`
+}
+
+// ListBudgets lists the budgets of the period passed in the "period" query
+// parameter, the current period by default. The amounts are expressed in the
+// currency passed in the "currency" query parameter.
+func ListBudgets(w http.ResponseWriter, r *http.Request) {
+	period, ok := parsePeriod(w, r.URL.Query().Get("period"))
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	reports, err := service.ListBudgets(db, auth.FromContext(r.Context()), period, reportCurrency(r))
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reports)
+}
+
+// GetBudget returns the department's budget for the period, the current
+// period if the period is empty, in the "currency" query parameter currency.
+func GetBudget(w http.ResponseWriter, r *http.Request, departmentID string, period string) {
+	id, ok := parseID(w, departmentID)
+	if !ok {
+		return
+	}
+	p, ok := parsePeriod(w, period)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	report, err := service.GetBudget(db, auth.FromContext(r.Context()), id, p, reportCurrency(r))
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// SetBudget sets the department's budget for the period, the current period
+// if the period is empty.
+func SetBudget(w http.ResponseWriter, r *http.Request, departmentID string, period string) {
+	id, ok := parseID(w, departmentID)
+	if !ok {
+		return
+	}
+	p, ok := parsePeriod(w, period)
+	if !ok {
+		return
+	}
+	req := &setBudgetRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Printf("failed to decode the budget: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	report, err := service.SetBudget(db, auth.FromContext(r.Context()), id, p, req.Budget)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func CopyForward(w http.ResponseWriter, r *http.Request, period string) {
+	p, ok := parsePeriod(w, period)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	copied, err := service.CopyForward(db, p)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &copyForwardResponse{
+		Period: p,
+		Copied: copied,
+	})
+}
+
+// GetBudgetEvolution returns the department subtree budgets for the periods
+// between the "from" and "to" query parameters in the "currency" query
+// parameter currency. The last four quarters are returned by default.
+func GetBudgetEvolution(w http.ResponseWriter, r *http.Request, departmentID string) {
+	id, ok := parseID(w, departmentID)
+	if !ok {
+		return
+	}
+	to, ok := parsePeriod(w, r.URL.Query().Get("to"))
+	if !ok {
+		return
+	}
+	from := to
+	if fromParam := r.URL.Query().Get("from"); len(fromParam) != 0 {
+		if from, ok = parsePeriod(w, fromParam); !ok {
+			return
+		}
+	} else {
+		for i := 0; i < 3; i++ {
+			from = service.PrevPeriod(from)
+		}
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	points, err := service.GetBudgetEvolution(db, id, from, to, reportCurrency(r))
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, points)
+}
+
+// ListAlerts lists the budget alerts of the period passed in the "period"
+// query parameter, the current period by default. Only the unresolved alerts
+// are listed if the "active" query parameter is "true".
+func ListAlerts(w http.ResponseWriter, r *http.Request) {
+	period, ok := parsePeriod(w, r.URL.Query().Get("period"))
+	if !ok {
+		return
+	}
+	activeOnly := false
+	if active := r.URL.Query().Get("active"); len(active) != 0 {
+		var err error
+		activeOnly, err = strconv.ParseBool(active)
+		if err != nil {
+			log.Printf("incorrect active flag %q: %v", active, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	alerts, err := service.ListAlerts(db, auth.FromContext(r.Context()), period, activeOnly)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, alerts)
+}
+
+func parsePeriod(w http.ResponseWriter, period string) (storage.Period, bool) {
+	if len(period) == 0 {
+		return service.CurrentPeriod(), true
+	}
+	p, err := service.ParsePeriod(period)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return storage.Period{}, false
+	}
+	return p, true
+}
+
+// reportCurrency returns the currency requested in the "currency" query
+// parameter, service.DefaultReportCurrency by default.
+func reportCurrency(r *http.Request) string {
+	if currency := r.URL.Query().Get("currency"); len(currency) != 0 {
+		return currency
+	}
+	return service.DefaultReportCurrency
+}
+
+func parseID(w http.ResponseWriter, departmentID string) (int, bool) {
+	id, err := strconv.Atoi(departmentID)
+	if err != nil {
+		log.Printf("incorrect department ID %q: %v", departmentID, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func getDB(w http.ResponseWriter, r *http.Request) (storage.DB, bool) {
+	dbIface := r.Context().Value(storage.ContextKeyDB)
+	if dbIface == nil {
+		log.Println("DB is not found in the request context")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	db, ok := dbIface.(storage.DB)
+	if !ok {
+		log.Println("DB in the request context is not of type storage.DB")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return db, true
+}
+
+func writeErr(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, service.ErrIncorrectBudget),
+		errors.Is(err, service.ErrIncorrectPeriod),
+		errors.Is(err, service.ErrIncorrectCurrency):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, service.ErrPeriodClosed):
+		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, service.ErrMissingRate):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	case errors.Is(err, service.ErrDepartmentNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, policy.ErrForbidden):
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("failed to serialize the response to JSON: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(resp); err != nil {
+		log.Printf("failed to write the response body: %v", err)
+	}
+}
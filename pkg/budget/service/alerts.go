@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+)
+
+var ErrIncorrectThresholds = fmt.Errorf("got incorrect alert thresholds")
+
+// DefaultAlertThresholds are the budget utilization percents alerted on when
+// no thresholds are configured.
+var DefaultAlertThresholds = []int{80, 100}
+
+// Notifier delivers a freshly raised budget alert.
+type Notifier interface {
+	Notify(ctx context.Context, a *storage.Alert) error
+}
+
+// ParseAlertThresholds parses a comma-separated list of utilization percents,
+// e.g. "80,100".
+func ParseAlertThresholds(s string) ([]int, error) {
+	parts := strings.Split(s, ",")
+	thresholds := make([]int, 0, len(parts))
+	seen := make(map[int]struct{}, len(parts))
+	for _, p := range parts {
+		t, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(p), "%"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a number", ErrIncorrectThresholds, p)
+		}
+		if t <= 0 {
+			return nil, fmt.Errorf("%w: %d is not positive", ErrIncorrectThresholds, t)
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		thresholds = append(thresholds, t)
+	}
+	sort.Ints(thresholds)
+	return thresholds, nil
+}
+
+// ListAlerts returns the alerts of the period, the payrolls are hidden from
+// the callers who may not see them.
+func ListAlerts(db storage.DB, caller *auth.Principal, period storage.Period, activeOnly bool) ([]*storage.Alert, error) {
+	if err := validatePeriod(period); err != nil {
+		return nil, err
+	}
+	alerts, err := db.ListAlerts(context.Background(), period, activeOnly)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list budget alerts")
+	}
+	if !policy.NewActor(caller, nil).Can(policy.FieldPayroll, policy.ActionRead, 0) {
+		for _, a := range alerts {
+			a.PayrollHidden = true
+		}
+	}
+	return alerts, nil
+}
+
+// EvaluateAlerts compares the payroll of every budgeted department against its
+// budget for the current period in DefaultReportCurrency. Both are rolled up
+// over the department subtree, as in the reports, so that a budget set on a
+// parent department covers the payrolls of its descendants. An alert is raised
+// and passed to the notifiers for every threshold the utilization reaches,
+// unless an unresolved alert for the same threshold exists already. The alerts of the thresholds the utilization
+// dropped below are resolved, so that a new breach is reported again.
+func EvaluateAlerts(db storage.DB, thresholds []int, notifiers []Notifier) ([]*storage.Alert, error) {
+	ctx := context.Background()
+	period := CurrentPeriod()
+	reports, err := listReports(db, period, DefaultReportCurrency)
+	if err != nil {
+		return nil, err
+	}
+	active, err := db.ListAlerts(ctx, period, true)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list active budget alerts")
+	}
+	type alertKey struct {
+		department int
+		threshold  int
+	}
+	activeByKey := make(map[alertKey]*storage.Alert, len(active))
+	for _, a := range active {
+		activeByKey[alertKey{a.Department, a.Threshold}] = a
+	}
+
+	raised := make([]*storage.Alert, 0)
+	toResolve := make([]int, 0)
+	for _, r := range reports {
+		for _, t := range thresholds {
+			key := alertKey{r.Department, t}
+			breached := thresholdReached(r, t)
+			existing, isActive := activeByKey[key]
+			if !breached {
+				if isActive {
+					toResolve = append(toResolve, existing.ID)
+				}
+				continue
+			}
+			if isActive {
+				continue
+			}
+			a, err := db.CreateAlert(ctx, &storage.Alert{
+				Department:    r.Department,
+				FiscalYear:    period.Year,
+				FiscalQuarter: period.Quarter,
+				Threshold:     t,
+				Budget:        r.SubtreeBudget.Amount,
+				Payroll:       r.SubtreePayroll.Amount,
+				Currency:      r.SubtreeBudget.Currency,
+			})
+			if err != nil {
+				return nil, wrapDBErr(err, "failed to create a budget alert")
+			}
+			if a == nil {
+				// raised concurrently by another evaluation
+				continue
+			}
+			raised = append(raised, a)
+		}
+	}
+	if err := db.ResolveAlerts(ctx, toResolve); err != nil {
+		return nil, wrapDBErr(err, "failed to resolve budget alerts")
+	}
+
+	for _, a := range raised {
+		for _, n := range notifiers {
+			if err := n.Notify(ctx, a); err != nil {
+				log.Printf("failed to notify about budget alert %d: %v", a.ID, err)
+			}
+		}
+	}
+	return raised, nil
+}
+
+// thresholdReached reports whether the payroll of the department subtree is
+// at least threshold percent of the subtree budget. Departments without a
+// budget of their own never reach a threshold, their payrolls count towards
+// the budgeted ancestors. A zero budget is reached by any payroll.
+func thresholdReached(r *Report, threshold int) bool {
+	if r.Budget == nil {
+		return false
+	}
+	if r.SubtreeBudget.Amount == 0 {
+		return r.SubtreePayroll.Amount > 0
+	}
+	limit := new(big.Rat).Mul(r.SubtreeBudget.Amount.Rat(), big.NewRat(int64(threshold), 100))
+	return r.SubtreePayroll.Amount.Rat().Cmp(limit) >= 0
+}
+
+// Alerter evaluates the budget alerts on a schedule and whenever it is
+// triggered after salaries or budgets change.
+type Alerter struct {
+	newDB      func() (storage.DB, error)
+	thresholds []int
+	notifiers  []Notifier
+	trigger    chan struct{}
+}
+
+func NewAlerter(newDB func() (storage.DB, error), thresholds []int, notifiers []Notifier) *Alerter {
+	return &Alerter{
+		newDB:      newDB,
+		thresholds: thresholds,
+		notifiers:  notifiers,
+		trigger:    make(chan struct{}, 1),
+	}
+}
+
+// Trigger schedules an evaluation. Triggers arriving while an evaluation is
+// pending are coalesced into it.
+func (a *Alerter) Trigger() {
+	select {
+	case a.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run evaluates the alerts every interval and on every trigger until the
+// context is done.
+func (a *Alerter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-a.trigger:
+		}
+		if err := a.evaluate(); err != nil {
+			log.Printf("failed to evaluate budget alerts: %v", err)
+		}
+	}
+}
+
+func (a *Alerter) evaluate() error {
+	db, err := a.newDB()
+	if err != nil {
+		return fmt.Errorf("failed to open the DB: %w", err)
+	}
+	defer db.Close()
+	_, err = EvaluateAlerts(db, a.thresholds, a.notifiers)
+	return err
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+)
+
+func TestEvaluateAlerts(t *testing.T) {
+	setNow(t, time.Date(2021, time.August, 15, 0, 0, 0, 0, time.UTC))
+	mock := &dbMock{
+		t: t,
+		depts: []*storage.Department{
+			{ID: 1, ParentID: 1, Name: "R&D"},
+			{ID: 2, ParentID: 2, Name: "Sales"},
+			{ID: 3, ParentID: 3, Name: "Accounting"},
+		},
+		budgets: []*storage.Budget{
+			{Department: 1, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub("1000.00")},
+			{Department: 2, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub("1000.00")},
+		},
+		payrolls: []*storage.Payroll{
+			{Department: 1, Total: rub("850.00")},
+			{Department: 2, Total: rub("1000.00")},
+			{Department: 3, Total: rub("5000.00")},
+		},
+	}
+	notifier := &notifierMock{}
+	thresholds := []int{80, 100}
+
+	raised, err := EvaluateAlerts(mock, thresholds, []Notifier{notifier})
+	if err != nil {
+		t.Fatalf("EvaluateAlerts failed: %v", err)
+	}
+	expected := map[[2]int]struct{}{{1, 80}: {}, {2, 80}: {}, {2, 100}: {}}
+	if err := compareAlerts(expected, raised); err != nil {
+		t.Fatalf("first evaluation: %v", err)
+	}
+	if len(notifier.notified) != len(expected) {
+		t.Fatalf("expected %d notifications, got %d", len(expected), len(notifier.notified))
+	}
+
+	// the same breaches are not reported again
+	raised, err = EvaluateAlerts(mock, thresholds, []Notifier{notifier})
+	if err != nil {
+		t.Fatalf("EvaluateAlerts failed: %v", err)
+	}
+	if len(raised) != 0 {
+		t.Fatalf("expected the breaches to be suppressed, got %d alerts", len(raised))
+	}
+
+	// the payroll drops below the thresholds and raises again
+	mock.payrolls[1].Total = rub("500.00")
+	if _, err := EvaluateAlerts(mock, thresholds, []Notifier{notifier}); err != nil {
+		t.Fatalf("EvaluateAlerts failed: %v", err)
+	}
+	active, _ := mock.ListAlerts(context.Background(), CurrentPeriod(), true)
+	if len(active) != 1 || active[0].Department != 1 {
+		t.Fatalf("expected only the department 1 alert to stay active, got %d active alerts", len(active))
+	}
+	mock.payrolls[1].Total = rub("1200.00")
+	raised, err = EvaluateAlerts(mock, thresholds, []Notifier{notifier})
+	if err != nil {
+		t.Fatalf("EvaluateAlerts failed: %v", err)
+	}
+	expected = map[[2]int]struct{}{{2, 80}: {}, {2, 100}: {}}
+	if err := compareAlerts(expected, raised); err != nil {
+		t.Fatalf("evaluation after the raise: %v", err)
+	}
+}
+
+func TestEvaluateAlertsParentBudget(t *testing.T) {
+	setNow(t, time.Date(2021, time.August, 15, 0, 0, 0, 0, time.UTC))
+	mock := &dbMock{
+		t: t,
+		depts: []*storage.Department{
+			{ID: 1, ParentID: 1, Name: "R&D"},
+			{ID: 2, ParentID: 1, Name: "Backend"},
+			{ID: 3, ParentID: 1, Name: "Frontend"},
+			{ID: 4, ParentID: 3, Name: "Design"},
+		},
+		// the budget is planned for R&D as a whole and for Design alone
+		budgets: []*storage.Budget{
+			{Department: 1, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub("1000.00")},
+			{Department: 4, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub("500.00")},
+		},
+		payrolls: []*storage.Payroll{
+			{Department: 1, Total: rub("100.00")},
+			{Department: 2, Total: rub("700.00")},
+			{Department: 3, Total: rub("300.00")},
+			{Department: 4, Total: rub("200.00")},
+		},
+	}
+
+	raised, err := EvaluateAlerts(mock, []int{80, 100}, nil)
+	if err != nil {
+		t.Fatalf("EvaluateAlerts failed: %v", err)
+	}
+	expected := map[[2]int]struct{}{{1, 80}: {}}
+	if err := compareAlerts(expected, raised); err != nil {
+		t.Fatal(err)
+	}
+	a := raised[0]
+	if a.Budget != rub("1500.00").Amount || a.Payroll != rub("1300.00").Amount || a.Currency != "RUB" {
+		t.Errorf("expected the subtree budget 1500.00 RUB and payroll 1300.00 RUB, got %v and %v %s", a.Budget, a.Payroll, a.Currency)
+	}
+}
+
+func TestParseAlertThresholds(t *testing.T) {
+	cases := []struct {
+		Thresholds  string
+		Expected    []int
+		ExpectedErr error
+	}{
+		{Thresholds: "80,100", Expected: []int{80, 100}},
+		{Thresholds: "100%, 80%, 100", Expected: []int{80, 100}},
+		{Thresholds: "90", Expected: []int{90}},
+		{Thresholds: "", ExpectedErr: ErrIncorrectThresholds},
+		{Thresholds: "80,0", ExpectedErr: ErrIncorrectThresholds},
+		{Thresholds: "eighty", ExpectedErr: ErrIncorrectThresholds},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			thresholds, err := ParseAlertThresholds(tc.Thresholds)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr == nil && !reflect.DeepEqual(thresholds, tc.Expected) {
+				t.Errorf("expected %v, got %v", tc.Expected, thresholds)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	received := make(chan *storage.Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := &storage.Alert{}
+		if err := json.NewDecoder(r.Body).Decode(a); err != nil {
+			t.Errorf("failed to decode the webhook body: %v", err)
+		}
+		received <- a
+	}))
+	defer srv.Close()
+
+	alert := &storage.Alert{ID: 7, Department: 2, Threshold: 80, Budget: 100000, Payroll: 85000, Currency: "RUB"}
+	if err := NewWebhookNotifier(srv.URL).Notify(context.Background(), alert); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	a := <-received
+	if a.ID != alert.ID || a.Threshold != alert.Threshold || a.Payroll != alert.Payroll {
+		t.Errorf("expected alert %+v, got %+v", *alert, *a)
+	}
+}
+
+func compareAlerts(expected map[[2]int]struct{}, actual []*storage.Alert) error {
+	if len(actual) != len(expected) {
+		return fmt.Errorf("expected %d alerts, got %d", len(expected), len(actual))
+	}
+	for _, a := range actual {
+		if _, ok := expected[[2]int{a.Department, a.Threshold}]; !ok {
+			return fmt.Errorf("unexpected alert for department %d, threshold %d", a.Department, a.Threshold)
+		}
+	}
+	return nil
+}
+
+type notifierMock struct {
+	notified []*storage.Alert
+}
+
+func (n *notifierMock) Notify(ctx context.Context, a *storage.Alert) error {
+	n.notified = append(n.notified, a)
+	return nil
+}
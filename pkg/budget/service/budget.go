@@ -0,0 +1,417 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+	ratesService "github.com/SergeyShpak/gopher-corp-backend/pkg/rates/service"
+)
+
+var (
+	ErrIncorrectBudget    = fmt.Errorf("got an incorrect budget")
+	ErrDepartmentNotFound = fmt.Errorf("department not found")
+	ErrIncorrectCurrency  = fmt.Errorf("got an incorrect report currency")
+	ErrMissingRate        = fmt.Errorf("an amount cannot be converted to the report currency")
+	ErrDBRequestFailed    = fmt.Errorf("a request to DB failed")
+)
+
+// DefaultReportCurrency is the currency the budget reports are expressed in
+// when no other currency is requested.
+const DefaultReportCurrency = "RUB"
+
+const (
+	StatusNoBudget    = "no_budget"
+	StatusUnderBudget = "under_budget"
+	StatusOnBudget    = "on_budget"
+	StatusOverBudget  = "over_budget"
+)
+
+// Report compares a department's budget for a fiscal period against its
+// payroll in the period, see payrollDate. The Subtree* fields roll the department's descendants up
+// into the totals. All the amounts are converted to the report currency with
+// the exchange rates in effect at the end of the period.
+type Report struct {
+	Department     int            `json:"department"`
+	ParentID       int            `json:"parent_id"`
+	Name           string         `json:"name"`
+	Period         storage.Period `json:"period"`
+	Budget         *money.Money   `json:"budget"`
+	Payroll        money.Money    `json:"payroll"`
+	Status         string         `json:"status"`
+	SubtreeBudget  money.Money    `json:"subtree_budget"`
+	SubtreePayroll money.Money    `json:"subtree_payroll"`
+	SubtreeStatus  string         `json:"subtree_status"`
+	// PayrollHidden replaces the payrolls with null in the JSON for the
+	// callers who may not see them.
+	PayrollHidden bool `json:"-"`
+}
+
+func (r Report) MarshalJSON() ([]byte, error) {
+	type report Report
+	if !r.PayrollHidden {
+		return json.Marshal(report(r))
+	}
+	return json.Marshal(struct {
+		report
+		Payroll        *money.Money `json:"payroll"`
+		SubtreePayroll *money.Money `json:"subtree_payroll"`
+	}{
+		report: report(r),
+	})
+}
+
+// EvolutionPoint is the budget of a department subtree in a fiscal period.
+type EvolutionPoint struct {
+	Period        storage.Period `json:"period"`
+	Budget        *money.Money   `json:"budget"`
+	SubtreeBudget money.Money    `json:"subtree_budget"`
+}
+
+// SetBudget sets the department's budget for the period. Only the current and
+// the future periods can be planned, the budgets of the past ones are kept as
+// history. The finance of a department subtree may set the budgets in it.
+func SetBudget(db storage.DB, caller *auth.Principal, departmentID int, period storage.Period, budget money.Money) (*Report, error) {
+	if err := policy.NewActor(caller, nil).CheckIn(policy.EntityBudget, policy.ActionWrite, 0, departmentID); err != nil {
+		return nil, err
+	}
+	if err := checkPeriodOpen(period); err != nil {
+		return nil, err
+	}
+	if err := budget.Normalize(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIncorrectBudget, err)
+	}
+	if budget.Amount.Sign() < 0 {
+		return nil, fmt.Errorf("%w: %v is negative", ErrIncorrectBudget, budget)
+	}
+	if err := db.SetBudget(context.Background(), departmentID, period, budget); err != nil {
+		return nil, wrapDBErr(err, "failed to set the budget")
+	}
+	return GetBudget(db, caller, departmentID, period, budget.Currency)
+}
+
+// GetBudget returns the report of the department, see ListBudgets.
+func GetBudget(db storage.DB, caller *auth.Principal, departmentID int, period storage.Period, currency string) (*Report, error) {
+	reports, err := listReports(db, period, currency)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reports {
+		if r.Department == departmentID {
+			hidePayrolls(caller, r)
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: department %d", ErrDepartmentNotFound, departmentID)
+}
+
+// ListBudgets returns the reports of all the departments for the period
+// expressed in the currency. The payrolls are hidden from the callers who may
+// not see them, e.g. the managers, the statuses are not.
+func ListBudgets(db storage.DB, caller *auth.Principal, period storage.Period, currency string) ([]*Report, error) {
+	reports, err := listReports(db, period, currency)
+	if err != nil {
+		return nil, err
+	}
+	hidePayrolls(caller, reports...)
+	return reports, nil
+}
+
+func hidePayrolls(caller *auth.Principal, reports ...*Report) {
+	if policy.NewActor(caller, nil).Can(policy.FieldPayroll, policy.ActionRead, 0) {
+		return
+	}
+	for _, r := range reports {
+		r.PayrollHidden = true
+	}
+}
+
+func listReports(db storage.DB, period storage.Period, currency string) ([]*Report, error) {
+	if err := validatePeriod(period); err != nil {
+		return nil, err
+	}
+	currency, err := normalizeCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	depts, err := db.ListDepartments(ctx)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list departments")
+	}
+	budgets, err := db.ListBudgets(ctx, period)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list budgets")
+	}
+	payrolls, err := db.ListPayrolls(ctx, periodStart(period), payrollDate(period))
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list payrolls")
+	}
+	conv, err := loadConverter(db, period)
+	if err != nil {
+		return nil, err
+	}
+	return buildReports(period, currency, conv, depts, budgets, payrolls)
+}
+
+// CopyForward copies the budgets of the period preceding the given one to it.
+// The departments which already have a budget for the period keep it.
+func CopyForward(db storage.DB, period storage.Period) (int, error) {
+	if err := checkPeriodOpen(period); err != nil {
+		return 0, err
+	}
+	copied, err := db.CopyBudgets(context.Background(), PrevPeriod(period), period)
+	if err != nil {
+		return 0, wrapDBErr(err, "failed to copy the budgets forward")
+	}
+	return copied, nil
+}
+
+// GetBudgetEvolution returns the budgets of the department and of its
+// subtree for every period from..to expressed in the currency.
+func GetBudgetEvolution(db storage.DB, departmentID int, from storage.Period, to storage.Period, currency string) ([]*EvolutionPoint, error) {
+	if err := validatePeriod(from); err != nil {
+		return nil, err
+	}
+	if err := validatePeriod(to); err != nil {
+		return nil, err
+	}
+	currency, err := normalizeCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+	span := periodIndex(to) - periodIndex(from) + 1
+	if span <= 0 || span > maxEvolutionPeriods {
+		return nil, fmt.Errorf(
+			"%w: %s..%s must span from 1 to %d periods",
+			ErrIncorrectPeriod, FormatPeriod(from), FormatPeriod(to), maxEvolutionPeriods,
+		)
+	}
+	ctx := context.Background()
+	depts, err := db.ListDepartments(ctx)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list departments")
+	}
+	budgets, err := db.ListBudgetsInRange(ctx, from, to)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list budgets")
+	}
+	byPeriod := make(map[storage.Period][]*storage.Budget)
+	for _, b := range budgets {
+		byPeriod[b.Period()] = append(byPeriod[b.Period()], b)
+	}
+
+	points := make([]*EvolutionPoint, 0, span)
+	for p := from; periodIndex(p) <= periodIndex(to); p = NextPeriod(p) {
+		conv, err := loadConverter(db, p)
+		if err != nil {
+			return nil, err
+		}
+		reports, err := buildReports(p, currency, conv, depts, byPeriod[p], nil)
+		if err != nil {
+			return nil, err
+		}
+		var report *Report
+		for _, r := range reports {
+			if r.Department == departmentID {
+				report = r
+				break
+			}
+		}
+		if report == nil {
+			return nil, fmt.Errorf("%w: department %d", ErrDepartmentNotFound, departmentID)
+		}
+		points = append(points, &EvolutionPoint{
+			Period:        p,
+			Budget:        report.Budget,
+			SubtreeBudget: report.SubtreeBudget,
+		})
+	}
+	return points, nil
+}
+
+func checkPeriodOpen(period storage.Period) error {
+	if err := validatePeriod(period); err != nil {
+		return err
+	}
+	current := CurrentPeriod()
+	if periodIndex(period) < periodIndex(current) {
+		return fmt.Errorf(
+			"%w: %s is before the current period %s",
+			ErrPeriodClosed, FormatPeriod(period), FormatPeriod(current),
+		)
+	}
+	return nil
+}
+
+type node struct {
+	dept           *storage.Department
+	budget         *money.Money
+	payroll        money.Money
+	subtreeBudget  *money.Money
+	subtreePayroll money.Money
+	// subtreeBudgeted is set if at least one department of the subtree has a
+	// budget
+	subtreeBudgeted bool
+	children        []*node
+}
+
+// buildReports expresses every amount in the currency, the amounts that conv
+// cannot convert result in ErrMissingRate.
+func buildReports(
+	period storage.Period,
+	currency string,
+	conv *ratesService.Converter,
+	depts []*storage.Department,
+	budgets []*storage.Budget,
+	payrolls []*storage.Payroll,
+) ([]*Report, error) {
+	nodes := make(map[int]*node, len(depts))
+	for _, d := range depts {
+		nodes[d.ID] = &node{
+			dept:    d,
+			payroll: money.New(0, currency),
+		}
+	}
+	for _, b := range budgets {
+		n, ok := nodes[b.Department]
+		if !ok {
+			continue
+		}
+		budget, err := conv.Convert(b.Budget, currency)
+		if err != nil {
+			return nil, fmt.Errorf("%w: department %d budget: %v", ErrMissingRate, b.Department, err)
+		}
+		n.budget = &budget
+	}
+	for _, p := range payrolls {
+		n, ok := nodes[p.Department]
+		if !ok {
+			continue
+		}
+		total, err := conv.Convert(p.Total, currency)
+		if err != nil {
+			return nil, fmt.Errorf("%w: department %d payroll: %v", ErrMissingRate, p.Department, err)
+		}
+		if n.payroll, err = n.payroll.Add(total); err != nil {
+			return nil, fmt.Errorf("%w: department %d payroll: %v", ErrMissingRate, p.Department, err)
+		}
+	}
+	for _, d := range depts {
+		if d.ParentID == d.ID {
+			continue
+		}
+		if parent, ok := nodes[d.ParentID]; ok {
+			parent.children = append(parent.children, nodes[d.ID])
+		}
+	}
+
+	reports := make([]*Report, 0, len(depts))
+	for _, d := range depts {
+		n := nodes[d.ID]
+		if err := rollUp(n, make(map[int]struct{})); err != nil {
+			return nil, fmt.Errorf("%w: department %d subtree: %v", ErrMissingRate, d.ID, err)
+		}
+		r := &Report{
+			Department:     d.ID,
+			ParentID:       d.ParentID,
+			Name:           d.Name,
+			Period:         period,
+			Budget:         n.budget,
+			Payroll:        n.payroll,
+			Status:         compare(n.budget, n.payroll),
+			SubtreeBudget:  *n.subtreeBudget,
+			SubtreePayroll: n.subtreePayroll,
+		}
+		if n.subtreeBudgeted {
+			r.SubtreeStatus = compare(n.subtreeBudget, n.subtreePayroll)
+		} else {
+			r.SubtreeStatus = StatusNoBudget
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+// rollUp computes the subtree totals of n. Departments that are part of a
+// parent_id cycle are counted once.
+func rollUp(n *node, visiting map[int]struct{}) error {
+	if n.subtreeBudget != nil {
+		return nil
+	}
+	visiting[n.dept.ID] = struct{}{}
+	budget := money.New(0, n.payroll.Currency)
+	if n.budget != nil {
+		budget = *n.budget
+		n.subtreeBudgeted = true
+	}
+	payroll := n.payroll
+	for _, c := range n.children {
+		if _, ok := visiting[c.dept.ID]; ok {
+			continue
+		}
+		if err := rollUp(c, visiting); err != nil {
+			return err
+		}
+		var err error
+		if budget, err = budget.Add(*c.subtreeBudget); err != nil {
+			return err
+		}
+		if payroll, err = payroll.Add(c.subtreePayroll); err != nil {
+			return err
+		}
+		n.subtreeBudgeted = n.subtreeBudgeted || c.subtreeBudgeted
+	}
+	delete(visiting, n.dept.ID)
+	n.subtreeBudget = &budget
+	n.subtreePayroll = payroll
+	return nil
+}
+
+func compare(budget *money.Money, payroll money.Money) string {
+	if budget == nil {
+		return StatusNoBudget
+	}
+	switch {
+	case payroll.Amount < budget.Amount:
+		return StatusUnderBudget
+	case payroll.Amount > budget.Amount:
+		return StatusOverBudget
+	default:
+		return StatusOnBudget
+	}
+}
+
+// loadConverter converts with the exchange rates in effect on the last day of
+// the period.
+func loadConverter(db storage.DB, period storage.Period) (*ratesService.Converter, error) {
+	rates, err := db.ListRates(context.Background(), periodEnd(period))
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list exchange rates")
+	}
+	conv, err := ratesService.NewConverter(rates)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDBRequestFailed, err)
+	}
+	return conv, nil
+}
+
+func normalizeCurrency(currency string) (string, error) {
+	m := money.New(0, currency)
+	if err := m.Normalize(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrIncorrectCurrency, err)
+	}
+	return m.Currency, nil
+}
+
+func wrapDBErr(err error, msg string) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%w: %s: %v", ErrDepartmentNotFound, msg, err)
+	}
+	return fmt.Errorf("%w: %s: %v", ErrDBRequestFailed, msg, err)
+}
@@ -0,0 +1,610 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+	ratesStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/rates/storage"
+)
+
+var financePrincipal = &auth.Principal{Subject: "finance", Roles: []string{"finance"}}
+
+func TestListBudgets(t *testing.T) {
+	mock := &dbMock{
+		t: t,
+		depts: []*storage.Department{
+			{ID: 0, ParentID: 0, Name: "root"},
+			{ID: 1, ParentID: 0, Name: "R&D"},
+			{ID: 2, ParentID: 1, Name: "Backend"},
+			{ID: 3, ParentID: 1, Name: "Frontend"},
+			{ID: 4, ParentID: 0, Name: "Sales"},
+		},
+		budgets: []*storage.Budget{
+			{Department: 1, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub("100000.00")},
+			{Department: 2, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub("200000.10")},
+			{Department: 3, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub("50000.00")},
+		},
+		payrolls: []*storage.Payroll{
+			{Department: 1, Total: rub("100000.00")},
+			{Department: 2, Total: rub("150000.05")},
+			{Department: 3, Total: rub("60000.00")},
+			{Department: 4, Total: rub("10.01")},
+		},
+	}
+	expected := map[int]Report{
+		0: {
+			Payroll:        rub("0.00"),
+			Status:         StatusNoBudget,
+			SubtreeBudget:  rub("350000.10"),
+			SubtreePayroll: rub("310010.06"),
+			SubtreeStatus:  StatusUnderBudget,
+		},
+		1: {
+			Payroll:        rub("100000.00"),
+			Status:         StatusOnBudget,
+			SubtreeBudget:  rub("350000.10"),
+			SubtreePayroll: rub("310000.05"),
+			SubtreeStatus:  StatusUnderBudget,
+		},
+		2: {
+			Payroll:        rub("150000.05"),
+			Status:         StatusUnderBudget,
+			SubtreeBudget:  rub("200000.10"),
+			SubtreePayroll: rub("150000.05"),
+			SubtreeStatus:  StatusUnderBudget,
+		},
+		3: {
+			Payroll:        rub("60000.00"),
+			Status:         StatusOverBudget,
+			SubtreeBudget:  rub("50000.00"),
+			SubtreePayroll: rub("60000.00"),
+			SubtreeStatus:  StatusOverBudget,
+		},
+		4: {
+			Payroll:        rub("10.01"),
+			Status:         StatusNoBudget,
+			SubtreeBudget:  rub("0.00"),
+			SubtreePayroll: rub("10.01"),
+			SubtreeStatus:  StatusNoBudget,
+		},
+	}
+
+	reports, err := ListBudgets(mock, financePrincipal, storage.Period{Year: 2021, Quarter: 3}, "RUB")
+	if err != nil {
+		t.Fatalf("ListBudgets failed: %v", err)
+	}
+	if len(reports) != len(expected) {
+		t.Fatalf("expected %d reports, got %d", len(expected), len(reports))
+	}
+	for _, r := range reports {
+		e := expected[r.Department]
+		actual := *r
+		actual.Department, actual.ParentID, actual.Name, actual.Period, actual.Budget = 0, 0, "", storage.Period{}, nil
+		if actual != e {
+			t.Errorf("department %d: expected: %+v, got: %+v", r.Department, e, actual)
+		}
+	}
+}
+
+func TestListBudgetsHidesPayrolls(t *testing.T) {
+	mock := &dbMock{
+		t:     t,
+		depts: []*storage.Department{{ID: 1, ParentID: 0, Name: "R&D"}},
+		budgets: []*storage.Budget{
+			{Department: 1, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub("100000.00")},
+		},
+		payrolls: []*storage.Payroll{{Department: 1, Total: rub("150000.00")}},
+	}
+	cases := []struct {
+		Principal       *auth.Principal
+		ExpectedHidden  bool
+		ExpectedPayroll string
+	}{
+		{
+			Principal:       financePrincipal,
+			ExpectedPayroll: `{"amount":"150000.00","currency":"RUB"}`,
+		},
+		{
+			Principal:       &auth.Principal{Subject: "hr", Roles: []string{"hr"}},
+			ExpectedPayroll: `{"amount":"150000.00","currency":"RUB"}`,
+		},
+		{
+			Principal:       &auth.Principal{Subject: "gcole", EmployeeID: 1, Roles: []string{"manager"}},
+			ExpectedHidden:  true,
+			ExpectedPayroll: `null`,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			reports, err := ListBudgets(mock, tc.Principal, storage.Period{Year: 2021, Quarter: 3}, "RUB")
+			if err != nil {
+				t.Fatalf("ListBudgets failed: %v", err)
+			}
+			r := reports[0]
+			if r.PayrollHidden != tc.ExpectedHidden {
+				t.Errorf("expected the payroll to be hidden: %v, got: %v", tc.ExpectedHidden, r.PayrollHidden)
+			}
+			if r.Status != StatusOverBudget {
+				t.Errorf("expected status %s, got %s", StatusOverBudget, r.Status)
+			}
+			data, err := json.Marshal(r)
+			if err != nil {
+				t.Fatalf("failed to marshal the report: %v", err)
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatalf("failed to unmarshal the report: %v", err)
+			}
+			for _, f := range []string{"payroll", "subtree_payroll"} {
+				if string(fields[f]) != tc.ExpectedPayroll {
+					t.Errorf("expected %s %s, got %s", f, tc.ExpectedPayroll, fields[f])
+				}
+			}
+		})
+	}
+}
+
+func TestListBudgetsPayrollDate(t *testing.T) {
+	setNow(t, time.Date(2021, time.August, 15, 12, 0, 0, 0, time.UTC))
+	cases := []struct {
+		Period       storage.Period
+		ExpectedFrom time.Time
+		ExpectedTo   time.Time
+	}{
+		{
+			Period:       storage.Period{Year: 2021, Quarter: 2},
+			ExpectedFrom: time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC),
+			ExpectedTo:   time.Date(2021, time.June, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Period:       storage.Period{Year: 2021, Quarter: 3},
+			ExpectedFrom: time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC),
+			ExpectedTo:   time.Date(2021, time.August, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Period:       storage.Period{Year: 2021, Quarter: 4},
+			ExpectedFrom: time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC),
+			ExpectedTo:   time.Date(2021, time.August, 15, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t:     t,
+				depts: []*storage.Department{{ID: 1, ParentID: 1, Name: "R&D"}},
+			}
+			if _, err := ListBudgets(mock, financePrincipal, tc.Period, "RUB"); err != nil {
+				t.Fatalf("ListBudgets failed: %v", err)
+			}
+			if !mock.payrollsFrom.Equal(tc.ExpectedFrom) || !mock.payrollsTo.Equal(tc.ExpectedTo) {
+				t.Errorf(
+					"expected the payrolls from %v to %v, got from %v to %v",
+					tc.ExpectedFrom, tc.ExpectedTo, mock.payrollsFrom, mock.payrollsTo,
+				)
+			}
+		})
+	}
+}
+
+func TestListBudgetsConversion(t *testing.T) {
+	mock := &dbMock{
+		t: t,
+		depts: []*storage.Department{
+			{ID: 0, ParentID: 0, Name: "root"},
+			{ID: 1, ParentID: 0, Name: "R&D"},
+		},
+		budgets: []*storage.Budget{
+			{Department: 0, FiscalYear: 2021, FiscalQuarter: 3, Budget: money.New(100000, "USD")},
+			{Department: 1, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub("50000.00")},
+		},
+		payrolls: []*storage.Payroll{
+			{Department: 0, Total: money.New(10000, "EUR")},
+			{Department: 1, Total: rub("7000.00")},
+			{Department: 1, Total: money.New(10000, "USD")},
+		},
+		rates: []*ratesStorage.Rate{
+			{From: "USD", To: "RUB", Rate: "70", EffectiveDate: time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+			{From: "USD", To: "RUB", Rate: "75", EffectiveDate: time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC)},
+			{From: "USD", To: "RUB", Rate: "80", EffectiveDate: time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC)},
+			{From: "EUR", To: "USD", Rate: "1.2", EffectiveDate: time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	reports, err := ListBudgets(mock, financePrincipal, storage.Period{Year: 2021, Quarter: 3}, "usd")
+	if err != nil {
+		t.Fatalf("ListBudgets failed: %v", err)
+	}
+	expected := map[int]struct {
+		Budget         money.Money
+		Payroll        money.Money
+		SubtreePayroll money.Money
+	}{
+		0: {Budget: money.New(100000, "USD"), Payroll: money.New(12000, "USD"), SubtreePayroll: money.New(31333, "USD")},
+		1: {Budget: money.New(66667, "USD"), Payroll: money.New(19333, "USD"), SubtreePayroll: money.New(19333, "USD")},
+	}
+	for _, r := range reports {
+		e := expected[r.Department]
+		if r.Budget == nil || *r.Budget != e.Budget || r.Payroll != e.Payroll || r.SubtreePayroll != e.SubtreePayroll {
+			t.Errorf("department %d: expected: %+v, got: %+v (budget %v)", r.Department, e, r, r.Budget)
+		}
+	}
+
+	mock.payrolls = append(mock.payrolls, &storage.Payroll{Department: 1, Total: money.New(10000, "GBP")})
+	_, err = ListBudgets(mock, financePrincipal, storage.Period{Year: 2021, Quarter: 3}, "USD")
+	if err := compareErrs(ErrMissingRate, err); err != nil {
+		t.Error(err)
+	}
+	_, err = ListBudgets(mock, financePrincipal, storage.Period{Year: 2021, Quarter: 3}, "dollars")
+	if err := compareErrs(ErrIncorrectCurrency, err); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetBudget(t *testing.T) {
+	setNow(t, time.Date(2021, time.August, 15, 0, 0, 0, 0, time.UTC))
+	current := storage.Period{Year: 2021, Quarter: 3}
+	cases := []struct {
+		Period      storage.Period
+		Principal   *auth.Principal
+		Budget      money.Money
+		MockErr     error
+		ExpectedErr error
+	}{
+		{
+			Budget: rub("1000.50"),
+		},
+		{
+			Principal: &auth.Principal{
+				Subject:         "finance-partner",
+				DepartmentRoles: map[string]map[int]struct{}{"finance": {1: {}}},
+			},
+			Budget: rub("1000.50"),
+		},
+		{
+			Principal: &auth.Principal{
+				Subject:         "finance-partner",
+				DepartmentRoles: map[string]map[int]struct{}{"finance": {2: {}}},
+			},
+			Budget:      rub("1000.50"),
+			ExpectedErr: policy.ErrForbidden,
+		},
+		{
+			Principal:   &auth.Principal{Subject: "gcole", EmployeeID: 1, Roles: []string{"manager"}},
+			Budget:      rub("1000.50"),
+			ExpectedErr: policy.ErrForbidden,
+		},
+		{
+			Period: storage.Period{Year: 2022, Quarter: 1},
+			Budget: rub("1000.50"),
+		},
+		{
+			Period:      storage.Period{Year: 2021, Quarter: 2},
+			Budget:      rub("1000.50"),
+			ExpectedErr: ErrPeriodClosed,
+		},
+		{
+			Period:      storage.Period{Year: 2021, Quarter: 5},
+			Budget:      rub("1000.50"),
+			ExpectedErr: ErrIncorrectPeriod,
+		},
+		{
+			Budget:      rub("-1000"),
+			ExpectedErr: ErrIncorrectBudget,
+		},
+		{
+			Budget:      money.New(100000, "RU"),
+			ExpectedErr: ErrIncorrectBudget,
+		},
+		{
+			Budget:      rub("1000"),
+			MockErr:     fmt.Errorf("%w: department 1", storage.ErrNotFound),
+			ExpectedErr: ErrDepartmentNotFound,
+		},
+		{
+			Budget:      rub("1000"),
+			MockErr:     fmt.Errorf("some err"),
+			ExpectedErr: ErrDBRequestFailed,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t:             t,
+				depts:         []*storage.Department{{ID: 1}},
+				expectedError: tc.MockErr,
+			}
+			period := tc.Period
+			if period == (storage.Period{}) {
+				period = current
+			}
+			caller := tc.Principal
+			if caller == nil {
+				caller = &auth.Principal{Subject: "finance", Roles: []string{"finance"}}
+			}
+			report, err := SetBudget(mock, caller, 1, period, tc.Budget)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				return
+			}
+			if report.Budget == nil || *report.Budget != tc.Budget {
+				t.Errorf("expected budget %v, got %v", tc.Budget, report.Budget)
+			}
+			if report.Period != period {
+				t.Errorf("expected period %v, got %v", period, report.Period)
+			}
+		})
+	}
+}
+
+func TestCopyForward(t *testing.T) {
+	setNow(t, time.Date(2021, time.August, 15, 0, 0, 0, 0, time.UTC))
+	mock := &dbMock{
+		t: t,
+		budgets: []*storage.Budget{
+			{Department: 1, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub("100.00")},
+			{Department: 2, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub("200.00")},
+			{Department: 2, FiscalYear: 2021, FiscalQuarter: 4, Budget: rub("250.00")},
+		},
+	}
+	copied, err := CopyForward(mock, storage.Period{Year: 2021, Quarter: 4})
+	if err != nil {
+		t.Fatalf("CopyForward failed: %v", err)
+	}
+	if copied != 1 {
+		t.Fatalf("expected 1 copied budget, got %d", copied)
+	}
+	budgets, _ := mock.ListBudgets(context.Background(), storage.Period{Year: 2021, Quarter: 4})
+	expected := map[int]money.Money{1: rub("100.00"), 2: rub("250.00")}
+	if len(budgets) != len(expected) {
+		t.Fatalf("expected %d budgets, got %d", len(expected), len(budgets))
+	}
+	for _, b := range budgets {
+		if expected[b.Department] != b.Budget {
+			t.Errorf("department %d: expected budget %v, got %v", b.Department, expected[b.Department], b.Budget)
+		}
+	}
+
+	if _, err := CopyForward(mock, storage.Period{Year: 2021, Quarter: 2}); !errors.Is(err, ErrPeriodClosed) {
+		t.Errorf("expected error %v, got %v", ErrPeriodClosed, err)
+	}
+}
+
+func TestGetBudgetEvolution(t *testing.T) {
+	mock := &dbMock{
+		t: t,
+		depts: []*storage.Department{
+			{ID: 0, ParentID: 0, Name: "root"},
+			{ID: 1, ParentID: 0, Name: "R&D"},
+			{ID: 2, ParentID: 1, Name: "Backend"},
+		},
+		budgets: []*storage.Budget{
+			{Department: 1, FiscalYear: 2020, FiscalQuarter: 4, Budget: rub("100.00")},
+			{Department: 2, FiscalYear: 2020, FiscalQuarter: 4, Budget: rub("50.00")},
+			{Department: 2, FiscalYear: 2021, FiscalQuarter: 2, Budget: rub("70.00")},
+			{Department: 1, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub("10.00")},
+		},
+	}
+	points, err := GetBudgetEvolution(mock, 1, storage.Period{Year: 2020, Quarter: 4}, storage.Period{Year: 2021, Quarter: 2}, "RUB")
+	if err != nil {
+		t.Fatalf("GetBudgetEvolution failed: %v", err)
+	}
+	expected := []struct {
+		Period        storage.Period
+		Budget        *money.Money
+		SubtreeBudget money.Money
+	}{
+		{Period: storage.Period{Year: 2020, Quarter: 4}, Budget: rubPtr("100.00"), SubtreeBudget: rub("150.00")},
+		{Period: storage.Period{Year: 2021, Quarter: 1}, SubtreeBudget: rub("0.00")},
+		{Period: storage.Period{Year: 2021, Quarter: 2}, SubtreeBudget: rub("70.00")},
+	}
+	if len(points) != len(expected) {
+		t.Fatalf("expected %d points, got %d", len(expected), len(points))
+	}
+	for i, e := range expected {
+		p := points[i]
+		budgetsEqual := (p.Budget == nil && e.Budget == nil) ||
+			(p.Budget != nil && e.Budget != nil && *p.Budget == *e.Budget)
+		if p.Period != e.Period || !budgetsEqual || p.SubtreeBudget != e.SubtreeBudget {
+			t.Errorf("point %d: expected %+v, got %+v (budget %v)", i, e, *p, p.Budget)
+		}
+	}
+
+	_, err = GetBudgetEvolution(mock, 1, storage.Period{Year: 2021, Quarter: 2}, storage.Period{Year: 2020, Quarter: 4}, "RUB")
+	if !errors.Is(err, ErrIncorrectPeriod) {
+		t.Errorf("expected error %v for a reversed range, got %v", ErrIncorrectPeriod, err)
+	}
+	_, err = GetBudgetEvolution(mock, 42, storage.Period{Year: 2020, Quarter: 4}, storage.Period{Year: 2021, Quarter: 2}, "RUB")
+	if !errors.Is(err, ErrDepartmentNotFound) {
+		t.Errorf("expected error %v for an unknown department, got %v", ErrDepartmentNotFound, err)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	cases := []struct {
+		Period      string
+		Expected    storage.Period
+		ExpectedErr error
+	}{
+		{Period: "2021Q3", Expected: storage.Period{Year: 2021, Quarter: 3}},
+		{Period: "2021-q1", Expected: storage.Period{Year: 2021, Quarter: 1}},
+		{Period: "2021Q5", ExpectedErr: ErrIncorrectPeriod},
+		{Period: "21Q1", ExpectedErr: ErrIncorrectPeriod},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			p, err := ParsePeriod(tc.Period)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if p != tc.Expected {
+				t.Errorf("expected %v, got %v", tc.Expected, p)
+			}
+		})
+	}
+}
+
+func setNow(t *testing.T, ts time.Time) {
+	oldNow := now
+	now = func() time.Time {
+		return ts
+	}
+	t.Cleanup(func() {
+		now = oldNow
+	})
+}
+
+func compareErrs(expectedErr error, actualErr error) error {
+	if expectedErr == nil && actualErr == nil {
+		return nil
+	}
+	if actualErr == nil {
+		return fmt.Errorf("expected an error \"%v\", got nil", expectedErr)
+	}
+	if !errors.Is(actualErr, expectedErr) {
+		return fmt.Errorf("expected error \"%v\" and actual error \"%v\" are different", expectedErr, actualErr)
+	}
+	return nil
+}
+
+func rub(amount string) money.Money {
+	m, err := money.Parse(amount, "RUB")
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func rubPtr(amount string) *money.Money {
+	m := rub(amount)
+	return &m
+}
+
+type dbMock struct {
+	t             *testing.T
+	depts         []*storage.Department
+	budgets       []*storage.Budget
+	payrolls      []*storage.Payroll
+	payrollsFrom  time.Time
+	payrollsTo    time.Time
+	alerts        []*storage.Alert
+	rates         []*ratesStorage.Rate
+	expectedError error
+}
+
+func (db *dbMock) SetBudget(ctx context.Context, departmentID int, period storage.Period, budget money.Money) error {
+	if db.expectedError != nil {
+		return db.expectedError
+	}
+	db.budgets = append(db.budgets, &storage.Budget{
+		Department:    departmentID,
+		FiscalYear:    period.Year,
+		FiscalQuarter: period.Quarter,
+		Budget:        budget,
+	})
+	return nil
+}
+
+func (db *dbMock) ListDepartments(ctx context.Context) ([]*storage.Department, error) {
+	return db.depts, nil
+}
+
+func (db *dbMock) ListBudgets(ctx context.Context, period storage.Period) ([]*storage.Budget, error) {
+	return db.ListBudgetsInRange(ctx, period, period)
+}
+
+func (db *dbMock) ListBudgetsInRange(ctx context.Context, from storage.Period, to storage.Period) ([]*storage.Budget, error) {
+	budgets := make([]*storage.Budget, 0)
+	for _, b := range db.budgets {
+		if periodIndex(b.Period()) >= periodIndex(from) && periodIndex(b.Period()) <= periodIndex(to) {
+			budgets = append(budgets, b)
+		}
+	}
+	return budgets, nil
+}
+
+func (db *dbMock) CopyBudgets(ctx context.Context, from storage.Period, to storage.Period) (int, error) {
+	existing := make(map[int]struct{})
+	for _, b := range db.budgets {
+		if b.Period() == to {
+			existing[b.Department] = struct{}{}
+		}
+	}
+	copied := 0
+	for _, b := range db.budgets {
+		if b.Period() != from {
+			continue
+		}
+		if _, ok := existing[b.Department]; ok {
+			continue
+		}
+		db.budgets = append(db.budgets, &storage.Budget{
+			Department:    b.Department,
+			FiscalYear:    to.Year,
+			FiscalQuarter: to.Quarter,
+			Budget:        b.Budget,
+		})
+		copied++
+	}
+	return copied, nil
+}
+
+func (db *dbMock) ListPayrolls(ctx context.Context, from time.Time, to time.Time) ([]*storage.Payroll, error) {
+	db.payrollsFrom, db.payrollsTo = from, to
+	return db.payrolls, nil
+}
+
+func (db *dbMock) ListRates(ctx context.Context, on time.Time) ([]*ratesStorage.Rate, error) {
+	rates := make([]*ratesStorage.Rate, 0, len(db.rates))
+	for _, r := range db.rates {
+		if !r.EffectiveDate.After(on) {
+			rates = append(rates, r)
+		}
+	}
+	return rates, nil
+}
+
+func (db *dbMock) ListAlerts(ctx context.Context, period storage.Period, activeOnly bool) ([]*storage.Alert, error) {
+	alerts := make([]*storage.Alert, 0)
+	for _, a := range db.alerts {
+		if a.FiscalYear != period.Year || a.FiscalQuarter != period.Quarter {
+			continue
+		}
+		if activeOnly && a.ResolvedAt != nil {
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, nil
+}
+
+func (db *dbMock) CreateAlert(ctx context.Context, a *storage.Alert) (*storage.Alert, error) {
+	created := *a
+	created.ID = len(db.alerts) + 1
+	db.alerts = append(db.alerts, &created)
+	return &created, nil
+}
+
+func (db *dbMock) ResolveAlerts(ctx context.Context, ids []int) error {
+	resolvedAt := now()
+	for _, id := range ids {
+		db.alerts[id-1].ResolvedAt = &resolvedAt
+	}
+	return nil
+}
+
+func (db *dbMock) Close() {}
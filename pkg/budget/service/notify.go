@@ -0,0 +1,62 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+)
+
+const webhookTimeout = time.Second * 5
+
+// LogNotifier writes the alerts to the standard logger.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, a *storage.Alert) error {
+	log.Printf(
+		"[WARN]: department %d subtree payroll %v %s reached %d%% of its %dQ%d budget %v %s",
+		a.Department, a.Payroll, a.Currency, a.Threshold, a.FiscalYear, a.FiscalQuarter, a.Budget, a.Currency,
+	)
+	return nil
+}
+
+// WebhookNotifier posts the alerts as JSON to the URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL: url,
+		Client: &http.Client{
+			Timeout: webhookTimeout,
+		},
+	}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, a *storage.Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("failed to serialize the alert to JSON: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create the webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
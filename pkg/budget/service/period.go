@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+)
+
+var (
+	ErrIncorrectPeriod = fmt.Errorf("got an incorrect fiscal period")
+	ErrPeriodClosed    = fmt.Errorf("fiscal period is closed")
+)
+
+// maxEvolutionPeriods limits the number of quarters a budget evolution query
+// may span.
+const maxEvolutionPeriods = 40
+
+var periodRe = regexp.MustCompile(`^([0-9]{4})-?[Qq]([1-4])$`)
+
+// now is replaced in tests.
+var now = time.Now
+
+func CurrentPeriod() storage.Period {
+	return periodOf(now())
+}
+
+// ParsePeriod parses a fiscal period written as "2021Q3" or "2021-Q3".
+func ParsePeriod(s string) (storage.Period, error) {
+	m := periodRe.FindStringSubmatch(s)
+	if m == nil {
+		return storage.Period{}, fmt.Errorf("%w: %q, expected a period like 2021Q3", ErrIncorrectPeriod, s)
+	}
+	year, _ := strconv.Atoi(m[1])
+	quarter, _ := strconv.Atoi(m[2])
+	return storage.Period{Year: year, Quarter: quarter}, nil
+}
+
+func FormatPeriod(p storage.Period) string {
+	return fmt.Sprintf("%dQ%d", p.Year, p.Quarter)
+}
+
+func validatePeriod(p storage.Period) error {
+	if p.Year < 1900 || p.Year > 9999 || p.Quarter < 1 || p.Quarter > 4 {
+		return fmt.Errorf("%w: %s", ErrIncorrectPeriod, FormatPeriod(p))
+	}
+	return nil
+}
+
+func periodOf(t time.Time) storage.Period {
+	return storage.Period{
+		Year:    t.Year(),
+		Quarter: (int(t.Month())-1)/3 + 1,
+	}
+}
+
+// periodStart returns the first day of the period.
+func periodStart(p storage.Period) time.Time {
+	return time.Date(p.Year, time.Month(p.Quarter*3-2), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// payrollDate returns the day the payroll of the period is taken on: the last
+// day of a past period and today for the current and the future ones, the
+// payroll of the future periods is forecast from today's.
+func payrollDate(p storage.Period) time.Time {
+	t := now()
+	today := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if end := periodEnd(p); end.Before(today) {
+		return end
+	}
+	return today
+}
+
+// periodEnd returns the last day of the period.
+func periodEnd(p storage.Period) time.Time {
+	return time.Date(p.Year, time.Month(p.Quarter*3)+1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+}
+
+func PrevPeriod(p storage.Period) storage.Period {
+	if p.Quarter == 1 {
+		return storage.Period{Year: p.Year - 1, Quarter: 4}
+	}
+	return storage.Period{Year: p.Year, Quarter: p.Quarter - 1}
+}
+
+func NextPeriod(p storage.Period) storage.Period {
+	if p.Quarter == 4 {
+		return storage.Period{Year: p.Year + 1, Quarter: 1}
+	}
+	return storage.Period{Year: p.Year, Quarter: p.Quarter + 1}
+}
+
+// periodIndex numbers the quarters consecutively, so that periods can be
+// compared and subtracted.
+func periodIndex(p storage.Period) int {
+	return p.Year*4 + p.Quarter - 1
+}
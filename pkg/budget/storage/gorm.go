@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	ratesStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/rates/storage"
+)
+
+type gormDB struct {
+	db  *gorm.DB
+	src *auditStorage.Source
+}
+
+func newGormDB(c *database.ConnString, src *auditStorage.Source) (*gormDB, error) {
+	db, err := database.OpenGorm(c)
+	if err != nil {
+		return nil, err
+	}
+	return &gormDB{
+		db:  db,
+		src: src,
+	}, nil
+}
+
+func (g *gormDB) SetBudget(ctx context.Context, departmentID int, period Period, budget money.Money) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before []*Budget
+		req := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Table("departments_budget").
+			Select("department, fiscal_year, fiscal_quarter, budget_amount, budget_currency").
+			Where("department = ? AND fiscal_year = ? AND fiscal_quarter = ?", departmentID, period.Year, period.Quarter).
+			Find(&before)
+		if err := req.Error; err != nil {
+			return fmt.Errorf("failed to query the department budget: %w", err)
+		}
+		var after []*Budget
+		err := tx.Raw(
+			`INSERT INTO departments_budget (department, fiscal_year, fiscal_quarter, budget_amount, budget_currency)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (department, fiscal_year, fiscal_quarter)
+			DO UPDATE SET
+				budget_amount = EXCLUDED.budget_amount,
+				budget_currency = EXCLUDED.budget_currency,
+				updated_at = NOW()
+			RETURNING department, fiscal_year, fiscal_quarter, budget_amount, budget_currency`,
+			departmentID,
+			period.Year,
+			period.Quarter,
+			budget.Amount,
+			budget.Currency,
+		).Scan(&after).Error
+		if err != nil {
+			if database.IsForeignKeyViolation(err) {
+				return fmt.Errorf("%w: department %d", ErrNotFound, departmentID)
+			}
+			return fmt.Errorf("failed to set the department budget: %w", err)
+		}
+		c := &auditStorage.Change{
+			Action:     auditStorage.ActionCreate,
+			EntityType: auditStorage.EntityBudget,
+			EntityID:   budgetID(after[0]),
+			After:      after[0],
+		}
+		if len(before) != 0 {
+			c.Action = auditStorage.ActionUpdate
+			c.Before = before[0]
+		}
+		return auditStorage.Record(tx, g.src, c)
+	})
+}
+
+func (g *gormDB) ListDepartments(ctx context.Context) ([]*Department, error) {
+	var depts []*Department
+	if err := g.db.WithContext(ctx).Order("id").Find(&depts).Error; err != nil {
+		return nil, fmt.Errorf("failed to query departments: %w", err)
+	}
+	return depts, nil
+}
+
+func (g *gormDB) ListBudgets(ctx context.Context, period Period) ([]*Budget, error) {
+	return g.ListBudgetsInRange(ctx, period, period)
+}
+
+func (g *gormDB) ListBudgetsInRange(ctx context.Context, from Period, to Period) ([]*Budget, error) {
+	var budgets []*Budget
+	req := g.db.WithContext(ctx).
+		Table("departments_budget").
+		Select("department, fiscal_year, fiscal_quarter, budget_amount, budget_currency").
+		Where("(fiscal_year, fiscal_quarter) >= (?, ?)", from.Year, from.Quarter).
+		Where("(fiscal_year, fiscal_quarter) <= (?, ?)", to.Year, to.Quarter).
+		Order("fiscal_year, fiscal_quarter, department").
+		Find(&budgets)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query department budgets: %w", err)
+	}
+	return budgets, nil
+}
+
+func (g *gormDB) CopyBudgets(ctx context.Context, from Period, to Period) (int, error) {
+	var copied []*Budget
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(
+			`INSERT INTO departments_budget (department, fiscal_year, fiscal_quarter, budget_amount, budget_currency)
+			SELECT department, ?, ?, budget_amount, budget_currency
+			FROM departments_budget
+			WHERE fiscal_year = ? AND fiscal_quarter = ?
+			ON CONFLICT (department, fiscal_year, fiscal_quarter) DO NOTHING
+			RETURNING department, fiscal_year, fiscal_quarter, budget_amount, budget_currency`,
+			to.Year,
+			to.Quarter,
+			from.Year,
+			from.Quarter,
+		).Scan(&copied).Error
+		if err != nil {
+			return fmt.Errorf("failed to copy department budgets: %w", err)
+		}
+		for _, b := range copied {
+			err := auditStorage.Record(tx, g.src, &auditStorage.Change{
+				Action:     auditStorage.ActionCopy,
+				EntityType: auditStorage.EntityBudget,
+				EntityID:   budgetID(b),
+				After:      b,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(copied), nil
+}
+
+func (g *gormDB) ListPayrolls(ctx context.Context, from time.Time, to time.Time) ([]*Payroll, error) {
+	var payrolls []*Payroll
+	// department_payrolls leaves out the payrolls the caller may not read
+	req := g.db.WithContext(ctx).Raw(
+		`SELECT department, total_currency, total_amount FROM department_payrolls(?, ?)`,
+		from.Format("2006-01-02"),
+		to.Format("2006-01-02"),
+	).Scan(&payrolls)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query department payrolls: %w", err)
+	}
+	return payrolls, nil
+}
+
+func (g *gormDB) ListRates(ctx context.Context, on time.Time) ([]*ratesStorage.Rate, error) {
+	var rates []*ratesStorage.Rate
+	req := g.db.WithContext(ctx).Raw(
+		`SELECT DISTINCT ON (from_currency, to_currency) from_currency, to_currency, rate, effective_date
+		FROM exchange_rates
+		WHERE effective_date <= ?
+		ORDER BY from_currency, to_currency, effective_date DESC`,
+		on.Format("2006-01-02"),
+	).Scan(&rates)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query exchange rates: %w", err)
+	}
+	return rates, nil
+}
+
+func (g *gormDB) ListAlerts(ctx context.Context, period Period, activeOnly bool) ([]*Alert, error) {
+	var alerts []*Alert
+	req := g.db.WithContext(ctx).
+		Where("fiscal_year = ? AND fiscal_quarter = ?", period.Year, period.Quarter)
+	if activeOnly {
+		req = req.Where("resolved_at IS NULL")
+	}
+	if err := req.Order("created_at, id").Find(&alerts).Error; err != nil {
+		return nil, fmt.Errorf("failed to query budget alerts: %w", err)
+	}
+	return alerts, nil
+}
+
+func (g *gormDB) CreateAlert(ctx context.Context, a *Alert) (*Alert, error) {
+	var alerts []*Alert
+	req := g.db.WithContext(ctx).Raw(
+		`INSERT INTO budget_alerts (department, fiscal_year, fiscal_quarter, threshold, budget, payroll, currency)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (department, fiscal_year, fiscal_quarter, threshold) WHERE resolved_at IS NULL
+		DO NOTHING
+		RETURNING *`,
+		a.Department,
+		a.FiscalYear,
+		a.FiscalQuarter,
+		a.Threshold,
+		a.Budget,
+		a.Payroll,
+		a.Currency,
+	).Scan(&alerts)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to insert the budget alert: %w", err)
+	}
+	if len(alerts) == 0 {
+		return nil, nil
+	}
+	return alerts[0], nil
+}
+
+func (g *gormDB) ResolveAlerts(ctx context.Context, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	req := g.db.WithContext(ctx).
+		Model(&Alert{}).
+		Where("id IN ? AND resolved_at IS NULL", ids).
+		Update("resolved_at", gorm.Expr("NOW()"))
+	if err := req.Error; err != nil {
+		return fmt.Errorf("failed to resolve budget alerts: %w", err)
+	}
+	return nil
+}
+
+func (g *gormDB) Close() {
+	database.CloseGorm(g.db)
+}
+
+// budgetID identifies a budget in the audit log, like "3/2021Q2".
+func budgetID(b *Budget) string {
+	return fmt.Sprintf("%d/%dQ%d", b.Department, b.FiscalYear, b.FiscalQuarter)
+}
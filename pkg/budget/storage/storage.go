@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	ratesStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/rates/storage"
+)
+
+type ContextKey int
+
+const ContextKeyDB ContextKey = iota + 1
+
+var ErrNotFound = fmt.Errorf("not found")
+
+type Department struct {
+	ID       int    `gorm:"column:id"`
+	ParentID int    `gorm:"column:parent_id"`
+	Name     string `gorm:"column:name"`
+}
+
+// Period is a fiscal quarter.
+type Period struct {
+	Year    int `json:"year"`
+	Quarter int `json:"quarter"`
+}
+
+// Budget is a row of the departments_budget table.
+type Budget struct {
+	Department    int         `gorm:"column:department" json:"department"`
+	FiscalYear    int         `gorm:"column:fiscal_year" json:"fiscal_year"`
+	FiscalQuarter int         `gorm:"column:fiscal_quarter" json:"fiscal_quarter"`
+	Budget        money.Money `gorm:"embedded;embeddedPrefix:budget_" json:"budget"`
+}
+
+func (b *Budget) Period() Period {
+	return Period{
+		Year:    b.FiscalYear,
+		Quarter: b.FiscalQuarter,
+	}
+}
+
+// Payroll is the sum of salaries of the employees of a department paid in a
+// single currency.
+type Payroll struct {
+	Department int         `gorm:"column:department"`
+	Total      money.Money `gorm:"embedded;embeddedPrefix:total_"`
+}
+
+// Alert is a row of the budget_alerts table. It is raised when the payroll of
+// a department subtree reaches Threshold percent of the subtree budget for the
+// period, and is resolved when the payroll drops back below it. Budget and
+// Payroll are the subtree totals.
+type Alert struct {
+	ID            int          `gorm:"column:id" json:"id"`
+	Department    int          `gorm:"column:department" json:"department"`
+	FiscalYear    int          `gorm:"column:fiscal_year" json:"fiscal_year"`
+	FiscalQuarter int          `gorm:"column:fiscal_quarter" json:"fiscal_quarter"`
+	Threshold     int          `gorm:"column:threshold" json:"threshold"`
+	Budget        money.Amount `gorm:"column:budget" json:"budget"`
+	Payroll       money.Amount `gorm:"column:payroll" json:"payroll"`
+	Currency      string       `gorm:"column:currency" json:"currency"`
+	CreatedAt     time.Time    `gorm:"column:created_at" json:"created_at"`
+	ResolvedAt    *time.Time   `gorm:"column:resolved_at" json:"resolved_at"`
+	// PayrollHidden replaces the payroll with null in the JSON for the
+	// callers who may not see the payrolls.
+	PayrollHidden bool `gorm:"-" json:"-"`
+}
+
+func (a Alert) MarshalJSON() ([]byte, error) {
+	type alert Alert
+	if !a.PayrollHidden {
+		return json.Marshal(alert(a))
+	}
+	return json.Marshal(struct {
+		alert
+		Payroll *money.Amount `json:"payroll"`
+	}{
+		alert: alert(a),
+	})
+}
+
+func (Alert) TableName() string {
+	return "budget_alerts"
+}
+
+type DB interface {
+	SetBudget(ctx context.Context, departmentID int, period Period, budget money.Money) error
+	ListDepartments(ctx context.Context) ([]*Department, error)
+	ListBudgets(ctx context.Context, period Period) ([]*Budget, error)
+	// ListBudgetsInRange returns the budgets of the periods from..to, both
+	// ends included.
+	ListBudgetsInRange(ctx context.Context, from Period, to Period) ([]*Budget, error)
+	// CopyBudgets copies the budgets of the from period to the to period for
+	// the departments that have no budget in the to period yet. It returns
+	// the number of copied budgets.
+	CopyBudgets(ctx context.Context, from Period, to Period) (int, error)
+	// ListPayrolls sums up the salaries of the employees who worked between
+	// the from and to dates, both included. Every employee is counted with
+	// the salary and in the department they had on the to date, or on their
+	// last day if they left before it. The payrolls the caller may not read
+	// are left out.
+	ListPayrolls(ctx context.Context, from time.Time, to time.Time) ([]*Payroll, error)
+	// ListRates returns the exchange rate of every currency pair in effect on
+	// the date.
+	ListRates(ctx context.Context, on time.Time) ([]*ratesStorage.Rate, error)
+	// ListAlerts returns the alerts of the period, only the unresolved ones if
+	// activeOnly is set.
+	ListAlerts(ctx context.Context, period Period, activeOnly bool) ([]*Alert, error)
+	// CreateAlert returns nil if an unresolved alert for the same department,
+	// period and threshold already exists.
+	CreateAlert(ctx context.Context, a *Alert) (*Alert, error)
+	ResolveAlerts(ctx context.Context, ids []int) error
+	Close()
+}
+
+// NewDB opens the storage, the changes made through it are recorded in the
+// audit log as made by src.
+func NewDB(connStr *database.ConnString, src *auditStorage.Source) (DB, error) {
+	gormDB, err := newGormDB(connStr, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a gorm connection: %w", err)
+	}
+	return gormDB, nil
+}
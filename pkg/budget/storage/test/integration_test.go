@@ -0,0 +1,379 @@
+//go:build integration_tests
+// +build integration_tests
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+const (
+	DB_HOST     = "127.0.0.1"
+	DB_USER     = "gopher"
+	DB_PASSWORD = "P@ssw0rd"
+	DB_NAME     = "gopher_corp"
+)
+
+var DB_PORT = ""
+
+func TestMain(m *testing.M) {
+	os.Exit(testMain(m))
+}
+
+func testMain(m *testing.M) int {
+	setupResult, err := setup()
+	if err != nil {
+		log.Println("setup err: ", err)
+		return -1
+	}
+	defer teardown(setupResult)
+	return m.Run()
+}
+
+type setupResult struct {
+	Pool              *dockertest.Pool
+	PostgresContainer *dockertest.Resource
+}
+
+const dockerMaxWait = time.Second * 5
+
+func setup() (r *setupResult, err error) {
+	testFileDir, err := getTestFileDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the script dir: %w", err)
+	}
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a new docketest pool: %w", err)
+	}
+	pool.MaxWait = dockerMaxWait
+
+	postgresContainer, err := runPostgresContainer(pool, testFileDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run the Postgres container: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if err := pool.Purge(postgresContainer); err != nil {
+				log.Println("failed to purge the postgres container: %w", err)
+			}
+		}
+	}()
+
+	migrationContainer, err := runMigrationContainer(pool, testFileDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run the migration container: %w", err)
+	}
+
+	defer func() {
+		if err := pool.Purge(migrationContainer); err != nil {
+			err = fmt.Errorf("failed to purge the migration container: %w", err)
+		}
+	}()
+
+	if err := pool.Retry(func() error {
+		err := prepopulateDB(testFileDir)
+		if err != nil {
+			log.Printf("populate DB err: %v", err)
+		}
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to prepopulate the DB: %w", err)
+	}
+
+	return &setupResult{
+		Pool:              pool,
+		PostgresContainer: postgresContainer,
+	}, nil
+}
+
+func getTestFileDir() (string, error) {
+	_, fileName, _, ok := runtime.Caller(0)
+	if !ok {
+		return "", fmt.Errorf("failed to get the caller info")
+	}
+	fileDir := filepath.Dir(fileName)
+	dir, err := filepath.Abs(fileDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the absolute path to the directory %s: %w", dir, err)
+	}
+	return fileDir, nil
+}
+
+func runPostgresContainer(pool *dockertest.Pool, testFileDir string) (*dockertest.Resource, error) {
+	postgresContainer, err := pool.RunWithOptions(
+		&dockertest.RunOptions{
+			Repository: "postgres",
+			Tag:        "14.0",
+			Env: []string{
+				"POSTGRES_PASSWORD=P@ssw0rd",
+			},
+		},
+		func(config *docker.HostConfig) {
+			config.AutoRemove = false
+			config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+			config.Mounts = []docker.HostMount{
+				{
+					Target: "/docker-entrypoint-initdb.d",
+					Source: filepath.Join(testFileDir, "init"),
+					Type:   "bind",
+				},
+			}
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the postgres docker container: %w", err)
+	}
+	postgresContainer.Expire(120)
+
+	DB_PORT = postgresContainer.GetPort("5432/tcp")
+
+	// Wait for the DB to start
+	if err := pool.Retry(func() error {
+		db, err := getDBConnector()
+		if err != nil {
+			return fmt.Errorf("failed to get a DB connector: %w", err)
+		}
+		return db.Ping(context.Background())
+	}); err != nil {
+		pool.Purge(postgresContainer)
+		return nil, fmt.Errorf("failed to ping the created DB: %w", err)
+	}
+	return postgresContainer, nil
+}
+
+func runMigrationContainer(pool *dockertest.Pool, testFileDir string) (*dockertest.Resource, error) {
+	migrationsDir, err := filepath.Abs(filepath.Join(testFileDir, "../../../../migrations"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the absolute path of the migrations dir: %w", err)
+	}
+	migrationContainer, err := pool.RunWithOptions(
+		&dockertest.RunOptions{
+			Repository: "migrate/migrate",
+			Tag:        "v4.15.0",
+			Cmd: []string{
+				"-path=/migrations",
+				fmt.Sprintf(
+					"-database=%s",
+					composeConnectionString(),
+				),
+				"up",
+			},
+		},
+		func(config *docker.HostConfig) {
+			config.AutoRemove = false
+			config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+			config.Mounts = []docker.HostMount{
+				{
+					Target: "/migrations",
+					Source: migrationsDir,
+					Type:   "bind",
+				},
+			}
+			config.NetworkMode = "host"
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the migration container: %w", err)
+	}
+
+	return migrationContainer, err
+}
+
+func prepopulateDB(testFileDir string) error {
+	prepopulateScriptPath := filepath.Join(testFileDir, "prepopulate_db.sql")
+	scriptBytes, err := os.ReadFile(prepopulateScriptPath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", prepopulateScriptPath, err)
+	}
+	conn, err := getDBConnector()
+	if err != nil {
+		return fmt.Errorf("failed to get a DB connector: %w", err)
+	}
+	if _, err := conn.Exec(context.Background(), string(scriptBytes)); err != nil {
+		return fmt.Errorf("failed to execute the prepopulate script: %w", err)
+	}
+	return nil
+}
+
+func teardown(r *setupResult) {
+	if err := r.Pool.Purge(r.PostgresContainer); err != nil {
+		log.Printf("failed to purge the Postgres container: %v", err)
+	}
+}
+
+func TestListPayrollsInPeriod(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a DB connector: %v", err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+
+	var rd, sales int
+	err = conn.QueryRow(ctx, `INSERT INTO departments (parent_id, name) VALUES (0, 'Payroll R&D') RETURNING id`).Scan(&rd)
+	if err != nil {
+		t.Fatalf("failed to insert a department: %v", err)
+	}
+	err = conn.QueryRow(ctx, `INSERT INTO departments (parent_id, name) VALUES (0, 'Payroll Sales') RETURNING id`).Scan(&sales)
+	if err != nil {
+		t.Fatalf("failed to insert a department: %v", err)
+	}
+	insertEmployee := func(name string, department int, salary string, entryAt string, terminatedOn interface{}) int {
+		var id int
+		err := conn.QueryRow(ctx, insertPayrollEmployeeQuery, name, salary, department, entryAt, terminatedOn).Scan(&id)
+		if err != nil {
+			t.Fatalf("failed to insert employee %s: %v", name, err)
+		}
+		return id
+	}
+	exec := func(query string, args ...interface{}) {
+		if _, err := conn.Exec(ctx, query, args...); err != nil {
+			t.Fatalf("failed to execute %q: %v", query, err)
+		}
+	}
+
+	// raised in August, the November raise is paid from then on, the
+	// cancelled September one is not
+	ann := insertEmployee("Ann", rd, "300", "2021-01-01", nil)
+	exec(insertSalaryQuery, ann, "100", "2021-01-01", false)
+	exec(insertSalaryQuery, ann, "150", "2021-08-01", false)
+	exec(insertSalaryQuery, ann, "999", "2021-09-01", true)
+	exec(insertSalaryQuery, ann, "300", "2021-11-01", false)
+	// left in the middle of the third quarter
+	ben := insertEmployee("Ben", rd, "80", "2021-01-01", "2021-08-15")
+	exec(insertSalaryQuery, ben, "80", "2021-01-01", false)
+	// moved from Sales to R&D at the start of the fourth quarter, has no
+	// salary history
+	cid := insertEmployee("Cid", rd, "50", "2021-01-01", nil)
+	exec(insertAssignmentQuery, cid, sales, "2021-01-01", "2021-10-01")
+	exec(insertAssignmentQuery, cid, rd, "2021-10-01", nil)
+	// hired after both quarters
+	insertEmployee("Dan", rd, "70", "2022-01-01", nil)
+
+	db, err := storage.NewDB(getConnectionString(), auditStorage.System("test"))
+	if err != nil {
+		t.Fatalf("failed to open the budget storage: %v", err)
+	}
+	defer db.Close()
+
+	cases := []struct {
+		From     time.Time
+		To       time.Time
+		Expected map[int]string
+	}{
+		{
+			From:     time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC),
+			To:       time.Date(2021, time.September, 30, 0, 0, 0, 0, time.UTC),
+			Expected: map[int]string{rd: "230.00", sales: "50.00"},
+		},
+		{
+			From:     time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC),
+			To:       time.Date(2021, time.December, 31, 0, 0, 0, 0, time.UTC),
+			Expected: map[int]string{rd: "350.00"},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			payrolls, err := db.ListPayrolls(ctx, tc.From, tc.To)
+			if err != nil {
+				t.Fatalf("failed to list the payrolls: %v", err)
+			}
+			actual := make(map[int]string)
+			for _, p := range payrolls {
+				if p.Department != rd && p.Department != sales {
+					continue
+				}
+				if p.Total.Currency != "RUB" {
+					t.Errorf("department %d: expected the payroll in RUB, got %s", p.Department, p.Total.Currency)
+				}
+				actual[p.Department] = p.Total.Amount.String()
+			}
+			if len(actual) != len(tc.Expected) {
+				t.Fatalf("expected the payrolls %v, got %v", tc.Expected, actual)
+			}
+			for dept, expected := range tc.Expected {
+				if actual[dept] != expected {
+					t.Errorf("department %d: expected the payroll %s, got %s", dept, expected, actual[dept])
+				}
+			}
+		})
+	}
+}
+
+// insertPayrollEmployeeQuery creates an employee named $1 with the salary $2
+// in the department $3, who joined on $4 and left on $5 unless it is null.
+const insertPayrollEmployeeQuery = `INSERT INTO employees (first_name, last_name, salary_amount, salary_currency, manager_id, department, position, entry_at, terminated_on)
+	VALUES(
+		$1, 'Payroll',
+		$2::numeric, 'RUB',
+		(SELECT id FROM employees WHERE first_name = 'Bob' AND last_name = 'Morane' LIMIT 1),
+		$3,
+		(SELECT id FROM positions WHERE title = 'Backend Dev'),
+		$4::date,
+		$5::date
+	)
+	RETURNING id`
+
+// insertSalaryQuery records the salary $2 of the employee $1 effective on $3,
+// it is cancelled if $4 is set and applied otherwise.
+const insertSalaryQuery = `INSERT INTO salary_history (employee_id, salary_amount, salary_currency, effective_date, changed_by, applied_at, cancelled_at)
+	VALUES (
+		$1, $2::numeric, 'RUB', $3::date, 'test',
+		CASE WHEN $4::boolean THEN NULL ELSE NOW() END,
+		CASE WHEN $4::boolean THEN NOW() END
+	)`
+
+// insertAssignmentQuery assigns the employee $1 to the department $2 from $3
+// until $4 exclusive, or for good if $4 is null.
+const insertAssignmentQuery = `INSERT INTO employee_assignments (employee_id, department, position, manager_id, valid_from, valid_to)
+	VALUES (
+		$1, $2,
+		(SELECT id FROM positions WHERE title = 'Backend Dev'),
+		(SELECT id FROM employees WHERE first_name = 'Bob' AND last_name = 'Morane' LIMIT 1),
+		$3::date, $4::date
+	)`
+
+func getDBConnector() (*pgxpool.Pool, error) {
+	log.Println(composeConnectionString())
+	cfg, err := pgxpool.ParseConfig(composeConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the PGX pool config from connection string: %w", err)
+	}
+	cfg.ConnConfig.ConnectTimeout = time.Second * 1
+	db, err := pgxpool.ConnectConfig(context.Background(), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the postgres DB using a PGX connection pool: %w", err)
+	}
+	return db, nil
+}
+
+func getConnectionString() *database.ConnString {
+	return &database.ConnString{
+		Host:     DB_HOST,
+		Port:     DB_PORT,
+		User:     DB_USER,
+		Password: DB_PASSWORD,
+		DBName:   DB_NAME,
+	}
+}
+
+func composeConnectionString() string {
+	return fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=disable", DB_USER, url.QueryEscape(DB_PASSWORD), DB_HOST, DB_PORT, DB_NAME)
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type ConnString struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	DBName   string
+	// Session are the run-time parameters set on every connection.
+	Session map[string]string
+}
+
+// WithSession returns a copy of the connection string setting the run-time
+// parameters on every connection.
+func (c *ConnString) WithSession(params map[string]string) *ConnString {
+	withSession := *c
+	withSession.Session = params
+	return &withSession
+}
+
+func OpenGorm(c *ConnString) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(composeGormDSN(c)), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Gorm connection: %w", err)
+	}
+	return db, nil
+}
+
+func CloseGorm(db *gorm.DB) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return
+	}
+	sqlDB.Close()
+}
+
+func composeGormDSN(c *ConnString) string {
+	dsn := fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Europe/Moscow",
+		c.Host,
+		c.User,
+		c.Password,
+		c.DBName,
+		c.Port,
+	)
+	names := make([]string, 0, len(c.Session))
+	for name := range c.Session {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dsn += fmt.Sprintf(" %s='%s'", name, dsnEscaper.Replace(c.Session[name]))
+	}
+	return dsn
+}
+
+var dsnEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+const (
+	pgCodeForeignKeyViolation = "23503"
+	pgCodeUniqueViolation     = "23505"
+	pgCodeCheckViolation      = "23514"
+)
+
+func IsForeignKeyViolation(err error) bool {
+	return hasPgCode(err, pgCodeForeignKeyViolation)
+}
+
+func IsUniqueViolation(err error) bool {
+	return hasPgCode(err, pgCodeUniqueViolation)
+}
+
+func IsCheckViolation(err error) bool {
+	return hasPgCode(err, pgCodeCheckViolation)
+}
+
+func hasPgCode(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == code
+}
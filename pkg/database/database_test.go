@@ -0,0 +1,31 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/jackc/pgconn"
+)
+
+func TestComposeGormDSNSession(t *testing.T) {
+	c := &ConnString{Host: "localhost", Port: "5432", User: "gopher", Password: "secret", DBName: "corp"}
+	session := map[string]string{
+		"app.user_id":     `o'brien\ the "third"`,
+		"app.departments": "hr:2,hr:4",
+		"app.roles":       "",
+	}
+	config, err := pgconn.ParseConfig(composeGormDSN(c.WithSession(session)))
+	if err != nil {
+		t.Fatalf("failed to parse the DSN: %v", err)
+	}
+	for name, val := range session {
+		if actual := config.RuntimeParams[name]; actual != val {
+			t.Errorf("expected %s %q, got %q", name, val, actual)
+		}
+	}
+	if config.User != "gopher" || config.Database != "corp" {
+		t.Errorf("unexpected connection config %+v", config)
+	}
+	if c.Session != nil {
+		t.Errorf("the connection string was changed")
+	}
+}
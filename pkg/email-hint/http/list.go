@@ -5,47 +5,121 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
 	"github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/service"
 	"github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
 )
 
-func GetPhonesByEmailPrefix(w http.ResponseWriter, r *http.Request, emailPrefix string) {
+// GetPhonesByEmailPrefix returns the phones of the employees found by the
+// email prefix. The callers throttled by the guard get 429 with the
+// Retry-After header.
+func GetPhonesByEmailPrefix(w http.ResponseWriter, r *http.Request, guard *service.ScrapingGuard, emailPrefix string) {
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	caller := auth.Subject(r.Context())
+	phones, err := service.GetPhonesByEmailPrefix(db, guard, caller, auth.EmployeeID(r.Context()), emailPrefix)
+	if err != nil {
+		if errors.Is(err, service.ErrLookupThrottled) {
+			retryAfter := (guard.RetryAfter(caller) + time.Second - 1) / time.Second
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+		}
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, phones)
+}
+
+// GetPhonesByNumber returns the employees found by the phone number.
+func GetPhonesByNumber(w http.ResponseWriter, r *http.Request, number string) {
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	phones, err := service.GetPhonesByNumber(db, auth.EmployeeID(r.Context()), number)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, phones)
+}
+
+// ListLookups returns who looked up the employee's phone number. The
+// "limit" query parameter bounds the number of returned lookups.
+func ListLookups(w http.ResponseWriter, r *http.Request, employeeID string) {
+	id, err := strconv.Atoi(employeeID)
+	if err != nil {
+		log.Printf("incorrect employee ID %q: %v", employeeID, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	limit := 0
+	if val := r.URL.Query().Get("limit"); len(val) != 0 {
+		if limit, err = strconv.Atoi(val); err != nil {
+			log.Printf("incorrect limit %q: %v", val, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	lookups, err := service.ListLookups(db, auth.FromContext(r.Context()), id, limit)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, lookups)
+}
+
+func getDB(w http.ResponseWriter, r *http.Request) (storage.DB, bool) {
 	dbIface := r.Context().Value(storage.ContextKeyDB)
 	if dbIface == nil {
 		log.Println("DB is not found in the request context")
 		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return nil, false
 	}
 	db, ok := dbIface.(storage.DB)
 	if !ok {
 		log.Println("DB in the request context is not of type storage.DB")
 		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return nil, false
 	}
-	phones, err := service.GetPhonesByEmailPrefix(db, emailPrefix)
-	if err != nil {
-		log.Println(err)
-		if errors.Is(err, service.ErrIncorrectEmailPrefix) {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		if errors.Is(err, service.ErrDBRequestFailed) {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+	return db, true
+}
+
+func writeErr(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, service.ErrIncorrectEmailPrefix),
+		errors.Is(err, service.ErrIncorrectPhoneNumber),
+		errors.Is(err, service.ErrIncorrectLookupFilter):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, policy.ErrForbidden):
+		w.WriteHeader(http.StatusForbidden)
+	case errors.Is(err, service.ErrLookupThrottled):
+		w.WriteHeader(http.StatusTooManyRequests)
+	default:
 		w.WriteHeader(http.StatusInternalServerError)
-		return
 	}
-	resp, err := json.Marshal(phones)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	resp, err := json.Marshal(v)
 	if err != nil {
-		log.Printf("failed to serialize the phones list to JSON: %v", err)
+		log.Printf("failed to serialize the response to JSON: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	if _, err := w.Write(resp); err != nil {
-		log.Printf("failed to write the phones list as a response body: %v", err)
+		log.Printf("failed to write the response body: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -5,9 +5,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/service"
 	"github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
 	"github.com/gorilla/mux"
 )
 
@@ -32,6 +37,18 @@ func TestGetPhonesByEmailPrefix(t *testing.T) {
 			EmailPrefix:      "",
 			ExpectedRespCode: http.StatusBadRequest,
 		},
+		{
+			EmailPrefix:      "%25",
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			EmailPrefix:      "ali%25",
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			EmailPrefix:      "al",
+			ExpectedRespCode: http.StatusBadRequest,
+		},
 		{
 			EmailPrefix:      "alidd",
 			ExpectedPrefix:   "alidd",
@@ -57,9 +74,10 @@ func TestGetPhonesByEmailPrefix(t *testing.T) {
 				phonesToReturn: nil,
 			}))
 
+			// the prefix is taken from the decoded path, as the router does
 			handler := mux.NewRouter()
-			handler.HandleFunc(urlPath, func(w http.ResponseWriter, r *http.Request) {
-				GetPhonesByEmailPrefix(w, r, tc.EmailPrefix)
+			handler.PathPrefix("/phone/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				GetPhonesByEmailPrefix(w, r, nil, strings.TrimPrefix(r.URL.Path, "/phone/"))
 			}).Methods("GET")
 			rr := httptest.NewRecorder()
 
@@ -72,6 +90,35 @@ func TestGetPhonesByEmailPrefix(t *testing.T) {
 	}
 }
 
+func TestGetPhonesByEmailPrefixThrottled(t *testing.T) {
+	policy := service.DefaultScrapingPolicy
+	policy.Budget, policy.BaseDelay = 1, time.Minute
+	guard, err := service.NewScrapingGuard(policy, nil)
+	if err != nil {
+		t.Fatalf("failed to create the scraping guard: %v", err)
+	}
+	var rr *httptest.ResponseRecorder
+	for i, expectedCode := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req, err := http.NewRequest("GET", "/phone/alidd", nil)
+		if err != nil {
+			t.Fatalf("failed to create an http request: %v", err)
+		}
+		req = req.WithContext(auth.NewContext(req.Context(), &auth.Principal{Subject: "bob"}))
+		req = req.WithContext(context.WithValue(req.Context(), storage.ContextKeyDB, &dbMock{
+			t:              t,
+			expectedPrefix: "alidd",
+		}))
+		rr = httptest.NewRecorder()
+		GetPhonesByEmailPrefix(rr, req, guard, "alidd")
+		if rr.Code != expectedCode {
+			t.Fatalf("request %d: expected code: %d, got: %d", i, expectedCode, rr.Code)
+		}
+	}
+	if retryAfter := rr.Header().Get("Retry-After"); retryAfter != "60" {
+		t.Errorf("expected to retry after 60 seconds, got %q", retryAfter)
+	}
+}
+
 type dbMock struct {
 	t              *testing.T
 	expectedPrefix string
@@ -87,4 +134,24 @@ func (db *dbMock) GetPhonesByEmailPrefix(ctx context.Context, prefix string) ([]
 	return db.phonesToReturn, db.expectedError
 }
 
+func (db *dbMock) GetPhonesByNumber(ctx context.Context, number string) ([]*storage.FoundPhone, error) {
+	return db.phonesToReturn, db.expectedError
+}
+
+func (db *dbMock) RecordLookup(ctx context.Context, l *storage.Lookup) (*storage.Lookup, error) {
+	return l, nil
+}
+
+func (db *dbMock) ListLookups(ctx context.Context, employeeID int, limit int) ([]*storage.Lookup, error) {
+	return nil, nil
+}
+
+func (db *dbMock) GetViewer(ctx context.Context, employeeID int) (*visibility.Viewer, error) {
+	return &visibility.Viewer{}, nil
+}
+
+func (db *dbMock) DeleteLookupsBefore(ctx context.Context, t time.Time) (int64, error) {
+	return 0, nil
+}
+
 func (db *dbMock) Close() {}
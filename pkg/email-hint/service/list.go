@@ -6,20 +6,144 @@ import (
 	"strings"
 
 	"github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/encryption"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
 )
 
 var (
 	ErrIncorrectEmailPrefix = fmt.Errorf("got an incorrect email prefix")
+	ErrIncorrectPhoneNumber = fmt.Errorf("got an incorrect phone number")
 	ErrDBRequestFailed      = fmt.Errorf("a request to DB failed")
 )
 
-func GetPhonesByEmailPrefix(db storage.DB, emailPrefix string) ([]*storage.FoundPhone, error) {
-	if len(emailPrefix) == 0 {
-		return nil, fmt.Errorf("%w: the passed prefix is empty", ErrIncorrectEmailPrefix)
+// The email prefixes are at least minEmailPrefixLength characters long, so
+// that a lookup does not return a large part of the directory and the prefix
+// is indexed, and at most as long as an email address.
+const (
+	minEmailPrefixLength = encryption.MinPrefixLength
+	maxEmailPrefixLength = 254
+)
+
+// GetPhonesByEmailPrefix returns the phones of the employees whose emails the
+// viewer may see, the phones the viewer may not see are blank. The zero
+// viewerID stands for an anonymous caller. The lookup made by the caller is
+// recorded in the lookup log, the phones are not returned if it cannot be
+// recorded. The guard throttles the callers enumerating the directory, a nil
+// guard lets all the lookups through.
+func GetPhonesByEmailPrefix(db storage.DB, guard *ScrapingGuard, caller string, viewerID int, emailPrefix string) ([]*storage.FoundPhone, error) {
+	prefix := strings.ToLower(emailPrefix)
+	if err := checkEmailPrefix(prefix); err != nil {
+		return nil, err
 	}
-	phones, err := db.GetPhonesByEmailPrefix(context.Background(), strings.ToLower(emailPrefix))
+	if guard != nil {
+		if err := guard.Allow(caller, prefix); err != nil {
+			return nil, err
+		}
+	}
+	found, err := db.GetPhonesByEmailPrefix(context.Background(), prefix)
 	if err != nil {
 		return nil, fmt.Errorf("%w: failed to get phones by email prefix: %v", ErrDBRequestFailed, err)
 	}
+	v, err := db.GetViewer(context.Background(), viewerID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to get the viewer: %v", ErrDBRequestFailed, err)
+	}
+	phones := filterVisible(v, found)
+	l := &storage.Lookup{
+		Caller:      caller,
+		Prefix:      prefix,
+		EmployeeIDs: make([]int, len(phones)),
+	}
+	for i, p := range phones {
+		l.EmployeeIDs[i] = p.EmployeeID
+	}
+	if _, err := db.RecordLookup(context.Background(), l); err != nil {
+		return nil, fmt.Errorf("%w: failed to record the lookup: %v", ErrDBRequestFailed, err)
+	}
 	return phones, nil
 }
+
+// GetPhonesByNumber returns the employees with the phone number whose phones
+// the viewer may see, the emails the viewer may not see are blank. The zero
+// viewerID stands for an anonymous caller.
+func GetPhonesByNumber(db storage.DB, viewerID int, number string) ([]*storage.FoundPhone, error) {
+	number = strings.TrimSpace(number)
+	if len(number) == 0 {
+		return nil, fmt.Errorf("%w: the passed number is empty", ErrIncorrectPhoneNumber)
+	}
+	found, err := db.GetPhonesByNumber(context.Background(), number)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to get phones by number: %v", ErrDBRequestFailed, err)
+	}
+	v, err := db.GetViewer(context.Background(), viewerID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to get the viewer: %v", ErrDBRequestFailed, err)
+	}
+	return filterVisibleByPhone(v, found), nil
+}
+
+// checkEmailPrefix allows the prefixes made of the characters of the company
+// email addresses: latin letters, digits, ".", "_", "-", "+" and "@". The
+// prefix is expected to be lowercased.
+func checkEmailPrefix(prefix string) error {
+	if len(prefix) == 0 {
+		return fmt.Errorf("%w: the passed prefix is empty", ErrIncorrectEmailPrefix)
+	}
+	for _, c := range prefix {
+		switch {
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		case c == '.', c == '_', c == '-', c == '+', c == '@':
+		default:
+			return fmt.Errorf("%w: the prefix contains %q", ErrIncorrectEmailPrefix, c)
+		}
+	}
+	if len(prefix) < minEmailPrefixLength {
+		return fmt.Errorf("%w: the prefix is shorter than %d characters", ErrIncorrectEmailPrefix, minEmailPrefixLength)
+	}
+	if len(prefix) > maxEmailPrefixLength {
+		return fmt.Errorf("%w: the prefix is longer than %d characters", ErrIncorrectEmailPrefix, maxEmailPrefixLength)
+	}
+	return nil
+}
+
+// filterVisible drops the employees whose emails the viewer may not see, so
+// that a hidden email or alias is not revealed by matching the prefix, and
+// blanks the phones the viewer may not see.
+func filterVisible(v *visibility.Viewer, found []*storage.FoundPhone) []*storage.FoundPhone {
+	phones := make([]*storage.FoundPhone, 0, len(found))
+	for _, p := range found {
+		owner := visibility.Owner{
+			EmployeeID: p.EmployeeID,
+			Department: p.Department,
+		}
+		if !v.CanSee(owner, p.EmailVisibility) {
+			continue
+		}
+		if !v.CanSee(owner, p.PhoneVisibility) {
+			p.Phone, p.Phones = "", make([]*storage.Phone, 0)
+		}
+		phones = append(phones, p)
+	}
+	return phones
+}
+
+// filterVisibleByPhone drops the employees whose phones the viewer may not
+// see, so that a hidden phone is not revealed by matching the number, and
+// blanks the emails the viewer may not see.
+func filterVisibleByPhone(v *visibility.Viewer, found []*storage.FoundPhone) []*storage.FoundPhone {
+	phones := make([]*storage.FoundPhone, 0, len(found))
+	for _, p := range found {
+		owner := visibility.Owner{
+			EmployeeID: p.EmployeeID,
+			Department: p.Department,
+		}
+		if !v.CanSee(owner, p.PhoneVisibility) {
+			continue
+		}
+		if !v.CanSee(owner, p.EmailVisibility) {
+			p.Email, p.Emails = "", make([]*storage.Email, 0)
+		}
+		phones = append(phones, p)
+	}
+	return phones
+}
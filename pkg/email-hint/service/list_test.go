@@ -4,9 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
 	"github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
 )
 
 func TestGetPhonesByEmailPrefix(t *testing.T) {
@@ -15,6 +21,7 @@ func TestGetPhonesByEmailPrefix(t *testing.T) {
 		ExpectedPrefix string
 		ExpectedPhones []*storage.FoundPhone
 		MockErr        error
+		RecordErr      error
 		ExpectedErr    error
 	}{
 		{
@@ -22,9 +29,10 @@ func TestGetPhonesByEmailPrefix(t *testing.T) {
 			ExpectedPrefix: "aliddl",
 			ExpectedPhones: []*storage.FoundPhone{
 				{
-					FirstName: "Alice",
-					LastName:  "Liddell",
-					Phone:     "+12345",
+					EmployeeID: 1,
+					FirstName:  "Alice",
+					LastName:   "Liddell",
+					Phone:      "+12345",
 				},
 			},
 			MockErr:     nil,
@@ -55,6 +63,20 @@ func TestGetPhonesByEmailPrefix(t *testing.T) {
 			MockErr:        fmt.Errorf("some simple err"),
 			ExpectedErr:    ErrDBRequestFailed,
 		},
+		{
+			EmailPrefix:    "aliddl",
+			ExpectedPrefix: "aliddl",
+			ExpectedPhones: []*storage.FoundPhone{
+				{
+					EmployeeID: 1,
+					FirstName:  "Alice",
+					LastName:   "Liddell",
+					Phone:      "+12345",
+				},
+			},
+			RecordErr:   fmt.Errorf("some simple err"),
+			ExpectedErr: ErrDBRequestFailed,
+		},
 	}
 
 	for i, tc := range cases {
@@ -63,9 +85,10 @@ func TestGetPhonesByEmailPrefix(t *testing.T) {
 				t:              t,
 				expectedPrefix: tc.ExpectedPrefix,
 				expectedError:  tc.MockErr,
+				recordError:    tc.RecordErr,
 				phonesToReturn: tc.ExpectedPhones,
 			}
-			actualFoundPhones, actualErr := GetPhonesByEmailPrefix(mock, tc.EmailPrefix)
+			actualFoundPhones, actualErr := GetPhonesByEmailPrefix(mock, nil, "bob", 0, tc.EmailPrefix)
 			if t.Failed() {
 				return
 			}
@@ -73,13 +96,31 @@ func TestGetPhonesByEmailPrefix(t *testing.T) {
 				t.Error(err)
 				return
 			}
+			if tc.ExpectedErr != nil {
+				if actualFoundPhones != nil {
+					t.Errorf("phones were returned despite the error")
+				}
+				return
+			}
+			expectedLookup := &storage.Lookup{
+				Caller:      "bob",
+				Prefix:      tc.ExpectedPrefix,
+				EmployeeIDs: make([]int, len(tc.ExpectedPhones)),
+			}
+			for i, p := range tc.ExpectedPhones {
+				expectedLookup.EmployeeIDs[i] = p.EmployeeID
+			}
+			if !reflect.DeepEqual(mock.recorded, expectedLookup) {
+				t.Errorf("expected lookup %+v to be recorded, got %+v", expectedLookup, mock.recorded)
+				return
+			}
 			if len(actualFoundPhones) != len(tc.ExpectedPhones) {
 				t.Errorf("expected phones len %d, got %d", len(tc.ExpectedPhones), len(actualFoundPhones))
 				return
 			}
 			for i, expectedPhone := range tc.ExpectedPhones {
 				actualPhone := *actualFoundPhones[i]
-				if *expectedPhone != actualPhone {
+				if !reflect.DeepEqual(*expectedPhone, actualPhone) {
 					t.Errorf("phone %d: expected: %v, got: %v", i, *expectedPhone, actualPhone)
 					return
 				}
@@ -88,6 +129,269 @@ func TestGetPhonesByEmailPrefix(t *testing.T) {
 	}
 }
 
+func TestGetPhonesByEmailPrefixPolicy(t *testing.T) {
+	cases := []struct {
+		EmailPrefix    string
+		ExpectedPrefix string
+		ExpectedErr    error
+	}{
+		{
+			EmailPrefix:    "ali",
+			ExpectedPrefix: "ali",
+		},
+		{
+			EmailPrefix:    "Dale.Cooper+FBI@gopher-corp",
+			ExpectedPrefix: "dale.cooper+fbi@gopher-corp",
+		},
+		{
+			EmailPrefix:    "a_b",
+			ExpectedPrefix: "a_b",
+		},
+		{
+			EmailPrefix:    strings.Repeat("a", maxEmailPrefixLength),
+			ExpectedPrefix: strings.Repeat("a", maxEmailPrefixLength),
+		},
+		{
+			EmailPrefix: strings.Repeat("a", maxEmailPrefixLength+1),
+			ExpectedErr: ErrIncorrectEmailPrefix,
+		},
+		{
+			EmailPrefix: "al",
+			ExpectedErr: ErrIncorrectEmailPrefix,
+		},
+		{
+			EmailPrefix: "%",
+			ExpectedErr: ErrIncorrectEmailPrefix,
+		},
+		{
+			EmailPrefix: "ali%",
+			ExpectedErr: ErrIncorrectEmailPrefix,
+		},
+		{
+			EmailPrefix: "%%%",
+			ExpectedErr: ErrIncorrectEmailPrefix,
+		},
+		{
+			EmailPrefix: `ali\`,
+			ExpectedErr: ErrIncorrectEmailPrefix,
+		},
+		{
+			EmailPrefix: "ali ddl",
+			ExpectedErr: ErrIncorrectEmailPrefix,
+		},
+		{
+			EmailPrefix: "алиса",
+			ExpectedErr: ErrIncorrectEmailPrefix,
+		},
+		{
+			EmailPrefix: "ali'--",
+			ExpectedErr: ErrIncorrectEmailPrefix,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t:              t,
+				expectedPrefix: tc.ExpectedPrefix,
+			}
+			_, err := GetPhonesByEmailPrefix(mock, nil, "bob", 0, tc.EmailPrefix)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil && mock.recorded != nil {
+				t.Errorf("the rejected lookup was recorded")
+			}
+		})
+	}
+}
+
+func TestGetPhonesByEmailPrefixVisibility(t *testing.T) {
+	department, managers, hidden := visibility.Department, visibility.Managers, visibility.Hidden
+	found := func() []*storage.FoundPhone {
+		phones := []*storage.FoundPhone{
+			{EmployeeID: 1, Department: 1, Phone: "+1"},
+			{EmployeeID: 2, Department: 1, Phone: "+2", PhoneVisibility: &department},
+			{EmployeeID: 3, Department: 2, Phone: "+3", PhoneVisibility: &managers},
+			{EmployeeID: 4, Department: 2, Phone: "+4", EmailVisibility: &hidden},
+			{EmployeeID: 5, Department: 2, Phone: "+5", EmailVisibility: &department},
+		}
+		for _, p := range phones {
+			p.Phones = []*storage.Phone{{Type: "work", Number: p.Phone, Primary: true}}
+		}
+		return phones
+	}
+	cases := []struct {
+		Viewer         *visibility.Viewer
+		ExpectedIDs    []int
+		ExpectedPhones []string
+	}{
+		{
+			Viewer:         &visibility.Viewer{},
+			ExpectedIDs:    []int{1, 2, 3},
+			ExpectedPhones: []string{"+1", "", ""},
+		},
+		{
+			Viewer:         &visibility.Viewer{EmployeeID: 6, Department: 1},
+			ExpectedIDs:    []int{1, 2, 3},
+			ExpectedPhones: []string{"+1", "+2", ""},
+		},
+		{
+			Viewer: &visibility.Viewer{
+				EmployeeID: 7,
+				Department: 2,
+				Reports:    map[int]struct{}{3: {}},
+			},
+			ExpectedIDs:    []int{1, 2, 3, 5},
+			ExpectedPhones: []string{"+1", "", "+3", "+5"},
+		},
+		{
+			Viewer:         &visibility.Viewer{EmployeeID: 4, Department: 2},
+			ExpectedIDs:    []int{1, 2, 3, 4, 5},
+			ExpectedPhones: []string{"+1", "", "", "+4", "+5"},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t:              t,
+				expectedPrefix: "dal",
+				phonesToReturn: found(),
+				viewer:         tc.Viewer,
+			}
+			phones, err := GetPhonesByEmailPrefix(mock, nil, "bob", tc.Viewer.EmployeeID, "dal")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if mock.viewerID != tc.Viewer.EmployeeID {
+				t.Errorf("expected viewer %d to be loaded, got %d", tc.Viewer.EmployeeID, mock.viewerID)
+				return
+			}
+			ids, numbers := make([]int, len(phones)), make([]string, len(phones))
+			for i, p := range phones {
+				ids[i], numbers[i] = p.EmployeeID, p.Phone
+				if (len(p.Phones) != 0) != (len(p.Phone) != 0) {
+					t.Errorf("expected the phones of employee %d to be hidden with the primary one, got %v", p.EmployeeID, p.Phones)
+					return
+				}
+			}
+			if !reflect.DeepEqual(ids, tc.ExpectedIDs) || !reflect.DeepEqual(numbers, tc.ExpectedPhones) {
+				t.Errorf("expected employees %v with phones %q, got %v with %q", tc.ExpectedIDs, tc.ExpectedPhones, ids, numbers)
+				return
+			}
+			if !reflect.DeepEqual(mock.recorded.EmployeeIDs, tc.ExpectedIDs) {
+				t.Errorf("expected employees %v to be recorded, got %v", tc.ExpectedIDs, mock.recorded.EmployeeIDs)
+			}
+		})
+	}
+}
+
+func TestGetPhonesByNumber(t *testing.T) {
+	department, managers, hidden := visibility.Department, visibility.Managers, visibility.Hidden
+	found := func() []*storage.FoundPhone {
+		phones := []*storage.FoundPhone{
+			{EmployeeID: 1, Department: 1, Email: "dcooper@gopher-corp.com"},
+			{EmployeeID: 2, Department: 1, Email: "gcole@gopher-corp.com", PhoneVisibility: &department},
+			{EmployeeID: 3, Department: 2, Email: "hstone@gopher-corp.com", PhoneVisibility: &managers},
+			{EmployeeID: 4, Department: 2, Email: "ltruman@gopher-corp.com", EmailVisibility: &hidden},
+		}
+		for _, p := range phones {
+			p.Phone = "+12345"
+			p.Emails = []*storage.Email{{Type: "work", Address: p.Email, Primary: true}}
+		}
+		return phones
+	}
+	cases := []struct {
+		Number         string
+		ExpectedNumber string
+		Viewer         *visibility.Viewer
+		MockErr        error
+		ExpectedIDs    []int
+		ExpectedEmails []string
+		ExpectedErr    error
+	}{
+		{
+			Number:         "+12345",
+			ExpectedNumber: "+12345",
+			Viewer:         &visibility.Viewer{},
+			ExpectedIDs:    []int{1, 4},
+			ExpectedEmails: []string{"dcooper@gopher-corp.com", ""},
+		},
+		{
+			Number:         " +12345 ",
+			ExpectedNumber: "+12345",
+			Viewer:         &visibility.Viewer{EmployeeID: 5, Department: 1},
+			ExpectedIDs:    []int{1, 2, 4},
+			ExpectedEmails: []string{"dcooper@gopher-corp.com", "gcole@gopher-corp.com", ""},
+		},
+		{
+			Number:         "+12345",
+			ExpectedNumber: "+12345",
+			Viewer: &visibility.Viewer{
+				EmployeeID: 4,
+				Department: 2,
+				Reports:    map[int]struct{}{3: {}},
+			},
+			ExpectedIDs:    []int{1, 3, 4},
+			ExpectedEmails: []string{"dcooper@gopher-corp.com", "hstone@gopher-corp.com", "ltruman@gopher-corp.com"},
+		},
+		{
+			Number:      " ",
+			ExpectedErr: ErrIncorrectPhoneNumber,
+		},
+		{
+			Number:         "+12345",
+			ExpectedNumber: "+12345",
+			MockErr:        fmt.Errorf("some simple err"),
+			ExpectedErr:    ErrDBRequestFailed,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t:              t,
+				expectedNumber: tc.ExpectedNumber,
+				expectedError:  tc.MockErr,
+				phonesToReturn: found(),
+				viewer:         tc.Viewer,
+			}
+			viewerID := 0
+			if tc.Viewer != nil {
+				viewerID = tc.Viewer.EmployeeID
+			}
+			phones, err := GetPhonesByNumber(mock, viewerID, tc.Number)
+			if t.Failed() {
+				return
+			}
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				if phones != nil {
+					t.Errorf("phones were returned despite the error")
+				}
+				return
+			}
+			ids, emails := make([]int, len(phones)), make([]string, len(phones))
+			for i, p := range phones {
+				ids[i], emails[i] = p.EmployeeID, p.Email
+				if (len(p.Emails) != 0) != (len(p.Email) != 0) {
+					t.Errorf("expected the emails of employee %d to be hidden with the primary one, got %v", p.EmployeeID, p.Emails)
+					return
+				}
+			}
+			if !reflect.DeepEqual(ids, tc.ExpectedIDs) || !reflect.DeepEqual(emails, tc.ExpectedEmails) {
+				t.Errorf("expected employees %v with emails %q, got %v with %q", tc.ExpectedIDs, tc.ExpectedEmails, ids, emails)
+			}
+		})
+	}
+}
+
 func compareErrs(expectedErr error, actualErr error) error {
 	if expectedErr == nil && actualErr == nil {
 		return nil
@@ -101,11 +405,102 @@ func compareErrs(expectedErr error, actualErr error) error {
 	return nil
 }
 
+func TestListLookups(t *testing.T) {
+	hr := &auth.Principal{Subject: "hr", Roles: []string{"hr"}}
+	cases := []struct {
+		Caller        *auth.Principal
+		EmployeeID    int
+		Limit         int
+		ExpectedLimit int
+		ExpectedErr   error
+	}{
+		{
+			Caller:        hr,
+			EmployeeID:    1,
+			ExpectedLimit: defaultLookupsLimit,
+		},
+		{
+			Caller:        &auth.Principal{Subject: "dcooper", EmployeeID: 1},
+			EmployeeID:    1,
+			Limit:         maxLookupsLimit,
+			ExpectedLimit: maxLookupsLimit,
+		},
+		{
+			Caller:      &auth.Principal{Subject: "gcole", EmployeeID: 2, Roles: []string{"manager"}},
+			EmployeeID:  1,
+			ExpectedErr: policy.ErrForbidden,
+		},
+		{
+			EmployeeID:  0,
+			ExpectedErr: ErrIncorrectLookupFilter,
+		},
+		{
+			EmployeeID:  1,
+			Limit:       maxLookupsLimit + 1,
+			ExpectedErr: ErrIncorrectLookupFilter,
+		},
+		{
+			EmployeeID:  1,
+			Limit:       -1,
+			ExpectedErr: ErrIncorrectLookupFilter,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{t: t}
+			_, err := ListLookups(mock, tc.Caller, tc.EmployeeID, tc.Limit)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				return
+			}
+			if mock.listedEmployee != tc.EmployeeID || mock.listedLimit != tc.ExpectedLimit {
+				t.Errorf(
+					"expected the lookups of employee %d limited to %d, got employee %d and limit %d",
+					tc.EmployeeID, tc.ExpectedLimit, mock.listedEmployee, mock.listedLimit,
+				)
+			}
+		})
+	}
+}
+
+func TestLookupLogCleaner(t *testing.T) {
+	setNow(t, time.Date(2021, time.July, 10, 12, 0, 0, 0, time.UTC))
+	mock := &dbMock{t: t}
+	cleaner := NewLookupLogCleaner(func() (storage.DB, error) {
+		return mock, nil
+	}, 24*time.Hour)
+	if err := cleaner.clean(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := time.Date(2021, time.July, 9, 12, 0, 0, 0, time.UTC)
+	if !mock.deletedBefore.Equal(expected) {
+		t.Errorf("expected the lookups before %v to be deleted, got %v", expected, mock.deletedBefore)
+	}
+}
+
+func setNow(t *testing.T, at time.Time) {
+	prev := now
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = prev })
+}
+
 type dbMock struct {
 	t              *testing.T
 	expectedPrefix string
+	expectedNumber string
 	expectedError  error
+	recordError    error
 	phonesToReturn []*storage.FoundPhone
+	recorded       *storage.Lookup
+	listedEmployee int
+	listedLimit    int
+	deletedBefore  time.Time
+	viewer         *visibility.Viewer
+	viewerID       int
 }
 
 func (db *dbMock) GetPhonesByEmailPrefix(ctx context.Context, prefix string) ([]*storage.FoundPhone, error) {
@@ -116,4 +511,38 @@ func (db *dbMock) GetPhonesByEmailPrefix(ctx context.Context, prefix string) ([]
 	return db.phonesToReturn, db.expectedError
 }
 
+func (db *dbMock) GetPhonesByNumber(ctx context.Context, number string) ([]*storage.FoundPhone, error) {
+	if number != db.expectedNumber {
+		db.t.Errorf("error in DB mock: expected phone number: %s, got: %s", db.expectedNumber, number)
+		return nil, nil
+	}
+	return db.phonesToReturn, db.expectedError
+}
+
+func (db *dbMock) RecordLookup(ctx context.Context, l *storage.Lookup) (*storage.Lookup, error) {
+	if db.recordError != nil {
+		return nil, db.recordError
+	}
+	db.recorded = l
+	return l, nil
+}
+
+func (db *dbMock) ListLookups(ctx context.Context, employeeID int, limit int) ([]*storage.Lookup, error) {
+	db.listedEmployee, db.listedLimit = employeeID, limit
+	return nil, nil
+}
+
+func (db *dbMock) GetViewer(ctx context.Context, employeeID int) (*visibility.Viewer, error) {
+	db.viewerID = employeeID
+	if db.viewer == nil {
+		return &visibility.Viewer{}, nil
+	}
+	return db.viewer, nil
+}
+
+func (db *dbMock) DeleteLookupsBefore(ctx context.Context, t time.Time) (int64, error) {
+	db.deletedBefore = t
+	return 0, nil
+}
+
 func (db *dbMock) Close() {}
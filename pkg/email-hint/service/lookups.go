@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+)
+
+var ErrIncorrectLookupFilter = fmt.Errorf("got an incorrect lookup filter")
+
+// DefaultLookupRetention is how long the lookups are kept by default.
+const DefaultLookupRetention = 365 * 24 * time.Hour
+
+const (
+	defaultLookupsLimit = 100
+	maxLookupsLimit     = 1000
+)
+
+var now = time.Now
+
+// ListLookups returns the lookups that returned the employee's phone number,
+// the latest first. Only the employee and HR may see them.
+func ListLookups(db storage.DB, caller *auth.Principal, employeeID int, limit int) ([]*storage.Lookup, error) {
+	if employeeID <= 0 {
+		return nil, fmt.Errorf("%w: incorrect employee ID %d", ErrIncorrectLookupFilter, employeeID)
+	}
+	switch {
+	case limit == 0:
+		limit = defaultLookupsLimit
+	case limit < 0 || limit > maxLookupsLimit:
+		return nil, fmt.Errorf("%w: the limit must be between 1 and %d", ErrIncorrectLookupFilter, maxLookupsLimit)
+	}
+	if err := policy.NewActor(caller, nil).Check(policy.EntityPhoneLookups, policy.ActionRead, employeeID); err != nil {
+		return nil, err
+	}
+	lookups, err := db.ListLookups(context.Background(), employeeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list the lookups: %v", ErrDBRequestFailed, err)
+	}
+	return lookups, nil
+}
+
+// LookupLogCleaner deletes the lookups older than the retention period.
+type LookupLogCleaner struct {
+	newDB     func() (storage.DB, error)
+	retention time.Duration
+}
+
+func NewLookupLogCleaner(newDB func() (storage.DB, error), retention time.Duration) *LookupLogCleaner {
+	return &LookupLogCleaner{
+		newDB:     newDB,
+		retention: retention,
+	}
+}
+
+// Run deletes the expired lookups at once and then every interval until the
+// context is done.
+func (c *LookupLogCleaner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.clean(); err != nil {
+			log.Printf("failed to delete the expired phone lookups: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *LookupLogCleaner) clean() error {
+	db, err := c.newDB()
+	if err != nil {
+		return fmt.Errorf("failed to open the DB: %w", err)
+	}
+	defer db.Close()
+	deleted, err := db.DeleteLookupsBefore(context.Background(), now().Add(-c.retention))
+	if err != nil {
+		return err
+	}
+	if deleted != 0 {
+		log.Printf("deleted %d expired phone lookups", deleted)
+	}
+	return nil
+}
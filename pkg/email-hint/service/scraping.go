@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrLookupThrottled         = fmt.Errorf("too many phone lookups")
+	ErrIncorrectScrapingPolicy = fmt.Errorf("got an incorrect scraping policy")
+)
+
+// The reasons a caller is throttled for.
+const (
+	ScrapingReasonBudget   = "budget"
+	ScrapingReasonPrefixes = "prefixes"
+	ScrapingReasonSweep    = "sweep"
+)
+
+// ScrapingPolicy tells when the phone lookups of a caller look like an
+// enumeration of the directory.
+type ScrapingPolicy struct {
+	// Budget is the number of lookups a caller may make within Window.
+	Budget int
+	Window time.Duration
+	// MaxPrefixes is the number of distinct prefixes a caller may look up
+	// within Window.
+	MaxPrefixes int
+	// SweepLength is the number of consecutive lookups with alphabetically
+	// increasing prefixes, like "aa", "ab", "ac", that makes a sweep. A prefix
+	// extending the previous one continues a search and is not a step.
+	SweepLength int
+	// BaseDelay is how long a caller is throttled for the first offence, the
+	// delay doubles with every further offence within Window up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+var DefaultScrapingPolicy = ScrapingPolicy{
+	Budget:      200,
+	Window:      time.Hour,
+	MaxPrefixes: 100,
+	SweepLength: 10,
+	BaseDelay:   time.Minute,
+	MaxDelay:    24 * time.Hour,
+}
+
+func (p *ScrapingPolicy) validate() error {
+	if p.Budget <= 0 || p.MaxPrefixes <= 0 || p.SweepLength <= 1 {
+		return fmt.Errorf("%w: the budget and the prefixes must be positive, the sweep longer than 1", ErrIncorrectScrapingPolicy)
+	}
+	if p.Window <= 0 || p.BaseDelay <= 0 || p.MaxDelay < p.BaseDelay {
+		return fmt.Errorf("%w: the window and the delays must be positive, the max delay not below the base one", ErrIncorrectScrapingPolicy)
+	}
+	return nil
+}
+
+// ScrapingAlert is raised every time a caller is throttled.
+type ScrapingAlert struct {
+	Caller string `json:"caller"`
+	Reason string `json:"reason"`
+	// Lookups and Prefixes are the numbers of the lookups and of the distinct
+	// prefixes within the window.
+	Lookups  int `json:"lookups"`
+	Prefixes int `json:"prefixes"`
+	// Offences is the number of times the caller has been throttled in a row.
+	Offences      int       `json:"offences"`
+	ThrottledTill time.Time `json:"throttled_till"`
+}
+
+// ScrapingNotifier delivers a scraping alert.
+type ScrapingNotifier interface {
+	Notify(ctx context.Context, a *ScrapingAlert) error
+}
+
+// LogScrapingNotifier writes the scraping alerts to the standard logger.
+type LogScrapingNotifier struct{}
+
+func (LogScrapingNotifier) Notify(ctx context.Context, a *ScrapingAlert) error {
+	log.Printf(
+		"[WARN]: phone lookups of %q throttled till %s for %s: %d lookups, %d prefixes, offence #%d",
+		a.Caller, a.ThrottledTill.Format(time.RFC3339), a.Reason, a.Lookups, a.Prefixes, a.Offences,
+	)
+	return nil
+}
+
+// ScrapingGuard keeps the recent lookups of every caller in memory and
+// throttles the callers that enumerate the directory.
+type ScrapingGuard struct {
+	policy    ScrapingPolicy
+	notifiers []ScrapingNotifier
+
+	mux         sync.Mutex
+	callers     map[string]*callerLookups
+	lastCleanup time.Time
+}
+
+type callerLookups struct {
+	lookups       []*pastLookup
+	lastPrefix    string
+	sweep         int
+	offences      int
+	lastOffence   time.Time
+	throttledTill time.Time
+}
+
+type pastLookup struct {
+	at     time.Time
+	prefix string
+}
+
+func NewScrapingGuard(policy ScrapingPolicy, notifiers []ScrapingNotifier) (*ScrapingGuard, error) {
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	return &ScrapingGuard{
+		policy:      policy,
+		notifiers:   notifiers,
+		callers:     make(map[string]*callerLookups),
+		lastCleanup: now(),
+	}, nil
+}
+
+// Allow counts the caller's lookup of the prefix. ErrLookupThrottled is
+// returned and the lookup is not counted if the caller is throttled or the
+// lookup exceeds the policy, in which case the caller is throttled and the
+// notifiers are alerted.
+func (g *ScrapingGuard) Allow(caller string, prefix string) error {
+	alert, err := g.allow(caller, prefix)
+	if alert != nil {
+		for _, n := range g.notifiers {
+			if err := n.Notify(context.Background(), alert); err != nil {
+				log.Printf("failed to deliver the scraping alert: %v", err)
+			}
+		}
+	}
+	return err
+}
+
+// RetryAfter returns how long the caller is throttled for.
+func (g *ScrapingGuard) RetryAfter(caller string) time.Duration {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	c, ok := g.callers[caller]
+	if !ok {
+		return 0
+	}
+	if d := c.throttledTill.Sub(now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func (g *ScrapingGuard) allow(caller string, prefix string) (*ScrapingAlert, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	at := now()
+	g.cleanup(at)
+	c, ok := g.callers[caller]
+	if !ok {
+		c = &callerLookups{}
+		g.callers[caller] = c
+	}
+	c.expire(at, g.policy.Window)
+	if at.Before(c.throttledTill) {
+		return nil, fmt.Errorf("%w: %q is throttled till %s", ErrLookupThrottled, caller, c.throttledTill.Format(time.RFC3339))
+	}
+
+	sweep := 1
+	if prefix > c.lastPrefix && !strings.HasPrefix(prefix, c.lastPrefix) {
+		sweep = c.sweep + 1
+	}
+	lookups := len(c.lookups) + 1
+	prefixes := c.prefixesWith(prefix)
+	reason := ""
+	switch {
+	case lookups > g.policy.Budget:
+		reason = ScrapingReasonBudget
+	case prefixes > g.policy.MaxPrefixes:
+		reason = ScrapingReasonPrefixes
+	case sweep >= g.policy.SweepLength:
+		reason = ScrapingReasonSweep
+	}
+	if len(reason) == 0 {
+		c.lookups = append(c.lookups, &pastLookup{at: at, prefix: prefix})
+		c.lastPrefix, c.sweep = prefix, sweep
+		return nil, nil
+	}
+
+	if at.Sub(c.lastOffence) > g.policy.Window {
+		c.offences = 0
+	}
+	c.offences++
+	c.lastOffence = at
+	c.throttledTill = at.Add(g.policy.delay(c.offences))
+	alert := &ScrapingAlert{
+		Caller:        caller,
+		Reason:        reason,
+		Lookups:       lookups,
+		Prefixes:      prefixes,
+		Offences:      c.offences,
+		ThrottledTill: c.throttledTill,
+	}
+	return alert, fmt.Errorf("%w: %q is throttled till %s for the %s", ErrLookupThrottled, caller, c.throttledTill.Format(time.RFC3339), reason)
+}
+
+// delay returns how long a caller is throttled for the offence, counting from
+// one.
+func (p *ScrapingPolicy) delay(offence int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < offence && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return d
+}
+
+// cleanup forgets the callers with no lookups, throttling or offences within
+// the window, at most once a window.
+func (g *ScrapingGuard) cleanup(at time.Time) {
+	if at.Sub(g.lastCleanup) < g.policy.Window {
+		return
+	}
+	g.lastCleanup = at
+	for caller, c := range g.callers {
+		c.expire(at, g.policy.Window)
+		if len(c.lookups) == 0 && !at.Before(c.throttledTill) && at.Sub(c.lastOffence) > g.policy.Window {
+			delete(g.callers, caller)
+		}
+	}
+}
+
+// expire forgets the lookups made before the window.
+func (c *callerLookups) expire(at time.Time, window time.Duration) {
+	i := 0
+	for i < len(c.lookups) && at.Sub(c.lookups[i].at) >= window {
+		i++
+	}
+	c.lookups = c.lookups[i:]
+}
+
+// prefixesWith returns the number of the distinct prefixes within the window
+// including the prefix.
+func (c *callerLookups) prefixesWith(prefix string) int {
+	distinct := map[string]struct{}{prefix: {}}
+	for _, l := range c.lookups {
+		distinct[l.prefix] = struct{}{}
+	}
+	return len(distinct)
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestScrapingGuard(t *testing.T) {
+	policy := ScrapingPolicy{
+		Budget:      5,
+		Window:      time.Hour,
+		MaxPrefixes: 4,
+		SweepLength: 4,
+		BaseDelay:   time.Minute,
+		MaxDelay:    time.Hour,
+	}
+	type lookup struct {
+		After          time.Duration
+		Caller         string
+		Prefix         string
+		ExpectedReason string
+		ExpectedErr    error
+	}
+	cases := []struct {
+		Lookups []lookup
+	}{
+		// a search refining the prefix is not a sweep
+		{
+			Lookups: []lookup{
+				{Prefix: "ali"},
+				{Prefix: "alid"},
+				{Prefix: "alidd"},
+				{Prefix: "aliddell"},
+			},
+		},
+		{
+			Lookups: []lookup{
+				{Prefix: "ali"},
+				{Prefix: "ali"},
+				{Prefix: "ali"},
+				{Prefix: "ali"},
+				{Prefix: "ali"},
+				{Prefix: "ali", ExpectedReason: ScrapingReasonBudget, ExpectedErr: ErrLookupThrottled},
+				{After: 30 * time.Second, Prefix: "ali", ExpectedErr: ErrLookupThrottled},
+				{After: 31 * time.Second, Prefix: "ali", ExpectedReason: ScrapingReasonBudget, ExpectedErr: ErrLookupThrottled},
+				{After: time.Hour, Prefix: "ali"},
+			},
+		},
+		{
+			Lookups: []lookup{
+				{Prefix: "zzz"},
+				{Prefix: "yyy"},
+				{Prefix: "xxx"},
+				{Prefix: "www"},
+				{Prefix: "vvv", ExpectedReason: ScrapingReasonPrefixes, ExpectedErr: ErrLookupThrottled},
+				{Prefix: "www", ExpectedErr: ErrLookupThrottled},
+				{After: time.Minute, Prefix: "www"},
+			},
+		},
+		{
+			Lookups: []lookup{
+				{Prefix: "aaa"},
+				{Prefix: "aab"},
+				{Prefix: "aac"},
+				{Prefix: "aad", ExpectedReason: ScrapingReasonSweep, ExpectedErr: ErrLookupThrottled},
+				{After: time.Minute, Prefix: "aae", ExpectedReason: ScrapingReasonSweep, ExpectedErr: ErrLookupThrottled},
+				{After: 2 * time.Minute, Prefix: "aaa"},
+			},
+		},
+		// the callers are throttled separately
+		{
+			Lookups: []lookup{
+				{Caller: "alice", Prefix: "aaa"},
+				{Caller: "alice", Prefix: "aab"},
+				{Caller: "bob", Prefix: "aac"},
+				{Caller: "alice", Prefix: "aac"},
+				{Caller: "bob", Prefix: "aad"},
+				{Caller: "alice", Prefix: "aad", ExpectedReason: ScrapingReasonSweep, ExpectedErr: ErrLookupThrottled},
+				{Caller: "bob", Prefix: "aae"},
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			at := time.Date(2021, time.July, 10, 12, 0, 0, 0, time.UTC)
+			setNow(t, at)
+			notifier := &scrapingNotifierMock{}
+			guard, err := NewScrapingGuard(policy, []ScrapingNotifier{notifier})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for j, l := range tc.Lookups {
+				at = at.Add(l.After)
+				setNow(t, at)
+				notifier.alerts = nil
+				err := guard.Allow(l.Caller, l.Prefix)
+				if err := compareErrs(l.ExpectedErr, err); err != nil {
+					t.Errorf("lookup %d: %v", j, err)
+					return
+				}
+				if len(l.ExpectedReason) == 0 {
+					if len(notifier.alerts) != 0 {
+						t.Errorf("lookup %d: unexpected alert %+v", j, notifier.alerts[0])
+						return
+					}
+					continue
+				}
+				if len(notifier.alerts) != 1 || notifier.alerts[0].Reason != l.ExpectedReason {
+					t.Errorf("lookup %d: expected a %s alert, got %+v", j, l.ExpectedReason, notifier.alerts)
+					return
+				}
+				alert := notifier.alerts[0]
+				if retryAfter := guard.RetryAfter(l.Caller); !alert.ThrottledTill.Equal(at.Add(retryAfter)) {
+					t.Errorf("lookup %d: expected to retry at %v, got %v", j, alert.ThrottledTill, at.Add(retryAfter))
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestScrapingGuardProgressiveDelay(t *testing.T) {
+	policy := ScrapingPolicy{
+		Budget:      1,
+		Window:      24 * time.Hour,
+		MaxPrefixes: 1,
+		SweepLength: 2,
+		BaseDelay:   time.Minute,
+		MaxDelay:    5 * time.Minute,
+	}
+	at := time.Date(2021, time.July, 10, 12, 0, 0, 0, time.UTC)
+	setNow(t, at)
+	guard, err := NewScrapingGuard(policy, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := guard.Allow("bob", "ali"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, expected := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		if err := guard.Allow("bob", "ali"); err == nil {
+			t.Fatalf("offence %d: the lookup over the budget was allowed", i)
+		}
+		if retryAfter := guard.RetryAfter("bob"); retryAfter != expected {
+			t.Fatalf("offence %d: expected to be throttled for %v, got %v", i, expected, retryAfter)
+		}
+		at = at.Add(expected)
+		setNow(t, at)
+		if retryAfter := guard.RetryAfter("bob"); retryAfter != 0 {
+			t.Fatalf("offence %d: expected the throttling to be over, got %v", i, retryAfter)
+		}
+	}
+}
+
+func TestNewScrapingGuard(t *testing.T) {
+	cases := []struct {
+		Policy      ScrapingPolicy
+		ExpectedErr error
+	}{
+		{
+			Policy: DefaultScrapingPolicy,
+		},
+		{
+			Policy:      ScrapingPolicy{},
+			ExpectedErr: ErrIncorrectScrapingPolicy,
+		},
+		{
+			Policy: ScrapingPolicy{
+				Budget:      1,
+				Window:      time.Hour,
+				MaxPrefixes: 1,
+				SweepLength: 1,
+				BaseDelay:   time.Minute,
+				MaxDelay:    time.Hour,
+			},
+			ExpectedErr: ErrIncorrectScrapingPolicy,
+		},
+		{
+			Policy: ScrapingPolicy{
+				Budget:      1,
+				Window:      time.Hour,
+				MaxPrefixes: 1,
+				SweepLength: 2,
+				BaseDelay:   time.Hour,
+				MaxDelay:    time.Minute,
+			},
+			ExpectedErr: ErrIncorrectScrapingPolicy,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			_, err := NewScrapingGuard(tc.Policy, nil)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestGetPhonesByEmailPrefixThrottled(t *testing.T) {
+	setNow(t, time.Date(2021, time.July, 10, 12, 0, 0, 0, time.UTC))
+	policy := DefaultScrapingPolicy
+	policy.Budget = 1
+	guard, err := NewScrapingGuard(policy, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mock := &dbMock{
+		t:              t,
+		expectedPrefix: "ali",
+	}
+	if _, err := GetPhonesByEmailPrefix(mock, guard, "bob", 0, "ali"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mock.recorded = nil
+	_, err = GetPhonesByEmailPrefix(mock, guard, "bob", 0, "ali")
+	if err := compareErrs(ErrLookupThrottled, err); err != nil {
+		t.Fatal(err)
+	}
+	if mock.recorded != nil {
+		t.Errorf("the throttled lookup was recorded")
+	}
+	// the rejected prefixes do not count
+	_, err = GetPhonesByEmailPrefix(mock, guard, "alice", 0, "%")
+	if err := compareErrs(ErrIncorrectEmailPrefix, err); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetPhonesByEmailPrefix(mock, guard, "alice", 0, "ali"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+type scrapingNotifierMock struct {
+	alerts []*ScrapingAlert
+}
+
+func (n *scrapingNotifierMock) Notify(ctx context.Context, a *ScrapingAlert) error {
+	n.alerts = append(n.alerts, a)
+	return nil
+}
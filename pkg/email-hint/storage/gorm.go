@@ -5,21 +5,26 @@ import (
 	"fmt"
 	"time"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/encryption"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
 )
 
 type Employee struct {
-	ID         int       `gorm:"column:id"`
-	FirstName  string    `gorm:"column:first_name"`
-	LastName   string    `gorm:"column:last_name"`
-	Salary     string    `gorm:"column:salary"`
-	ManagerID  int       `gorm:"column:manager_id"`
-	Department int       `gorm:"column:department"`
-	Position   int       `gorm:"column:position"`
-	EntryAt    time.Time `gorm:"column:entry_at"`
-	Phone      string    `gorm:"column:phone"`
-	Email      string    `gorm:"column:email"`
+	ID         int         `gorm:"column:id"`
+	FirstName  string      `gorm:"column:first_name"`
+	LastName   string      `gorm:"column:last_name"`
+	Salary     money.Money `gorm:"embedded;embeddedPrefix:salary_"`
+	ManagerID  int         `gorm:"column:manager_id"`
+	Department int         `gorm:"column:department"`
+	Position   int         `gorm:"column:position"`
+	EntryAt    time.Time   `gorm:"column:entry_at"`
+
+	PhoneVisibility *string `gorm:"column:phone_visibility"`
+	EmailVisibility *string `gorm:"column:email_visibility"`
 }
 
 type gormDB struct {
@@ -27,9 +32,9 @@ type gormDB struct {
 }
 
 func newGormDB(c *ConnString) (*gormDB, error) {
-	db, err := gorm.Open(postgres.Open(composeGormDSN(c)), &gorm.Config{})
+	db, err := database.OpenGorm(c)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open Gorm connection: %w", err)
+		return nil, err
 	}
 	return &gormDB{
 		db: db,
@@ -37,36 +42,156 @@ func newGormDB(c *ConnString) (*gormDB, error) {
 }
 
 func (g *gormDB) GetPhonesByEmailPrefix(ctx context.Context, prefix string) ([]*FoundPhone, error) {
+	k, err := encryption.Current()
+	if err != nil {
+		return nil, err
+	}
 	var emps []Employee
-	req := g.db.
-		Select("first_name", "last_name", "phone", "email").
-		Where("email LIKE ?", prefix+"%").
+	req := g.db.WithContext(ctx).
+		Select("id", "first_name", "last_name", "department", "phone_visibility", "email_visibility").
+		Where(`id IN (SELECT employee_id FROM employee_emails WHERE address_prefixes @> ARRAY[?])`, k.Index(prefix)).
+		Where("terminated_on IS NULL OR terminated_on >= CURRENT_DATE").
+		Order("id").
 		Find(&emps)
 	if err := req.Error; err != nil {
 		return nil, fmt.Errorf("failed to query phones by email: %w", err)
 	}
+	return g.loadContacts(ctx, k, emps)
+}
+
+func (g *gormDB) GetPhonesByNumber(ctx context.Context, number string) ([]*FoundPhone, error) {
+	k, err := encryption.Current()
+	if err != nil {
+		return nil, err
+	}
+	var emps []Employee
+	req := g.db.WithContext(ctx).
+		Select("id", "first_name", "last_name", "department", "phone_visibility", "email_visibility").
+		Where(`id IN (SELECT employee_id FROM employee_phones WHERE number_index = ?)`, k.Index(number)).
+		Where("terminated_on IS NULL OR terminated_on >= CURRENT_DATE").
+		Order("id").
+		Find(&emps)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query phones by number: %w", err)
+	}
+	return g.loadContacts(ctx, k, emps)
+}
+
+// loadContacts returns the found employees with their phones and emails
+// opened with the keyring.
+func (g *gormDB) loadContacts(ctx context.Context, k *encryption.Keyring, emps []Employee) ([]*FoundPhone, error) {
+	var err error
 	phones := make([]*FoundPhone, len(emps))
+	byID := make(map[int]*FoundPhone, len(emps))
+	ids := make([]int, len(emps))
 	for i, e := range emps {
 		p := &FoundPhone{
-			FirstName: e.FirstName,
-			LastName:  e.LastName,
-			Phone:     e.Phone,
-			Email:     e.Email,
+			EmployeeID: e.ID,
+			FirstName:  e.FirstName,
+			LastName:   e.LastName,
+			Phones:     make([]*Phone, 0),
+			Emails:     make([]*Email, 0),
+
+			Department:      e.Department,
+			PhoneVisibility: e.PhoneVisibility,
+			EmailVisibility: e.EmailVisibility,
 		}
 		phones[i] = p
+		byID[e.ID] = p
+		ids[i] = e.ID
+	}
+	if len(ids) == 0 {
+		return phones, nil
+	}
+	var numbers []*Phone
+	if err := g.db.WithContext(ctx).Where("employee_id IN ?", ids).Order("employee_id, ordinal").Find(&numbers).Error; err != nil {
+		return nil, fmt.Errorf("failed to query the phones of the found employees: %w", err)
+	}
+	for _, n := range numbers {
+		if n.Number, err = k.Open(n.Number); err != nil {
+			return nil, fmt.Errorf("failed to open the phone of employee %d: %w", n.EmployeeID, err)
+		}
+		p := byID[n.EmployeeID]
+		p.Phones = append(p.Phones, n)
+		if n.Primary {
+			p.Phone = n.Number
+		}
+	}
+	var addresses []*Email
+	if err := g.db.WithContext(ctx).Where("employee_id IN ?", ids).Order("employee_id, ordinal").Find(&addresses).Error; err != nil {
+		return nil, fmt.Errorf("failed to query the emails of the found employees: %w", err)
+	}
+	for _, a := range addresses {
+		if a.Address, err = k.Open(a.Address); err != nil {
+			return nil, fmt.Errorf("failed to open the email of employee %d: %w", a.EmployeeID, err)
+		}
+		p := byID[a.EmployeeID]
+		p.Emails = append(p.Emails, a)
+		if a.Primary {
+			p.Email = a.Address
+		}
 	}
 	return phones, nil
 }
 
-func (g *gormDB) Close() {}
-
-func composeGormDSN(c *ConnString) string {
-	return fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Europe/Moscow",
-		c.Host,
-		c.User,
-		c.Password,
-		c.DBName,
-		c.Port,
-	)
+func (g *gormDB) RecordLookup(ctx context.Context, l *Lookup) (*Lookup, error) {
+	var recorded []*Lookup
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(
+			`INSERT INTO phone_lookups (caller, prefix) VALUES (?, ?) RETURNING *`,
+			l.Caller,
+			l.Prefix,
+		).Scan(&recorded).Error
+		if err != nil {
+			return err
+		}
+		if len(recorded) == 0 {
+			return fmt.Errorf("no row returned")
+		}
+		for _, id := range l.EmployeeIDs {
+			err := tx.Exec(
+				`INSERT INTO phone_lookup_results (lookup_id, employee_id) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+				recorded[0].ID,
+				id,
+			).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record the phone lookup: %w", err)
+	}
+	recorded[0].EmployeeIDs = l.EmployeeIDs
+	return recorded[0], nil
+}
+
+func (g *gormDB) ListLookups(ctx context.Context, employeeID int, limit int) ([]*Lookup, error) {
+	var lookups []*Lookup
+	req := g.db.WithContext(ctx).
+		Where("id IN (SELECT lookup_id FROM phone_lookup_results WHERE employee_id = ?)", employeeID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&lookups)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query the phone lookups: %w", err)
+	}
+	return lookups, nil
+}
+
+func (g *gormDB) GetViewer(ctx context.Context, employeeID int) (*visibility.Viewer, error) {
+	return visibility.LoadViewer(g.db.WithContext(ctx), employeeID)
+}
+
+func (g *gormDB) DeleteLookupsBefore(ctx context.Context, t time.Time) (int64, error) {
+	req := g.db.WithContext(ctx).Where("created_at < ?", t).Delete(&Lookup{})
+	if err := req.Error; err != nil {
+		return 0, fmt.Errorf("failed to delete the old phone lookups: %w", err)
+	}
+	return req.RowsAffected, nil
+}
+
+func (g *gormDB) Close() {
+	database.CloseGorm(g.db)
 }
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
+)
+
+type ContextKey int
+
+const ContextKeyDB ContextKey = iota + 1
+
+// FoundPhone is an employee found by an email prefix or a phone number. Phone and Email are the
+// primary contacts, Phones and Emails list all of them.
+type FoundPhone struct {
+	EmployeeID int      `json:"-"`
+	FirstName  string   `json:"first_name"`
+	LastName   string   `json:"last_name"`
+	Phone      string   `json:"phone"`
+	Email      string   `json:"email"`
+	Phones     []*Phone `json:"phones"`
+	Emails     []*Email `json:"emails"`
+	// Department and the visibility of the contacts decide who sees them.
+	Department      int     `json:"-"`
+	PhoneVisibility *string `json:"-"`
+	EmailVisibility *string `json:"-"`
+}
+
+// Phone is a phone number of an employee.
+type Phone struct {
+	EmployeeID int    `gorm:"column:employee_id" json:"-"`
+	Type       string `gorm:"column:type" json:"type"`
+	Number     string `gorm:"column:number" json:"number"`
+	Primary    bool   `gorm:"column:is_primary" json:"primary"`
+}
+
+func (Phone) TableName() string {
+	return "employee_phones"
+}
+
+// Email is an email address of an employee, a work address or an alias.
+type Email struct {
+	EmployeeID int    `gorm:"column:employee_id" json:"-"`
+	Type       string `gorm:"column:type" json:"type"`
+	Address    string `gorm:"column:address" json:"address"`
+	Primary    bool   `gorm:"column:is_primary" json:"primary"`
+}
+
+func (Email) TableName() string {
+	return "employee_emails"
+}
+
+// Lookup is a search of the phone numbers by an email prefix made by Caller.
+// EmployeeIDs are the employees whose numbers were returned.
+type Lookup struct {
+	ID          int64     `gorm:"column:id" json:"id"`
+	Caller      string    `gorm:"column:caller" json:"caller"`
+	Prefix      string    `gorm:"column:prefix" json:"prefix"`
+	EmployeeIDs []int     `gorm:"-" json:"employee_ids,omitempty"`
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (Lookup) TableName() string {
+	return "phone_lookups"
+}
+
+type DB interface {
+	// GetPhonesByEmailPrefix returns the current employees with an email
+	// address or an alias starting with the prefix. The prefix is matched
+	// literally by the blind indexes of the address prefixes, so it must be
+	// at least encryption.MinPrefixLength long. The addresses are stored
+	// lowercased, so the prefix must be lowercased as well.
+	GetPhonesByEmailPrefix(ctx context.Context, prefix string) ([]*FoundPhone, error)
+	// GetPhonesByNumber returns the current employees with the phone number,
+	// matched exactly by the blind index of the numbers. The numbers are
+	// stored trimmed, so the number must be trimmed as well.
+	GetPhonesByNumber(ctx context.Context, number string) ([]*FoundPhone, error)
+	RecordLookup(ctx context.Context, l *Lookup) (*Lookup, error)
+	// ListLookups returns the lookups that returned the employee's phone
+	// number, the latest first, without the IDs of the returned employees.
+	ListLookups(ctx context.Context, employeeID int, limit int) ([]*Lookup, error)
+	// GetViewer returns the employee as a viewer of the contacts of the other
+	// employees.
+	GetViewer(ctx context.Context, employeeID int) (*visibility.Viewer, error)
+	// DeleteLookupsBefore deletes the lookups made before the time and
+	// returns the number of deleted lookups.
+	DeleteLookupsBefore(ctx context.Context, t time.Time) (int64, error)
+	Close()
+}
+
+type ConnString = database.ConnString
+
+func NewDB(connStr *ConnString) (DB, error) {
+	gormDB, err := newGormDB(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a gorm connection: %w", err)
+	}
+	return gormDB, nil
+}
@@ -6,4 +6,8 @@ CREATE DATABASE gopher_corp
     TEMPLATE = 'template0'
     ENCODING = 'utf-8'
     LC_COLLATE = 'C.UTF-8'
-    LC_CTYPE = 'C.UTF-8';
\ No newline at end of file
+    LC_CTYPE = 'C.UTF-8';
+
+CREATE ROLE gopher_app NOLOGIN;
+
+GRANT gopher_app TO gopher;
@@ -1,3 +1,4 @@
+//go:build integration_tests
 // +build integration_tests
 
 package storage
@@ -9,13 +10,17 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"sort"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
 	"github.com/SergeyShpak/gopher-corp-backend/pkg/email-hint/storage"
+	employeesStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/encryption"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/ory/dockertest/v3"
@@ -92,6 +97,12 @@ func setup() (r *setupResult, err error) {
 		}
 	}()
 
+	keyring, err := encryption.ParseKeyring([]byte(testKeyring))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the test keyring: %w", err)
+	}
+	encryption.SetKeyring(keyring)
+
 	if err := pool.Retry(func() error {
 		err := prepopulateDB(testFileDir)
 		if err != nil {
@@ -101,6 +112,9 @@ func setup() (r *setupResult, err error) {
 	}); err != nil {
 		return nil, fmt.Errorf("failed to prepopulate the DB: %w", err)
 	}
+	if err := sealContacts(); err != nil {
+		return nil, fmt.Errorf("failed to seal the prepopulated contacts: %w", err)
+	}
 
 	return &setupResult{
 		Pool:              pool,
@@ -251,17 +265,9 @@ func TestGetPhonesByEmailPrefix(t *testing.T) {
 		},
 	}
 	batch := &pgx.Batch{}
-	const query = `INSERT INTO employees (first_name, last_name, phone, email, salary, manager_id, department, position)
-		VALUES(
-			$1, $2, $3, $4, 
-			45000,
-			(SELECT id FROM employees WHERE first_name = 'Bob' AND last_name = 'Morane' LIMIT 1),
-			(SELECT id FROM departments WHERE name = 'R&D'),
-			(SELECT id FROM positions WHERE title = 'Backend Dev')
-		)`
 	for _, e := range employees {
 		batch.Queue(
-			query,
+			insertEmployeeQuery,
 			e.FirstName,
 			e.LastName,
 			e.Phone,
@@ -271,11 +277,15 @@ func TestGetPhonesByEmailPrefix(t *testing.T) {
 				string(strings.ToLower(e.FirstName)[0]),
 				strings.ToLower(e.LastName),
 			),
+			nil,
 		)
 	}
 	if _, err := conn.SendBatch(context.Background(), batch).Exec(); err != nil {
 		t.Fatalf("failed to create DB data: %v", err)
 	}
+	if err := sealContacts(); err != nil {
+		t.Fatalf("failed to seal the contacts: %v", err)
+	}
 
 	db, err := storage.NewDB(getConnectionString())
 	if err != nil {
@@ -296,12 +306,311 @@ func TestGetPhonesByEmailPrefix(t *testing.T) {
 	})
 	for i, e := range employees {
 		p := *phones[i]
-		if e != p {
+		if p.EmployeeID == 0 {
+			t.Fatalf("the employee ID of %v is not returned", p)
+		}
+		if len(p.Phones) != 1 || p.Phones[0].Number != e.Phone || len(p.Emails) != 1 || p.Emails[0].Address != e.Email {
+			t.Fatalf("expected only the primary contacts of %v, got %+v and %+v", e, p.Phones, p.Emails)
+		}
+		p.EmployeeID, p.Department = 0, 0
+		p.Phones, p.Emails = nil, nil
+		if !reflect.DeepEqual(e, p) {
 			t.Fatalf("expected object %v is not equal to the actual object %v", e, p)
 		}
 	}
 }
 
+func TestGetPhonesByEmailPrefixSkipsLeftEmployees(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+
+	emailTestPrefix := "test_get_phones_skips_left"
+	// the employee is found on their last day, but not after it
+	batch := &pgx.Batch{}
+	batch.Queue(insertEmployeeQuery, "Leland", "Palmer", "+75678", emailTestPrefix+"_lpalmer@gopher_corp.com", 1)
+	batch.Queue(insertEmployeeQuery, "Shelly", "Johnson", "+76789", emailTestPrefix+"_sjohnson@gopher_corp.com", 0)
+	if _, err := conn.SendBatch(context.Background(), batch).Exec(); err != nil {
+		t.Fatalf("failed to create DB data: %v", err)
+	}
+	if err := sealContacts(); err != nil {
+		t.Fatalf("failed to seal the contacts: %v", err)
+	}
+
+	db, err := storage.NewDB(getConnectionString())
+	if err != nil {
+		t.Fatalf("failed to create a DB object: %v", err)
+	}
+	phones, err := db.GetPhonesByEmailPrefix(context.Background(), emailTestPrefix)
+	if err != nil {
+		t.Fatalf("GetPhonesByEmailPrefix failed: %v", err)
+	}
+	if len(phones) != 1 || phones[0].LastName != "Johnson" {
+		t.Fatalf("expected to find only the employee on their last day, got %+v", phones)
+	}
+}
+
+func TestGetPhonesByEmailPrefixMatchesAliases(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+
+	emailTestPrefix := "test_get_phones_by_alias"
+	var employeeID int
+	err = conn.QueryRow(
+		context.Background(),
+		insertEmployeeQuery+` RETURNING employee_id`,
+		"Harry", "Truman", "+77890", "htruman@gopher_corp.com", nil,
+	).Scan(&employeeID)
+	if err != nil {
+		t.Fatalf("failed to create DB data: %v", err)
+	}
+	batch := &pgx.Batch{}
+	batch.Queue(
+		`INSERT INTO employee_phones (employee_id, type, number, ordinal) VALUES ($1, 'mobile', '+78901', 1)`,
+		employeeID,
+	)
+	batch.Queue(
+		`INSERT INTO employee_emails (employee_id, type, address, ordinal) VALUES ($1, 'alias', $2, 1)`,
+		employeeID,
+		emailTestPrefix+"_sheriff@gopher_corp.com",
+	)
+	if _, err := conn.SendBatch(context.Background(), batch).Exec(); err != nil {
+		t.Fatalf("failed to create DB data: %v", err)
+	}
+	if err := sealContacts(); err != nil {
+		t.Fatalf("failed to seal the contacts: %v", err)
+	}
+
+	db, err := storage.NewDB(getConnectionString())
+	if err != nil {
+		t.Fatalf("failed to create a DB object: %v", err)
+	}
+	phones, err := db.GetPhonesByEmailPrefix(context.Background(), emailTestPrefix)
+	if err != nil {
+		t.Fatalf("GetPhonesByEmailPrefix failed: %v", err)
+	}
+	if len(phones) != 1 || phones[0].EmployeeID != employeeID {
+		t.Fatalf("expected to find employee %d by the alias, got %+v", employeeID, phones)
+	}
+	p := phones[0]
+	if p.Phone != "+77890" || p.Email != "htruman@gopher_corp.com" {
+		t.Fatalf("expected the primary contacts to be returned, got %q and %q", p.Phone, p.Email)
+	}
+	expectedPhones := []*storage.Phone{
+		{EmployeeID: employeeID, Type: "work", Number: "+77890", Primary: true},
+		{EmployeeID: employeeID, Type: "mobile", Number: "+78901"},
+	}
+	expectedEmails := []*storage.Email{
+		{EmployeeID: employeeID, Type: "work", Address: "htruman@gopher_corp.com", Primary: true},
+		{EmployeeID: employeeID, Type: "alias", Address: emailTestPrefix + "_sheriff@gopher_corp.com"},
+	}
+	if !reflect.DeepEqual(p.Phones, expectedPhones) || !reflect.DeepEqual(p.Emails, expectedEmails) {
+		t.Fatalf("expected all the contacts in order, got %+v and %+v", p.Phones, p.Emails)
+	}
+}
+
+func TestGetPhonesByEmailPrefixMatchesIndexedPrefixes(t *testing.T) {
+	db, err := storage.NewDB(getConnectionString())
+	if err != nil {
+		t.Fatalf("failed to create a DB object: %v", err)
+	}
+	// only the prefixes of the addresses at least MinPrefixLength long are
+	// indexed, the other strings are matched by no address
+	cases := []struct {
+		Prefix   string
+		Expected bool
+	}{
+		{Prefix: "bmo", Expected: true},
+		{Prefix: "bmorane@gopher_", Expected: true},
+		{Prefix: "bmorane@gopher_corp.com", Expected: true},
+		{Prefix: "bm"},
+		{Prefix: "morane"},
+		{Prefix: "bmorane@gopher_corp.com."},
+		{Prefix: "BMorane"},
+		{Prefix: "bmorane@gopher%"},
+		{Prefix: "_morane"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			phones, err := db.GetPhonesByEmailPrefix(context.Background(), tc.Prefix)
+			if err != nil {
+				t.Fatalf("GetPhonesByEmailPrefix(%q) failed: %v", tc.Prefix, err)
+			}
+			if !tc.Expected && len(phones) != 0 {
+				t.Fatalf("expected prefix %q to match nothing, got %+v", tc.Prefix, phones)
+			}
+			if tc.Expected && (len(phones) != 1 || phones[0].LastName != "Morane") {
+				t.Fatalf("expected prefix %q to match Bob Morane, got %+v", tc.Prefix, phones)
+			}
+		})
+	}
+}
+
+func TestGetPhonesByNumberMatchesExactNumbers(t *testing.T) {
+	db, err := storage.NewDB(getConnectionString())
+	if err != nil {
+		t.Fatalf("failed to create a DB object: %v", err)
+	}
+	// the numbers are matched by their blind index, only the whole number
+	// matches
+	cases := []struct {
+		Number   string
+		Expected bool
+	}{
+		{Number: "+79231234567", Expected: true},
+		{Number: "+7923123456"},
+		{Number: "79231234567"},
+		{Number: "+79231234567 "},
+		{Number: "+7923%"},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			phones, err := db.GetPhonesByNumber(context.Background(), tc.Number)
+			if err != nil {
+				t.Fatalf("GetPhonesByNumber(%q) failed: %v", tc.Number, err)
+			}
+			if !tc.Expected && len(phones) != 0 {
+				t.Fatalf("expected number %q to match nothing, got %+v", tc.Number, phones)
+			}
+			if tc.Expected && (len(phones) != 1 || phones[0].LastName != "Morane" || phones[0].Phone != tc.Number) {
+				t.Fatalf("expected number %q to match Bob Morane, got %+v", tc.Number, phones)
+			}
+		})
+	}
+}
+
+func TestEmailPrefixSearchUsesIndex(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	keyring, err := encryption.Current()
+	if err != nil {
+		t.Fatalf("failed to get the keyring: %v", err)
+	}
+	tx, err := conn.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("failed to begin a transaction: %v", err)
+	}
+	defer tx.Rollback(context.Background())
+	// the test tables are small enough for a sequential scan to be cheaper
+	if _, err := tx.Exec(context.Background(), `SET LOCAL enable_seqscan = off`); err != nil {
+		t.Fatalf("failed to disable the sequential scans: %v", err)
+	}
+	rows, err := tx.Query(
+		context.Background(),
+		`EXPLAIN SELECT employee_id FROM employee_emails WHERE address_prefixes @> ARRAY[$1]`,
+		keyring.Index("a_liddel"),
+	)
+	if err != nil {
+		t.Fatalf("failed to explain the prefix search: %v", err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			t.Fatalf("failed to scan the plan: %v", err)
+		}
+		plan = append(plan, line)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("failed to read the plan: %v", err)
+	}
+	if !strings.Contains(strings.Join(plan, "\n"), "employee_emails_address_prefixes_idx") {
+		t.Fatalf("expected the prefix search to use the prefixes index, got the plan:\n%s", strings.Join(plan, "\n"))
+	}
+}
+
+func TestLookupLog(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	var employeeID int
+	err = conn.QueryRow(context.Background(), `SELECT id FROM employees ORDER BY id LIMIT 1`).Scan(&employeeID)
+	if err != nil {
+		t.Fatalf("failed to query an employee: %v", err)
+	}
+
+	db, err := storage.NewDB(getConnectionString())
+	if err != nil {
+		t.Fatalf("failed to create a DB object: %v", err)
+	}
+	recorded, err := db.RecordLookup(context.Background(), &storage.Lookup{
+		Caller:      "test_lookup_log",
+		Prefix:      "test_lookup_log",
+		EmployeeIDs: []int{employeeID},
+	})
+	if err != nil {
+		t.Fatalf("RecordLookup failed: %v", err)
+	}
+	lookups, err := db.ListLookups(context.Background(), employeeID, 1)
+	if err != nil {
+		t.Fatalf("ListLookups failed: %v", err)
+	}
+	if len(lookups) != 1 || lookups[0].ID != recorded.ID || lookups[0].Caller != "test_lookup_log" {
+		t.Fatalf("expected to find the recorded lookup %+v, got %+v", recorded, lookups)
+	}
+
+	if _, err := db.DeleteLookupsBefore(context.Background(), recorded.CreatedAt.Add(time.Second)); err != nil {
+		t.Fatalf("DeleteLookupsBefore failed: %v", err)
+	}
+	lookups, err = db.ListLookups(context.Background(), employeeID, 1)
+	if err != nil {
+		t.Fatalf("ListLookups failed: %v", err)
+	}
+	if len(lookups) != 0 {
+		t.Fatalf("expected the expired lookups to be deleted, got %+v", lookups)
+	}
+}
+
+// insertEmployeeQuery creates an employee with the primary work phone $3 and
+// email $4, who has left $5 days ago unless $5 is null.
+const insertEmployeeQuery = `WITH e AS (
+		INSERT INTO employees (first_name, last_name, salary_amount, salary_currency, manager_id, department, position, terminated_on)
+		VALUES(
+			$1, $2,
+			45000, 'RUB',
+			(SELECT id FROM employees WHERE first_name = 'Bob' AND last_name = 'Morane' LIMIT 1),
+			(SELECT id FROM departments WHERE name = 'R&D'),
+			(SELECT id FROM positions WHERE title = 'Backend Dev'),
+			CURRENT_DATE - $5::int
+		)
+		RETURNING id
+	), p AS (
+		INSERT INTO employee_phones (employee_id, type, number, is_primary, ordinal)
+		SELECT id, 'work', $3, TRUE, 0 FROM e
+	)
+	INSERT INTO employee_emails (employee_id, type, address, is_primary, ordinal)
+	SELECT id, 'work', $4, TRUE, 0 FROM e`
+
+// testKeyring seals the contacts of the tests.
+const testKeyring = `{
+	"primary": "test",
+	"keys": {"test": "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE="},
+	"index_key": "QUJDREVGR0hJSktMTU5PUFFSU1RVVldYWVpBQkNERUY="
+}`
+
+// sealContacts seals and indexes the contacts inserted in plaintext, as it is
+// done for the contacts stored before the encryption.
+func sealContacts() error {
+	db, err := database.OpenGorm(getConnectionString())
+	if err != nil {
+		return fmt.Errorf("failed to open a gorm connection: %w", err)
+	}
+	defer database.CloseGorm(db)
+	if _, err := employeesStorage.ReencryptContacts(context.Background(), db, false); err != nil {
+		return fmt.Errorf("failed to reencrypt the contacts: %w", err)
+	}
+	return nil
+}
+
 func getDBConnector() (*pgxpool.Pool, error) {
 	log.Println(composeConnectionString())
 	cfg, err := pgxpool.ParseConfig(composeConnectionString())
@@ -55,14 +55,13 @@ VALUES
 COMMIT;
 
 BEGIN DEFERRABLE;
-    INSERT INTO employees (first_name, last_name, phone, email, salary, manager_id, department, position)
+    INSERT INTO employees (first_name, last_name, salary_amount, salary_currency, manager_id, department, position)
     VALUES
         (
             'Bob',
             'Morane',
-            '+79231234567',
-            'bmorane@gopher_corp.com',
             500000,
+            'RUB',
             42,
             (SELECT id FROM departments WHERE name = 'executives'),
             (SELECT id FROM positions WHERE title = 'CSO')
@@ -80,14 +79,13 @@ BEGIN DEFERRABLE;
         first_name = 'Bob'
         AND last_name = 'Morane';
 
-    INSERT INTO employees (first_name, last_name, phone, email, salary, manager_id, department, position)
+    INSERT INTO employees (first_name, last_name, salary_amount, salary_currency, manager_id, department, position)
     VALUES
         (
             'Charley',
             'Bucket',
-            '+79159876543',
-            'cbucket@gopher_corp.com',
             1000000,
+            'RUB',
             42,
             (SELECT id FROM departments WHERE name = 'executives'),
             (SELECT id FROM positions WHERE title = 'CEO')
@@ -105,14 +103,13 @@ BEGIN DEFERRABLE;
         first_name = 'Charley'
         AND last_name = 'Bucket';
 
-    INSERT INTO employees (first_name, last_name, phone, email, salary, manager_id, department, position)
+    INSERT INTO employees (first_name, last_name, salary_amount, salary_currency, manager_id, department, position)
     VALUES
         (
             'Alice',
             'Liddell',
-            '+79169008070',
-            'aliddell@gopher_corp.com',
             500000,
+            'RUB',
             42,
             (SELECT id FROM departments WHERE name = 'executives'),
             (SELECT id FROM positions WHERE title = 'CTO')
@@ -129,4 +126,24 @@ BEGIN DEFERRABLE;
     WHERE
         first_name = 'Alice'
         AND last_name = 'Liddell';
+
+    INSERT INTO employee_phones (employee_id, type, number, is_primary, ordinal)
+    SELECT e.id, 'work', c.phone, TRUE, 0
+    FROM employees e
+    JOIN (
+        VALUES
+            ('Bob', 'Morane', '+79231234567'),
+            ('Charley', 'Bucket', '+79159876543'),
+            ('Alice', 'Liddell', '+79169008070')
+    ) AS c (first_name, last_name, phone) USING (first_name, last_name);
+
+    INSERT INTO employee_emails (employee_id, type, address, is_primary, ordinal)
+    SELECT e.id, 'work', c.email, TRUE, 0
+    FROM employees e
+    JOIN (
+        VALUES
+            ('Bob', 'Morane', 'bmorane@gopher_corp.com'),
+            ('Charley', 'Bucket', 'cbucket@gopher_corp.com'),
+            ('Alice', 'Liddell', 'aliddell@gopher_corp.com')
+    ) AS c (first_name, last_name, email) USING (first_name, last_name);
 COMMIT;
\ No newline at end of file
@@ -0,0 +1,479 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/service"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+)
+
+type employeeRequest struct {
+	storage.Employee
+	SalaryBandOverrideReason string `json:"salary_band_override_reason"`
+	SalaryChangeReason       string `json:"salary_change_reason"`
+}
+
+type transferRequest struct {
+	Department    int    `json:"department"`
+	Position      int    `json:"position"`
+	ManagerID     int    `json:"manager_id"`
+	EffectiveDate string `json:"effective_date"`
+	Reason        string `json:"reason"`
+}
+
+type terminationRequest struct {
+	LastDay          string `json:"last_day"`
+	Reason           string `json:"reason"`
+	ReportsManagerID *int   `json:"reports_manager_id"`
+}
+
+type rehireRequest struct {
+	Department    int    `json:"department"`
+	Position      int    `json:"position"`
+	ManagerID     int    `json:"manager_id"`
+	EffectiveDate string `json:"effective_date"`
+	Reason        string `json:"reason"`
+}
+
+// contactVisibilityRequest resets the visibility omitted or set to null to
+// the company-wide default.
+type contactVisibilityRequest struct {
+	Phone *string `json:"phone"`
+	Email *string `json:"email"`
+}
+
+type salaryChangeRequest struct {
+	Salary                   money.Money `json:"salary"`
+	EffectiveDate            string      `json:"effective_date"`
+	Reason                   string      `json:"reason"`
+	SalaryBandOverrideReason string      `json:"salary_band_override_reason"`
+}
+
+func GetEmployee(w http.ResponseWriter, r *http.Request, employeeID string) {
+	id, ok := parseID(w, employeeID)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	e, err := service.GetEmployee(db, auth.FromContext(r.Context()), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, e)
+}
+
+func CreateEmployee(w http.ResponseWriter, r *http.Request) {
+	req := &employeeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Printf("failed to decode the employee: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	created, err := service.CreateEmployee(db, auth.FromContext(r.Context()), &req.Employee, req.SalaryBandOverrideReason, &storage.SalaryChange{
+		Reason:    req.SalaryChangeReason,
+		ChangedBy: auth.Subject(r.Context()),
+	})
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func UpdateEmployee(w http.ResponseWriter, r *http.Request, employeeID string) {
+	id, ok := parseID(w, employeeID)
+	if !ok {
+		return
+	}
+	req := &employeeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Printf("failed to decode the employee: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	req.ID = id
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	updated, err := service.UpdateEmployee(db, auth.FromContext(r.Context()), &req.Employee, req.SalaryBandOverrideReason, &storage.SalaryChange{
+		Reason:    req.SalaryChangeReason,
+		ChangedBy: auth.Subject(r.Context()),
+	})
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// ListSalaryChanges returns the employee's compensation timeline.
+func ListSalaryChanges(w http.ResponseWriter, r *http.Request, employeeID string) {
+	id, ok := parseID(w, employeeID)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	changes, err := service.ListSalaryChanges(db, auth.FromContext(r.Context()), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
+// ScheduleSalaryChange schedules a change of the employee's salary on the
+// effective date, a date like 2021-10-01.
+func ScheduleSalaryChange(w http.ResponseWriter, r *http.Request, employeeID string) {
+	id, ok := parseID(w, employeeID)
+	if !ok {
+		return
+	}
+	req := &salaryChangeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Printf("failed to decode the salary change: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	effectiveDate, err := time.Parse("2006-01-02", req.EffectiveDate)
+	if err != nil {
+		log.Printf("incorrect effective date %q: %v", req.EffectiveDate, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	scheduled, err := service.ScheduleSalaryChange(db, auth.FromContext(r.Context()), &storage.SalaryChange{
+		EmployeeID:    id,
+		Salary:        req.Salary,
+		EffectiveDate: effectiveDate,
+		Reason:        req.Reason,
+		ChangedBy:     auth.Subject(r.Context()),
+	}, req.SalaryBandOverrideReason)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, scheduled)
+}
+
+// CancelSalaryChange cancels a scheduled change of the employee's salary.
+func CancelSalaryChange(w http.ResponseWriter, r *http.Request, employeeID string, changeID string) {
+	id, ok := parseID(w, employeeID)
+	if !ok {
+		return
+	}
+	cID, err := strconv.Atoi(changeID)
+	if err != nil {
+		log.Printf("incorrect salary change ID %q: %v", changeID, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	if err := service.CancelSalaryChange(db, auth.FromContext(r.Context()), id, cID); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Transfer moves the employee to another department, position or manager. The
+// effective date is today unless given.
+func Transfer(w http.ResponseWriter, r *http.Request, employeeID string) {
+	id, ok := parseID(w, employeeID)
+	if !ok {
+		return
+	}
+	req := &transferRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Printf("failed to decode the transfer: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	validFrom, ok := parseDate(w, req.EffectiveDate)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	a, err := service.Transfer(db, auth.FromContext(r.Context()), &storage.Assignment{
+		EmployeeID: id,
+		Department: req.Department,
+		Position:   req.Position,
+		ManagerID:  req.ManagerID,
+		ValidFrom:  validFrom,
+		Reason:     req.Reason,
+		ChangedBy:  auth.Subject(r.Context()),
+	})
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, a)
+}
+
+// ListAssignments returns the employee's department, position and manager
+// history.
+func ListAssignments(w http.ResponseWriter, r *http.Request, employeeID string) {
+	id, ok := parseID(w, employeeID)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	assignments, err := service.ListAssignments(db, id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, assignments)
+}
+
+// ListDepartmentAssignments returns the assignments of the employees who were
+// in the department given by the "department" query parameter on the date
+// given by the "date" query parameter, today by default.
+func ListDepartmentAssignments(w http.ResponseWriter, r *http.Request) {
+	department, err := strconv.Atoi(r.URL.Query().Get("department"))
+	if err != nil {
+		log.Printf("incorrect department %q: %v", r.URL.Query().Get("department"), err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	on := time.Now()
+	if date := r.URL.Query().Get("date"); len(date) != 0 {
+		if on, err = time.Parse("2006-01-02", date); err != nil {
+			log.Printf("incorrect date %q: %v", date, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	assignments, err := service.ListDepartmentAssignments(db, department, on)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, assignments)
+}
+
+// TerminateEmployee ends the employee's employment after the last day, today
+// unless given. The record is kept, so the request also serves as the soft
+// delete of the employee, in which case the body may be empty.
+func TerminateEmployee(w http.ResponseWriter, r *http.Request, employeeID string) {
+	id, ok := parseID(w, employeeID)
+	if !ok {
+		return
+	}
+	req := &terminationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("failed to decode the termination: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	lastDay, ok := parseDate(w, req.LastDay)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	t, err := service.TerminateEmployee(db, auth.FromContext(r.Context()), &storage.Termination{
+		EmployeeID:       id,
+		LastDay:          lastDay,
+		Reason:           req.Reason,
+		ReportsManagerID: req.ReportsManagerID,
+		TerminatedBy:     auth.Subject(r.Context()),
+	})
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, t)
+}
+
+// RehireEmployee reactivates a terminated employee. The effective date is
+// today unless given.
+func RehireEmployee(w http.ResponseWriter, r *http.Request, employeeID string) {
+	id, ok := parseID(w, employeeID)
+	if !ok {
+		return
+	}
+	req := &rehireRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("failed to decode the rehire: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	validFrom, ok := parseDate(w, req.EffectiveDate)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	e, err := service.RehireEmployee(db, auth.FromContext(r.Context()), &storage.Assignment{
+		EmployeeID: id,
+		Department: req.Department,
+		Position:   req.Position,
+		ManagerID:  req.ManagerID,
+		ValidFrom:  validFrom,
+		Reason:     req.Reason,
+		ChangedBy:  auth.Subject(r.Context()),
+	})
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, e)
+}
+
+func ListTerminations(w http.ResponseWriter, r *http.Request, employeeID string) {
+	id, ok := parseID(w, employeeID)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	terminations, err := service.ListTerminations(db, id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, terminations)
+}
+
+// parseDate parses an optional date like 2021-07-01, an empty date results in
+// the zero time.
+func parseDate(w http.ResponseWriter, date string) (time.Time, bool) {
+	if len(date) == 0 {
+		return time.Time{}, true
+	}
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		log.Printf("incorrect date %q: %v", date, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// SetContactVisibility sets who sees the employee's phone and email.
+func SetContactVisibility(w http.ResponseWriter, r *http.Request, employeeID string) {
+	id, ok := parseID(w, employeeID)
+	if !ok {
+		return
+	}
+	req := &contactVisibilityRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Printf("failed to decode the contact visibility: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	e, err := service.SetContactVisibility(db, auth.FromContext(r.Context()), id, req.Phone, req.Email)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, e)
+}
+
+func parseID(w http.ResponseWriter, employeeID string) (int, bool) {
+	id, err := strconv.Atoi(employeeID)
+	if err != nil {
+		log.Printf("incorrect employee ID %q: %v", employeeID, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func getDB(w http.ResponseWriter, r *http.Request) (storage.DB, bool) {
+	dbIface := r.Context().Value(storage.ContextKeyDB)
+	if dbIface == nil {
+		log.Println("DB is not found in the request context")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	db, ok := dbIface.(storage.DB)
+	if !ok {
+		log.Println("DB in the request context is not of type storage.DB")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return db, true
+}
+
+func writeErr(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, service.ErrIncorrectEmployee),
+		errors.Is(err, service.ErrIncorrectSalaryChange),
+		errors.Is(err, service.ErrIncorrectTransfer),
+		errors.Is(err, service.ErrIncorrectTermination):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, policy.ErrForbidden):
+		w.WriteHeader(http.StatusForbidden)
+	case errors.Is(err, service.ErrEmployeeNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, service.ErrEmployeeConflict):
+		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, service.ErrSalaryOutOfBand):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("failed to serialize the response to JSON: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(resp); err != nil {
+		log.Printf("failed to write the response body: %v", err)
+	}
+}
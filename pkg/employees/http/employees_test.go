@@ -0,0 +1,151 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	positionsStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/positions/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
+	"github.com/gorilla/mux"
+)
+
+func TestCreateEmployee(t *testing.T) {
+	cases := []struct {
+		Body             string
+		ExpectedRespCode int
+	}{
+		{
+			Body:             `{"first_name":"Dale","last_name":"Cooper","salary":{"amount":"45000","currency":"RUB"},"manager_id":1,"department":2,"position":3}`,
+			ExpectedRespCode: http.StatusCreated,
+		},
+		{
+			Body:             `{"first_name":"Dale","last_name":"Cooper","salary":{"amount":"95000","currency":"RUB"},"manager_id":1,"department":2,"position":3}`,
+			ExpectedRespCode: http.StatusUnprocessableEntity,
+		},
+		{
+			Body:             `{"first_name":"Dale","last_name":"Cooper","salary":{"amount":"95000","currency":"RUB"},"manager_id":1,"department":2,"position":3,"salary_band_override_reason":"relocation"}`,
+			ExpectedRespCode: http.StatusCreated,
+		},
+		{
+			Body:             `{"first_name":"","last_name":"Cooper","salary":{"amount":"45000","currency":"RUB"},"manager_id":1,"department":2,"position":3}`,
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			Body:             `{"first_name":"Dale","last_name":"Cooper","salary":{"amount":"45000.001","currency":"RUB"},"manager_id":1,"department":2,"position":3}`,
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			Body:             `not a json`,
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/employees", strings.NewReader(tc.Body))
+			if err != nil {
+				t.Errorf("failed to create an http request: %v", err)
+				return
+			}
+			req = req.WithContext(context.WithValue(req.Context(), storage.ContextKeyDB, &dbMock{
+				band: &positionsStorage.SalaryBand{
+					Currency:  "RUB",
+					MinSalary: 4000000,
+					MaxSalary: 6000000,
+				},
+			}))
+			req = req.WithContext(auth.NewContext(req.Context(), &auth.Principal{Subject: "hr", Roles: []string{"hr"}}))
+
+			handler := mux.NewRouter()
+			handler.HandleFunc("/employees", CreateEmployee).Methods("POST")
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.ExpectedRespCode {
+				t.Errorf("expected code: %d, got: %d", tc.ExpectedRespCode, rr.Code)
+			}
+		})
+	}
+}
+
+type dbMock struct {
+	band *positionsStorage.SalaryBand
+}
+
+func (db *dbMock) GetEmployee(ctx context.Context, id int) (*storage.Employee, error) {
+	return nil, fmt.Errorf("%w: employee %d", storage.ErrNotFound, id)
+}
+
+func (db *dbMock) CreateEmployee(ctx context.Context, e *storage.Employee, override *storage.SalaryBandOverride, salaryChange *storage.SalaryChange) (*storage.Employee, error) {
+	return e, nil
+}
+
+func (db *dbMock) UpdateEmployee(ctx context.Context, e *storage.Employee, override *storage.SalaryBandOverride, salaryChange *storage.SalaryChange) (*storage.Employee, error) {
+	return e, nil
+}
+
+func (db *dbMock) ListSalaryChanges(ctx context.Context, employeeID int) ([]*storage.SalaryChange, error) {
+	return nil, fmt.Errorf("%w: employee %d", storage.ErrNotFound, employeeID)
+}
+
+func (db *dbMock) ScheduleSalaryChange(ctx context.Context, c *storage.SalaryChange, override *storage.SalaryBandOverride) (*storage.SalaryChange, error) {
+	return c, nil
+}
+
+func (db *dbMock) CancelSalaryChange(ctx context.Context, employeeID int, changeID int) error {
+	return fmt.Errorf("%w: pending salary change %d", storage.ErrNotFound, changeID)
+}
+
+func (db *dbMock) ApplyDueSalaryChanges(ctx context.Context, on time.Time) ([]*storage.SalaryChange, error) {
+	return nil, nil
+}
+
+func (db *dbMock) ApplySalaryChange(ctx context.Context, employeeID int, changeID int, on time.Time) (*storage.SalaryChange, error) {
+	return nil, fmt.Errorf("%w: due salary change %d", storage.ErrNotFound, changeID)
+}
+
+func (db *dbMock) GetSalaryBand(ctx context.Context, positionID int, currency string) (*positionsStorage.SalaryBand, error) {
+	return db.band, nil
+}
+
+func (db *dbMock) Close() {}
+
+func (db *dbMock) Transfer(ctx context.Context, a *storage.Assignment) (*storage.Assignment, error) {
+	return a, nil
+}
+
+func (db *dbMock) ListAssignments(ctx context.Context, employeeID int) ([]*storage.Assignment, error) {
+	return nil, fmt.Errorf("%w: employee %d", storage.ErrNotFound, employeeID)
+}
+
+func (db *dbMock) ListDepartmentAssignments(ctx context.Context, department int, on time.Time) ([]*storage.Assignment, error) {
+	return nil, nil
+}
+
+func (db *dbMock) TerminateEmployee(ctx context.Context, t *storage.Termination) (*storage.Termination, error) {
+	return t, nil
+}
+
+func (db *dbMock) RehireEmployee(ctx context.Context, a *storage.Assignment) (*storage.Employee, error) {
+	return nil, fmt.Errorf("%w: employee %d", storage.ErrNotFound, a.EmployeeID)
+}
+
+func (db *dbMock) ListTerminations(ctx context.Context, employeeID int) ([]*storage.Termination, error) {
+	return nil, nil
+}
+
+func (db *dbMock) SetContactVisibility(ctx context.Context, id int, phone *string, email *string) (*storage.Employee, error) {
+	return nil, fmt.Errorf("%w: employee %d", storage.ErrNotFound, id)
+}
+
+func (db *dbMock) GetViewer(ctx context.Context, employeeID int) (*visibility.Viewer, error) {
+	return &visibility.Viewer{}, nil
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
+)
+
+// SetContactVisibility sets who sees the employee's phone and email. A nil
+// visibility resets it to the company-wide default. Only the employee and HR,
+// of the company or of the employee's department subtree, may set it.
+func SetContactVisibility(db storage.DB, p *auth.Principal, id int, phone *string, email *string) (*storage.Employee, error) {
+	current, err := db.GetEmployee(context.Background(), id)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to get the employee")
+	}
+	if err := policy.NewActor(p, nil).CheckIn(policy.EntityContacts, policy.ActionWrite, id, current.Department); err != nil {
+		return nil, err
+	}
+	for _, level := range []*string{phone, email} {
+		if level == nil {
+			continue
+		}
+		if err := visibility.Validate(*level); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrIncorrectEmployee, err)
+		}
+	}
+	e, err := db.SetContactVisibility(context.Background(), id, phone, email)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to set the contact visibility")
+	}
+	return e, nil
+}
+
+var (
+	phoneTypes = map[string]struct{}{
+		storage.PhoneTypeWork:      {},
+		storage.PhoneTypeMobile:    {},
+		storage.PhoneTypeExtension: {},
+	}
+	emailTypes = map[string]struct{}{
+		storage.EmailTypeWork:  {},
+		storage.EmailTypeAlias: {},
+	}
+)
+
+// normalizeContacts checks the employee's phones and emails. A Phone or an
+// Email given without the lists becomes the only, primary work contact. The
+// type defaults to work and the first contact is primary if no other one is.
+// The emails are lowercased, as they are unique regardless of the case.
+func normalizeContacts(e *storage.Employee) error {
+	if phone := strings.TrimSpace(e.Phone); len(e.Phones) == 0 && len(phone) != 0 {
+		e.Phones = []*storage.Phone{{Number: phone}}
+	}
+	if email := strings.TrimSpace(e.Email); len(e.Emails) == 0 && len(email) != 0 {
+		e.Emails = []*storage.Email{{Address: email}}
+	}
+	e.Phone, e.Email = "", ""
+
+	primary := -1
+	for i, p := range e.Phones {
+		if p == nil {
+			return fmt.Errorf("%w: phone %d is null", ErrIncorrectEmployee, i)
+		}
+		p.Number = strings.TrimSpace(p.Number)
+		if len(p.Number) == 0 {
+			return fmt.Errorf("%w: phone %d is empty", ErrIncorrectEmployee, i)
+		}
+		if len(p.Type) == 0 {
+			p.Type = storage.PhoneTypeWork
+		}
+		if _, ok := phoneTypes[p.Type]; !ok {
+			return fmt.Errorf("%w: unknown phone type %q", ErrIncorrectEmployee, p.Type)
+		}
+		if p.Primary {
+			if primary != -1 {
+				return fmt.Errorf("%w: phones %d and %d are both primary", ErrIncorrectEmployee, primary, i)
+			}
+			primary = i
+		}
+	}
+	if primary == -1 && len(e.Phones) != 0 {
+		primary = 0
+		e.Phones[0].Primary = true
+	}
+	if primary != -1 {
+		e.Phone = e.Phones[primary].Number
+	}
+
+	primary = -1
+	addresses := make(map[string]int, len(e.Emails))
+	for i, m := range e.Emails {
+		if m == nil {
+			return fmt.Errorf("%w: email %d is null", ErrIncorrectEmployee, i)
+		}
+		m.Address = strings.ToLower(strings.TrimSpace(m.Address))
+		if len(m.Address) == 0 {
+			return fmt.Errorf("%w: email %d is empty", ErrIncorrectEmployee, i)
+		}
+		if j, ok := addresses[m.Address]; ok {
+			return fmt.Errorf("%w: emails %d and %d are the same", ErrIncorrectEmployee, j, i)
+		}
+		addresses[m.Address] = i
+		if len(m.Type) == 0 {
+			m.Type = storage.EmailTypeWork
+		}
+		if _, ok := emailTypes[m.Type]; !ok {
+			return fmt.Errorf("%w: unknown email type %q", ErrIncorrectEmployee, m.Type)
+		}
+		if m.Primary {
+			if primary != -1 {
+				return fmt.Errorf("%w: emails %d and %d are both primary", ErrIncorrectEmployee, primary, i)
+			}
+			primary = i
+		}
+	}
+	if primary == -1 && len(e.Emails) != 0 {
+		primary = 0
+		e.Emails[0].Primary = true
+	}
+	if primary != -1 {
+		e.Email = e.Emails[primary].Address
+	}
+	return nil
+}
+
+// hideContacts blanks the contacts of the employee the viewer may not see.
+func hideContacts(v *visibility.Viewer, e *storage.Employee) {
+	owner := visibility.Owner{
+		EmployeeID: e.ID,
+		Department: e.Department,
+	}
+	if !v.CanSee(owner, e.PhoneVisibility) {
+		e.Phone, e.Phones = "", make([]*storage.Phone, 0)
+	}
+	if !v.CanSee(owner, e.EmailVisibility) {
+		e.Email, e.Emails = "", make([]*storage.Email, 0)
+	}
+}
+
+// keepHiddenContacts makes the update keep the stored phones or emails the
+// caller may not see: GetEmployee returns them blank, and sending them back
+// must not delete them. The caller may not replace the contacts they do not
+// see. Nil Phones or Emails keep the stored ones, so omitting them in the
+// update keeps them too.
+func keepHiddenContacts(v *visibility.Viewer, current *storage.Employee, e *storage.Employee) error {
+	owner := visibility.Owner{
+		EmployeeID: current.ID,
+		Department: current.Department,
+	}
+	if !v.CanSee(owner, current.PhoneVisibility) {
+		if len(e.Phones) != 0 {
+			return fmt.Errorf("%w: the phones of employee %d are hidden from the caller", policy.ErrForbidden, current.ID)
+		}
+		e.Phones = nil
+	}
+	if !v.CanSee(owner, current.EmailVisibility) {
+		if len(e.Emails) != 0 {
+			return fmt.Errorf("%w: the emails of employee %d are hidden from the caller", policy.ErrForbidden, current.ID)
+		}
+		e.Emails = nil
+	}
+	return nil
+}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
+)
+
+func TestGetEmployeeContactVisibility(t *testing.T) {
+	employee := &storage.Employee{
+		ID:              3,
+		Department:      2,
+		Phone:           "+12345",
+		Email:           "dcooper@gopher_corp.com",
+		Phones:          []*storage.Phone{{Type: storage.PhoneTypeWork, Number: "+12345", Primary: true}},
+		Emails:          []*storage.Email{{Type: storage.EmailTypeWork, Address: "dcooper@gopher_corp.com", Primary: true}},
+		PhoneVisibility: strPtr(visibility.Managers),
+	}
+	cases := []struct {
+		Principal            *auth.Principal
+		Viewer               *visibility.Viewer
+		ExpectedPhone        string
+		ExpectedEmail        string
+		ExpectedSalaryHidden bool
+	}{
+		{
+			ExpectedEmail:        "dcooper@gopher_corp.com",
+			ExpectedSalaryHidden: true,
+		},
+		{
+			Principal:     &auth.Principal{Subject: "dcooper", EmployeeID: 3},
+			Viewer:        &visibility.Viewer{EmployeeID: 3, Department: 2},
+			ExpectedPhone: "+12345",
+			ExpectedEmail: "dcooper@gopher_corp.com",
+		},
+		{
+			Principal:            &auth.Principal{Subject: "lpalmer", EmployeeID: 4},
+			Viewer:               &visibility.Viewer{EmployeeID: 4, Department: 2},
+			ExpectedEmail:        "dcooper@gopher_corp.com",
+			ExpectedSalaryHidden: true,
+		},
+		{
+			Principal:     &auth.Principal{Subject: "gcole", EmployeeID: 1, Roles: []string{"manager"}},
+			Viewer:        &visibility.Viewer{EmployeeID: 1, Department: 1, Reports: map[int]struct{}{3: {}}},
+			ExpectedPhone: "+12345",
+			ExpectedEmail: "dcooper@gopher_corp.com",
+		},
+		{
+			Principal:     &auth.Principal{Subject: "hr", Roles: []string{"hr"}},
+			Viewer:        &visibility.Viewer{},
+			ExpectedEmail: "dcooper@gopher_corp.com",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t:        t,
+				employee: employee,
+				viewer:   tc.Viewer,
+			}
+			e, err := GetEmployee(mock, tc.Principal, employee.ID)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if e.Phone != tc.ExpectedPhone || e.Email != tc.ExpectedEmail {
+				t.Errorf(
+					"expected phone %q and email %q, got %q and %q",
+					tc.ExpectedPhone, tc.ExpectedEmail, e.Phone, e.Email,
+				)
+				return
+			}
+			if (len(e.Phones) != 0) != (len(tc.ExpectedPhone) != 0) {
+				t.Errorf("expected the phones to be visible: %v, got %v", len(tc.ExpectedPhone) != 0, e.Phones)
+			}
+			if e.SalaryHidden != tc.ExpectedSalaryHidden {
+				t.Errorf("expected the salary to be hidden: %v, got %v", tc.ExpectedSalaryHidden, e.SalaryHidden)
+			}
+		})
+	}
+	if employee.Phone != "+12345" || len(employee.Phones) != 1 {
+		t.Errorf("the stored employee was changed")
+	}
+}
+
+func TestSetContactVisibility(t *testing.T) {
+	self := &auth.Principal{Subject: "dcooper", EmployeeID: 1}
+	cases := []struct {
+		Principal   *auth.Principal
+		Phone       *string
+		Email       *string
+		ExpectedErr error
+	}{
+		{
+			Principal: self,
+			Phone:     strPtr(visibility.Hidden),
+			Email:     strPtr(visibility.Department),
+		},
+		{
+			Principal: &auth.Principal{Subject: "hr", Roles: []string{"hr"}},
+			Phone:     nil,
+			Email:     nil,
+		},
+		{
+			Principal:   self,
+			Phone:       strPtr("friends"),
+			ExpectedErr: ErrIncorrectEmployee,
+		},
+		{
+			Principal:   self,
+			Email:       strPtr(""),
+			ExpectedErr: ErrIncorrectEmployee,
+		},
+		{
+			Principal:   &auth.Principal{Subject: "gcole", EmployeeID: 2, Roles: []string{"manager"}},
+			Phone:       strPtr(visibility.Hidden),
+			ExpectedErr: policy.ErrForbidden,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t:        t,
+				employee: &storage.Employee{ID: 1},
+			}
+			_, err := SetContactVisibility(mock, tc.Principal, 1, tc.Phone, tc.Email)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				if mock.saved != nil {
+					t.Errorf("the visibility was saved despite the error")
+				}
+				return
+			}
+			if mock.saved.PhoneVisibility != tc.Phone || mock.saved.EmailVisibility != tc.Email {
+				t.Errorf("expected the visibility %v and %v to be saved", tc.Phone, tc.Email)
+			}
+		})
+	}
+}
+
+func TestCreateEmployeeContacts(t *testing.T) {
+	cases := []struct {
+		Phone          string
+		Email          string
+		Phones         []*storage.Phone
+		Emails         []*storage.Email
+		ExpectedPhones []storage.Phone
+		ExpectedEmails []storage.Email
+		ExpectedPhone  string
+		ExpectedEmail  string
+		ExpectedErr    error
+	}{
+		{},
+		{
+			Phone:          " +12345 ",
+			Email:          " DCooper@gopher_corp.com",
+			ExpectedPhones: []storage.Phone{{Type: storage.PhoneTypeWork, Number: "+12345", Primary: true}},
+			ExpectedEmails: []storage.Email{{Type: storage.EmailTypeWork, Address: "dcooper@gopher_corp.com", Primary: true}},
+			ExpectedPhone:  "+12345",
+			ExpectedEmail:  "dcooper@gopher_corp.com",
+		},
+		{
+			Phone: "+12345",
+			Email: "dcooper@gopher_corp.com",
+			Phones: []*storage.Phone{
+				{Type: storage.PhoneTypeMobile, Number: "+23456"},
+				{Type: storage.PhoneTypeExtension, Number: "042", Primary: true},
+			},
+			Emails: []*storage.Email{
+				{Address: "dale@gopher_corp.com"},
+				{Type: storage.EmailTypeAlias, Address: "Agent.Cooper@gopher_corp.com"},
+			},
+			ExpectedPhones: []storage.Phone{
+				{Type: storage.PhoneTypeMobile, Number: "+23456"},
+				{Type: storage.PhoneTypeExtension, Number: "042", Primary: true},
+			},
+			ExpectedEmails: []storage.Email{
+				{Type: storage.EmailTypeWork, Address: "dale@gopher_corp.com", Primary: true},
+				{Type: storage.EmailTypeAlias, Address: "agent.cooper@gopher_corp.com"},
+			},
+			ExpectedPhone: "042",
+			ExpectedEmail: "dale@gopher_corp.com",
+		},
+		{
+			Phones: []*storage.Phone{
+				{Number: "+12345", Primary: true},
+				{Number: "+23456", Primary: true},
+			},
+			ExpectedErr: ErrIncorrectEmployee,
+		},
+		{
+			Phones:      []*storage.Phone{{Type: "fax", Number: "+12345"}},
+			ExpectedErr: ErrIncorrectEmployee,
+		},
+		{
+			Phones:      []*storage.Phone{{Number: "  "}},
+			ExpectedErr: ErrIncorrectEmployee,
+		},
+		{
+			Phones:      []*storage.Phone{nil},
+			ExpectedErr: ErrIncorrectEmployee,
+		},
+		{
+			Emails:      []*storage.Email{{Type: storage.PhoneTypeMobile, Address: "dcooper@gopher_corp.com"}},
+			ExpectedErr: ErrIncorrectEmployee,
+		},
+		{
+			Emails: []*storage.Email{
+				{Address: "dcooper@gopher_corp.com", Primary: true},
+				{Address: "dale@gopher_corp.com", Primary: true},
+			},
+			ExpectedErr: ErrIncorrectEmployee,
+		},
+		{
+			Emails: []*storage.Email{
+				{Address: "dcooper@gopher_corp.com"},
+				{Type: storage.EmailTypeAlias, Address: " DCooper@gopher_corp.com"},
+			},
+			ExpectedErr: ErrIncorrectEmployee,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{t: t}
+			e := &storage.Employee{
+				FirstName:  "Dale",
+				LastName:   "Cooper",
+				Salary:     money.New(4500000, "RUB"),
+				ManagerID:  1,
+				Department: 2,
+				Position:   3,
+				Phone:      tc.Phone,
+				Email:      tc.Email,
+				Phones:     tc.Phones,
+				Emails:     tc.Emails,
+			}
+			_, err := CreateEmployee(mock, hrPrincipal, e, "", nil)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				return
+			}
+			saved := mock.saved
+			if saved.Phone != tc.ExpectedPhone || saved.Email != tc.ExpectedEmail {
+				t.Errorf(
+					"expected the primary phone %q and email %q, got %q and %q",
+					tc.ExpectedPhone, tc.ExpectedEmail, saved.Phone, saved.Email,
+				)
+				return
+			}
+			var phones []storage.Phone
+			for _, p := range saved.Phones {
+				phones = append(phones, *p)
+			}
+			var emails []storage.Email
+			for _, m := range saved.Emails {
+				emails = append(emails, *m)
+			}
+			if !reflect.DeepEqual(phones, tc.ExpectedPhones) || !reflect.DeepEqual(emails, tc.ExpectedEmails) {
+				t.Errorf("expected phones %+v and emails %+v, got %+v and %+v", tc.ExpectedPhones, tc.ExpectedEmails, phones, emails)
+			}
+		})
+	}
+}
+
+func TestUpdateEmployeeContacts(t *testing.T) {
+	// the phones are hidden from HR, the emails are not
+	cases := []struct {
+		Phones         []*storage.Phone
+		Emails         []*storage.Email
+		ExpectedPhones []*storage.Phone
+		ExpectedEmails []*storage.Email
+		ExpectedErr    error
+	}{
+		{},
+		{
+			Phones:         []*storage.Phone{},
+			Emails:         []*storage.Email{},
+			ExpectedEmails: []*storage.Email{},
+		},
+		{
+			Emails:         []*storage.Email{{Address: "dale@gopher_corp.com"}},
+			ExpectedEmails: []*storage.Email{{Type: storage.EmailTypeWork, Address: "dale@gopher_corp.com", Primary: true}},
+		},
+		{
+			Phones:      []*storage.Phone{{Number: "+23456"}},
+			ExpectedErr: policy.ErrForbidden,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t:        t,
+				employee: employeeWithHiddenPhones(),
+			}
+			e := employeeWithHiddenPhones()
+			e.Phone, e.Email = "", ""
+			e.Phones, e.Emails = tc.Phones, tc.Emails
+			_, err := UpdateEmployee(mock, hrPrincipal, e, "", nil)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				return
+			}
+			if !reflect.DeepEqual(mock.saved.Phones, tc.ExpectedPhones) || !reflect.DeepEqual(mock.saved.Emails, tc.ExpectedEmails) {
+				t.Errorf(
+					"expected phones %+v and emails %+v to be saved, got %+v and %+v",
+					tc.ExpectedPhones, tc.ExpectedEmails, mock.saved.Phones, mock.saved.Emails,
+				)
+			}
+		})
+	}
+}
+
+func TestUpdateEmployeeRoundTrip(t *testing.T) {
+	stored := employeeWithHiddenPhones()
+	mock := &dbMock{
+		t:        t,
+		employee: stored,
+	}
+	e, err := GetEmployee(mock, hrPrincipal, stored.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.LastName = "Cooper-Horne"
+	updated, err := UpdateEmployee(mock, hrPrincipal, e, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mock.saved.Phones != nil {
+		t.Errorf("expected the hidden phones to be kept, got %+v", mock.saved.Phones)
+	}
+	if !reflect.DeepEqual(mock.saved.Emails, stored.Emails) {
+		t.Errorf("expected the emails %+v to be saved, got %+v", stored.Emails, mock.saved.Emails)
+	}
+	if len(updated.Phone) != 0 || len(updated.Phones) != 0 {
+		t.Errorf("expected the hidden phones to be blank in the result, got %q and %+v", updated.Phone, updated.Phones)
+	}
+}
+
+// employeeWithHiddenPhones is an employee whose phones only they see.
+func employeeWithHiddenPhones() *storage.Employee {
+	return &storage.Employee{
+		ID:              3,
+		FirstName:       "Dale",
+		LastName:        "Cooper",
+		Salary:          money.New(4500000, "RUB"),
+		ManagerID:       1,
+		Department:      2,
+		Position:        3,
+		Phone:           "+12345",
+		Email:           "dcooper@gopher_corp.com",
+		Phones:          []*storage.Phone{{Type: storage.PhoneTypeWork, Number: "+12345", Primary: true}},
+		Emails:          []*storage.Email{{Type: storage.EmailTypeWork, Address: "dcooper@gopher_corp.com", Primary: true}},
+		PhoneVisibility: strPtr(visibility.Hidden),
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
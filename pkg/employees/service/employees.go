@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+	positionsService "github.com/SergeyShpak/gopher-corp-backend/pkg/positions/service"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
+)
+
+var (
+	ErrIncorrectEmployee = fmt.Errorf("got an incorrect employee")
+	ErrEmployeeNotFound  = fmt.Errorf("employee not found")
+	ErrEmployeeConflict  = fmt.Errorf("employee conflicts with the existing data")
+	ErrSalaryOutOfBand   = fmt.Errorf("salary is out of the position salary band")
+	ErrDBRequestFailed   = fmt.Errorf("a request to DB failed")
+)
+
+// hireReason describes the salary change recorded for a new employee when no
+// other reason is given.
+const hireReason = "hire"
+
+// GetEmployee returns the employee as seen by the caller: the contacts the
+// caller may not see are blank and the salary is hidden from the callers
+// other than HR, the employee and their managers. A nil p stands for an
+// anonymous caller.
+func GetEmployee(db storage.DB, p *auth.Principal, id int) (*storage.Employee, error) {
+	e, err := db.GetEmployee(context.Background(), id)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to get the employee")
+	}
+	v, err := getViewer(db, p)
+	if err != nil {
+		return nil, err
+	}
+	hideContacts(v, e)
+	if !policy.NewActor(p, v).Can(policy.FieldSalary, policy.ActionRead, e.ID) {
+		e.SalaryHidden = true
+	}
+	return e, nil
+}
+
+// CreateEmployee creates the employee. If the salary is outside of the salary
+// band of the employee's position in the salary currency, overrideReason must
+// explain why, otherwise ErrSalaryOutOfBand is returned. The salary starts the
+// employee's salary history, salaryChange gives the reason and the author of
+// the entry. The HR of a department subtree may create the employees in it.
+func CreateEmployee(db storage.DB, p *auth.Principal, e *storage.Employee, overrideReason string, salaryChange *storage.SalaryChange) (*storage.Employee, error) {
+	if err := normalizeEmployee(e); err != nil {
+		return nil, err
+	}
+	if err := policy.NewActor(p, nil).CheckIn(policy.EntityEmployee, policy.ActionWrite, 0, e.Department); err != nil {
+		return nil, err
+	}
+	override, err := checkSalaryBand(db, e, overrideReason)
+	if err != nil {
+		return nil, err
+	}
+	salaryChange = describeSalaryChange(salaryChange)
+	if len(salaryChange.Reason) == 0 {
+		salaryChange.Reason = hireReason
+	}
+	created, err := db.CreateEmployee(context.Background(), e, override, salaryChange)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to create the employee")
+	}
+	return created, nil
+}
+
+// UpdateEmployee replaces the employee's data. The salary band is enforced the
+// same way as in CreateEmployee when the salary or the position changes, so
+// that the other changes do not fail once the band has moved. A salary change
+// is added to the salary history with the reason and the author given by
+// salaryChange. The HR of a department subtree may update the employees in it,
+// and move them only within it. The phones or the emails omitted or hidden
+// from the caller are kept, see keepHiddenContacts.
+func UpdateEmployee(db storage.DB, p *auth.Principal, e *storage.Employee, overrideReason string, salaryChange *storage.SalaryChange) (*storage.Employee, error) {
+	if err := normalizeEmployee(e); err != nil {
+		return nil, err
+	}
+	current, err := db.GetEmployee(context.Background(), e.ID)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to get the employee")
+	}
+	if err := policy.NewActor(p, nil).CheckIn(policy.EntityEmployee, policy.ActionWrite, e.ID, current.Department, e.Department); err != nil {
+		return nil, err
+	}
+	v, err := getViewer(db, p)
+	if err != nil {
+		return nil, err
+	}
+	if err := keepHiddenContacts(v, current, e); err != nil {
+		return nil, err
+	}
+	var override *storage.SalaryBandOverride
+	if e.Salary != current.Salary || e.Position != current.Position {
+		override, err = checkSalaryBand(db, e, overrideReason)
+		if err != nil {
+			return nil, err
+		}
+	}
+	updated, err := db.UpdateEmployee(context.Background(), e, override, describeSalaryChange(salaryChange))
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to update the employee")
+	}
+	hideContacts(v, updated)
+	return updated, nil
+}
+
+func checkSalaryBand(db storage.DB, e *storage.Employee, overrideReason string) (*storage.SalaryBandOverride, error) {
+	band, err := db.GetSalaryBand(context.Background(), e.Position, e.Salary.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to get the salary band: %v", ErrDBRequestFailed, err)
+	}
+	if band == nil {
+		return nil, nil
+	}
+	ok, err := positionsService.SalaryWithinBand(band, e.Salary)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to check the salary band: %v", ErrDBRequestFailed, err)
+	}
+	if ok {
+		return nil, nil
+	}
+	overrideReason = strings.TrimSpace(overrideReason)
+	if len(overrideReason) == 0 {
+		return nil, fmt.Errorf(
+			"%w: salary %v is not in [%v, %v] and no override reason is given",
+			ErrSalaryOutOfBand, e.Salary, band.MinSalary, band.MaxSalary,
+		)
+	}
+	return &storage.SalaryBandOverride{
+		EmployeeID: e.ID,
+		PositionID: e.Position,
+		Salary:     e.Salary.Amount,
+		Currency:   e.Salary.Currency,
+		Reason:     overrideReason,
+	}, nil
+}
+
+func normalizeEmployee(e *storage.Employee) error {
+	e.FirstName = strings.TrimSpace(e.FirstName)
+	e.LastName = strings.TrimSpace(e.LastName)
+	if len(e.FirstName) == 0 || len(e.LastName) == 0 {
+		return fmt.Errorf("%w: first and last names must not be empty", ErrIncorrectEmployee)
+	}
+	if err := normalizeContacts(e); err != nil {
+		return err
+	}
+	if err := e.Salary.Normalize(); err != nil {
+		return fmt.Errorf("%w: %v", ErrIncorrectEmployee, err)
+	}
+	if e.Salary.Amount.Sign() <= 0 {
+		return fmt.Errorf("%w: the salary must be positive", ErrIncorrectEmployee)
+	}
+	if e.ManagerID <= 0 || e.Department <= 0 || e.Position <= 0 {
+		return fmt.Errorf("%w: incorrect manager, department or position ID", ErrIncorrectEmployee)
+	}
+	return nil
+}
+
+// getViewer returns the caller as a viewer of the employees.
+func getViewer(db storage.DB, p *auth.Principal) (*visibility.Viewer, error) {
+	viewerID := 0
+	if p != nil {
+		viewerID = p.EmployeeID
+	}
+	v, err := db.GetViewer(context.Background(), viewerID)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to get the viewer")
+	}
+	return v, nil
+}
+
+func wrapDBErr(err error, msg string) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%w: %s: %v", ErrEmployeeNotFound, msg, err)
+	}
+	if errors.Is(err, storage.ErrConflict) {
+		return fmt.Errorf("%w: %s: %v", ErrEmployeeConflict, msg, err)
+	}
+	return fmt.Errorf("%w: %s: %v", ErrDBRequestFailed, msg, err)
+}
@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	positionsStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/positions/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
+)
+
+func TestCreateEmployeeSalaryBand(t *testing.T) {
+	band := &positionsStorage.SalaryBand{
+		Currency:  "RUB",
+		MinSalary: 4000000,
+		MaxSalary: 6000000,
+	}
+	cases := []struct {
+		Salary           money.Money
+		Band             *positionsStorage.SalaryBand
+		OverrideReason   string
+		ExpectedOverride bool
+		ExpectedErr      error
+	}{
+		{
+			Salary: money.New(4500000, "RUB"),
+			Band:   band,
+		},
+		{
+			Salary: money.New(7500000, "RUB"),
+			Band:   nil,
+		},
+		{
+			Salary:      money.New(7500000, "RUB"),
+			Band:        band,
+			ExpectedErr: ErrSalaryOutOfBand,
+		},
+		{
+			Salary:         money.New(7500000, "RUB"),
+			Band:           band,
+			OverrideReason: "   ",
+			ExpectedErr:    ErrSalaryOutOfBand,
+		},
+		{
+			Salary:           money.New(7500000, "RUB"),
+			Band:             band,
+			OverrideReason:   "retention offer",
+			ExpectedOverride: true,
+		},
+		{
+			Salary:      money.New(-4500000, "RUB"),
+			Band:        band,
+			ExpectedErr: ErrIncorrectEmployee,
+		},
+		{
+			Salary:      money.New(0, "RUB"),
+			Band:        band,
+			ExpectedErr: ErrIncorrectEmployee,
+		},
+		{
+			Salary: money.New(7500000, "usd"),
+			Band:   band,
+		},
+		{
+			Salary:      money.New(4500000, "RU"),
+			Band:        band,
+			ExpectedErr: ErrIncorrectEmployee,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t:    t,
+				band: tc.Band,
+			}
+			e := &storage.Employee{
+				FirstName:  "Dale",
+				LastName:   "Cooper",
+				Salary:     tc.Salary,
+				ManagerID:  1,
+				Department: 2,
+				Position:   3,
+			}
+			_, err := CreateEmployee(mock, hrPrincipal, e, tc.OverrideReason, nil)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				if mock.saved != nil {
+					t.Errorf("the employee was saved despite the error")
+				}
+				return
+			}
+			if tc.ExpectedOverride != (mock.override != nil) {
+				t.Errorf("expected an override to be recorded: %v, got: %v", tc.ExpectedOverride, mock.override)
+				return
+			}
+			if mock.override != nil && mock.override.Reason != tc.OverrideReason {
+				t.Errorf("expected override reason %q, got %q", tc.OverrideReason, mock.override.Reason)
+			}
+		})
+	}
+}
+
+func TestUpdateEmployeeSalaryBand(t *testing.T) {
+	// the band has moved above the employee's salary since they were hired
+	band := &positionsStorage.SalaryBand{
+		Currency:  "RUB",
+		MinSalary: 5000000,
+		MaxSalary: 7000000,
+	}
+	current := &storage.Employee{
+		ID:         42,
+		FirstName:  "Dale",
+		LastName:   "Cooper",
+		Salary:     money.New(4500000, "RUB"),
+		ManagerID:  1,
+		Department: 2,
+		Position:   3,
+	}
+	cases := []struct {
+		LastName    string
+		Salary      money.Money
+		Department  int
+		Position    int
+		ExpectedErr error
+	}{
+		{
+			LastName:   "Cooper-Horne",
+			Salary:     money.New(4500000, "RUB"),
+			Department: 2,
+			Position:   3,
+		},
+		{
+			LastName:    "Cooper",
+			Salary:      money.New(4600000, "RUB"),
+			Department:  2,
+			Position:    3,
+			ExpectedErr: ErrSalaryOutOfBand,
+		},
+		{
+			LastName:    "Cooper",
+			Salary:      money.New(4500000, "RUB"),
+			Department:  2,
+			Position:    4,
+			ExpectedErr: ErrSalaryOutOfBand,
+		},
+		{
+			LastName:    "Cooper",
+			Salary:      money.New(4500000, "RUB"),
+			Department:  0,
+			Position:    3,
+			ExpectedErr: ErrIncorrectEmployee,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t:        t,
+				band:     band,
+				employee: current,
+			}
+			e := *current
+			e.LastName, e.Salary, e.Department, e.Position = tc.LastName, tc.Salary, tc.Department, tc.Position
+			_, err := UpdateEmployee(mock, hrPrincipal, &e, "", nil)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr == nil && mock.saved == nil {
+				t.Errorf("the employee was not saved")
+			}
+		})
+	}
+}
+
+func TestUpdateEmployeeNotFound(t *testing.T) {
+	mock := &dbMock{
+		t:             t,
+		expectedError: fmt.Errorf("%w: employee 42", storage.ErrNotFound),
+	}
+	e := &storage.Employee{
+		ID:         42,
+		FirstName:  "Dale",
+		LastName:   "Cooper",
+		Salary:     money.New(4500000, "RUB"),
+		ManagerID:  1,
+		Department: 2,
+		Position:   3,
+	}
+	_, err := UpdateEmployee(mock, hrPrincipal, e, "", nil)
+	if err := compareErrs(ErrEmployeeNotFound, err); err != nil {
+		t.Error(err)
+	}
+}
+
+// hrPrincipal may change the employees of every department.
+var hrPrincipal = &auth.Principal{Subject: "hr", Roles: []string{"hr"}}
+
+func compareErrs(expectedErr error, actualErr error) error {
+	if expectedErr == nil && actualErr == nil {
+		return nil
+	}
+	if actualErr == nil {
+		return fmt.Errorf("expected an error \"%v\", got nil", expectedErr)
+	}
+	if !errors.Is(actualErr, expectedErr) {
+		return fmt.Errorf("expected error \"%v\" and actual error \"%v\" are different", expectedErr, actualErr)
+	}
+	return nil
+}
+
+type dbMock struct {
+	t             *testing.T
+	band          *positionsStorage.SalaryBand
+	expectedError error
+	employee      *storage.Employee
+	saved         *storage.Employee
+	override      *storage.SalaryBandOverride
+	salaryChange  *storage.SalaryChange
+	changes       []*storage.SalaryChange
+	assignments   []*storage.Assignment
+	terminations  []*storage.Termination
+	viewer        *visibility.Viewer
+}
+
+func (db *dbMock) GetEmployee(ctx context.Context, id int) (*storage.Employee, error) {
+	if db.employee == nil || db.employee.ID != id {
+		if db.expectedError != nil {
+			return nil, db.expectedError
+		}
+		return nil, fmt.Errorf("%w: employee %d", storage.ErrNotFound, id)
+	}
+	e := *db.employee
+	return &e, nil
+}
+
+func (db *dbMock) CreateEmployee(ctx context.Context, e *storage.Employee, override *storage.SalaryBandOverride, salaryChange *storage.SalaryChange) (*storage.Employee, error) {
+	return db.save(e, override, salaryChange)
+}
+
+func (db *dbMock) UpdateEmployee(ctx context.Context, e *storage.Employee, override *storage.SalaryBandOverride, salaryChange *storage.SalaryChange) (*storage.Employee, error) {
+	return db.save(e, override, salaryChange)
+}
+
+func (db *dbMock) save(e *storage.Employee, override *storage.SalaryBandOverride, salaryChange *storage.SalaryChange) (*storage.Employee, error) {
+	if db.expectedError != nil {
+		return nil, db.expectedError
+	}
+	db.saved = e
+	db.override = override
+	db.salaryChange = salaryChange
+	saved := *e
+	return &saved, nil
+}
+
+func (db *dbMock) ListSalaryChanges(ctx context.Context, employeeID int) ([]*storage.SalaryChange, error) {
+	return db.changes, nil
+}
+
+func (db *dbMock) ScheduleSalaryChange(ctx context.Context, c *storage.SalaryChange, override *storage.SalaryBandOverride) (*storage.SalaryChange, error) {
+	if db.expectedError != nil {
+		return nil, db.expectedError
+	}
+	scheduled := *c
+	scheduled.ID = len(db.changes) + 1
+	db.changes = append(db.changes, &scheduled)
+	db.override = override
+	return &scheduled, nil
+}
+
+func (db *dbMock) CancelSalaryChange(ctx context.Context, employeeID int, changeID int) error {
+	for _, c := range db.changes {
+		if c.ID == changeID && c.EmployeeID == employeeID && c.AppliedAt == nil && c.CancelledAt == nil {
+			cancelledAt := now()
+			c.CancelledAt = &cancelledAt
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: pending salary change %d", storage.ErrNotFound, changeID)
+}
+
+func (db *dbMock) ApplyDueSalaryChanges(ctx context.Context, on time.Time) ([]*storage.SalaryChange, error) {
+	applied := make([]*storage.SalaryChange, 0)
+	for _, c := range db.changes {
+		if c.AppliedAt != nil || c.CancelledAt != nil || c.EffectiveDate.After(on) {
+			continue
+		}
+		appliedAt := on
+		c.AppliedAt = &appliedAt
+		if db.employee != nil && db.employee.ID == c.EmployeeID {
+			db.employee.Salary = c.Salary
+		}
+		applied = append(applied, c)
+	}
+	return applied, nil
+}
+
+func (db *dbMock) ApplySalaryChange(ctx context.Context, employeeID int, changeID int, on time.Time) (*storage.SalaryChange, error) {
+	var applied *storage.SalaryChange
+	for _, c := range db.changes {
+		if c.EmployeeID != employeeID || c.AppliedAt != nil || c.CancelledAt != nil || c.EffectiveDate.After(on) || c.ID > changeID {
+			continue
+		}
+		appliedAt := on
+		c.AppliedAt = &appliedAt
+		if db.employee != nil && db.employee.ID == c.EmployeeID {
+			db.employee.Salary = c.Salary
+		}
+		if c.ID == changeID {
+			applied = c
+		}
+	}
+	if applied == nil {
+		return nil, fmt.Errorf("%w: due salary change %d", storage.ErrNotFound, changeID)
+	}
+	return applied, nil
+}
+
+func (db *dbMock) GetSalaryBand(ctx context.Context, positionID int, currency string) (*positionsStorage.SalaryBand, error) {
+	if db.band == nil || db.band.Currency != currency {
+		return nil, nil
+	}
+	return db.band, nil
+}
+
+func (db *dbMock) Close() {}
+
+func (db *dbMock) Transfer(ctx context.Context, a *storage.Assignment) (*storage.Assignment, error) {
+	if db.expectedError != nil {
+		return nil, db.expectedError
+	}
+	for _, current := range db.assignments {
+		if current.EmployeeID == a.EmployeeID && current.ValidTo == nil {
+			validTo := a.ValidFrom
+			current.ValidTo = &validTo
+		}
+	}
+	started := *a
+	started.ID = len(db.assignments) + 1
+	db.assignments = append(db.assignments, &started)
+	return &started, nil
+}
+
+func (db *dbMock) ListAssignments(ctx context.Context, employeeID int) ([]*storage.Assignment, error) {
+	return db.assignments, nil
+}
+
+func (db *dbMock) ListDepartmentAssignments(ctx context.Context, department int, on time.Time) ([]*storage.Assignment, error) {
+	assignments := make([]*storage.Assignment, 0)
+	for _, a := range db.assignments {
+		if a.Department == department && !a.ValidFrom.After(on) && (a.ValidTo == nil || a.ValidTo.After(on)) {
+			assignments = append(assignments, a)
+		}
+	}
+	return assignments, nil
+}
+
+func (db *dbMock) TerminateEmployee(ctx context.Context, t *storage.Termination) (*storage.Termination, error) {
+	if db.expectedError != nil {
+		return nil, db.expectedError
+	}
+	terminated := *t
+	terminated.ID = len(db.terminations) + 1
+	db.terminations = append(db.terminations, &terminated)
+	return &terminated, nil
+}
+
+func (db *dbMock) RehireEmployee(ctx context.Context, a *storage.Assignment) (*storage.Employee, error) {
+	if db.expectedError != nil {
+		return nil, db.expectedError
+	}
+	started := *a
+	db.assignments = append(db.assignments, &started)
+	rehired := *db.employee
+	rehired.Department = a.Department
+	rehired.Position = a.Position
+	rehired.ManagerID = a.ManagerID
+	rehired.EntryAt = a.ValidFrom
+	rehired.TerminatedOn = nil
+	return &rehired, nil
+}
+
+func (db *dbMock) ListTerminations(ctx context.Context, employeeID int) ([]*storage.Termination, error) {
+	return db.terminations, nil
+}
+
+func (db *dbMock) SetContactVisibility(ctx context.Context, id int, phone *string, email *string) (*storage.Employee, error) {
+	e, err := db.GetEmployee(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	e.PhoneVisibility, e.EmailVisibility = phone, email
+	db.saved = e
+	return e, nil
+}
+
+func (db *dbMock) GetViewer(ctx context.Context, employeeID int) (*visibility.Viewer, error) {
+	if db.viewer == nil || db.viewer.EmployeeID != employeeID {
+		return &visibility.Viewer{}, nil
+	}
+	return db.viewer, nil
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+)
+
+var ErrIncorrectSalaryChange = fmt.Errorf("got an incorrect salary change")
+
+// now is replaced in tests.
+var now = time.Now
+
+// ListSalaryChanges returns the employee's compensation timeline: the applied,
+// the scheduled and the cancelled salary changes ordered by the effective
+// date. Only HR, the employee and their managers may see it.
+func ListSalaryChanges(db storage.DB, p *auth.Principal, employeeID int) ([]*storage.SalaryChange, error) {
+	v, err := getViewer(db, p)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.NewActor(p, v).Check(policy.EntitySalary, policy.ActionRead, employeeID); err != nil {
+		return nil, err
+	}
+	changes, err := db.ListSalaryChanges(context.Background(), employeeID)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list the salary changes")
+	}
+	return changes, nil
+}
+
+// ScheduleSalaryChange schedules the change of the employee's salary on the
+// effective date. A change effective today is applied at once, the due changes
+// of the other employees are left to SalaryChangeApplier. The salary band of
+// the employee's position is enforced the same way as in CreateEmployee.
+func ScheduleSalaryChange(db storage.DB, p *auth.Principal, c *storage.SalaryChange, overrideReason string) (*storage.SalaryChange, error) {
+	if err := c.Salary.Normalize(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIncorrectSalaryChange, err)
+	}
+	if c.Salary.Amount.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: the salary must be positive", ErrIncorrectSalaryChange)
+	}
+	today := truncateToDate(now())
+	c.EffectiveDate = truncateToDate(c.EffectiveDate)
+	if c.EffectiveDate.Before(today) {
+		return nil, fmt.Errorf(
+			"%w: effective date %s is in the past",
+			ErrIncorrectSalaryChange, c.EffectiveDate.Format("2006-01-02"),
+		)
+	}
+	c.Reason = strings.TrimSpace(c.Reason)
+
+	e, err := db.GetEmployee(context.Background(), c.EmployeeID)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to get the employee")
+	}
+	if err := policy.NewActor(p, nil).CheckIn(policy.EntitySalary, policy.ActionWrite, e.ID, e.Department); err != nil {
+		return nil, err
+	}
+	e.Salary = c.Salary
+	override, err := checkSalaryBand(db, e, overrideReason)
+	if err != nil {
+		return nil, err
+	}
+	scheduled, err := db.ScheduleSalaryChange(context.Background(), c, override)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to schedule the salary change")
+	}
+	if scheduled.EffectiveDate.After(today) {
+		return scheduled, nil
+	}
+	applied, err := db.ApplySalaryChange(context.Background(), scheduled.EmployeeID, scheduled.ID, today)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to apply the salary change")
+	}
+	return applied, nil
+}
+
+// CancelSalaryChange cancels a scheduled salary change of the employee.
+func CancelSalaryChange(db storage.DB, p *auth.Principal, employeeID int, changeID int) error {
+	e, err := db.GetEmployee(context.Background(), employeeID)
+	if err != nil {
+		return wrapDBErr(err, "failed to get the employee")
+	}
+	if err := policy.NewActor(p, nil).CheckIn(policy.EntitySalary, policy.ActionWrite, e.ID, e.Department); err != nil {
+		return err
+	}
+	if err := db.CancelSalaryChange(context.Background(), employeeID, changeID); err != nil {
+		return wrapDBErr(err, "failed to cancel the salary change")
+	}
+	return nil
+}
+
+// ApplyDueSalaryChanges applies the scheduled salary changes that became
+// effective.
+func ApplyDueSalaryChanges(db storage.DB) ([]*storage.SalaryChange, error) {
+	applied, err := db.ApplyDueSalaryChanges(context.Background(), now())
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to apply the due salary changes")
+	}
+	return applied, nil
+}
+
+// SalaryChangeApplier applies the scheduled salary changes on a schedule.
+type SalaryChangeApplier struct {
+	newDB     func() (storage.DB, error)
+	onApplied func(applied []*storage.SalaryChange)
+}
+
+// NewSalaryChangeApplier creates an applier calling onApplied after some
+// changes are applied.
+func NewSalaryChangeApplier(newDB func() (storage.DB, error), onApplied func(applied []*storage.SalaryChange)) *SalaryChangeApplier {
+	return &SalaryChangeApplier{
+		newDB:     newDB,
+		onApplied: onApplied,
+	}
+}
+
+// Run applies the due changes at once and then every interval until the
+// context is done.
+func (a *SalaryChangeApplier) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := a.apply(); err != nil {
+			log.Printf("failed to apply the scheduled salary changes: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *SalaryChangeApplier) apply() error {
+	db, err := a.newDB()
+	if err != nil {
+		return fmt.Errorf("failed to open the DB: %w", err)
+	}
+	defer db.Close()
+	applied, err := ApplyDueSalaryChanges(db)
+	if err != nil {
+		return err
+	}
+	if len(applied) != 0 && a.onApplied != nil {
+		a.onApplied(applied)
+	}
+	return nil
+}
+
+// describeSalaryChange returns a copy of the change description with the
+// reason trimmed.
+func describeSalaryChange(c *storage.SalaryChange) *storage.SalaryChange {
+	if c == nil {
+		return &storage.SalaryChange{}
+	}
+	return &storage.SalaryChange{
+		Reason:    strings.TrimSpace(c.Reason),
+		ChangedBy: c.ChangedBy,
+	}
+}
+
+func truncateToDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
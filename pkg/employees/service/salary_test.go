@@ -0,0 +1,196 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	positionsStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/positions/storage"
+)
+
+func TestScheduleSalaryChange(t *testing.T) {
+	setNow(t, time.Date(2021, time.August, 15, 12, 0, 0, 0, time.UTC))
+	band := &positionsStorage.SalaryBand{
+		Currency:  "RUB",
+		MinSalary: 4000000,
+		MaxSalary: 6000000,
+	}
+	cases := []struct {
+		EmployeeID       int
+		Salary           money.Money
+		EffectiveDate    time.Time
+		OverrideReason   string
+		ExpectedApplied  bool
+		ExpectedOverride bool
+		ExpectedErr      error
+	}{
+		{
+			EmployeeID:    1,
+			Salary:        money.New(5500000, "RUB"),
+			EffectiveDate: time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			EmployeeID:      1,
+			Salary:          money.New(5500000, "rub"),
+			EffectiveDate:   time.Date(2021, time.August, 15, 0, 0, 0, 0, time.UTC),
+			ExpectedApplied: true,
+		},
+		{
+			EmployeeID:    1,
+			Salary:        money.New(5500000, "RUB"),
+			EffectiveDate: time.Date(2021, time.August, 14, 0, 0, 0, 0, time.UTC),
+			ExpectedErr:   ErrIncorrectSalaryChange,
+		},
+		{
+			EmployeeID:    1,
+			Salary:        money.New(0, "RUB"),
+			EffectiveDate: time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC),
+			ExpectedErr:   ErrIncorrectSalaryChange,
+		},
+		{
+			EmployeeID:    1,
+			Salary:        money.New(7500000, "RUB"),
+			EffectiveDate: time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC),
+			ExpectedErr:   ErrSalaryOutOfBand,
+		},
+		{
+			EmployeeID:       1,
+			Salary:           money.New(7500000, "RUB"),
+			EffectiveDate:    time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC),
+			OverrideReason:   "promotion pending",
+			ExpectedOverride: true,
+		},
+		{
+			EmployeeID:    42,
+			Salary:        money.New(5500000, "RUB"),
+			EffectiveDate: time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC),
+			ExpectedErr:   ErrEmployeeNotFound,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t:    t,
+				band: band,
+				employee: &storage.Employee{
+					ID:       1,
+					Salary:   money.New(4500000, "RUB"),
+					Position: 3,
+				},
+			}
+			c, err := ScheduleSalaryChange(mock, hrPrincipal, &storage.SalaryChange{
+				EmployeeID:    tc.EmployeeID,
+				Salary:        tc.Salary,
+				EffectiveDate: tc.EffectiveDate,
+				Reason:        " annual review ",
+				ChangedBy:     "hr",
+			}, tc.OverrideReason)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				if len(mock.changes) != 0 {
+					t.Errorf("the salary change was scheduled despite the error")
+				}
+				return
+			}
+			if c.Reason != "annual review" {
+				t.Errorf("expected the reason to be trimmed, got %q", c.Reason)
+			}
+			if tc.ExpectedApplied != (c.AppliedAt != nil) {
+				t.Errorf("expected the change to be applied: %v, got applied at: %v", tc.ExpectedApplied, c.AppliedAt)
+			}
+			expectedSalary := money.New(4500000, "RUB")
+			if tc.ExpectedApplied {
+				expectedSalary = money.New(5500000, "RUB")
+			}
+			if mock.employee.Salary != expectedSalary {
+				t.Errorf("expected the employee salary %v, got %v", expectedSalary, mock.employee.Salary)
+			}
+			if tc.ExpectedOverride != (mock.override != nil) {
+				t.Errorf("expected an override to be recorded: %v, got: %v", tc.ExpectedOverride, mock.override)
+			}
+		})
+	}
+}
+
+func TestScheduleSalaryChangeLeavesOtherEmployees(t *testing.T) {
+	setNow(t, time.Date(2021, time.August, 15, 12, 0, 0, 0, time.UTC))
+	other := &storage.SalaryChange{
+		ID:            1,
+		EmployeeID:    2,
+		Salary:        money.New(5000000, "RUB"),
+		EffectiveDate: time.Date(2021, time.August, 14, 0, 0, 0, 0, time.UTC),
+	}
+	mock := &dbMock{
+		t: t,
+		employee: &storage.Employee{
+			ID:       1,
+			Salary:   money.New(4500000, "RUB"),
+			Position: 3,
+		},
+		changes: []*storage.SalaryChange{other},
+	}
+	c, err := ScheduleSalaryChange(mock, hrPrincipal, &storage.SalaryChange{
+		EmployeeID:    1,
+		Salary:        money.New(5500000, "RUB"),
+		EffectiveDate: time.Date(2021, time.August, 15, 0, 0, 0, 0, time.UTC),
+		ChangedBy:     "hr",
+	}, "")
+	if err != nil {
+		t.Fatalf("failed to schedule the salary change: %v", err)
+	}
+	if c.AppliedAt == nil {
+		t.Errorf("expected the change effective today to be applied")
+	}
+	if other.AppliedAt != nil {
+		t.Errorf("expected the due change of the other employee to be left to the applier")
+	}
+}
+
+func TestApplyDueSalaryChanges(t *testing.T) {
+	setNow(t, time.Date(2021, time.October, 1, 3, 0, 0, 0, time.UTC))
+	mock := &dbMock{
+		t: t,
+		employee: &storage.Employee{
+			ID:     1,
+			Salary: money.New(4500000, "RUB"),
+		},
+		changes: []*storage.SalaryChange{
+			{ID: 1, EmployeeID: 1, Salary: money.New(5000000, "RUB"), EffectiveDate: time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)},
+			{ID: 2, EmployeeID: 1, Salary: money.New(5500000, "RUB"), EffectiveDate: time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC)},
+			{ID: 3, EmployeeID: 1, Salary: money.New(6000000, "RUB"), EffectiveDate: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	if err := CancelSalaryChange(mock, hrPrincipal, 1, 1); err != nil {
+		t.Fatalf("CancelSalaryChange failed: %v", err)
+	}
+	applied, err := ApplyDueSalaryChanges(mock)
+	if err != nil {
+		t.Fatalf("ApplyDueSalaryChanges failed: %v", err)
+	}
+	if len(applied) != 1 || applied[0].ID != 2 {
+		t.Fatalf("expected the change 2 to be applied, got %+v", applied)
+	}
+	if mock.employee.Salary != money.New(5500000, "RUB") {
+		t.Errorf("expected the employee salary 55000.00 RUB, got %v", mock.employee.Salary)
+	}
+	err = CancelSalaryChange(mock, hrPrincipal, 1, 2)
+	if err := compareErrs(ErrEmployeeNotFound, err); err != nil {
+		t.Errorf("cancelling an applied change: %v", err)
+	}
+}
+
+func setNow(t *testing.T, n time.Time) {
+	prev := now
+	now = func() time.Time {
+		return n
+	}
+	t.Cleanup(func() {
+		now = prev
+	})
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+)
+
+var ErrIncorrectTermination = fmt.Errorf("got an incorrect termination")
+
+// rehireReason describes the assignment of a rehired employee when no other
+// reason is given.
+const rehireReason = "rehire"
+
+// TerminateEmployee ends the employee's employment after t.LastDay, today if
+// it is zero. The employee is kept in the database but leaves the phone
+// lookup and the payrolls once the last day has passed. The direct reports
+// are moved to t.ReportsManagerID at once.
+func TerminateEmployee(db storage.DB, p *auth.Principal, t *storage.Termination) (*storage.Termination, error) {
+	if t.LastDay.IsZero() {
+		t.LastDay = now()
+	}
+	t.LastDay = truncateToDate(t.LastDay)
+	t.Reason = strings.TrimSpace(t.Reason)
+	if t.ReportsManagerID != nil {
+		if *t.ReportsManagerID <= 0 {
+			return nil, fmt.Errorf("%w: incorrect manager ID of the direct reports", ErrIncorrectTermination)
+		}
+		if *t.ReportsManagerID == t.EmployeeID {
+			return nil, fmt.Errorf("%w: the direct reports cannot be reassigned to the terminated employee", ErrIncorrectTermination)
+		}
+	}
+	e, err := db.GetEmployee(context.Background(), t.EmployeeID)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to get the employee")
+	}
+	if err := policy.NewActor(p, nil).CheckIn(policy.EntityTermination, policy.ActionWrite, e.ID, e.Department); err != nil {
+		return nil, err
+	}
+	terminated, err := db.TerminateEmployee(context.Background(), t)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to terminate the employee")
+	}
+	return terminated, nil
+}
+
+// RehireEmployee reactivates a terminated employee from a.ValidFrom, today if
+// it is zero. The fields of a left zero keep the values the employee had when
+// they left. Rehires cannot be dated in the future.
+func RehireEmployee(db storage.DB, p *auth.Principal, a *storage.Assignment) (*storage.Employee, error) {
+	today := truncateToDate(now())
+	if a.ValidFrom.IsZero() {
+		a.ValidFrom = today
+	}
+	a.ValidFrom = truncateToDate(a.ValidFrom)
+	if a.ValidFrom.After(today) {
+		return nil, fmt.Errorf(
+			"%w: rehire date %s is in the future",
+			ErrIncorrectTermination, a.ValidFrom.Format("2006-01-02"),
+		)
+	}
+	a.Reason = strings.TrimSpace(a.Reason)
+	if len(a.Reason) == 0 {
+		a.Reason = rehireReason
+	}
+	if a.Department < 0 || a.Position < 0 || a.ManagerID < 0 {
+		return nil, fmt.Errorf("%w: incorrect department, position or manager ID", ErrIncorrectTermination)
+	}
+
+	e, err := db.GetEmployee(context.Background(), a.EmployeeID)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to get the employee")
+	}
+	if a.Department == 0 {
+		a.Department = e.Department
+	}
+	if a.Position == 0 {
+		a.Position = e.Position
+	}
+	if a.ManagerID == 0 {
+		a.ManagerID = e.ManagerID
+	}
+	if err := policy.NewActor(p, nil).CheckIn(policy.EntityTermination, policy.ActionWrite, e.ID, e.Department, a.Department); err != nil {
+		return nil, err
+	}
+	rehired, err := db.RehireEmployee(context.Background(), a)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to rehire the employee")
+	}
+	return rehired, nil
+}
+
+// ListTerminations returns the employee's past and current terminations.
+func ListTerminations(db storage.DB, employeeID int) ([]*storage.Termination, error) {
+	terminations, err := db.ListTerminations(context.Background(), employeeID)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list the terminations")
+	}
+	return terminations, nil
+}
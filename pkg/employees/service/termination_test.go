@@ -0,0 +1,152 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+)
+
+func TestTerminateEmployee(t *testing.T) {
+	setNow(t, time.Date(2021, time.August, 15, 12, 0, 0, 0, time.UTC))
+	cases := []struct {
+		Termination storage.Termination
+		Expected    storage.Termination
+		ExpectedErr error
+	}{
+		{
+			Termination: storage.Termination{EmployeeID: 1, Reason: " resigned "},
+			Expected: storage.Termination{
+				EmployeeID: 1, Reason: "resigned",
+				LastDay: time.Date(2021, time.August, 15, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			Termination: storage.Termination{
+				EmployeeID: 1, ReportsManagerID: intPtr(7),
+				LastDay: time.Date(2021, time.September, 30, 18, 0, 0, 0, time.UTC),
+			},
+			Expected: storage.Termination{
+				EmployeeID: 1, ReportsManagerID: intPtr(7),
+				LastDay: time.Date(2021, time.September, 30, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			Termination: storage.Termination{EmployeeID: 1, ReportsManagerID: intPtr(1)},
+			ExpectedErr: ErrIncorrectTermination,
+		},
+		{
+			Termination: storage.Termination{EmployeeID: 1, ReportsManagerID: intPtr(-1)},
+			ExpectedErr: ErrIncorrectTermination,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{t: t, employee: &storage.Employee{ID: 1, Department: 2}}
+			termination := tc.Termination
+			terminated, err := TerminateEmployee(mock, hrPrincipal, &termination)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				if len(mock.terminations) != 0 {
+					t.Errorf("the employee was terminated despite the error")
+				}
+				return
+			}
+			terminated.ID = 0
+			if !terminated.LastDay.Equal(tc.Expected.LastDay) || terminated.Reason != tc.Expected.Reason ||
+				!equalIntPtrs(terminated.ReportsManagerID, tc.Expected.ReportsManagerID) {
+				t.Errorf("expected termination %+v, got %+v", tc.Expected, *terminated)
+			}
+		})
+	}
+}
+
+func TestRehireEmployee(t *testing.T) {
+	setNow(t, time.Date(2021, time.August, 15, 12, 0, 0, 0, time.UTC))
+	cases := []struct {
+		Assignment  storage.Assignment
+		Expected    storage.Assignment
+		ExpectedErr error
+	}{
+		{
+			Assignment: storage.Assignment{EmployeeID: 1},
+			Expected: storage.Assignment{
+				EmployeeID: 1, Department: 2, Position: 3, ManagerID: 7,
+				ValidFrom: time.Date(2021, time.August, 15, 0, 0, 0, 0, time.UTC),
+				Reason:    rehireReason,
+			},
+		},
+		{
+			Assignment: storage.Assignment{
+				EmployeeID: 1, Department: 5, ManagerID: 8, Reason: "returned from the sabbatical",
+				ValidFrom: time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC),
+			},
+			Expected: storage.Assignment{
+				EmployeeID: 1, Department: 5, Position: 3, ManagerID: 8,
+				ValidFrom: time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC),
+				Reason:    "returned from the sabbatical",
+			},
+		},
+		{
+			Assignment: storage.Assignment{
+				EmployeeID: 1,
+				ValidFrom:  time.Date(2021, time.August, 16, 0, 0, 0, 0, time.UTC),
+			},
+			ExpectedErr: ErrIncorrectTermination,
+		},
+		{
+			Assignment:  storage.Assignment{EmployeeID: 42},
+			ExpectedErr: ErrEmployeeNotFound,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			lastDay := time.Date(2021, time.June, 30, 0, 0, 0, 0, time.UTC)
+			mock := &dbMock{
+				t: t,
+				employee: &storage.Employee{
+					ID:           1,
+					Department:   2,
+					Position:     3,
+					ManagerID:    7,
+					TerminatedOn: &lastDay,
+				},
+			}
+			a := tc.Assignment
+			rehired, err := RehireEmployee(mock, hrPrincipal, &a)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				if len(mock.assignments) != 0 {
+					t.Errorf("the employee was rehired despite the error")
+				}
+				return
+			}
+			if len(mock.assignments) != 1 || *mock.assignments[0] != tc.Expected {
+				t.Fatalf("expected assignment %+v, got %+v", tc.Expected, mock.assignments)
+			}
+			if rehired.TerminatedOn != nil || !rehired.EntryAt.Equal(tc.Expected.ValidFrom) {
+				t.Errorf("expected the employee to be reactivated on %v, got %+v", tc.Expected.ValidFrom, rehired)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func equalIntPtrs(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
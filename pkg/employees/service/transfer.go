@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+)
+
+var ErrIncorrectTransfer = fmt.Errorf("got an incorrect transfer")
+
+// Transfer moves the employee to another department, position or manager
+// from the a.ValidFrom date, today if it is zero. The fields of a left zero
+// keep their current values. Transfers cannot be dated in the future. The HR
+// of a department subtree may transfer the employees only within it.
+func Transfer(db storage.DB, p *auth.Principal, a *storage.Assignment) (*storage.Assignment, error) {
+	today := truncateToDate(now())
+	if a.ValidFrom.IsZero() {
+		a.ValidFrom = today
+	}
+	a.ValidFrom = truncateToDate(a.ValidFrom)
+	if a.ValidFrom.After(today) {
+		return nil, fmt.Errorf(
+			"%w: transfer date %s is in the future",
+			ErrIncorrectTransfer, a.ValidFrom.Format("2006-01-02"),
+		)
+	}
+	a.Reason = strings.TrimSpace(a.Reason)
+
+	e, err := db.GetEmployee(context.Background(), a.EmployeeID)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to get the employee")
+	}
+	if a.Department == 0 && a.Position == 0 && a.ManagerID == 0 {
+		return nil, fmt.Errorf("%w: no department, position or manager given", ErrIncorrectTransfer)
+	}
+	if a.Department == 0 {
+		a.Department = e.Department
+	}
+	if a.Position == 0 {
+		a.Position = e.Position
+	}
+	if a.ManagerID == 0 {
+		a.ManagerID = e.ManagerID
+	}
+	if a.Department < 0 || a.Position < 0 || a.ManagerID < 0 {
+		return nil, fmt.Errorf("%w: incorrect department, position or manager ID", ErrIncorrectTransfer)
+	}
+	if a.Department == e.Department && a.Position == e.Position && a.ManagerID == e.ManagerID {
+		return nil, fmt.Errorf("%w: the employee already has the assignment", ErrIncorrectTransfer)
+	}
+	if err := policy.NewActor(p, nil).CheckIn(policy.EntityAssignment, policy.ActionWrite, e.ID, e.Department, a.Department); err != nil {
+		return nil, err
+	}
+
+	started, err := db.Transfer(context.Background(), a)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to transfer the employee")
+	}
+	return started, nil
+}
+
+// ListAssignments returns the employee's department, position and manager
+// history.
+func ListAssignments(db storage.DB, employeeID int) ([]*storage.Assignment, error) {
+	assignments, err := db.ListAssignments(context.Background(), employeeID)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list the assignments")
+	}
+	return assignments, nil
+}
+
+// ListDepartmentAssignments returns the assignments of the employees who were
+// in the department on the date.
+func ListDepartmentAssignments(db storage.DB, department int, on time.Time) ([]*storage.Assignment, error) {
+	assignments, err := db.ListDepartmentAssignments(context.Background(), department, truncateToDate(on))
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list the department assignments")
+	}
+	return assignments, nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+)
+
+func TestTransfer(t *testing.T) {
+	setNow(t, time.Date(2021, time.August, 15, 12, 0, 0, 0, time.UTC))
+	cases := []struct {
+		Assignment  storage.Assignment
+		Expected    storage.Assignment
+		ExpectedErr error
+	}{
+		{
+			Assignment: storage.Assignment{EmployeeID: 1, Department: 5},
+			Expected: storage.Assignment{
+				EmployeeID: 1, Department: 5, Position: 3, ManagerID: 7,
+				ValidFrom: time.Date(2021, time.August, 15, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			Assignment: storage.Assignment{
+				EmployeeID: 1, Department: 5, Position: 4, ManagerID: 8,
+				ValidFrom: time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC),
+			},
+			Expected: storage.Assignment{
+				EmployeeID: 1, Department: 5, Position: 4, ManagerID: 8,
+				ValidFrom: time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			Assignment:  storage.Assignment{EmployeeID: 1, ManagerID: 7},
+			ExpectedErr: ErrIncorrectTransfer,
+		},
+		{
+			Assignment:  storage.Assignment{EmployeeID: 1},
+			ExpectedErr: ErrIncorrectTransfer,
+		},
+		{
+			Assignment: storage.Assignment{
+				EmployeeID: 1, Department: 5,
+				ValidFrom: time.Date(2021, time.August, 16, 0, 0, 0, 0, time.UTC),
+			},
+			ExpectedErr: ErrIncorrectTransfer,
+		},
+		{
+			Assignment:  storage.Assignment{EmployeeID: 42, Department: 5},
+			ExpectedErr: ErrEmployeeNotFound,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t: t,
+				employee: &storage.Employee{
+					ID:         1,
+					Department: 2,
+					Position:   3,
+					ManagerID:  7,
+				},
+			}
+			a := tc.Assignment
+			started, err := Transfer(mock, hrPrincipal, &a)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				if len(mock.assignments) != 0 {
+					t.Errorf("the employee was transferred despite the error")
+				}
+				return
+			}
+			started.ID = 0
+			if *started != tc.Expected {
+				t.Errorf("expected: %+v, got: %+v", tc.Expected, *started)
+			}
+		})
+	}
+}
+
+func TestTransferInDepartmentSubtree(t *testing.T) {
+	setNow(t, time.Date(2021, time.August, 15, 12, 0, 0, 0, time.UTC))
+	// the HR partner of the division 2 with the departments 4 and 5
+	partner := &auth.Principal{
+		Subject:         "hr-partner",
+		EmployeeID:      9,
+		DepartmentRoles: map[string]map[int]struct{}{"hr": {2: {}, 4: {}, 5: {}}},
+	}
+	cases := []struct {
+		Principal   *auth.Principal
+		Department  int
+		ExpectedErr error
+	}{
+		{
+			Principal:  partner,
+			Department: 5,
+		},
+		{
+			Principal:   partner,
+			Department:  6,
+			ExpectedErr: policy.ErrForbidden,
+		},
+		// the roles of a subtree are not the roles everywhere
+		{
+			Principal: &auth.Principal{
+				Subject:         "hr-partner",
+				DepartmentRoles: map[string]map[int]struct{}{"hr": {5: {}}},
+			},
+			Department:  5,
+			ExpectedErr: policy.ErrForbidden,
+		},
+		{
+			Principal: &auth.Principal{
+				Subject:         "finance-partner",
+				DepartmentRoles: map[string]map[int]struct{}{"finance": {2: {}, 5: {}}},
+			},
+			Department:  5,
+			ExpectedErr: policy.ErrForbidden,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t:        t,
+				employee: &storage.Employee{ID: 1, Department: 2, Position: 3, ManagerID: 7},
+			}
+			_, err := Transfer(mock, tc.Principal, &storage.Assignment{EmployeeID: 1, Department: tc.Department})
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil && len(mock.assignments) != 0 {
+				t.Errorf("the employee was transferred despite the error")
+			}
+		})
+	}
+}
+
+func TestListDepartmentAssignments(t *testing.T) {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2021, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	mock := &dbMock{
+		t: t,
+		employee: &storage.Employee{
+			ID:         1,
+			Department: 2,
+			Position:   3,
+			ManagerID:  7,
+		},
+		assignments: []*storage.Assignment{
+			{ID: 1, EmployeeID: 1, Department: 2, Position: 3, ManagerID: 7, ValidFrom: date(time.January, 1)},
+		},
+	}
+	setNow(t, date(time.August, 15))
+	if _, err := Transfer(mock, hrPrincipal, &storage.Assignment{EmployeeID: 1, Department: 5, ValidFrom: date(time.July, 1)}); err != nil {
+		t.Fatalf("Transfer failed: %v", err)
+	}
+	cases := []struct {
+		Department int
+		On         time.Time
+		Expected   int
+	}{
+		{Department: 2, On: date(time.June, 30), Expected: 1},
+		{Department: 2, On: date(time.July, 1), Expected: 0},
+		{Department: 5, On: date(time.June, 30), Expected: 0},
+		{Department: 5, On: date(time.July, 1).Add(time.Hour * 15), Expected: 1},
+	}
+	for i, tc := range cases {
+		assignments, err := ListDepartmentAssignments(mock, tc.Department, tc.On)
+		if err != nil {
+			t.Fatalf("test case #%d: ListDepartmentAssignments failed: %v", i, err)
+		}
+		if len(assignments) != tc.Expected {
+			t.Errorf("test case #%d: expected %d assignments, got %d", i, tc.Expected, len(assignments))
+		}
+	}
+}
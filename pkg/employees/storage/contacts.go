@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/encryption"
+)
+
+// sealedColumn is a sealed column of the contacts with its blind indexes.
+type sealedColumn struct {
+	table  string
+	column string
+	index  string
+	// prefixes is the column of the indexes of the prefixes, empty if the
+	// prefixes are not searched.
+	prefixes string
+}
+
+var sealedColumns = []*sealedColumn{
+	{table: "employee_phones", column: "number", index: "number_index"},
+	{table: "employee_emails", column: "address", index: "address_index", prefixes: "address_prefixes"},
+}
+
+// sealedBatchSize is the number of the contacts rewritten in a transaction.
+const sealedBatchSize = 500
+
+// sealedValue is a stored contact, Indexed tells if its blind index is set.
+type sealedValue struct {
+	ID      int    `gorm:"column:id"`
+	Value   string `gorm:"column:value"`
+	Indexed bool   `gorm:"column:indexed"`
+}
+
+// ReencryptContacts seals with the primary key of the keyring the phones and
+// the emails that are sealed with the other keys or stored in plaintext and
+// indexes the ones that are not indexed. All the contacts are indexed again
+// if reindex is set, e.g. after the index key has changed. It returns the
+// number of the rewritten contacts.
+func ReencryptContacts(ctx context.Context, db *gorm.DB, reindex bool) (int64, error) {
+	k, err := encryption.Current()
+	if err != nil {
+		return 0, err
+	}
+	return rewriteContacts(ctx, db, func(c *sealedColumn, v *sealedValue) (map[string]interface{}, error) {
+		if k.IsCurrent(v.Value) && v.Indexed && !reindex {
+			return nil, nil
+		}
+		plaintext, err := k.Open(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		sealed, err := k.Rewrap(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		updates := map[string]interface{}{
+			c.column: sealed,
+			c.index:  k.Index(plaintext),
+		}
+		if len(c.prefixes) != 0 {
+			updates[c.prefixes] = k.PrefixIndexes(plaintext)
+		}
+		return updates, nil
+	})
+}
+
+// CountUnindexedContacts returns the number of the phones and the emails
+// without the blind indexes. The lookups do not find them until
+// ReencryptContacts indexes them.
+func CountUnindexedContacts(ctx context.Context, db *gorm.DB) (int64, error) {
+	var unindexed int64
+	for _, c := range sealedColumns {
+		var n int64
+		if err := db.WithContext(ctx).Table(c.table).Where(c.index + " IS NULL").Count(&n).Error; err != nil {
+			return 0, fmt.Errorf("failed to count the unindexed %s: %w", c.table, err)
+		}
+		unindexed += n
+	}
+	return unindexed, nil
+}
+
+// DecryptContacts stores the phones and the emails in plaintext without the
+// blind indexes, so that the encryption can be rolled back. It returns the
+// number of the rewritten contacts.
+func DecryptContacts(ctx context.Context, db *gorm.DB) (int64, error) {
+	k, err := encryption.Current()
+	if err != nil {
+		return 0, err
+	}
+	return rewriteContacts(ctx, db, func(c *sealedColumn, v *sealedValue) (map[string]interface{}, error) {
+		if !encryption.IsSealed(v.Value) && !v.Indexed {
+			return nil, nil
+		}
+		plaintext, err := k.Open(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		updates := map[string]interface{}{
+			c.column: plaintext,
+			c.index:  nil,
+		}
+		if len(c.prefixes) != 0 {
+			updates[c.prefixes] = nil
+		}
+		return updates, nil
+	})
+}
+
+// rewriteContacts updates the contacts of all the sealed columns by the
+// columns rewrite returns, nil leaves a contact as it is.
+func rewriteContacts(ctx context.Context, db *gorm.DB, rewrite func(c *sealedColumn, v *sealedValue) (map[string]interface{}, error)) (int64, error) {
+	var rewritten int64
+	for _, c := range sealedColumns {
+		lastID := 0
+		for {
+			var values []*sealedValue
+			var n int64
+			err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				query := fmt.Sprintf(
+					`SELECT id, %s AS value, %s IS NOT NULL AS indexed FROM %s WHERE id > ? ORDER BY id LIMIT ? FOR UPDATE`,
+					c.column, c.index, c.table,
+				)
+				if err := tx.Raw(query, lastID, sealedBatchSize).Scan(&values).Error; err != nil {
+					return fmt.Errorf("failed to query the %s: %w", c.table, err)
+				}
+				for _, v := range values {
+					updates, err := rewrite(c, v)
+					if err != nil {
+						return fmt.Errorf("failed to rewrite row %d of %s: %w", v.ID, c.table, err)
+					}
+					if updates == nil {
+						continue
+					}
+					if err := tx.Table(c.table).Where("id = ?", v.ID).Updates(updates).Error; err != nil {
+						return wrapWriteErr(err, fmt.Sprintf("failed to update row %d of %s", v.ID, c.table))
+					}
+					n++
+				}
+				return nil
+			})
+			if err != nil {
+				return rewritten, err
+			}
+			rewritten += n
+			if len(values) < sealedBatchSize {
+				break
+			}
+			lastID = values[len(values)-1].ID
+		}
+	}
+	return rewritten, nil
+}
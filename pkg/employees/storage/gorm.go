@@ -0,0 +1,1042 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/encryption"
+	positionsStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/positions/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
+)
+
+// employeeColumns are read with employeeSalaries joined: the salaries the
+// caller may not read are left out of employee_salaries and read as zero.
+const employeeColumns = `employees.id, first_name, last_name, employee_salaries.salary_amount,
+	employee_salaries.salary_currency, manager_id, department, position, entry_at, terminated_on,
+	phone_visibility, email_visibility`
+
+const employeeSalaries = `LEFT JOIN employee_salaries ON employee_salaries.employee_id = employees.id`
+
+// lockEmployees locks the rows of employees only, not of the joined
+// employee_salaries.
+var lockEmployees = clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}}
+
+type gormDB struct {
+	db  *gorm.DB
+	src *auditStorage.Source
+}
+
+func newGormDB(c *database.ConnString, src *auditStorage.Source) (*gormDB, error) {
+	db, err := database.OpenGorm(c)
+	if err != nil {
+		return nil, err
+	}
+	return &gormDB{
+		db:  db,
+		src: src,
+	}, nil
+}
+
+func (g *gormDB) GetEmployee(ctx context.Context, id int) (*Employee, error) {
+	return getEmployee(g.db.WithContext(ctx), id)
+}
+
+func (g *gormDB) CreateEmployee(ctx context.Context, e *Employee, override *SalaryBandOverride, salaryChange *SalaryChange) (*Employee, error) {
+	var created *Employee
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		columns := []string{
+			"first_name", "last_name", "salary_amount", "salary_currency",
+			"manager_id", "department", "position",
+		}
+		if !e.EntryAt.IsZero() {
+			columns = append(columns, "entry_at")
+		}
+		if err := checkNotLeft(tx, e.ManagerID); err != nil {
+			return err
+		}
+		row := *e
+		row.ID = 0
+		if err := tx.Select(columns).Create(&row).Error; err != nil {
+			return wrapWriteErr(err, "failed to insert the employee")
+		}
+		if err := insertSalaryBandOverride(tx, row.ID, override); err != nil {
+			return err
+		}
+		if err := saveContacts(tx, row.ID, e.Phones, e.Emails); err != nil {
+			return err
+		}
+		var err error
+		created, err = getEmployee(tx, row.ID)
+		if err != nil {
+			return err
+		}
+		entryDate := created.EntryAt.Format("2006-01-02")
+		if err := insertAppliedSalaryChange(tx, created, &entryDate, salaryChange); err != nil {
+			return err
+		}
+		if _, err := StartAssignment(tx, assignmentOf(created, salaryChange), &entryDate); err != nil {
+			return err
+		}
+		return recordEmployee(tx, g.src, auditStorage.ActionCreate, nil, created)
+	})
+	if err != nil {
+		// the manager foreign key is deferred, so it is checked on commit
+		return nil, wrapWriteErr(err, "failed to create the employee")
+	}
+	return created, nil
+}
+
+func (g *gormDB) UpdateEmployee(ctx context.Context, e *Employee, override *SalaryBandOverride, salaryChange *SalaryChange) (*Employee, error) {
+	var updated *Employee
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockEmployee(tx, e.ID); err != nil {
+			return err
+		}
+		current, err := getEmployee(tx, e.ID)
+		if err != nil {
+			return err
+		}
+		if err := checkNotLeft(tx, e.ID, e.ManagerID); err != nil {
+			return err
+		}
+		values := map[string]interface{}{
+			"first_name":      e.FirstName,
+			"last_name":       e.LastName,
+			"salary_amount":   e.Salary.Amount,
+			"salary_currency": e.Salary.Currency,
+			"manager_id":      e.ManagerID,
+			"department":      e.Department,
+			"position":        e.Position,
+		}
+		if !e.EntryAt.IsZero() {
+			values["entry_at"] = e.EntryAt
+		}
+		req := tx.Model(&Employee{}).Where("id = ?", e.ID).Updates(values)
+		if err := req.Error; err != nil {
+			return wrapWriteErr(err, "failed to update the employee")
+		}
+		if req.RowsAffected == 0 {
+			return fmt.Errorf("%w: employee %d", ErrNotFound, e.ID)
+		}
+		if err := insertSalaryBandOverride(tx, e.ID, override); err != nil {
+			return err
+		}
+		if err := saveContacts(tx, e.ID, e.Phones, e.Emails); err != nil {
+			return err
+		}
+		updated, err = getEmployee(tx, e.ID)
+		if err != nil {
+			return err
+		}
+		if updated.Salary != current.Salary {
+			if err := insertAppliedSalaryChange(tx, updated, nil, salaryChange); err != nil {
+				return err
+			}
+		}
+		if updated.Department != current.Department ||
+			updated.Position != current.Position ||
+			updated.ManagerID != current.ManagerID {
+			if _, err := StartAssignment(tx, assignmentOf(updated, salaryChange), nil); err != nil {
+				return err
+			}
+		}
+		return recordEmployee(tx, g.src, auditStorage.ActionUpdate, current, updated)
+	})
+	if err != nil {
+		// the manager foreign key is deferred, so it is checked on commit
+		return nil, wrapWriteErr(err, "failed to update the employee")
+	}
+	return updated, nil
+}
+
+func (g *gormDB) GetSalaryBand(ctx context.Context, positionID int, currency string) (*positionsStorage.SalaryBand, error) {
+	var bands []*positionsStorage.SalaryBand
+	req := g.db.WithContext(ctx).
+		Where("position_id = ? AND currency = ?", positionID, currency).
+		Limit(1).
+		Find(&bands)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query the salary band: %w", err)
+	}
+	if len(bands) == 0 {
+		return nil, nil
+	}
+	return bands[0], nil
+}
+
+func (g *gormDB) ListSalaryChanges(ctx context.Context, employeeID int) ([]*SalaryChange, error) {
+	db := g.db.WithContext(ctx)
+	var changes []*SalaryChange
+	req := db.Where("employee_id = ?", employeeID).Order("effective_date, id").Find(&changes)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query the salary history: %w", err)
+	}
+	if len(changes) == 0 {
+		if _, err := getEmployee(db, employeeID); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+func (g *gormDB) ScheduleSalaryChange(ctx context.Context, c *SalaryChange, override *SalaryBandOverride) (*SalaryChange, error) {
+	row := &SalaryChange{
+		EmployeeID:    c.EmployeeID,
+		Salary:        c.Salary,
+		EffectiveDate: c.EffectiveDate,
+		Reason:        c.Reason,
+		ChangedBy:     c.ChangedBy,
+	}
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkNotLeft(tx, c.EmployeeID); err != nil {
+			return err
+		}
+		columns := []string{"employee_id", "salary_amount", "salary_currency", "effective_date", "reason", "changed_by"}
+		if err := tx.Select(columns).Create(row).Error; err != nil {
+			return wrapWriteErr(err, "failed to insert the salary change")
+		}
+		if err := insertSalaryBandOverride(tx, c.EmployeeID, override); err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", row.ID).Take(row).Error; err != nil {
+			return err
+		}
+		return auditStorage.Record(tx, g.src, &auditStorage.Change{
+			Action:     auditStorage.ActionCreate,
+			EntityType: auditStorage.EntitySalaryChange,
+			EntityID:   row.ID,
+			After:      row,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule the salary change: %w", err)
+	}
+	return row, nil
+}
+
+func (g *gormDB) CancelSalaryChange(ctx context.Context, employeeID int, changeID int) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var pending []*SalaryChange
+		req := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND employee_id = ?", changeID, employeeID).
+			Where("applied_at IS NULL AND cancelled_at IS NULL").
+			Find(&pending)
+		if err := req.Error; err != nil {
+			return fmt.Errorf("failed to query the salary change: %w", err)
+		}
+		if len(pending) == 0 {
+			return fmt.Errorf("%w: pending salary change %d of employee %d", ErrNotFound, changeID, employeeID)
+		}
+		var cancelled []*SalaryChange
+		err := tx.Raw(
+			`UPDATE salary_history SET cancelled_at = NOW() WHERE id = ? RETURNING *`,
+			changeID,
+		).Scan(&cancelled).Error
+		if err != nil {
+			return fmt.Errorf("failed to cancel the salary change: %w", err)
+		}
+		return recordSalaryChanges(tx, g.src, auditStorage.ActionCancel, pending, cancelled)
+	})
+}
+
+func (g *gormDB) ApplyDueSalaryChanges(ctx context.Context, on time.Time) ([]*SalaryChange, error) {
+	var applied []*SalaryChange
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var due []*SalaryChange
+		req := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("applied_at IS NULL AND cancelled_at IS NULL").
+			Where("effective_date <= ?", on.Format("2006-01-02")).
+			Order("effective_date, id").
+			Find(&due)
+		if err := req.Error; err != nil {
+			return fmt.Errorf("failed to query the due salary changes: %w", err)
+		}
+		var err error
+		applied, err = applySalaryChanges(tx, g.src, due)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (g *gormDB) ApplySalaryChange(ctx context.Context, employeeID int, changeID int, on time.Time) (*SalaryChange, error) {
+	var applied *SalaryChange
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var pending []*SalaryChange
+		req := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND employee_id = ?", changeID, employeeID).
+			Where("applied_at IS NULL AND cancelled_at IS NULL").
+			Where("effective_date <= ?", on.Format("2006-01-02")).
+			Find(&pending)
+		if err := req.Error; err != nil {
+			return fmt.Errorf("failed to query the salary change: %w", err)
+		}
+		if len(pending) == 0 {
+			return fmt.Errorf("%w: due salary change %d of employee %d", ErrNotFound, changeID, employeeID)
+		}
+		change := pending[0]
+		// the employee's earlier due changes are applied first, otherwise
+		// they would overwrite the change when applied later
+		var due []*SalaryChange
+		req = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("employee_id = ?", employeeID).
+			Where("applied_at IS NULL AND cancelled_at IS NULL").
+			Where("(effective_date, id) <= (?, ?)", change.EffectiveDate.Format("2006-01-02"), change.ID).
+			Order("effective_date, id").
+			Find(&due)
+		if err := req.Error; err != nil {
+			return fmt.Errorf("failed to query the due salary changes: %w", err)
+		}
+		changes, err := applySalaryChanges(tx, g.src, due)
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			if c.ID == changeID {
+				applied = c
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// applySalaryChanges applies the locked due changes in their order and
+// returns them marked applied.
+func applySalaryChanges(tx *gorm.DB, src *auditStorage.Source, due []*SalaryChange) ([]*SalaryChange, error) {
+	if len(due) == 0 {
+		return nil, nil
+	}
+	ids := make([]int, 0, len(due))
+	for _, c := range due {
+		before, err := getEmployee(tx, c.EmployeeID)
+		if err != nil {
+			return nil, err
+		}
+		// the changes are ordered, so the latest one of an employee wins
+		err = tx.Model(&Employee{}).Where("id = ?", c.EmployeeID).Updates(map[string]interface{}{
+			"salary_amount":   c.Salary.Amount,
+			"salary_currency": c.Salary.Currency,
+		}).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply the salary change %d: %w", c.ID, err)
+		}
+		after, err := getEmployee(tx, c.EmployeeID)
+		if err != nil {
+			return nil, err
+		}
+		if err := recordEmployee(tx, src, auditStorage.ActionApply, before, after); err != nil {
+			return nil, err
+		}
+		ids = append(ids, c.ID)
+	}
+	err := tx.Model(&SalaryChange{}).Where("id IN ?", ids).Update("applied_at", gorm.Expr("NOW()")).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark the salary changes applied: %w", err)
+	}
+	var applied []*SalaryChange
+	if err := tx.Where("id IN ?", ids).Order("effective_date, id").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	if err := recordSalaryChanges(tx, src, auditStorage.ActionApply, due, applied); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (g *gormDB) Transfer(ctx context.Context, a *Assignment) (*Assignment, error) {
+	var started *Assignment
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockEmployee(tx, a.EmployeeID); err != nil {
+			return err
+		}
+		before, err := getEmployee(tx, a.EmployeeID)
+		if err != nil {
+			return err
+		}
+		if err := checkNotLeft(tx, a.EmployeeID, a.ManagerID); err != nil {
+			return err
+		}
+		err = tx.Model(&Employee{}).Where("id = ?", a.EmployeeID).Updates(map[string]interface{}{
+			"department": a.Department,
+			"position":   a.Position,
+			"manager_id": a.ManagerID,
+		}).Error
+		if err != nil {
+			return wrapWriteErr(err, "failed to update the employee")
+		}
+		validFrom := a.ValidFrom.Format("2006-01-02")
+		if started, err = StartAssignment(tx, a, &validFrom); err != nil {
+			return err
+		}
+		after, err := getEmployee(tx, a.EmployeeID)
+		if err != nil {
+			return err
+		}
+		return recordEmployee(tx, g.src, auditStorage.ActionTransfer, before, after)
+	})
+	if err != nil {
+		// the manager foreign key is deferred, so it is checked on commit
+		return nil, wrapWriteErr(err, "failed to transfer the employee")
+	}
+	return started, nil
+}
+
+func (g *gormDB) ListAssignments(ctx context.Context, employeeID int) ([]*Assignment, error) {
+	db := g.db.WithContext(ctx)
+	var assignments []*Assignment
+	req := db.Where("employee_id = ?", employeeID).Order("valid_from, id").Find(&assignments)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query the assignments: %w", err)
+	}
+	if len(assignments) == 0 {
+		if _, err := getEmployee(db, employeeID); err != nil {
+			return nil, err
+		}
+	}
+	return assignments, nil
+}
+
+func (g *gormDB) ListDepartmentAssignments(ctx context.Context, department int, on time.Time) ([]*Assignment, error) {
+	date := on.Format("2006-01-02")
+	var assignments []*Assignment
+	req := g.db.WithContext(ctx).
+		Where("department = ?", department).
+		Where("valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", date, date).
+		Order("employee_id").
+		Find(&assignments)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query the department assignments: %w", err)
+	}
+	return assignments, nil
+}
+
+func (g *gormDB) TerminateEmployee(ctx context.Context, t *Termination) (*Termination, error) {
+	var terminated []*Termination
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		e, err := lockEmployee(tx, t.EmployeeID)
+		if err != nil {
+			return err
+		}
+		if e.TerminatedOn != nil {
+			return fmt.Errorf("%w: employee %d is already terminated", ErrConflict, t.EmployeeID)
+		}
+		before, err := getEmployee(tx, t.EmployeeID)
+		if err != nil {
+			return err
+		}
+		lastDay := t.LastDay.Format("2006-01-02")
+		if err := reassignReports(tx, g.src, e, t); err != nil {
+			return err
+		}
+		req := tx.Exec(
+			`UPDATE employee_assignments SET valid_to = ?::date + 1
+			WHERE employee_id = ? AND valid_to IS NULL AND valid_from <= ?`,
+			lastDay,
+			t.EmployeeID,
+			lastDay,
+		)
+		if err := req.Error; err != nil {
+			return fmt.Errorf("failed to end the current assignment: %w", err)
+		}
+		if req.RowsAffected == 0 {
+			return fmt.Errorf("%w: the current assignment of employee %d starts after %s", ErrConflict, t.EmployeeID, lastDay)
+		}
+		var pending []*SalaryChange
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("employee_id = ? AND applied_at IS NULL AND cancelled_at IS NULL", t.EmployeeID).
+			Where("effective_date > ?", lastDay).
+			Order("id").
+			Find(&pending).Error
+		if err != nil {
+			return fmt.Errorf("failed to query the scheduled salary changes: %w", err)
+		}
+		if len(pending) != 0 {
+			ids := make([]int, len(pending))
+			for i, c := range pending {
+				ids[i] = c.ID
+			}
+			var cancelled []*SalaryChange
+			err = tx.Raw(
+				`UPDATE salary_history SET cancelled_at = NOW() WHERE id IN ? RETURNING *`,
+				ids,
+			).Scan(&cancelled).Error
+			if err != nil {
+				return fmt.Errorf("failed to cancel the scheduled salary changes: %w", err)
+			}
+			if err := recordSalaryChanges(tx, g.src, auditStorage.ActionCancel, pending, cancelled); err != nil {
+				return err
+			}
+		}
+		err = tx.Model(&Employee{}).Where("id = ?", t.EmployeeID).Update("terminated_on", lastDay).Error
+		if err != nil {
+			return fmt.Errorf("failed to terminate the employee: %w", err)
+		}
+		after, err := getEmployee(tx, t.EmployeeID)
+		if err != nil {
+			return err
+		}
+		if err := recordEmployee(tx, g.src, auditStorage.ActionTerminate, before, after); err != nil {
+			return err
+		}
+		return tx.Raw(
+			`INSERT INTO employee_terminations (employee_id, last_day, reason, reports_manager_id, terminated_by)
+			VALUES (?, ?, ?, ?, ?)
+			RETURNING *`,
+			t.EmployeeID,
+			lastDay,
+			t.Reason,
+			t.ReportsManagerID,
+			t.TerminatedBy,
+		).Scan(&terminated).Error
+	})
+	if err != nil {
+		// the manager foreign key is deferred, so it is checked on commit
+		return nil, wrapWriteErr(err, "failed to terminate the employee")
+	}
+	if len(terminated) == 0 {
+		return nil, fmt.Errorf("failed to terminate the employee: no row returned")
+	}
+	return terminated[0], nil
+}
+
+func (g *gormDB) RehireEmployee(ctx context.Context, a *Assignment) (*Employee, error) {
+	var rehired *Employee
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		e, err := lockEmployee(tx, a.EmployeeID)
+		if err != nil {
+			return err
+		}
+		before, err := getEmployee(tx, a.EmployeeID)
+		if err != nil {
+			return err
+		}
+		validFrom := a.ValidFrom.Format("2006-01-02")
+		if e.TerminatedOn == nil {
+			return fmt.Errorf("%w: employee %d is not terminated", ErrConflict, a.EmployeeID)
+		}
+		if !a.ValidFrom.After(*e.TerminatedOn) {
+			return fmt.Errorf(
+				"%w: employee %d cannot be rehired on %s, the last day was %s",
+				ErrConflict, a.EmployeeID, validFrom, e.TerminatedOn.Format("2006-01-02"),
+			)
+		}
+		if a.ManagerID != a.EmployeeID {
+			if err := checkNotLeft(tx, a.ManagerID); err != nil {
+				return err
+			}
+		}
+		err = tx.Model(&Employee{}).Where("id = ?", a.EmployeeID).Updates(map[string]interface{}{
+			"department":    a.Department,
+			"position":      a.Position,
+			"manager_id":    a.ManagerID,
+			"entry_at":      validFrom,
+			"terminated_on": nil,
+		}).Error
+		if err != nil {
+			return wrapWriteErr(err, "failed to reactivate the employee")
+		}
+		err = tx.Model(&Termination{}).
+			Where("employee_id = ? AND rehired_on IS NULL", a.EmployeeID).
+			Updates(map[string]interface{}{
+				"rehired_on": validFrom,
+				"rehired_by": a.ChangedBy,
+			}).Error
+		if err != nil {
+			return fmt.Errorf("failed to close the termination: %w", err)
+		}
+		if _, err := StartAssignment(tx, a, &validFrom); err != nil {
+			return err
+		}
+		if rehired, err = getEmployee(tx, a.EmployeeID); err != nil {
+			return err
+		}
+		return recordEmployee(tx, g.src, auditStorage.ActionRehire, before, rehired)
+	})
+	if err != nil {
+		// the manager foreign key is deferred, so it is checked on commit
+		return nil, wrapWriteErr(err, "failed to rehire the employee")
+	}
+	return rehired, nil
+}
+
+func (g *gormDB) ListTerminations(ctx context.Context, employeeID int) ([]*Termination, error) {
+	db := g.db.WithContext(ctx)
+	var terminations []*Termination
+	req := db.Where("employee_id = ?", employeeID).Order("last_day, id").Find(&terminations)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query the terminations: %w", err)
+	}
+	if len(terminations) == 0 {
+		if _, err := getEmployee(db, employeeID); err != nil {
+			return nil, err
+		}
+	}
+	return terminations, nil
+}
+
+func (g *gormDB) SetContactVisibility(ctx context.Context, id int, phone *string, email *string) (*Employee, error) {
+	var updated *Employee
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockEmployee(tx, id); err != nil {
+			return err
+		}
+		before, err := getEmployee(tx, id)
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&Employee{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"phone_visibility": phone,
+			"email_visibility": email,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to set the contact visibility: %w", err)
+		}
+		if updated, err = getEmployee(tx, id); err != nil {
+			return err
+		}
+		return recordEmployee(tx, g.src, auditStorage.ActionUpdate, before, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (g *gormDB) GetViewer(ctx context.Context, employeeID int) (*visibility.Viewer, error) {
+	return visibility.LoadViewer(g.db.WithContext(ctx), employeeID)
+}
+
+func (g *gormDB) Close() {
+	database.CloseGorm(g.db)
+}
+
+func getEmployee(db *gorm.DB, id int) (*Employee, error) {
+	e := &Employee{}
+	req := db.Model(&Employee{}).Select(employeeColumns).Joins(employeeSalaries).Where("employees.id = ?", id).Take(e)
+	if err := req.Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: employee %d", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to query the employee: %w", err)
+	}
+	if err := loadContacts(db, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// loadContacts sets the phones and the emails of the employees, the first
+// primary ones become their Phone and Email.
+func loadContacts(db *gorm.DB, employees ...*Employee) error {
+	byID := make(map[int]*Employee, len(employees))
+	ids := make([]int, len(employees))
+	for i, e := range employees {
+		e.Phones, e.Emails = make([]*Phone, 0), make([]*Email, 0)
+		byID[e.ID] = e
+		ids[i] = e.ID
+	}
+	k, err := encryption.Current()
+	if err != nil {
+		return err
+	}
+	var phones []*Phone
+	if err := db.Where("employee_id IN ?", ids).Order("employee_id, ordinal").Find(&phones).Error; err != nil {
+		return fmt.Errorf("failed to query the phones: %w", err)
+	}
+	for _, p := range phones {
+		if p.Number, err = k.Open(p.Number); err != nil {
+			return fmt.Errorf("failed to open the phone of employee %d: %w", p.EmployeeID, err)
+		}
+		e := byID[p.EmployeeID]
+		e.Phones = append(e.Phones, p)
+		if p.Primary {
+			e.Phone = p.Number
+		}
+	}
+	var emails []*Email
+	if err := db.Where("employee_id IN ?", ids).Order("employee_id, ordinal").Find(&emails).Error; err != nil {
+		return fmt.Errorf("failed to query the emails: %w", err)
+	}
+	for _, m := range emails {
+		if m.Address, err = k.Open(m.Address); err != nil {
+			return fmt.Errorf("failed to open the email of employee %d: %w", m.EmployeeID, err)
+		}
+		e := byID[m.EmployeeID]
+		e.Emails = append(e.Emails, m)
+		if m.Primary {
+			e.Email = m.Address
+		}
+	}
+	return nil
+}
+
+// saveContacts replaces the phones and the emails of the employee, their
+// ordinals follow the order of the slices. A nil slice keeps the stored
+// contacts, an empty one deletes them. The contacts are stored sealed, with
+// their blind indexes.
+func saveContacts(tx *gorm.DB, employeeID int, phones []*Phone, emails []*Email) error {
+	k, err := encryption.Current()
+	if err != nil {
+		return err
+	}
+	if phones != nil {
+		if err := tx.Where("employee_id = ?", employeeID).Delete(&Phone{}).Error; err != nil {
+			return fmt.Errorf("failed to delete the old phones: %w", err)
+		}
+	}
+	if emails != nil {
+		if err := tx.Where("employee_id = ?", employeeID).Delete(&Email{}).Error; err != nil {
+			return fmt.Errorf("failed to delete the old emails: %w", err)
+		}
+	}
+	if len(phones) != 0 {
+		rows := make([]*phoneRow, len(phones))
+		for i, p := range phones {
+			row := &phoneRow{Phone: *p, NumberIndex: k.Index(p.Number)}
+			row.EmployeeID, row.Ordinal = employeeID, i
+			if row.Number, err = k.Seal(p.Number); err != nil {
+				return fmt.Errorf("failed to seal the phones: %w", err)
+			}
+			rows[i] = row
+		}
+		if err := tx.Create(rows).Error; err != nil {
+			return wrapWriteErr(err, "failed to insert the phones")
+		}
+	}
+	if len(emails) != 0 {
+		rows := make([]*emailRow, len(emails))
+		for i, m := range emails {
+			row := &emailRow{
+				Email:           *m,
+				AddressIndex:    k.Index(m.Address),
+				AddressPrefixes: k.PrefixIndexes(m.Address),
+			}
+			row.EmployeeID, row.Ordinal = employeeID, i
+			if row.Address, err = k.Seal(m.Address); err != nil {
+				return fmt.Errorf("failed to seal the emails: %w", err)
+			}
+			rows[i] = row
+		}
+		if err := tx.Create(rows).Error; err != nil {
+			return wrapWriteErr(err, "failed to insert the emails")
+		}
+	}
+	return nil
+}
+
+// LoadEmployee returns the employee as seen in the tx transaction, so that the
+// other packages changing the employees can record the changes in the audit
+// log.
+func LoadEmployee(tx *gorm.DB, id int) (*Employee, error) {
+	return getEmployee(tx, id)
+}
+
+func insertSalaryBandOverride(tx *gorm.DB, employeeID int, override *SalaryBandOverride) error {
+	if override == nil {
+		return nil
+	}
+	row := *override
+	row.EmployeeID = employeeID
+	if err := tx.Table("salary_band_overrides").Create(&row).Error; err != nil {
+		return wrapWriteErr(err, "failed to record the salary band override")
+	}
+	return nil
+}
+
+// lockEmployee locks the employee's row for the rest of the transaction and
+// returns the employee's salary, assignment and last working day.
+func lockEmployee(tx *gorm.DB, id int) (*Employee, error) {
+	e := &Employee{}
+	req := tx.Model(&Employee{}).
+		Select("employee_salaries.salary_amount, employee_salaries.salary_currency, department, position, manager_id, terminated_on").
+		Joins(employeeSalaries).
+		Clauses(lockEmployees).
+		Where("employees.id = ?", id).
+		Take(e)
+	if err := req.Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: employee %d", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to lock the employee: %w", err)
+	}
+	return e, nil
+}
+
+// checkNotLeft returns ErrConflict if any of the employees has worked their
+// last day.
+func checkNotLeft(tx *gorm.DB, ids ...int) error {
+	var left []int
+	req := tx.Model(&Employee{}).
+		Where("id IN ? AND terminated_on < CURRENT_DATE", ids).
+		Order("id").
+		Pluck("id", &left)
+	if err := req.Error; err != nil {
+		return fmt.Errorf("failed to query the terminated employees: %w", err)
+	}
+	if len(left) != 0 {
+		return fmt.Errorf("%w: employees %v have left", ErrConflict, left)
+	}
+	return nil
+}
+
+// reassignReports moves the direct reports of the terminated employee e to
+// t.ReportsManagerID from today. The report who becomes the new manager is
+// moved under e's manager, or manages themselves if e did.
+func reassignReports(tx *gorm.DB, src *auditStorage.Source, e *Employee, t *Termination) error {
+	var reports []*Employee
+	req := tx.Model(&Employee{}).
+		Select(employeeColumns).
+		Joins(employeeSalaries).
+		Clauses(lockEmployees).
+		Where("manager_id = ? AND employees.id <> ?", t.EmployeeID, t.EmployeeID).
+		Where("terminated_on IS NULL OR terminated_on >= CURRENT_DATE").
+		Order("employees.id").
+		Find(&reports)
+	if err := req.Error; err != nil {
+		return fmt.Errorf("failed to query the direct reports: %w", err)
+	}
+	if len(reports) == 0 {
+		return nil
+	}
+	if err := loadContacts(tx, reports...); err != nil {
+		return err
+	}
+	if t.ReportsManagerID == nil {
+		return fmt.Errorf("%w: employee %d has %d direct reports and no new manager is given", ErrConflict, t.EmployeeID, len(reports))
+	}
+	newManager := *t.ReportsManagerID
+	if err := checkNotLeft(tx, newManager); err != nil {
+		return err
+	}
+	for _, r := range reports {
+		managerID := newManager
+		if r.ID == newManager {
+			managerID = e.ManagerID
+			if e.ManagerID == t.EmployeeID {
+				managerID = r.ID
+			}
+		}
+		err := tx.Model(&Employee{}).Where("id = ?", r.ID).Update("manager_id", managerID).Error
+		if err != nil {
+			return wrapWriteErr(err, "failed to reassign the direct report")
+		}
+		a := &Assignment{
+			EmployeeID: r.ID,
+			Department: r.Department,
+			Position:   r.Position,
+			ManagerID:  managerID,
+			Reason:     fmt.Sprintf("manager %d terminated", t.EmployeeID),
+			ChangedBy:  t.TerminatedBy,
+		}
+		if _, err := StartAssignment(tx, a, nil); err != nil {
+			return err
+		}
+		after, err := getEmployee(tx, r.ID)
+		if err != nil {
+			return err
+		}
+		if err := recordEmployee(tx, src, auditStorage.ActionReassign, r, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignmentOf returns the employee's current assignment described by the
+// reason and the author of the change.
+func assignmentOf(e *Employee, c *SalaryChange) *Assignment {
+	a := &Assignment{
+		EmployeeID: e.ID,
+		Department: e.Department,
+		Position:   e.Position,
+		ManagerID:  e.ManagerID,
+	}
+	if c != nil {
+		a.Reason, a.ChangedBy = c.Reason, c.ChangedBy
+	}
+	return a
+}
+
+// StartAssignment ends the employee's current assignment and starts a on the
+// date, today if the date is nil. It runs in the tx transaction, in which the
+// employee's row must be locked, so that the changes of the other packages
+// touching the assignments are recorded in the same transaction.
+func StartAssignment(tx *gorm.DB, a *Assignment, validFrom *string) (*Assignment, error) {
+	var later int64
+	req := tx.Model(&Assignment{}).
+		Where("employee_id = ? AND valid_to IS NULL", a.EmployeeID).
+		Where("valid_from > COALESCE(?::date, CURRENT_DATE)", validFrom).
+		Count(&later)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query the current assignment: %w", err)
+	}
+	if later != 0 {
+		return nil, fmt.Errorf("%w: the current assignment of employee %d starts later", ErrConflict, a.EmployeeID)
+	}
+	err := tx.Exec(
+		`DELETE FROM employee_assignments
+		WHERE employee_id = ? AND valid_to IS NULL AND valid_from = COALESCE(?::date, CURRENT_DATE)`,
+		a.EmployeeID,
+		validFrom,
+	).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to replace the current assignment: %w", err)
+	}
+	err = tx.Exec(
+		`UPDATE employee_assignments SET valid_to = COALESCE(?::date, CURRENT_DATE)
+		WHERE employee_id = ? AND valid_to IS NULL`,
+		validFrom,
+		a.EmployeeID,
+	).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to end the current assignment: %w", err)
+	}
+	var started []*Assignment
+	err = tx.Raw(
+		`INSERT INTO employee_assignments
+			(employee_id, department, position, manager_id, valid_from, reason, changed_by)
+		VALUES (?, ?, ?, ?, COALESCE(?::date, CURRENT_DATE), ?, ?)
+		RETURNING *`,
+		a.EmployeeID,
+		a.Department,
+		a.Position,
+		a.ManagerID,
+		validFrom,
+		a.Reason,
+		a.ChangedBy,
+	).Scan(&started).Error
+	if err != nil {
+		return nil, wrapWriteErr(err, "failed to start the assignment")
+	}
+	if len(started) == 0 {
+		return nil, fmt.Errorf("failed to start the assignment: no row returned")
+	}
+	return started[0], nil
+}
+
+// insertAppliedSalaryChange records the employee's current salary as a change
+// effective on the date, today if the date is nil.
+func insertAppliedSalaryChange(tx *gorm.DB, e *Employee, effectiveDate *string, c *SalaryChange) error {
+	reason, changedBy := "", ""
+	if c != nil {
+		reason, changedBy = c.Reason, c.ChangedBy
+	}
+	err := tx.Exec(
+		`INSERT INTO salary_history
+			(employee_id, salary_amount, salary_currency, effective_date, reason, changed_by, applied_at)
+		VALUES (?, ?, ?, COALESCE(?::date, CURRENT_DATE), ?, ?, NOW())`,
+		e.ID,
+		e.Salary.Amount,
+		e.Salary.Currency,
+		effectiveDate,
+		reason,
+		changedBy,
+	).Error
+	if err != nil {
+		return wrapWriteErr(err, "failed to record the salary change")
+	}
+	return nil
+}
+
+// RecordEmployee records the change of the employee in the audit log in the
+// tx transaction, so that the other packages changing the employees record
+// them the same way, see recordEmployee.
+func RecordEmployee(tx *gorm.DB, src *auditStorage.Source, action string, before *Employee, after *Employee) error {
+	return recordEmployee(tx, src, action, before, after)
+}
+
+// recordEmployee records the change of the employee in the audit log, before
+// is nil for a created employee. The contacts are not recorded, see
+// auditedEmployee.
+func recordEmployee(tx *gorm.DB, src *auditStorage.Source, action string, before *Employee, after *Employee) error {
+	auditedBefore, err := auditedEmployee(before)
+	if err != nil {
+		return err
+	}
+	auditedAfter, err := auditedEmployee(after)
+	if err != nil {
+		return err
+	}
+	return auditStorage.Record(tx, src, &auditStorage.Change{
+		Action:     action,
+		EntityType: auditStorage.EntityEmployee,
+		EntityID:   after.ID,
+		Before:     auditedBefore,
+		After:      auditedAfter,
+	})
+}
+
+// auditedEmployee returns the employee as recorded in the audit log. The
+// phones and the emails are replaced by their blind index, so that the log
+// tells when they change without keeping them in plaintext.
+func auditedEmployee(e *Employee) (interface{}, error) {
+	if e == nil {
+		return nil, nil
+	}
+	k, err := encryption.Current()
+	if err != nil {
+		return nil, err
+	}
+	contacts, err := json.Marshal(struct {
+		Phones []*Phone `json:"phones"`
+		Emails []*Email `json:"emails"`
+	}{e.Phones, e.Emails})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize the contacts of employee %d: %w", e.ID, err)
+	}
+	type employee Employee
+	return struct {
+		employee
+		Phone    *string  `json:"phone,omitempty"`
+		Email    *string  `json:"email,omitempty"`
+		Phones   []*Phone `json:"phones,omitempty"`
+		Emails   []*Email `json:"emails,omitempty"`
+		Contacts string   `json:"contacts"`
+	}{
+		employee: employee(*e),
+		Contacts: k.Index(string(contacts)),
+	}, nil
+}
+
+// recordSalaryChanges records the changes of the salary history entries in
+// the audit log, the entries before the change are matched by ID.
+func recordSalaryChanges(tx *gorm.DB, src *auditStorage.Source, action string, before []*SalaryChange, after []*SalaryChange) error {
+	byID := make(map[int]*SalaryChange, len(before))
+	for _, c := range before {
+		byID[c.ID] = c
+	}
+	for _, c := range after {
+		err := auditStorage.Record(tx, src, &auditStorage.Change{
+			Action:     action,
+			EntityType: auditStorage.EntitySalaryChange,
+			EntityID:   c.ID,
+			Before:     byID[c.ID],
+			After:      c,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func wrapWriteErr(err error, msg string) error {
+	if database.IsUniqueViolation(err) || database.IsForeignKeyViolation(err) {
+		return fmt.Errorf("%w: %s: %v", ErrConflict, msg, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/encryption"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	positionsStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/positions/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
+)
+
+type ContextKey int
+
+const ContextKeyDB ContextKey = iota + 1
+
+var (
+	ErrNotFound = fmt.Errorf("not found")
+	ErrConflict = fmt.Errorf("conflicts with the existing data")
+)
+
+type Employee struct {
+	ID         int         `gorm:"column:id" json:"id"`
+	FirstName  string      `gorm:"column:first_name" json:"first_name"`
+	LastName   string      `gorm:"column:last_name" json:"last_name"`
+	Salary     money.Money `gorm:"embedded;embeddedPrefix:salary_" json:"salary"`
+	ManagerID  int         `gorm:"column:manager_id" json:"manager_id"`
+	Department int         `gorm:"column:department" json:"department"`
+	Position   int         `gorm:"column:position" json:"position"`
+	EntryAt    time.Time   `gorm:"column:entry_at" json:"entry_at"`
+	// Phone and Email are the primary contacts of the employee. On a write
+	// they are used when no Phones or Emails are given.
+	Phone  string   `gorm:"-" json:"phone"`
+	Email  string   `gorm:"-" json:"email"`
+	Phones []*Phone `gorm:"-" json:"phones"`
+	Emails []*Email `gorm:"-" json:"emails"`
+	// TerminatedOn is the last working day of a terminated employee.
+	TerminatedOn *time.Time `gorm:"column:terminated_on" json:"terminated_on"`
+	// PhoneVisibility and EmailVisibility tell who sees the contacts, nil
+	// stands for the company-wide default.
+	PhoneVisibility *string `gorm:"column:phone_visibility" json:"phone_visibility"`
+	EmailVisibility *string `gorm:"column:email_visibility" json:"email_visibility"`
+	// SalaryHidden replaces the salary with null in the JSON for the callers
+	// who may not see it.
+	SalaryHidden bool `gorm:"-" json:"-"`
+}
+
+func (e Employee) MarshalJSON() ([]byte, error) {
+	type employee Employee
+	if !e.SalaryHidden {
+		return json.Marshal(employee(e))
+	}
+	return json.Marshal(struct {
+		employee
+		Salary *money.Money `json:"salary"`
+	}{
+		employee: employee(e),
+	})
+}
+
+// The types of the phone numbers.
+const (
+	PhoneTypeWork      = "work"
+	PhoneTypeMobile    = "mobile"
+	PhoneTypeExtension = "extension"
+)
+
+// The types of the email addresses, an alias is an additional address of the
+// employee.
+const (
+	EmailTypeWork  = "work"
+	EmailTypeAlias = "alias"
+)
+
+// Phone is a phone number of an employee. The phones are listed by Ordinal,
+// at most one of them is primary. The numbers are stored sealed.
+type Phone struct {
+	EmployeeID int    `gorm:"column:employee_id" json:"-"`
+	Type       string `gorm:"column:type" json:"type"`
+	Number     string `gorm:"column:number" json:"number"`
+	Primary    bool   `gorm:"column:is_primary" json:"primary"`
+	Ordinal    int    `gorm:"column:ordinal" json:"-"`
+}
+
+func (Phone) TableName() string {
+	return "employee_phones"
+}
+
+// Email is an email address of an employee. The emails are listed by
+// Ordinal, at most one of them is primary. The addresses are stored sealed.
+type Email struct {
+	EmployeeID int    `gorm:"column:employee_id" json:"-"`
+	Type       string `gorm:"column:type" json:"type"`
+	Address    string `gorm:"column:address" json:"address"`
+	Primary    bool   `gorm:"column:is_primary" json:"primary"`
+	Ordinal    int    `gorm:"column:ordinal" json:"-"`
+}
+
+func (Email) TableName() string {
+	return "employee_emails"
+}
+
+// phoneRow is a phone as stored, with the blind index of the sealed number.
+type phoneRow struct {
+	Phone
+	NumberIndex string `gorm:"column:number_index"`
+}
+
+// emailRow is an email as stored, with the blind indexes of the sealed
+// address and of its prefixes.
+type emailRow struct {
+	Email
+	AddressIndex    string             `gorm:"column:address_index"`
+	AddressPrefixes encryption.Indexes `gorm:"column:address_prefixes"`
+}
+
+// SalaryBandOverride records why an employee is paid outside of the salary
+// band of their position.
+type SalaryBandOverride struct {
+	EmployeeID int          `gorm:"column:employee_id"`
+	PositionID int          `gorm:"column:position_id"`
+	Salary     money.Amount `gorm:"column:salary"`
+	Currency   string       `gorm:"column:currency"`
+	Reason     string       `gorm:"column:reason"`
+}
+
+// SalaryChange is an entry of an employee's compensation timeline. A change
+// with a future effective date is scheduled: it is applied to the employee's
+// salary once the date comes and may be cancelled until then.
+type SalaryChange struct {
+	ID            int         `gorm:"column:id" json:"id"`
+	EmployeeID    int         `gorm:"column:employee_id" json:"employee_id"`
+	Salary        money.Money `gorm:"embedded;embeddedPrefix:salary_" json:"salary"`
+	EffectiveDate time.Time   `gorm:"column:effective_date" json:"effective_date"`
+	Reason        string      `gorm:"column:reason" json:"reason"`
+	ChangedBy     string      `gorm:"column:changed_by" json:"changed_by"`
+	CreatedAt     time.Time   `gorm:"column:created_at" json:"created_at"`
+	AppliedAt     *time.Time  `gorm:"column:applied_at" json:"applied_at"`
+	CancelledAt   *time.Time  `gorm:"column:cancelled_at" json:"cancelled_at"`
+}
+
+func (SalaryChange) TableName() string {
+	return "salary_history"
+}
+
+// Assignment is a period during which an employee worked in a department, in
+// a position and under a manager. It is valid from ValidFrom until ValidTo
+// exclusive, the current assignment has no ValidTo.
+type Assignment struct {
+	ID         int        `gorm:"column:id" json:"id"`
+	EmployeeID int        `gorm:"column:employee_id" json:"employee_id"`
+	Department int        `gorm:"column:department" json:"department"`
+	Position   int        `gorm:"column:position" json:"position"`
+	ManagerID  int        `gorm:"column:manager_id" json:"manager_id"`
+	ValidFrom  time.Time  `gorm:"column:valid_from" json:"valid_from"`
+	ValidTo    *time.Time `gorm:"column:valid_to" json:"valid_to"`
+	Reason     string     `gorm:"column:reason" json:"reason"`
+	ChangedBy  string     `gorm:"column:changed_by" json:"changed_by"`
+	CreatedAt  time.Time  `gorm:"column:created_at" json:"created_at"`
+}
+
+func (Assignment) TableName() string {
+	return "employee_assignments"
+}
+
+// Termination ends an employee's employment after LastDay. The employee's
+// direct reports are reassigned to ReportsManagerID. A rehire closes the
+// termination.
+type Termination struct {
+	ID               int        `gorm:"column:id" json:"id"`
+	EmployeeID       int        `gorm:"column:employee_id" json:"employee_id"`
+	LastDay          time.Time  `gorm:"column:last_day" json:"last_day"`
+	Reason           string     `gorm:"column:reason" json:"reason"`
+	ReportsManagerID *int       `gorm:"column:reports_manager_id" json:"reports_manager_id"`
+	TerminatedBy     string     `gorm:"column:terminated_by" json:"terminated_by"`
+	CreatedAt        time.Time  `gorm:"column:created_at" json:"created_at"`
+	RehiredOn        *time.Time `gorm:"column:rehired_on" json:"rehired_on"`
+	RehiredBy        *string    `gorm:"column:rehired_by" json:"rehired_by"`
+}
+
+func (Termination) TableName() string {
+	return "employee_terminations"
+}
+
+type DB interface {
+	GetEmployee(ctx context.Context, id int) (*Employee, error)
+	// CreateEmployee saves the employee's phones and emails in the given
+	// order. It records the employee's salary as an applied change
+	// described by the reason and the author of salaryChange.
+	CreateEmployee(ctx context.Context, e *Employee, override *SalaryBandOverride, salaryChange *SalaryChange) (*Employee, error)
+	// UpdateEmployee replaces the employee's phones and emails, nil Phones or
+	// Emails keep the stored ones. It records an applied change described by
+	// the reason and the author of salaryChange if the employee's salary
+	// changes. A change of the
+	// department, the position or the manager starts a new assignment
+	// effective today. ErrConflict is returned if the employee or the manager
+	// has left.
+	UpdateEmployee(ctx context.Context, e *Employee, override *SalaryBandOverride, salaryChange *SalaryChange) (*Employee, error)
+	// ListSalaryChanges returns the employee's compensation timeline ordered
+	// by the effective date.
+	ListSalaryChanges(ctx context.Context, employeeID int) ([]*SalaryChange, error)
+	ScheduleSalaryChange(ctx context.Context, c *SalaryChange, override *SalaryBandOverride) (*SalaryChange, error)
+	// CancelSalaryChange returns ErrNotFound if the employee has no pending
+	// change with the ID.
+	CancelSalaryChange(ctx context.Context, employeeID int, changeID int) error
+	// ApplyDueSalaryChanges applies the pending changes effective on the date
+	// or earlier and returns them.
+	ApplyDueSalaryChanges(ctx context.Context, on time.Time) ([]*SalaryChange, error)
+	// ApplySalaryChange applies the employee's pending change with the ID
+	// effective on the date or earlier together with the employee's pending
+	// changes preceding it and returns the change. ErrNotFound is returned if
+	// the employee has no such change.
+	ApplySalaryChange(ctx context.Context, employeeID int, changeID int, on time.Time) (*SalaryChange, error)
+	// Transfer ends the employee's current assignment on a.ValidFrom and
+	// starts a. The employee's department, position and manager are updated
+	// accordingly. A current assignment starting on the same date is replaced,
+	// ErrConflict is returned if it starts later.
+	Transfer(ctx context.Context, a *Assignment) (*Assignment, error)
+	// ListAssignments returns the employee's assignments ordered by date.
+	ListAssignments(ctx context.Context, employeeID int) ([]*Assignment, error)
+	// ListDepartmentAssignments returns the assignments of the department
+	// valid on the date.
+	ListDepartmentAssignments(ctx context.Context, department int, on time.Time) ([]*Assignment, error)
+	// TerminateEmployee ends the employee's current assignment after
+	// t.LastDay and cancels the salary changes scheduled after it. The direct
+	// reports are moved to t.ReportsManagerID from today, ErrConflict is
+	// returned if the employee has reports and no manager is given. A report
+	// that becomes their new manager takes over the employee's own manager.
+	TerminateEmployee(ctx context.Context, t *Termination) (*Termination, error)
+	// RehireEmployee reactivates the terminated employee from a.ValidFrom,
+	// which must be after the last day, and starts a.
+	RehireEmployee(ctx context.Context, a *Assignment) (*Employee, error)
+	// ListTerminations returns the employee's terminations ordered by date.
+	ListTerminations(ctx context.Context, employeeID int) ([]*Termination, error)
+	// SetContactVisibility sets who sees the employee's phone and email, nil
+	// resetting the visibility to the company-wide default.
+	SetContactVisibility(ctx context.Context, id int, phone *string, email *string) (*Employee, error)
+	// GetViewer returns the employee as a viewer of the contacts of the other
+	// employees.
+	GetViewer(ctx context.Context, employeeID int) (*visibility.Viewer, error)
+	// GetSalaryBand returns nil if the position has no band in the currency.
+	GetSalaryBand(ctx context.Context, positionID int, currency string) (*positionsStorage.SalaryBand, error)
+	Close()
+}
+
+// NewDB opens the storage, the changes made through it are recorded in the
+// audit log as made by src.
+func NewDB(connStr *database.ConnString, src *auditStorage.Source) (DB, error) {
+	gormDB, err := newGormDB(connStr, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a gorm connection: %w", err)
+	}
+	return gormDB, nil
+}
@@ -0,0 +1,536 @@
+//go:build integration_tests
+// +build integration_tests
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/encryption"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"gorm.io/gorm"
+)
+
+const (
+	DB_HOST     = "127.0.0.1"
+	DB_USER     = "gopher"
+	DB_PASSWORD = "P@ssw0rd"
+	DB_NAME     = "gopher_corp"
+)
+
+var DB_PORT = ""
+
+func TestMain(m *testing.M) {
+	os.Exit(testMain(m))
+}
+
+func testMain(m *testing.M) int {
+	setupResult, err := setup()
+	if err != nil {
+		log.Println("setup err: ", err)
+		return -1
+	}
+	defer teardown(setupResult)
+	return m.Run()
+}
+
+type setupResult struct {
+	Pool              *dockertest.Pool
+	PostgresContainer *dockertest.Resource
+}
+
+const dockerMaxWait = time.Second * 5
+
+func setup() (r *setupResult, err error) {
+	testFileDir, err := getTestFileDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the script dir: %w", err)
+	}
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a new docketest pool: %w", err)
+	}
+	pool.MaxWait = dockerMaxWait
+
+	postgresContainer, err := runPostgresContainer(pool, testFileDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run the Postgres container: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if err := pool.Purge(postgresContainer); err != nil {
+				log.Println("failed to purge the postgres container: %w", err)
+			}
+		}
+	}()
+
+	migrationContainer, err := runMigrationContainer(pool, testFileDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run the migration container: %w", err)
+	}
+
+	defer func() {
+		if err := pool.Purge(migrationContainer); err != nil {
+			err = fmt.Errorf("failed to purge the migration container: %w", err)
+		}
+	}()
+
+	keyring, err := encryption.ParseKeyring([]byte(testKeyring))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the test keyring: %w", err)
+	}
+	encryption.SetKeyring(keyring)
+
+	if err := pool.Retry(func() error {
+		err := prepopulateDB(testFileDir)
+		if err != nil {
+			log.Printf("populate DB err: %v", err)
+		}
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to prepopulate the DB: %w", err)
+	}
+	if err := sealContacts(); err != nil {
+		return nil, fmt.Errorf("failed to seal the prepopulated contacts: %w", err)
+	}
+
+	return &setupResult{
+		Pool:              pool,
+		PostgresContainer: postgresContainer,
+	}, nil
+}
+
+func getTestFileDir() (string, error) {
+	_, fileName, _, ok := runtime.Caller(0)
+	if !ok {
+		return "", fmt.Errorf("failed to get the caller info")
+	}
+	fileDir := filepath.Dir(fileName)
+	dir, err := filepath.Abs(fileDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the absolute path to the directory %s: %w", dir, err)
+	}
+	return fileDir, nil
+}
+
+func runPostgresContainer(pool *dockertest.Pool, testFileDir string) (*dockertest.Resource, error) {
+	postgresContainer, err := pool.RunWithOptions(
+		&dockertest.RunOptions{
+			Repository: "postgres",
+			Tag:        "14.0",
+			Env: []string{
+				"POSTGRES_PASSWORD=P@ssw0rd",
+			},
+		},
+		func(config *docker.HostConfig) {
+			config.AutoRemove = false
+			config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+			config.Mounts = []docker.HostMount{
+				{
+					Target: "/docker-entrypoint-initdb.d",
+					Source: filepath.Join(testFileDir, "init"),
+					Type:   "bind",
+				},
+			}
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the postgres docker container: %w", err)
+	}
+	postgresContainer.Expire(120)
+
+	DB_PORT = postgresContainer.GetPort("5432/tcp")
+
+	// Wait for the DB to start
+	if err := pool.Retry(func() error {
+		db, err := getDBConnector()
+		if err != nil {
+			return fmt.Errorf("failed to get a DB connector: %w", err)
+		}
+		return db.Ping(context.Background())
+	}); err != nil {
+		pool.Purge(postgresContainer)
+		return nil, fmt.Errorf("failed to ping the created DB: %w", err)
+	}
+	return postgresContainer, nil
+}
+
+func runMigrationContainer(pool *dockertest.Pool, testFileDir string) (*dockertest.Resource, error) {
+	migrationsDir, err := filepath.Abs(filepath.Join(testFileDir, "../../../../migrations"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the absolute path of the migrations dir: %w", err)
+	}
+	migrationContainer, err := pool.RunWithOptions(
+		&dockertest.RunOptions{
+			Repository: "migrate/migrate",
+			Tag:        "v4.15.0",
+			Cmd: []string{
+				"-path=/migrations",
+				fmt.Sprintf(
+					"-database=%s",
+					composeConnectionString(),
+				),
+				"up",
+			},
+		},
+		func(config *docker.HostConfig) {
+			config.AutoRemove = false
+			config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+			config.Mounts = []docker.HostMount{
+				{
+					Target: "/migrations",
+					Source: migrationsDir,
+					Type:   "bind",
+				},
+			}
+			config.NetworkMode = "host"
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the migration container: %w", err)
+	}
+
+	return migrationContainer, err
+}
+
+func prepopulateDB(testFileDir string) error {
+	prepopulateScriptPath := filepath.Join(testFileDir, "prepopulate_db.sql")
+	scriptBytes, err := os.ReadFile(prepopulateScriptPath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", prepopulateScriptPath, err)
+	}
+	conn, err := getDBConnector()
+	if err != nil {
+		return fmt.Errorf("failed to get a DB connector: %w", err)
+	}
+	if _, err := conn.Exec(context.Background(), string(scriptBytes)); err != nil {
+		return fmt.Errorf("failed to execute the prepopulate script: %w", err)
+	}
+	return nil
+}
+
+func teardown(r *setupResult) {
+	if err := r.Pool.Purge(r.PostgresContainer); err != nil {
+		log.Printf("failed to purge the Postgres container: %v", err)
+	}
+}
+func TestEmailAddressesAreUnique(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	keyring, err := encryption.Current()
+	if err != nil {
+		t.Fatalf("failed to get the keyring: %v", err)
+	}
+	var employeeID int
+	err = conn.QueryRow(context.Background(), `SELECT id FROM employees ORDER BY id LIMIT 1`).Scan(&employeeID)
+	if err != nil {
+		t.Fatalf("failed to query an employee: %v", err)
+	}
+	sealed, err := keyring.Seal("aliddell@gopher_corp.com")
+	if err != nil {
+		t.Fatalf("failed to seal the address: %v", err)
+	}
+	_, err = conn.Exec(
+		context.Background(),
+		`INSERT INTO employee_emails (employee_id, type, address, address_index, ordinal) VALUES ($1, 'alias', $2, $3, 100)`,
+		employeeID, sealed, keyring.Index("aliddell@gopher_corp.com"),
+	)
+	if !database.IsUniqueViolation(err) {
+		t.Fatalf("expected a unique violation for the duplicate address, got %v", err)
+	}
+}
+
+func TestContactsAreSealed(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	keyring, err := encryption.Current()
+	if err != nil {
+		t.Fatalf("failed to get the keyring: %v", err)
+	}
+	var number, address string
+	err = conn.QueryRow(
+		context.Background(),
+		`SELECT p.number, m.address
+		FROM employees e
+		JOIN employee_phones p ON p.employee_id = e.id AND p.is_primary
+		JOIN employee_emails m ON m.employee_id = e.id AND m.is_primary
+		WHERE e.first_name = 'Alice' AND e.last_name = 'Liddell'`,
+	).Scan(&number, &address)
+	if err != nil {
+		t.Fatalf("failed to query the stored contacts: %v", err)
+	}
+	if !keyring.IsCurrent(number) || !keyring.IsCurrent(address) {
+		t.Fatalf("expected the contacts to be sealed, got %q and %q", number, address)
+	}
+	if strings.Contains(number, "+79169008070") || strings.Contains(address, "aliddell") {
+		t.Fatalf("expected no plaintext in the stored contacts, got %q and %q", number, address)
+	}
+}
+
+func TestCountUnindexedContacts(t *testing.T) {
+	db, err := database.OpenGorm(getConnectionString())
+	if err != nil {
+		t.Fatalf("failed to open a gorm connection: %v", err)
+	}
+	defer database.CloseGorm(db)
+	n, err := storage.CountUnindexedContacts(context.Background(), db)
+	if err != nil {
+		t.Fatalf("failed to count the unindexed contacts: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected the sealed contacts to be indexed, got %d unindexed", n)
+	}
+	errRollback := errors.New("rollback")
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE employee_phones SET number_index = NULL`).Error
+		if err != nil {
+			return fmt.Errorf("failed to drop the phone indexes: %w", err)
+		}
+		if n, err = storage.CountUnindexedContacts(context.Background(), tx); err != nil {
+			return fmt.Errorf("failed to count the unindexed contacts: %w", err)
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected the 3 phones to be unindexed, got %d", n)
+	}
+}
+
+func TestUpdateEmployeeKeepsOmittedContacts(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	var employeeID int
+	err = conn.QueryRow(
+		context.Background(),
+		`SELECT id FROM employees WHERE first_name = 'Charley' AND last_name = 'Bucket'`,
+	).Scan(&employeeID)
+	if err != nil {
+		t.Fatalf("failed to query the employee: %v", err)
+	}
+	db, err := storage.NewDB(getConnectionString(), auditStorage.System("test"))
+	if err != nil {
+		t.Fatalf("failed to open the employees storage: %v", err)
+	}
+	defer db.Close()
+	e, err := db.GetEmployee(context.Background(), employeeID)
+	if err != nil {
+		t.Fatalf("GetEmployee failed: %v", err)
+	}
+	e.Phones, e.Emails = nil, []*storage.Email{}
+	updated, err := db.UpdateEmployee(context.Background(), e, nil, &storage.SalaryChange{})
+	if err != nil {
+		t.Fatalf("UpdateEmployee failed: %v", err)
+	}
+	if len(updated.Phones) != 1 || updated.Phones[0].Number != "+79159876543" {
+		t.Fatalf("expected the omitted phones to be kept, got %+v", updated.Phones)
+	}
+	if len(updated.Emails) != 0 {
+		t.Fatalf("expected the emails to be deleted, got %+v", updated.Emails)
+	}
+}
+
+func TestEmployeesChangeOnlyTheirContactVisibility(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	var employeeID int
+	err = conn.QueryRow(context.Background(), `SELECT id FROM employees ORDER BY id LIMIT 1`).Scan(&employeeID)
+	if err != nil {
+		t.Fatalf("failed to query an employee: %v", err)
+	}
+	cases := []struct {
+		Update        string
+		ExpectedError bool
+	}{
+		{Update: `UPDATE employees SET phone_visibility = 'hidden', email_visibility = 'managers' WHERE id = $1`},
+		{Update: `UPDATE employees SET salary_amount = 1 WHERE id = $1`, ExpectedError: true},
+		{Update: `UPDATE employees SET department = department + 1 WHERE id = $1`, ExpectedError: true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			tx, err := conn.Begin(context.Background())
+			if err != nil {
+				t.Fatalf("failed to begin a transaction: %v", err)
+			}
+			defer tx.Rollback(context.Background())
+			caller := &auth.Principal{Subject: "aliddell", EmployeeID: employeeID}
+			if err := setCaller(tx, caller); err != nil {
+				t.Fatalf("failed to set the caller: %v", err)
+			}
+			_, err = tx.Exec(context.Background(), tc.Update, employeeID)
+			var pgErr *pgconn.PgError
+			if tc.ExpectedError && (!errors.As(err, &pgErr) || pgErr.Code != "42501") {
+				t.Fatalf("expected the update to be forbidden, got %v", err)
+			}
+			if !tc.ExpectedError && err != nil {
+				t.Fatalf("failed to update: %v", err)
+			}
+		})
+	}
+}
+
+func TestRowLevelSecurityHidesSalariesAndContacts(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	var aliceID, department int
+	err = conn.QueryRow(
+		context.Background(),
+		`SELECT id, department FROM employees WHERE first_name = 'Alice' AND last_name = 'Liddell'`,
+	).Scan(&aliceID, &department)
+	if err != nil {
+		t.Fatalf("failed to query Alice Liddell: %v", err)
+	}
+	alice := &auth.Principal{Subject: "aliddell", EmployeeID: aliceID}
+	hr := &auth.Principal{
+		Subject:         "hr-partner",
+		DepartmentRoles: map[string]map[int]struct{}{policy.RoleHR: {department: {}}},
+	}
+	cases := []struct {
+		Caller        *auth.Principal
+		Query         string
+		ExpectedCount int
+		ExpectedError bool
+	}{
+		{Caller: alice, Query: `SELECT count(salary_amount) FROM employees`, ExpectedError: true},
+		{Caller: alice, Query: `SELECT count(*) FROM employees`, ExpectedCount: 3},
+		{Caller: alice, Query: `SELECT count(*) FROM employee_salaries`, ExpectedCount: 1},
+		{Caller: hr, Query: `SELECT count(*) FROM employee_salaries`, ExpectedCount: 3},
+		{Query: `SELECT count(*) FROM employee_salaries`, ExpectedCount: 3},
+		// Bob Morane hides his phones
+		{Caller: alice, Query: `SELECT count(*) FROM employee_phones`, ExpectedCount: 2},
+		{Caller: hr, Query: `SELECT count(*) FROM employee_phones`, ExpectedCount: 2},
+		{Query: `SELECT count(*) FROM employee_phones`, ExpectedCount: 3},
+		{Caller: alice, Query: `SELECT count(*) FROM employee_emails`, ExpectedCount: 3},
+		{Caller: &auth.Principal{Subject: "anonymous"}, Query: `SELECT count(*) FROM employees`, ExpectedCount: 0},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			tx, err := conn.Begin(context.Background())
+			if err != nil {
+				t.Fatalf("failed to begin a transaction: %v", err)
+			}
+			defer tx.Rollback(context.Background())
+			_, err = tx.Exec(
+				context.Background(),
+				`UPDATE employees SET phone_visibility = 'hidden' WHERE first_name = 'Bob' AND last_name = 'Morane'`,
+			)
+			if err != nil {
+				t.Fatalf("failed to hide the phones of Bob Morane: %v", err)
+			}
+			if tc.Caller != nil {
+				if err := setCaller(tx, tc.Caller); err != nil {
+					t.Fatalf("failed to set the caller: %v", err)
+				}
+			}
+			var count int
+			err = tx.QueryRow(context.Background(), tc.Query).Scan(&count)
+			var pgErr *pgconn.PgError
+			if tc.ExpectedError {
+				if !errors.As(err, &pgErr) || pgErr.Code != "42501" {
+					t.Fatalf("expected the query to be forbidden, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to query: %v", err)
+			}
+			if count != tc.ExpectedCount {
+				t.Errorf("expected %d rows, got %d", tc.ExpectedCount, count)
+			}
+		})
+	}
+}
+
+// setCaller sets the caller on the transaction as the service does on the
+// connections of the requests.
+func setCaller(tx pgx.Tx, p *auth.Principal) error {
+	for name, val := range policy.SessionParams(p) {
+		_, err := tx.Exec(context.Background(), `SELECT set_config($1, $2, true)`, name, val)
+		if err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// testKeyring seals the contacts of the tests.
+const testKeyring = `{
+	"primary": "test",
+	"keys": {"test": "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE="},
+	"index_key": "QUJDREVGR0hJSktMTU5PUFFSU1RVVldYWVpBQkNERUY="
+}`
+
+// sealContacts seals and indexes the contacts inserted in plaintext, as it is
+// done for the contacts stored before the encryption.
+func sealContacts() error {
+	db, err := database.OpenGorm(getConnectionString())
+	if err != nil {
+		return fmt.Errorf("failed to open a gorm connection: %w", err)
+	}
+	defer database.CloseGorm(db)
+	if _, err := storage.ReencryptContacts(context.Background(), db, false); err != nil {
+		return fmt.Errorf("failed to reencrypt the contacts: %w", err)
+	}
+	return nil
+}
+
+func getDBConnector() (*pgxpool.Pool, error) {
+	log.Println(composeConnectionString())
+	cfg, err := pgxpool.ParseConfig(composeConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the PGX pool config from connection string: %w", err)
+	}
+	cfg.ConnConfig.ConnectTimeout = time.Second * 1
+	db, err := pgxpool.ConnectConfig(context.Background(), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the postgres DB using a PGX connection pool: %w", err)
+	}
+	return db, nil
+}
+
+func getConnectionString() *database.ConnString {
+	return &database.ConnString{
+		Host:     DB_HOST,
+		Port:     DB_PORT,
+		User:     DB_USER,
+		Password: DB_PASSWORD,
+		DBName:   DB_NAME,
+	}
+}
+
+func composeConnectionString() string {
+	return fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=disable", DB_USER, url.QueryEscape(DB_PASSWORD), DB_HOST, DB_PORT, DB_NAME)
+}
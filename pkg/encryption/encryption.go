@@ -0,0 +1,330 @@
+// Package encryption seals the personal data stored in the DB. Every value is
+// encrypted with its own random data key and the data key is encrypted, or
+// wrapped, with the primary key of the keyring, so that rotating the keyring
+// rewraps only the data keys. The sealed values are searched by their blind
+// indexes, the keyed hashes of the values and of their prefixes.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+var (
+	ErrIncorrectKeyring = fmt.Errorf("incorrect keyring")
+	ErrIncorrectSealed  = fmt.Errorf("incorrect sealed value")
+	ErrUnknownKey       = fmt.Errorf("unknown key")
+	ErrNoKeyring        = fmt.Errorf("no keyring is set")
+)
+
+// MinPrefixLength is the length of the shortest indexed prefix, the shorter
+// prefixes are not searched.
+const MinPrefixLength = 3
+
+const (
+	// sealedPrefix starts the sealed values, followed by the ID of the key
+	// wrapping the data key, the wrapped data key and the ciphertext, all
+	// separated by colons.
+	sealedPrefix = "enc:v1:"
+	keySize      = 32
+	// indexSize is the size of the blind indexes, the HMACs are truncated.
+	indexSize = 16
+)
+
+var encoding = base64.RawStdEncoding
+
+// Keyring holds the keys wrapping the data keys and the key of the blind
+// indexes. The values are sealed with the primary key, the other ones only
+// open the values sealed before the rotation.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+	index   []byte
+}
+
+// keyfile is the JSON keyring file, the keys are base64-encoded 32 bytes:
+//
+//	{
+//		"primary": "2021-10",
+//		"keys": {"2021-07": "...", "2021-10": "..."},
+//		"index_key": "..."
+//	}
+//
+// The index key is not rotated, the blind indexes are recomputed when it
+// changes.
+type keyfile struct {
+	Primary  string            `json:"primary"`
+	Keys     map[string]string `json:"keys"`
+	IndexKey string            `json:"index_key"`
+}
+
+// LoadKeyring reads the keyring from the keyfile.
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the keyring: %w", err)
+	}
+	return ParseKeyring(data)
+}
+
+// ParseKeyring parses the keyring of the JSON keyfile.
+func ParseKeyring(data []byte) (*Keyring, error) {
+	var f keyfile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIncorrectKeyring, err)
+	}
+	k := &Keyring{
+		primary: f.Primary,
+		keys:    make(map[string]cipher.AEAD, len(f.Keys)),
+	}
+	for id, encoded := range f.Keys {
+		if len(id) == 0 || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("%w: key ID %q is empty or contains a colon", ErrIncorrectKeyring, id)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q: %v", ErrIncorrectKeyring, id, err)
+		}
+		k.keys[id], err = newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q: %v", ErrIncorrectKeyring, id, err)
+		}
+	}
+	if _, ok := k.keys[k.primary]; !ok {
+		return nil, fmt.Errorf("%w: no primary key %q", ErrIncorrectKeyring, k.primary)
+	}
+	var err error
+	k.index, err = decodeKey(f.IndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: index key: %v", ErrIncorrectKeyring, err)
+	}
+	return k, nil
+}
+
+// NewKey returns a random key for the keyfile.
+func NewKey() (string, error) {
+	key, err := randomBytes(keySize)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the key: %v", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("the key is %d bytes long instead of %d", len(key), keySize)
+	}
+	return key, nil
+}
+
+// Seal encrypts the value with a new data key wrapped with the primary key.
+func (k *Keyring) Seal(value string) (string, error) {
+	dataKey, err := randomBytes(keySize)
+	if err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(data, []byte(value), nil)
+	if err != nil {
+		return "", err
+	}
+	return k.wrap(dataKey, ciphertext)
+}
+
+// Open decrypts the sealed value. The values that are not sealed, e.g. stored
+// before the encryption was turned on, are returned as they are.
+func (k *Keyring) Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	_, dataKey, ciphertext, err := k.unwrap(value)
+	if err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(data, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrIncorrectSealed, err)
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap wraps the data key of the sealed value with the primary key, the
+// ciphertext is kept. The values that are not sealed are sealed.
+func (k *Keyring) Rewrap(value string) (string, error) {
+	if !IsSealed(value) {
+		return k.Seal(value)
+	}
+	keyID, dataKey, ciphertext, err := k.unwrap(value)
+	if err != nil {
+		return "", err
+	}
+	if keyID == k.primary {
+		return value, nil
+	}
+	return k.wrap(dataKey, ciphertext)
+}
+
+// IsCurrent tells if the value is sealed with the primary key.
+func (k *Keyring) IsCurrent(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix+k.primary+":")
+}
+
+// IsSealed tells if the value is sealed.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+func (k *Keyring) wrap(dataKey []byte, ciphertext []byte) (string, error) {
+	wrapped, err := seal(k.keys[k.primary], dataKey, []byte(k.primary))
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + k.primary + ":" + encoding.EncodeToString(wrapped) + ":" + encoding.EncodeToString(ciphertext), nil
+}
+
+// unwrap returns the ID of the key wrapping the data key of the sealed value,
+// the data key and the ciphertext.
+func (k *Keyring) unwrap(value string) (string, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, sealedPrefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, fmt.Errorf("%w: %d parts instead of 3", ErrIncorrectSealed, len(parts))
+	}
+	keyID := parts[0]
+	key, ok := k.keys[keyID]
+	if !ok {
+		return "", nil, nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	wrapped, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%w: failed to decode the data key: %v", ErrIncorrectSealed, err)
+	}
+	ciphertext, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%w: failed to decode the ciphertext: %v", ErrIncorrectSealed, err)
+	}
+	dataKey, err := open(key, wrapped, []byte(keyID))
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%w: failed to unwrap the data key: %v", ErrIncorrectSealed, err)
+	}
+	return keyID, dataKey, ciphertext, nil
+}
+
+// Index returns the blind index of the value, equal values have equal
+// indexes.
+func (k *Keyring) Index(value string) string {
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:indexSize])
+}
+
+// PrefixIndexes returns the blind indexes of the prefixes of the value at
+// least MinPrefixLength bytes long, the value included.
+func (k *Keyring) PrefixIndexes(value string) Indexes {
+	indexes := make(Indexes, 0)
+	for n := MinPrefixLength; n <= len(value); n++ {
+		indexes = append(indexes, k.Index(value[:n]))
+	}
+	return indexes
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext, the random nonce is prepended to the
+// ciphertext.
+func seal(aead cipher.AEAD, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce, err := randomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("the ciphertext is too short")
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	return aead.Open(nil, nonce, ciphertext[aead.NonceSize():], additionalData)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return b, nil
+}
+
+// Indexes are blind indexes stored in a TEXT[] column.
+type Indexes []string
+
+func (i *Indexes) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*i = nil
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into indexes", src)
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+	if len(s) == 0 {
+		*i = Indexes{}
+		return nil
+	}
+	*i = strings.Split(s, ",")
+	return nil
+}
+
+// Value returns the array literal of the indexes, the hex indexes need no
+// quoting.
+func (i Indexes) Value() (driver.Value, error) {
+	return "{" + strings.Join(i, ",") + "}", nil
+}
+
+var keyring *Keyring
+
+// SetKeyring sets the keyring the storages seal the values with. It is
+// expected to be called once on start.
+func SetKeyring(k *Keyring) {
+	keyring = k
+}
+
+// Current returns the keyring set by SetKeyring.
+func Current() (*Keyring, error) {
+	if keyring == nil {
+		return nil, ErrNoKeyring
+	}
+	return keyring, nil
+}
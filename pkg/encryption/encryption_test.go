@@ -0,0 +1,188 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	oldKey   = "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE="
+	newKey   = "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXphYmNkZWY="
+	indexKey = "QUJDREVGR0hJSktMTU5PUFFSU1RVVldYWVpBQkNERUY="
+)
+
+func TestParseKeyring(t *testing.T) {
+	cases := []struct {
+		Keyfile     string
+		ExpectedErr error
+	}{
+		{
+			Keyfile: `{"primary": "2021-10", "keys": {"2021-07": "` + oldKey + `", "2021-10": "` + newKey + `"}, "index_key": "` + indexKey + `"}`,
+		},
+		{
+			Keyfile:     `{"primary": "2021-11", "keys": {"2021-10": "` + newKey + `"}, "index_key": "` + indexKey + `"}`,
+			ExpectedErr: ErrIncorrectKeyring,
+		},
+		{
+			Keyfile:     `{"primary": "2021:10", "keys": {"2021:10": "` + newKey + `"}, "index_key": "` + indexKey + `"}`,
+			ExpectedErr: ErrIncorrectKeyring,
+		},
+		{
+			Keyfile:     `{"primary": "2021-10", "keys": {"2021-10": "c2hvcnQ="}, "index_key": "` + indexKey + `"}`,
+			ExpectedErr: ErrIncorrectKeyring,
+		},
+		{
+			Keyfile:     `{"primary": "2021-10", "keys": {"2021-10": "` + newKey + `"}}`,
+			ExpectedErr: ErrIncorrectKeyring,
+		},
+		{
+			Keyfile:     `not a keyfile`,
+			ExpectedErr: ErrIncorrectKeyring,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			_, err := ParseKeyring([]byte(tc.Keyfile))
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSealAndOpen(t *testing.T) {
+	k := newKeyring(t, "2021-10", map[string]string{"2021-10": newKey})
+	sealed, err := k.Seal("aliddell@gopher_corp.com")
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+	if !IsSealed(sealed) || !k.IsCurrent(sealed) || strings.Contains(sealed, "aliddell") {
+		t.Fatalf("unexpected sealed value %q", sealed)
+	}
+	again, err := k.Seal("aliddell@gopher_corp.com")
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+	if again == sealed {
+		t.Errorf("expected every value to be sealed with a new data key")
+	}
+	opened, err := k.Open(sealed)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	if opened != "aliddell@gopher_corp.com" {
+		t.Errorf("expected %q, got %q", "aliddell@gopher_corp.com", opened)
+	}
+
+	// the values stored before the encryption are read as they are
+	opened, err = k.Open("+79169008070")
+	if err != nil || opened != "+79169008070" {
+		t.Errorf("expected the plaintext to be returned, got %q and %v", opened, err)
+	}
+
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if _, err := k.Open(tampered); !errors.Is(err, ErrIncorrectSealed) {
+		t.Errorf("expected %v for the tampered value, got %v", ErrIncorrectSealed, err)
+	}
+	other := newKeyring(t, "2021-11", map[string]string{"2021-11": oldKey})
+	if _, err := other.Open(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected %v for the value sealed with an unknown key, got %v", ErrUnknownKey, err)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	old := newKeyring(t, "2021-07", map[string]string{"2021-07": oldKey})
+	sealed, err := old.Seal("+79169008070")
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+	rotated := newKeyring(t, "2021-10", map[string]string{"2021-07": oldKey, "2021-10": newKey})
+	if rotated.IsCurrent(sealed) {
+		t.Fatalf("expected the value sealed with the old key not to be current")
+	}
+	rewrapped, err := rotated.Rewrap(sealed)
+	if err != nil {
+		t.Fatalf("failed to rewrap: %v", err)
+	}
+	if !rotated.IsCurrent(rewrapped) {
+		t.Errorf("expected the rewrapped value to be current, got %q", rewrapped)
+	}
+	if sealed[strings.LastIndex(sealed, ":"):] != rewrapped[strings.LastIndex(rewrapped, ":"):] {
+		t.Errorf("expected the ciphertext to be kept")
+	}
+	if _, err := old.Open(rewrapped); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected the old keyring not to open the rewrapped value, got %v", err)
+	}
+	opened, err := newKeyring(t, "2021-10", map[string]string{"2021-10": newKey}).Open(rewrapped)
+	if err != nil || opened != "+79169008070" {
+		t.Errorf("expected the new key alone to open the value, got %q and %v", opened, err)
+	}
+
+	again, err := rotated.Rewrap(rewrapped)
+	if err != nil || again != rewrapped {
+		t.Errorf("expected the current value to be kept, got %q and %v", again, err)
+	}
+	plain, err := rotated.Rewrap("+79169008070")
+	if err != nil || !rotated.IsCurrent(plain) {
+		t.Errorf("expected the plaintext to be sealed, got %q and %v", plain, err)
+	}
+}
+
+func TestPrefixIndexes(t *testing.T) {
+	k := newKeyring(t, "2021-10", map[string]string{"2021-10": newKey})
+	indexes := k.PrefixIndexes("alice")
+	expected := Indexes{k.Index("ali"), k.Index("alic"), k.Index("alice")}
+	if !reflect.DeepEqual(indexes, expected) {
+		t.Errorf("expected indexes %v, got %v", expected, indexes)
+	}
+	if k.Index("alice") == k.Index("Alice") || len(k.Index("alice")) != 2*indexSize {
+		t.Errorf("unexpected index %q", k.Index("alice"))
+	}
+	if len(k.PrefixIndexes("al")) != 0 {
+		t.Errorf("expected no indexes of the value shorter than the shortest prefix")
+	}
+
+	value, err := indexes.Value()
+	if err != nil {
+		t.Fatalf("failed to get the value of the indexes: %v", err)
+	}
+	var scanned Indexes
+	if err := scanned.Scan(value); err != nil {
+		t.Fatalf("failed to scan the indexes: %v", err)
+	}
+	if !reflect.DeepEqual(scanned, indexes) {
+		t.Errorf("expected scanned indexes %v, got %v", indexes, scanned)
+	}
+}
+
+func newKeyring(t *testing.T, primary string, keys map[string]string) *Keyring {
+	quoted := make([]string, 0, len(keys))
+	for id, key := range keys {
+		quoted = append(quoted, fmt.Sprintf("%q: %q", id, key))
+	}
+	k, err := ParseKeyring([]byte(fmt.Sprintf(
+		`{"primary": %q, "keys": {%s}, "index_key": %q}`,
+		primary, strings.Join(quoted, ", "), indexKey,
+	)))
+	if err != nil {
+		t.Fatalf("failed to parse the keyring: %v", err)
+	}
+	return k
+}
+
+func compareErrs(expectedErr error, actualErr error) error {
+	if expectedErr == nil && actualErr == nil {
+		return nil
+	}
+	if actualErr == nil {
+		return fmt.Errorf("expected an error \"%v\", got nil", expectedErr)
+	}
+	if !errors.Is(actualErr, expectedErr) {
+		return fmt.Errorf("expected error \"%v\" and actual error \"%v\" are different", expectedErr, actualErr)
+	}
+	return nil
+}
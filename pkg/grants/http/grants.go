@@ -0,0 +1,135 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/grants/service"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/grants/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+)
+
+// ListGrants returns the department role grants, of the user given by the
+// "subject" query parameter if it is set.
+func ListGrants(w http.ResponseWriter, r *http.Request) {
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	grants, err := service.ListGrants(db, auth.FromContext(r.Context()), r.URL.Query().Get("subject"))
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, grants)
+}
+
+// CreateGrant grants a role in a department subtree.
+func CreateGrant(w http.ResponseWriter, r *http.Request) {
+	g := &storage.Grant{}
+	if err := json.NewDecoder(r.Body).Decode(g); err != nil {
+		log.Printf("failed to decode the grant: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	created, err := service.CreateGrant(db, auth.FromContext(r.Context()), g)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func RevokeGrant(w http.ResponseWriter, r *http.Request, grantID string) {
+	id, err := strconv.ParseInt(grantID, 10, 64)
+	if err != nil {
+		log.Printf("incorrect grant ID %q: %v", grantID, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	revoked, err := service.RevokeGrant(db, auth.FromContext(r.Context()), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, revoked)
+}
+
+// NewMiddleware adds to the principals of the requests the roles they hold
+// in the departments.
+func NewMiddleware(l *service.Loader) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := auth.FromContext(r.Context())
+			if p == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			loaded, err := l.Load(r.Context(), p)
+			if err != nil {
+				log.Println("[ERR]: ", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), loaded)))
+		})
+	}
+}
+
+func getDB(w http.ResponseWriter, r *http.Request) (storage.DB, bool) {
+	dbIface := r.Context().Value(storage.ContextKeyDB)
+	if dbIface == nil {
+		log.Println("DB is not found in the request context")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	db, ok := dbIface.(storage.DB)
+	if !ok {
+		log.Println("DB in the request context is not of type storage.DB")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return db, true
+}
+
+func writeErr(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, service.ErrIncorrectGrant):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, service.ErrGrantNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, service.ErrGrantConflict):
+		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, policy.ErrForbidden):
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("failed to serialize the response to JSON: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(resp); err != nil {
+		log.Printf("failed to write the response body: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/grants/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+)
+
+var (
+	ErrIncorrectGrant  = fmt.Errorf("got an incorrect grant")
+	ErrGrantNotFound   = fmt.Errorf("grant not found")
+	ErrGrantConflict   = fmt.Errorf("grant conflicts with the existing data")
+	ErrDBRequestFailed = fmt.Errorf("a request to DB failed")
+)
+
+// grantableRoles are the roles that may be granted in a department subtree.
+var grantableRoles = map[string]struct{}{
+	policy.RoleHR:      {},
+	policy.RoleFinance: {},
+	policy.RoleAdmin:   {},
+}
+
+// CreateGrant grants the role to the subject in the department and its
+// descendants. The admins of a subtree may grant the roles in it.
+func CreateGrant(db storage.DB, caller *auth.Principal, g *storage.Grant) (*storage.Grant, error) {
+	g.Subject = strings.TrimSpace(g.Subject)
+	g.Role = strings.ToLower(strings.TrimSpace(g.Role))
+	if len(g.Subject) == 0 {
+		return nil, fmt.Errorf("%w: no subject", ErrIncorrectGrant)
+	}
+	if _, ok := grantableRoles[g.Role]; !ok {
+		return nil, fmt.Errorf("%w: role %q cannot be granted in a department", ErrIncorrectGrant, g.Role)
+	}
+	if g.Department <= 0 {
+		return nil, fmt.Errorf("%w: incorrect department %d", ErrIncorrectGrant, g.Department)
+	}
+	if err := policy.NewActor(caller, nil).CheckIn(policy.EntityRoleGrant, policy.ActionWrite, 0, g.Department); err != nil {
+		return nil, err
+	}
+	created, err := db.CreateGrant(context.Background(), g)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to create the grant")
+	}
+	return created, nil
+}
+
+// ListGrants returns the grants of the subject, of everyone if it is empty.
+// The admins of a subtree get only the grants in it.
+func ListGrants(db storage.DB, caller *auth.Principal, subject string) ([]*storage.Grant, error) {
+	grants, err := db.ListGrants(context.Background(), strings.TrimSpace(subject))
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list the grants")
+	}
+	a := policy.NewActor(caller, nil)
+	visible := make([]*storage.Grant, 0, len(grants))
+	for _, g := range grants {
+		if a.CanIn(policy.EntityRoleGrant, policy.ActionRead, 0, g.Department) {
+			visible = append(visible, g)
+		}
+	}
+	return visible, nil
+}
+
+// RevokeGrant deletes the grant. The admins of a subtree may revoke the
+// grants in it.
+func RevokeGrant(db storage.DB, caller *auth.Principal, id int64) (*storage.Grant, error) {
+	g, err := db.GetGrant(context.Background(), id)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to get the grant")
+	}
+	if err := policy.NewActor(caller, nil).CheckIn(policy.EntityRoleGrant, policy.ActionWrite, 0, g.Department); err != nil {
+		return nil, err
+	}
+	deleted, err := db.DeleteGrant(context.Background(), id)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to revoke the grant")
+	}
+	return deleted, nil
+}
+
+// Loader adds to the principals the roles they hold in the departments.
+type Loader struct {
+	newDB func() (storage.DB, error)
+}
+
+func NewLoader(newDB func() (storage.DB, error)) *Loader {
+	return &Loader{
+		newDB: newDB,
+	}
+}
+
+// Load returns a copy of the principal with the department roles. The API
+// keys are not granted roles, they are returned as is.
+func (l *Loader) Load(ctx context.Context, p *auth.Principal) (*auth.Principal, error) {
+	if p == nil || p.APIKeyID != 0 {
+		return p, nil
+	}
+	db, err := l.newDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the DB: %w", err)
+	}
+	defer db.Close()
+	roles, err := db.GetDepartmentRoles(ctx, p.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to get the department roles: %v", ErrDBRequestFailed, err)
+	}
+	loaded := *p
+	loaded.DepartmentRoles = roles
+	return &loaded, nil
+}
+
+func wrapDBErr(err error, msg string) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%w: %s: %v", ErrGrantNotFound, msg, err)
+	}
+	if errors.Is(err, storage.ErrConflict) {
+		return fmt.Errorf("%w: %s: %v", ErrGrantConflict, msg, err)
+	}
+	if errors.Is(err, storage.ErrIncorrect) {
+		return fmt.Errorf("%w: %s: %v", ErrIncorrectGrant, msg, err)
+	}
+	return fmt.Errorf("%w: %s: %v", ErrDBRequestFailed, msg, err)
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/grants/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+)
+
+func TestCreateGrant(t *testing.T) {
+	admin := &auth.Principal{Subject: "root", Roles: []string{policy.RoleAdmin}}
+	divisionAdmin := &auth.Principal{
+		Subject:         "division-admin",
+		DepartmentRoles: map[string]map[int]struct{}{policy.RoleAdmin: {2: {}, 4: {}}},
+	}
+	cases := []struct {
+		Principal     *auth.Principal
+		Grant         storage.Grant
+		MockErr       error
+		ExpectedGrant storage.Grant
+		ExpectedErr   error
+	}{
+		{
+			Principal:     admin,
+			Grant:         storage.Grant{Subject: " hr-partner ", Role: "HR", Department: 2},
+			ExpectedGrant: storage.Grant{Subject: "hr-partner", Role: "hr", Department: 2},
+		},
+		// the admins of a subtree grant the roles in it
+		{
+			Principal:     divisionAdmin,
+			Grant:         storage.Grant{Subject: "hr-partner", Role: "hr", Department: 4},
+			ExpectedGrant: storage.Grant{Subject: "hr-partner", Role: "hr", Department: 4},
+		},
+		{
+			Principal:   divisionAdmin,
+			Grant:       storage.Grant{Subject: "hr-partner", Role: "hr", Department: 1},
+			ExpectedErr: policy.ErrForbidden,
+		},
+		{
+			Principal:   &auth.Principal{Subject: "hr", Roles: []string{policy.RoleHR}},
+			Grant:       storage.Grant{Subject: "hr-partner", Role: "hr", Department: 2},
+			ExpectedErr: policy.ErrForbidden,
+		},
+		{
+			Principal:   admin,
+			Grant:       storage.Grant{Subject: "hr-partner", Role: "employee", Department: 2},
+			ExpectedErr: ErrIncorrectGrant,
+		},
+		{
+			Principal:   admin,
+			Grant:       storage.Grant{Subject: " ", Role: "hr", Department: 2},
+			ExpectedErr: ErrIncorrectGrant,
+		},
+		{
+			Principal:   admin,
+			Grant:       storage.Grant{Subject: "hr-partner", Role: "hr"},
+			ExpectedErr: ErrIncorrectGrant,
+		},
+		{
+			Principal:   admin,
+			Grant:       storage.Grant{Subject: "hr-partner", Role: "hr", Department: 42},
+			MockErr:     fmt.Errorf("%w: unknown department", storage.ErrIncorrect),
+			ExpectedErr: ErrIncorrectGrant,
+		},
+		{
+			Principal:   admin,
+			Grant:       storage.Grant{Subject: "hr-partner", Role: "hr", Department: 2},
+			MockErr:     fmt.Errorf("%w: duplicate grant", storage.ErrConflict),
+			ExpectedErr: ErrGrantConflict,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{createErr: tc.MockErr}
+			g := tc.Grant
+			created, err := CreateGrant(mock, tc.Principal, &g)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				return
+			}
+			created.ID = 0
+			if *created != tc.ExpectedGrant {
+				t.Errorf("expected grant %+v, got %+v", tc.ExpectedGrant, *created)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	roles := map[string]map[int]struct{}{policy.RoleHR: {2: {}, 4: {}}}
+	cases := []struct {
+		Principal *auth.Principal
+		Expected  *auth.Principal
+	}{
+		{
+			Principal: &auth.Principal{Subject: "hr-partner", EmployeeID: 9},
+			Expected:  &auth.Principal{Subject: "hr-partner", EmployeeID: 9, DepartmentRoles: roles},
+		},
+		// the API keys are not granted roles
+		{
+			Principal: &auth.Principal{Subject: "hr-partner", APIKeyID: 1},
+			Expected:  &auth.Principal{Subject: "hr-partner", APIKeyID: 1},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{roles: map[string]map[string]map[int]struct{}{"hr-partner": roles}}
+			l := NewLoader(func() (storage.DB, error) {
+				return mock, nil
+			})
+			p, err := l.Load(context.Background(), tc.Principal)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if !reflect.DeepEqual(p, tc.Expected) {
+				t.Errorf("expected principal %+v, got %+v", tc.Expected, p)
+			}
+			if tc.Principal.DepartmentRoles != nil {
+				t.Errorf("the principal was changed")
+			}
+		})
+	}
+}
+
+type dbMock struct {
+	createErr error
+	roles     map[string]map[string]map[int]struct{}
+}
+
+func (db *dbMock) CreateGrant(ctx context.Context, g *storage.Grant) (*storage.Grant, error) {
+	if db.createErr != nil {
+		return nil, db.createErr
+	}
+	created := *g
+	created.ID = 1
+	return &created, nil
+}
+
+func (db *dbMock) ListGrants(ctx context.Context, subject string) ([]*storage.Grant, error) {
+	return nil, nil
+}
+
+func (db *dbMock) GetGrant(ctx context.Context, id int64) (*storage.Grant, error) {
+	return nil, storage.ErrNotFound
+}
+
+func (db *dbMock) DeleteGrant(ctx context.Context, id int64) (*storage.Grant, error) {
+	return nil, storage.ErrNotFound
+}
+
+func (db *dbMock) GetDepartmentRoles(ctx context.Context, subject string) (map[string]map[int]struct{}, error) {
+	return db.roles[subject], nil
+}
+
+func (db *dbMock) Close() {}
+
+func compareErrs(expectedErr error, actualErr error) error {
+	if expectedErr == nil && actualErr == nil {
+		return nil
+	}
+	if actualErr == nil {
+		return fmt.Errorf("expected an error \"%v\", got nil", expectedErr)
+	}
+	if !errors.Is(actualErr, expectedErr) {
+		return fmt.Errorf("expected error \"%v\" and actual error \"%v\" are different", expectedErr, actualErr)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+type gormDB struct {
+	db  *gorm.DB
+	src *auditStorage.Source
+}
+
+func newGormDB(c *database.ConnString, src *auditStorage.Source) (*gormDB, error) {
+	db, err := database.OpenGorm(c)
+	if err != nil {
+		return nil, err
+	}
+	return &gormDB{
+		db:  db,
+		src: src,
+	}, nil
+}
+
+func (g *gormDB) CreateGrant(ctx context.Context, grant *Grant) (*Grant, error) {
+	created := &Grant{}
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(
+			`INSERT INTO department_role_grants (subject, role, department)
+			VALUES (?, ?, ?)
+			RETURNING *`,
+			grant.Subject, grant.Role, grant.Department,
+		).Scan(created).Error
+		if err != nil {
+			return wrapWriteErr(err, "failed to create the grant")
+		}
+		return auditStorage.Record(tx, g.src, &auditStorage.Change{
+			Action:     auditStorage.ActionCreate,
+			EntityType: auditStorage.EntityRoleGrant,
+			EntityID:   created.ID,
+			After:      created,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (g *gormDB) ListGrants(ctx context.Context, subject string) ([]*Grant, error) {
+	req := g.db.WithContext(ctx).Order("id")
+	if len(subject) != 0 {
+		req = req.Where("subject = ?", subject)
+	}
+	var grants []*Grant
+	if err := req.Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("failed to query the grants: %w", err)
+	}
+	return grants, nil
+}
+
+func (g *gormDB) GetGrant(ctx context.Context, id int64) (*Grant, error) {
+	grant := &Grant{}
+	if err := g.db.WithContext(ctx).Where("id = ?", id).Take(grant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: grant %d", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to query the grant: %w", err)
+	}
+	return grant, nil
+}
+
+func (g *gormDB) DeleteGrant(ctx context.Context, id int64) (*Grant, error) {
+	deleted := &Grant{}
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", id).Take(deleted).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: grant %d", ErrNotFound, id)
+			}
+			return fmt.Errorf("failed to query the grant: %w", err)
+		}
+		if err := tx.Delete(&Grant{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete the grant: %w", err)
+		}
+		return auditStorage.Record(tx, g.src, &auditStorage.Change{
+			Action:     auditStorage.ActionRevoke,
+			EntityType: auditStorage.EntityRoleGrant,
+			EntityID:   id,
+			Before:     deleted,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+func (g *gormDB) GetDepartmentRoles(ctx context.Context, subject string) (map[string]map[int]struct{}, error) {
+	var rows []struct {
+		Role       string
+		Department int
+	}
+	err := g.db.WithContext(ctx).Raw(
+		`WITH RECURSIVE subtree AS (
+			SELECT role, department FROM department_role_grants WHERE subject = ?
+			UNION
+			SELECT s.role, d.id FROM departments d JOIN subtree s ON d.parent_id = s.department WHERE d.id <> d.parent_id
+		)
+		SELECT role, department FROM subtree`,
+		subject,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the department roles: %w", err)
+	}
+	roles := make(map[string]map[int]struct{})
+	for _, r := range rows {
+		if roles[r.Role] == nil {
+			roles[r.Role] = make(map[int]struct{})
+		}
+		roles[r.Role][r.Department] = struct{}{}
+	}
+	return roles, nil
+}
+
+func (g *gormDB) Close() {
+	database.CloseGorm(g.db)
+}
+
+func wrapWriteErr(err error, msg string) error {
+	if database.IsUniqueViolation(err) {
+		return fmt.Errorf("%w: %s: %v", ErrConflict, msg, err)
+	}
+	if database.IsForeignKeyViolation(err) {
+		return fmt.Errorf("%w: %s: unknown department: %v", ErrIncorrect, msg, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+type ContextKey int
+
+const ContextKeyDB ContextKey = iota + 1
+
+var (
+	ErrNotFound  = fmt.Errorf("not found")
+	ErrConflict  = fmt.Errorf("conflicts with the existing data")
+	ErrIncorrect = fmt.Errorf("incorrect data")
+)
+
+// Grant gives the user the role in the department and its descendants.
+type Grant struct {
+	ID         int64     `gorm:"column:id" json:"id"`
+	Subject    string    `gorm:"column:subject" json:"subject"`
+	Role       string    `gorm:"column:role" json:"role"`
+	Department int       `gorm:"column:department" json:"department"`
+	CreatedAt  time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (Grant) TableName() string {
+	return "department_role_grants"
+}
+
+type DB interface {
+	// CreateGrant creates the grant, an unknown department is ErrIncorrect.
+	CreateGrant(ctx context.Context, g *Grant) (*Grant, error)
+	// ListGrants returns the grants of the subject, of everyone if it is
+	// empty, by ID.
+	ListGrants(ctx context.Context, subject string) ([]*Grant, error)
+	GetGrant(ctx context.Context, id int64) (*Grant, error)
+	DeleteGrant(ctx context.Context, id int64) (*Grant, error)
+	// GetDepartmentRoles returns the departments where the subject holds the
+	// roles by role: the departments of the grants and all their
+	// descendants.
+	GetDepartmentRoles(ctx context.Context, subject string) (map[string]map[int]struct{}, error)
+	Close()
+}
+
+// NewDB opens the storage, the changes made through it are recorded in the
+// audit log as made by src.
+func NewDB(connStr *database.ConnString, src *auditStorage.Source) (DB, error) {
+	gormDB, err := newGormDB(connStr, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a gorm connection: %w", err)
+	}
+	return gormDB, nil
+}
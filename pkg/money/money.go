@@ -0,0 +1,249 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrIncorrectAmount   = fmt.Errorf("got an incorrect amount")
+	ErrIncorrectCurrency = fmt.Errorf("got an incorrect currency")
+	ErrCurrencyMismatch  = fmt.Errorf("currencies do not match")
+)
+
+// maxAmountDigits is the number of integer digits of a NUMERIC(15, 2) column.
+const maxAmountDigits = 13
+
+var (
+	amountRe   = regexp.MustCompile(`^(-?)([0-9]+)(?:\.([0-9]{1,2}))?$`)
+	currencyRe = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// Amount is an exact decimal amount with two fractional digits, counted in
+// hundredths. It is stored in NUMERIC(15, 2) columns and serialized to JSON
+// as a decimal string, e.g. "1234.50".
+type Amount int64
+
+func ParseAmount(s string) (Amount, error) {
+	m := amountRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("%w: %q is not a decimal with at most two fractional digits", ErrIncorrectAmount, s)
+	}
+	intPart := strings.TrimLeft(m[2], "0")
+	if len(intPart) > maxAmountDigits {
+		return 0, fmt.Errorf("%w: %q is too large", ErrIncorrectAmount, s)
+	}
+	units, _ := strconv.ParseInt("0"+intPart, 10, 64)
+	fracPart := m[3]
+	for len(fracPart) < 2 {
+		fracPart += "0"
+	}
+	frac, _ := strconv.ParseInt(fracPart, 10, 64)
+	a := Amount(units*100 + frac)
+	if m[1] == "-" {
+		a = -a
+	}
+	return a, nil
+}
+
+func (a Amount) String() string {
+	sign := ""
+	units := int64(a)
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, units/100, units%100)
+}
+
+func (a Amount) Sign() int {
+	switch {
+	case a < 0:
+		return -1
+	case a > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (a Amount) Rat() *big.Rat {
+	return big.NewRat(int64(a), 100)
+}
+
+// MulRat multiplies the amount by r, rounding half away from zero to the
+// hundredths.
+func (a Amount) MulRat(r *big.Rat) Amount {
+	return roundRat(new(big.Rat).Mul(a.Rat(), r))
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts both a decimal string and a JSON number.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	case int64:
+		*a = Amount(v * 100)
+		return nil
+	case float64:
+		return a.scanString(strconv.FormatFloat(v, 'f', 2, 64))
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrIncorrectAmount, src)
+	}
+}
+
+func (a *Amount) scanString(s string) error {
+	parsed, err := ParseAmount(s)
+	if err != nil {
+		// sums and products of NUMERIC columns may carry more digits
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return err
+		}
+		parsed = roundRat(r)
+	}
+	*a = parsed
+	return nil
+}
+
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Money is an amount in a currency. The currency is an ISO 4217 code.
+type Money struct {
+	Amount   Amount `gorm:"column:amount" json:"amount"`
+	Currency string `gorm:"column:currency" json:"currency"`
+}
+
+func New(amount Amount, currency string) Money {
+	return Money{
+		Amount:   amount,
+		Currency: currency,
+	}
+}
+
+// Parse parses the amount and normalizes the currency code.
+func Parse(amount string, currency string) (Money, error) {
+	a, err := ParseAmount(amount)
+	if err != nil {
+		return Money{}, err
+	}
+	m := New(a, currency)
+	if err := m.Normalize(); err != nil {
+		return Money{}, err
+	}
+	return m, nil
+}
+
+// Normalize upper-cases the currency code and checks it.
+func (m *Money) Normalize() error {
+	m.Currency = strings.ToUpper(strings.TrimSpace(m.Currency))
+	return ValidateCurrency(m.Currency)
+}
+
+func ValidateCurrency(currency string) error {
+	if !currencyRe.MatchString(currency) {
+		return fmt.Errorf("%w: %q is not an ISO 4217 code", ErrIncorrectCurrency, currency)
+	}
+	return nil
+}
+
+func (m Money) String() string {
+	return m.Amount.String() + " " + m.Currency
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.checkCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return New(m.Amount+o.Amount, m.Currency), nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.checkCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return New(m.Amount-o.Amount, m.Currency), nil
+}
+
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.checkCurrency(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// MulRat multiplies the amount by r, see Amount.MulRat.
+func (m Money) MulRat(r *big.Rat) Money {
+	return New(m.Amount.MulRat(r), m.Currency)
+}
+
+// Sum adds up the values, all of which must be in the currency.
+func Sum(currency string, values ...Money) (Money, error) {
+	total := New(0, currency)
+	for _, v := range values {
+		var err error
+		if total, err = total.Add(v); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+func (m Money) checkCurrency(o Money) error {
+	if m.Currency != o.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return nil
+}
+
+func roundRat(r *big.Rat) Amount {
+	hundredths := new(big.Rat).Mul(r, big.NewRat(100, 1))
+	num := new(big.Int).Set(hundredths.Num())
+	den := hundredths.Denom()
+	neg := num.Sign() < 0
+	num.Abs(num)
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if neg {
+		q.Neg(q)
+	}
+	return Amount(q.Int64())
+}
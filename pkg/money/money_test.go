@@ -0,0 +1,163 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	cases := []struct {
+		Amount      string
+		Expected    Amount
+		ExpectedErr error
+	}{
+		{Amount: "1234.5", Expected: 123450},
+		{Amount: "1234.05", Expected: 123405},
+		{Amount: "0.01", Expected: 1},
+		{Amount: "-10", Expected: -1000},
+		{Amount: " 42 ", Expected: 4200},
+		{Amount: "9999999999999.99", Expected: 999999999999999},
+		{Amount: "10000000000000", ExpectedErr: ErrIncorrectAmount},
+		{Amount: "1.005", ExpectedErr: ErrIncorrectAmount},
+		{Amount: "$1,000.00", ExpectedErr: ErrIncorrectAmount},
+		{Amount: "1e5", ExpectedErr: ErrIncorrectAmount},
+		{Amount: "", ExpectedErr: ErrIncorrectAmount},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			a, err := ParseAmount(tc.Amount)
+			if tc.ExpectedErr != nil {
+				if !errors.Is(err, tc.ExpectedErr) {
+					t.Errorf("expected error %v, got %v", tc.ExpectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if a != tc.Expected {
+				t.Errorf("expected %d, got %d", tc.Expected, a)
+			}
+		})
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	cases := map[Amount]string{
+		0:       "0.00",
+		5:       "0.05",
+		-5:      "-0.05",
+		123450:  "1234.50",
+		-123456: "-1234.56",
+	}
+	for a, expected := range cases {
+		if a.String() != expected {
+			t.Errorf("expected %s, got %s", expected, a.String())
+		}
+	}
+}
+
+func TestAmountScan(t *testing.T) {
+	cases := []struct {
+		Src      interface{}
+		Expected Amount
+	}{
+		{Src: []byte("45000.00"), Expected: 4500000},
+		{Src: "12.3", Expected: 1230},
+		{Src: int64(7), Expected: 700},
+		{Src: "1.005", Expected: 101},
+		{Src: "-1.005", Expected: -101},
+		{Src: "33.333333333", Expected: 3333},
+		{Src: nil, Expected: 0},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			var a Amount
+			if err := a.Scan(tc.Src); err != nil {
+				t.Errorf("failed to scan %v: %v", tc.Src, err)
+				return
+			}
+			if a != tc.Expected {
+				t.Errorf("expected %d, got %d", tc.Expected, a)
+			}
+		})
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	m := New(123450, "RUB")
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if string(data) != `{"amount":"1234.50","currency":"RUB"}` {
+		t.Fatalf("unexpected JSON: %s", data)
+	}
+
+	var fromString, fromNumber Money
+	if err := json.Unmarshal([]byte(`{"amount":"1234.50","currency":"RUB"}`), &fromString); err != nil {
+		t.Fatalf("failed to unmarshal a string amount: %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"amount":1234.5,"currency":"RUB"}`), &fromNumber); err != nil {
+		t.Fatalf("failed to unmarshal a number amount: %v", err)
+	}
+	if fromString != m || fromNumber != m {
+		t.Errorf("expected %v, got %v and %v", m, fromString, fromNumber)
+	}
+	if err := json.Unmarshal([]byte(`{"amount":"1.001","currency":"RUB"}`), &fromString); !errors.Is(err, ErrIncorrectAmount) {
+		t.Errorf("expected error %v, got %v", ErrIncorrectAmount, err)
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a := New(10010, "RUB")
+	b := New(20, "RUB")
+	sum, err := Sum("RUB", a, b, b)
+	if err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+	if sum != New(10050, "RUB") {
+		t.Errorf("expected 100.50 RUB, got %v", sum)
+	}
+	diff, err := a.Sub(b)
+	if err != nil || diff != New(9990, "RUB") {
+		t.Errorf("expected 99.90 RUB, got %v (err: %v)", diff, err)
+	}
+	if cmp, err := a.Cmp(b); err != nil || cmp != 1 {
+		t.Errorf("expected %v > %v, got %d (err: %v)", a, b, cmp, err)
+	}
+	if _, err := a.Add(New(1, "USD")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("expected error %v, got %v", ErrCurrencyMismatch, err)
+	}
+	if _, err := Sum("USD", a); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("expected error %v, got %v", ErrCurrencyMismatch, err)
+	}
+
+	// 100.10 * 1/3 = 33.3666...
+	if third := a.MulRat(big.NewRat(1, 3)); third != New(3337, "RUB") {
+		t.Errorf("expected 33.37 RUB, got %v", third)
+	}
+	// 0.05 * 1/2 = 0.025 rounds away from zero
+	if half := New(-5, "RUB").MulRat(big.NewRat(1, 2)); half != New(-3, "RUB") {
+		t.Errorf("expected -0.03 RUB, got %v", half)
+	}
+}
+
+func TestParse(t *testing.T) {
+	m, err := Parse("10", " usd ")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if m != New(1000, "USD") {
+		t.Errorf("expected 10.00 USD, got %v", m)
+	}
+	if _, err := Parse("10", "US"); !errors.Is(err, ErrIncorrectCurrency) {
+		t.Errorf("expected error %v, got %v", ErrIncorrectCurrency, err)
+	}
+}
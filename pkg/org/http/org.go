@@ -0,0 +1,129 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/org/service"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/org/storage"
+)
+
+// GetSnapshot returns the org tree as it was on the date given by the "date"
+// query parameter, today by default.
+func GetSnapshot(w http.ResponseWriter, r *http.Request) {
+	on, ok := parseDate(w, r.URL.Query().Get("date"), time.Now())
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	snapshot, err := service.GetSnapshot(db, on)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// GetDirectory returns the employees who worked on the date given by the
+// "date" query parameter, today by default.
+func GetDirectory(w http.ResponseWriter, r *http.Request) {
+	on, ok := parseDate(w, r.URL.Query().Get("date"), time.Now())
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	members, err := service.GetDirectory(db, on)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, members)
+}
+
+// GetDiff compares the org on the date given by the "from" query parameter
+// with the org on the date given by the "to" query parameter, today by
+// default.
+func GetDiff(w http.ResponseWriter, r *http.Request) {
+	from, ok := parseDate(w, r.URL.Query().Get("from"), time.Time{})
+	if !ok {
+		return
+	}
+	to, ok := parseDate(w, r.URL.Query().Get("to"), time.Now())
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	diff, err := service.GetDiff(db, from, to)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, diff)
+}
+
+// parseDate parses a date like 2021-07-01. An empty date is replaced with
+// defaultDate unless it is zero.
+func parseDate(w http.ResponseWriter, date string, defaultDate time.Time) (time.Time, bool) {
+	if len(date) == 0 && !defaultDate.IsZero() {
+		return defaultDate, true
+	}
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		log.Printf("incorrect date %q: %v", date, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func getDB(w http.ResponseWriter, r *http.Request) (storage.DB, bool) {
+	dbIface := r.Context().Value(storage.ContextKeyDB)
+	if dbIface == nil {
+		log.Println("DB is not found in the request context")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	db, ok := dbIface.(storage.DB)
+	if !ok {
+		log.Println("DB in the request context is not of type storage.DB")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return db, true
+}
+
+func writeErr(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, service.ErrIncorrectDates):
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("failed to serialize the response to JSON: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(resp); err != nil {
+		log.Printf("failed to write the response body: %v", err)
+	}
+}
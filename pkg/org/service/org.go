@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/org/storage"
+)
+
+var (
+	ErrIncorrectDates  = fmt.Errorf("got incorrect dates")
+	ErrDBRequestFailed = fmt.Errorf("a request to DB failed")
+)
+
+// Unit is a department of an org snapshot with its members and subunits.
+type Unit struct {
+	storage.Department
+	Members  []*storage.Member `json:"members"`
+	Children []*Unit           `json:"children"`
+}
+
+// Snapshot is the org tree as it was on the date.
+type Snapshot struct {
+	Date  time.Time `json:"date"`
+	Units []*Unit   `json:"units"`
+}
+
+// MemberChange is a change of an employee's department or manager.
+type MemberChange struct {
+	EmployeeID int    `json:"employee_id"`
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+	From       int    `json:"from"`
+	To         int    `json:"to"`
+}
+
+type DepartmentRename struct {
+	Department int    `json:"department"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+}
+
+// Diff lists the changes of the org between two dates.
+type Diff struct {
+	From              time.Time           `json:"from"`
+	To                time.Time           `json:"to"`
+	Hires             []*storage.Member   `json:"hires"`
+	Leavers           []*storage.Member   `json:"leavers"`
+	Transfers         []*MemberChange     `json:"transfers"`
+	ManagerChanges    []*MemberChange     `json:"manager_changes"`
+	DepartmentRenames []*DepartmentRename `json:"department_renames"`
+}
+
+// GetSnapshot returns the org tree as it was on the date. The departments
+// whose parent did not exist on the date are the roots of the tree.
+func GetSnapshot(db storage.DB, on time.Time) (*Snapshot, error) {
+	on = truncateToDate(on)
+	ctx := context.Background()
+	depts, err := db.ListDepartments(ctx, on)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list the departments: %v", ErrDBRequestFailed, err)
+	}
+	members, err := db.ListMembers(ctx, on)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list the members: %v", ErrDBRequestFailed, err)
+	}
+	return &Snapshot{
+		Date:  on,
+		Units: BuildTree(depts, members),
+	}, nil
+}
+
+// GetDirectory returns the employees who worked on the date ordered by the
+// last name.
+func GetDirectory(db storage.DB, on time.Time) ([]*storage.Member, error) {
+	members, err := db.ListMembers(context.Background(), truncateToDate(on))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list the members: %v", ErrDBRequestFailed, err)
+	}
+	return members, nil
+}
+
+// GetDiff compares the org on the from date with the org on the to date.
+func GetDiff(db storage.DB, from time.Time, to time.Time) (*Diff, error) {
+	from, to = truncateToDate(from), truncateToDate(to)
+	if !from.Before(to) {
+		return nil, fmt.Errorf(
+			"%w: %s is not before %s",
+			ErrIncorrectDates, from.Format("2006-01-02"), to.Format("2006-01-02"),
+		)
+	}
+	ctx := context.Background()
+	fromDepts, err := db.ListDepartments(ctx, from)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list the departments: %v", ErrDBRequestFailed, err)
+	}
+	toDepts, err := db.ListDepartments(ctx, to)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list the departments: %v", ErrDBRequestFailed, err)
+	}
+	fromMembers, err := db.ListMembers(ctx, from)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list the members: %v", ErrDBRequestFailed, err)
+	}
+	toMembers, err := db.ListMembers(ctx, to)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list the members: %v", ErrDBRequestFailed, err)
+	}
+
+	diff := &Diff{
+		From:              from,
+		To:                to,
+		Hires:             make([]*storage.Member, 0),
+		Leavers:           make([]*storage.Member, 0),
+		Transfers:         make([]*MemberChange, 0),
+		ManagerChanges:    make([]*MemberChange, 0),
+		DepartmentRenames: make([]*DepartmentRename, 0),
+	}
+	before := make(map[int]*storage.Member, len(fromMembers))
+	for _, m := range fromMembers {
+		before[m.EmployeeID] = m
+	}
+	after := make(map[int]*storage.Member, len(toMembers))
+	for _, m := range toMembers {
+		after[m.EmployeeID] = m
+		prev, ok := before[m.EmployeeID]
+		if !ok {
+			diff.Hires = append(diff.Hires, m)
+			continue
+		}
+		if prev.Department != m.Department {
+			diff.Transfers = append(diff.Transfers, newMemberChange(m, prev.Department, m.Department))
+		}
+		if prev.ManagerID != m.ManagerID {
+			diff.ManagerChanges = append(diff.ManagerChanges, newMemberChange(m, prev.ManagerID, m.ManagerID))
+		}
+	}
+	for _, m := range fromMembers {
+		if _, ok := after[m.EmployeeID]; !ok {
+			diff.Leavers = append(diff.Leavers, m)
+		}
+	}
+	names := make(map[int]string, len(fromDepts))
+	for _, d := range fromDepts {
+		names[d.ID] = d.Name
+	}
+	for _, d := range toDepts {
+		if name, ok := names[d.ID]; ok && name != d.Name {
+			diff.DepartmentRenames = append(diff.DepartmentRenames, &DepartmentRename{
+				Department: d.ID,
+				From:       name,
+				To:         d.Name,
+			})
+		}
+	}
+	return diff, nil
+}
+
+func newMemberChange(m *storage.Member, from int, to int) *MemberChange {
+	return &MemberChange{
+		EmployeeID: m.EmployeeID,
+		FirstName:  m.FirstName,
+		LastName:   m.LastName,
+		From:       from,
+		To:         to,
+	}
+}
+
+// BuildTree attaches every unit to its parent. The departments whose parent is
+// missing or is the department itself are roots. A parent_id cycle is broken
+// at its department with the lowest ID.
+func BuildTree(depts []*storage.Department, members []*storage.Member) []*Unit {
+	units := make(map[int]*Unit, len(depts))
+	for _, d := range depts {
+		units[d.ID] = &Unit{
+			Department: *d,
+			Members:    make([]*storage.Member, 0),
+			Children:   make([]*Unit, 0),
+		}
+	}
+	for _, m := range members {
+		if u, ok := units[m.Department]; ok {
+			u.Members = append(u.Members, m)
+		}
+	}
+	childrenOf := make(map[int][]*Unit, len(depts))
+	for _, d := range depts {
+		if d.ParentID != d.ID {
+			childrenOf[d.ParentID] = append(childrenOf[d.ParentID], units[d.ID])
+		}
+	}
+
+	roots := make([]*Unit, 0)
+	attached := make(map[int]struct{}, len(depts))
+	attach := func(root *Unit) {
+		roots = append(roots, root)
+		attached[root.ID] = struct{}{}
+		queue := []*Unit{root}
+		for len(queue) != 0 {
+			u := queue[0]
+			queue = queue[1:]
+			for _, c := range childrenOf[u.ID] {
+				if _, ok := attached[c.ID]; ok {
+					continue
+				}
+				attached[c.ID] = struct{}{}
+				u.Children = append(u.Children, c)
+				queue = append(queue, c)
+			}
+		}
+	}
+	for _, d := range depts {
+		if _, ok := units[d.ParentID]; !ok || d.ParentID == d.ID {
+			attach(units[d.ID])
+		}
+	}
+	// depts are ordered by ID, so the cycles are broken at the lowest ID
+	for _, d := range depts {
+		if _, ok := attached[d.ID]; !ok {
+			attach(units[d.ID])
+		}
+	}
+	return roots
+}
+
+func truncateToDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
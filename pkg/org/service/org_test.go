@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/org/storage"
+)
+
+var (
+	q2 = time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+	q3 = time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func TestGetSnapshot(t *testing.T) {
+	mock := newDBMock()
+	snapshot, err := GetSnapshot(mock, q2.Add(time.Hour*10))
+	if err != nil {
+		t.Fatalf("GetSnapshot failed: %v", err)
+	}
+	if !snapshot.Date.Equal(q2) {
+		t.Errorf("expected the snapshot date %v, got %v", q2, snapshot.Date)
+	}
+	if len(snapshot.Units) != 2 {
+		t.Fatalf("expected 2 root units, got %d", len(snapshot.Units))
+	}
+	root := snapshot.Units[0]
+	if root.ID != 1 || len(root.Children) != 1 || root.Children[0].ID != 2 {
+		t.Errorf("expected the root 1 with the single child 2, got %+v", root)
+	}
+	if len(root.Members) != 1 || len(root.Children[0].Members) != 2 {
+		t.Errorf("expected 1 member in the root and 2 in its child, got %d and %d", len(root.Members), len(root.Children[0].Members))
+	}
+	// departments 3 and 4 are parents of each other
+	cycle := snapshot.Units[1]
+	if cycle.ID != 3 || len(cycle.Children) != 1 || cycle.Children[0].ID != 4 || len(cycle.Children[0].Children) != 0 {
+		t.Errorf("expected the cycle to be broken at the department 3, got %+v", cycle)
+	}
+}
+
+func TestGetDiff(t *testing.T) {
+	mock := newDBMock()
+	diff, err := GetDiff(mock, q2, q3)
+	if err != nil {
+		t.Fatalf("GetDiff failed: %v", err)
+	}
+	if len(diff.Hires) != 1 || diff.Hires[0].EmployeeID != 4 {
+		t.Errorf("expected the employee 4 to be hired, got %+v", diff.Hires)
+	}
+	if len(diff.Leavers) != 1 || diff.Leavers[0].EmployeeID != 3 {
+		t.Errorf("expected the employee 3 to leave, got %+v", diff.Leavers)
+	}
+	expectedTransfer := MemberChange{EmployeeID: 2, LastName: "Two", From: 2, To: 1}
+	if len(diff.Transfers) != 1 || *diff.Transfers[0] != expectedTransfer {
+		t.Errorf("expected the transfer %+v, got %+v", expectedTransfer, diff.Transfers)
+	}
+	expectedManagerChange := MemberChange{EmployeeID: 2, LastName: "Two", From: 1, To: 4}
+	if len(diff.ManagerChanges) != 1 || *diff.ManagerChanges[0] != expectedManagerChange {
+		t.Errorf("expected the manager change %+v, got %+v", expectedManagerChange, diff.ManagerChanges)
+	}
+	expectedRename := DepartmentRename{Department: 2, From: "Backend", To: "Platform"}
+	if len(diff.DepartmentRenames) != 1 || *diff.DepartmentRenames[0] != expectedRename {
+		t.Errorf("expected the rename %+v, got %+v", expectedRename, diff.DepartmentRenames)
+	}
+
+	_, err = GetDiff(mock, q3, q2)
+	if !errors.Is(err, ErrIncorrectDates) {
+		t.Errorf("expected error %v for a reversed range, got %v", ErrIncorrectDates, err)
+	}
+}
+
+type dbMock struct {
+	depts   map[time.Time][]*storage.Department
+	members map[time.Time][]*storage.Member
+}
+
+func newDBMock() *dbMock {
+	return &dbMock{
+		depts: map[time.Time][]*storage.Department{
+			q2: {
+				{ID: 1, ParentID: 1, Name: "root"},
+				{ID: 2, ParentID: 1, Name: "Backend"},
+				{ID: 3, ParentID: 4, Name: "Loop A"},
+				{ID: 4, ParentID: 3, Name: "Loop B"},
+			},
+			q3: {
+				{ID: 1, ParentID: 1, Name: "root"},
+				{ID: 2, ParentID: 1, Name: "Platform"},
+			},
+		},
+		members: map[time.Time][]*storage.Member{
+			q2: {
+				{EmployeeID: 1, LastName: "One", Department: 1, ManagerID: 1},
+				{EmployeeID: 2, LastName: "Two", Department: 2, ManagerID: 1},
+				{EmployeeID: 3, LastName: "Three", Department: 2, ManagerID: 1},
+			},
+			q3: {
+				{EmployeeID: 1, LastName: "One", Department: 1, ManagerID: 1},
+				{EmployeeID: 2, LastName: "Two", Department: 1, ManagerID: 4},
+				{EmployeeID: 4, LastName: "Four", Department: 2, ManagerID: 1},
+			},
+		},
+	}
+}
+
+func (db *dbMock) ListDepartments(ctx context.Context, on time.Time) ([]*storage.Department, error) {
+	depts, ok := db.depts[on]
+	if !ok {
+		return nil, fmt.Errorf("unexpected date %v", on)
+	}
+	return depts, nil
+}
+
+func (db *dbMock) ListMembers(ctx context.Context, on time.Time) ([]*storage.Member, error) {
+	members, ok := db.members[on]
+	if !ok {
+		return nil, fmt.Errorf("unexpected date %v", on)
+	}
+	return members, nil
+}
+
+func (db *dbMock) Close() {}
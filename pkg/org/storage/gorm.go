@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+type gormDB struct {
+	db *gorm.DB
+}
+
+func newGormDB(c *database.ConnString) (*gormDB, error) {
+	db, err := database.OpenGorm(c)
+	if err != nil {
+		return nil, err
+	}
+	return &gormDB{
+		db: db,
+	}, nil
+}
+
+func (g *gormDB) ListDepartments(ctx context.Context, on time.Time) ([]*Department, error) {
+	date := on.Format("2006-01-02")
+	var depts []*Department
+	req := g.db.WithContext(ctx).
+		Table("department_history").
+		Select("department, parent_id, name").
+		Where("valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", date, date).
+		Order("department").
+		Find(&depts)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query the departments history: %w", err)
+	}
+	return depts, nil
+}
+
+func (g *gormDB) ListMembers(ctx context.Context, on time.Time) ([]*Member, error) {
+	date := on.Format("2006-01-02")
+	var members []*Member
+	req := g.db.WithContext(ctx).Raw(
+		`SELECT a.employee_id, e.first_name, e.last_name, a.department, a.position, a.manager_id, a.valid_from
+		FROM employee_assignments a
+		JOIN employees e ON e.id = a.employee_id
+		WHERE a.valid_from <= ? AND (a.valid_to IS NULL OR a.valid_to > ?)
+		ORDER BY e.last_name, e.first_name, a.employee_id`,
+		date,
+		date,
+	).Scan(&members)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query the assignments: %w", err)
+	}
+	return members, nil
+}
+
+func (g *gormDB) Close() {
+	database.CloseGorm(g.db)
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+type ContextKey int
+
+const ContextKeyDB ContextKey = iota + 1
+
+// Department is a department as it was on a date.
+type Department struct {
+	ID       int    `gorm:"column:department" json:"id"`
+	ParentID int    `gorm:"column:parent_id" json:"parent_id"`
+	Name     string `gorm:"column:name" json:"name"`
+}
+
+// Member is an employee's assignment on a date.
+type Member struct {
+	EmployeeID int       `gorm:"column:employee_id" json:"employee_id"`
+	FirstName  string    `gorm:"column:first_name" json:"first_name"`
+	LastName   string    `gorm:"column:last_name" json:"last_name"`
+	Department int       `gorm:"column:department" json:"department"`
+	Position   int       `gorm:"column:position" json:"position"`
+	ManagerID  int       `gorm:"column:manager_id" json:"manager_id"`
+	Since      time.Time `gorm:"column:valid_from" json:"since"`
+}
+
+type DB interface {
+	// ListDepartments returns the departments that existed on the date
+	// ordered by ID.
+	ListDepartments(ctx context.Context, on time.Time) ([]*Department, error)
+	// ListMembers returns the assignments of the employees who worked on the
+	// date ordered by the last name.
+	ListMembers(ctx context.Context, on time.Time) ([]*Member, error)
+	Close()
+}
+
+func NewDB(connStr *database.ConnString) (DB, error) {
+	gormDB, err := newGormDB(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a gorm connection: %w", err)
+	}
+	return gormDB, nil
+}
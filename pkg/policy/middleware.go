@@ -0,0 +1,57 @@
+package policy
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+)
+
+// Require rejects with 403 the requests of the actors who may not access the
+// object. Only the roles are checked, the relations to the employees are up
+// to the services.
+func Require(object string, action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := NewActor(auth.FromContext(r.Context()), nil).Check(object, action, 0); err != nil {
+				log.Printf("[WARN]: %v", err)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireByMethod is Require with the read access for the GET and HEAD
+// requests and the write one for the others.
+func RequireByMethod(object string) func(http.Handler) http.Handler {
+	read, write := Require(object, ActionRead), Require(object, ActionWrite)
+	return func(next http.Handler) http.Handler {
+		readNext, writeNext := read(next), write(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" || r.Method == "HEAD" {
+				readNext.ServeHTTP(w, r)
+				return
+			}
+			writeNext.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAnywhere is Require that also lets through the actors who hold the
+// roles only in some departments. The handler must check the departments
+// with CheckIn.
+func RequireAnywhere(object string, action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			a := NewActor(auth.FromContext(r.Context()), nil)
+			if !a.canAnywhere(object, action) {
+				log.Printf("[WARN]: %v", a.Check(object, action, 0))
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
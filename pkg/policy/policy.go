@@ -0,0 +1,328 @@
+// Package policy decides who may read and write what. The rules grant the
+// accesses to the objects, entities or their sensitive fields, by the roles
+// of the caller and by the caller's relation to the employee the object
+// belongs to. A role may be granted only in a department subtree, then it
+// counts only for the objects of the departments of the subtree.
+package policy
+
+import (
+	"fmt"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
+)
+
+var ErrForbidden = fmt.Errorf("access denied")
+
+// The roles of the callers, given by the roles claim of their tokens. Every
+// caller who is an employee has the employee role.
+const (
+	RoleEmployee = "employee"
+	RoleManager  = "manager"
+	RoleHR       = "hr"
+	RoleFinance  = "finance"
+	RoleAdmin    = "admin"
+)
+
+// The relations of the caller to the employee an object belongs to, granted
+// accesses like the roles.
+const (
+	// RelationSelf is the employee themself.
+	RelationSelf = "self"
+	// RelationChain is a direct or indirect manager of the employee.
+	RelationChain = "chain"
+)
+
+// The objects the accesses are granted to.
+const (
+	EntityEmployee     = "employee"
+	EntityContacts     = "contacts"
+	EntityAssignment   = "assignment"
+	EntityTermination  = "termination"
+	EntitySalary       = "salary"
+	EntityDirectory    = "directory"
+	EntityPhoneLookups = "phone_lookups"
+	EntityPosition     = "position"
+	EntityBudget       = "budget"
+	EntityRates        = "rates"
+	EntityOrg          = "org"
+	EntityReorg        = "reorg"
+	EntityAudit        = "audit"
+	EntityRoleGrant    = "role_grant"
+
+	// FieldSalary is the salary of an employee and of the salary changes.
+	FieldSalary = EntitySalary
+	// FieldSalaryBands are the salary bands of a position.
+	FieldSalaryBands = "salary_bands"
+	// FieldPayroll are the payrolls of the budget reports and alerts, the
+	// salaries summed up by department.
+	FieldPayroll = "payroll"
+)
+
+// The accesses to an object.
+const (
+	ActionRead  = "read"
+	ActionWrite = "write"
+)
+
+var everyone = []string{RoleEmployee, RoleManager, RoleHR, RoleFinance, RoleAdmin}
+
+// rule grants the accesses to an object to the roles and the relations. The
+// API keys are granted the accesses by the scopes of the resource, a
+// sensitive object needs the write scope to be read.
+type rule struct {
+	read      []string
+	write     []string
+	resource  string
+	sensitive bool
+}
+
+var rules = map[string]*rule{
+	EntityEmployee: {
+		read:     everyone,
+		write:    []string{RoleHR, RoleAdmin},
+		resource: auth.ResourceEmployees,
+	},
+	EntityContacts: {
+		read:     everyone,
+		write:    []string{RelationSelf, RoleHR, RoleAdmin},
+		resource: auth.ResourceEmployees,
+	},
+	EntityAssignment: {
+		read:     everyone,
+		write:    []string{RoleHR, RoleAdmin},
+		resource: auth.ResourceEmployees,
+	},
+	EntityTermination: {
+		read:     []string{RoleManager, RoleHR, RoleAdmin},
+		write:    []string{RoleHR, RoleAdmin},
+		resource: auth.ResourceEmployees,
+	},
+	EntitySalary: {
+		read:      []string{RelationSelf, RelationChain, RoleHR, RoleAdmin},
+		write:     []string{RoleHR, RoleAdmin},
+		resource:  auth.ResourceEmployees,
+		sensitive: true,
+	},
+	EntityDirectory: {
+		read:     everyone,
+		resource: auth.ResourceDirectory,
+	},
+	EntityPhoneLookups: {
+		read:     []string{RelationSelf, RoleHR, RoleAdmin},
+		resource: auth.ResourceAudit,
+	},
+	EntityPosition: {
+		read:     everyone,
+		write:    []string{RoleHR, RoleAdmin},
+		resource: auth.ResourcePositions,
+	},
+	FieldSalaryBands: {
+		read:      []string{RoleHR, RoleFinance, RoleAdmin},
+		write:     []string{RoleHR, RoleAdmin},
+		resource:  auth.ResourcePositions,
+		sensitive: true,
+	},
+	EntityBudget: {
+		read:     []string{RoleManager, RoleHR, RoleFinance, RoleAdmin},
+		write:    []string{RoleFinance, RoleAdmin},
+		resource: auth.ResourceBudget,
+	},
+	// the managers see the budgets and their statuses, not the payrolls
+	FieldPayroll: {
+		read:      []string{RoleHR, RoleFinance, RoleAdmin},
+		resource:  auth.ResourceBudget,
+		sensitive: true,
+	},
+	EntityRates: {
+		read:     everyone,
+		write:    []string{RoleFinance, RoleAdmin},
+		resource: auth.ResourceRates,
+	},
+	EntityOrg: {
+		read:     everyone,
+		resource: auth.ResourceOrg,
+	},
+	EntityReorg: {
+		read:     []string{RoleHR, RoleAdmin},
+		write:    []string{RoleHR, RoleAdmin},
+		resource: auth.ResourceReorgs,
+	},
+	EntityAudit: {
+		read:     []string{RoleAdmin},
+		resource: auth.ResourceAudit,
+	},
+	// the API keys have no resource to manage the grants
+	EntityRoleGrant: {
+		read:  []string{RoleAdmin},
+		write: []string{RoleAdmin},
+	},
+}
+
+// Actor is the caller whose accesses are checked. The zero Actor is an
+// anonymous caller who may do nothing.
+type Actor struct {
+	principal *auth.Principal
+	roles     map[string]struct{}
+	// reports are the employees the caller manages directly or indirectly,
+	// nil if they are not known and no relation to a manager holds.
+	reports map[int]struct{}
+}
+
+// NewActor returns the principal as an actor. The viewer gives the reports of
+// the principal's employee, a nil viewer leaves the management chain unknown.
+func NewActor(p *auth.Principal, v *visibility.Viewer) *Actor {
+	a := &Actor{
+		principal: p,
+		roles:     make(map[string]struct{}),
+	}
+	if p == nil {
+		return a
+	}
+	for _, r := range p.Roles {
+		a.roles[r] = struct{}{}
+	}
+	if p.EmployeeID != 0 {
+		a.roles[RoleEmployee] = struct{}{}
+	}
+	if v != nil && v.EmployeeID == p.EmployeeID {
+		a.reports = v.Reports
+	}
+	return a
+}
+
+// EmployeeID returns the employee the actor is, zero if none.
+func (a *Actor) EmployeeID() int {
+	if a.principal == nil {
+		return 0
+	}
+	return a.principal.EmployeeID
+}
+
+// Can tells if the actor may access the object. The object belongs to the
+// employee, the zero employeeID stands for an object of nobody, so that only
+// the roles are checked.
+func (a *Actor) Can(object string, action string, employeeID int) bool {
+	r, ok := rules[object]
+	if !ok || a.principal == nil {
+		return false
+	}
+	if a.principal.APIKeyID != 0 {
+		if len(r.resource) == 0 {
+			return false
+		}
+		access := auth.AccessRead
+		if action == ActionWrite || r.sensitive {
+			access = auth.AccessWrite
+		}
+		return a.principal.HasAccess(r.resource, access)
+	}
+	granted := r.read
+	if action == ActionWrite {
+		granted = r.write
+	}
+	for _, g := range granted {
+		if a.holds(g, employeeID) {
+			return true
+		}
+	}
+	return false
+}
+
+// holds tells if the actor has the role or the relation to the employee.
+func (a *Actor) holds(grant string, employeeID int) bool {
+	switch grant {
+	case RelationSelf:
+		return employeeID != 0 && a.principal.EmployeeID == employeeID
+	case RelationChain:
+		_, ok := a.reports[employeeID]
+		return employeeID != 0 && ok
+	}
+	_, ok := a.roles[grant]
+	return ok
+}
+
+// CanIn tells if the actor may access the object of the employee in all the
+// departments, e.g. the department an employee leaves and the one they join.
+// The roles held only in some departments count in the departments of their
+// grants and in their descendants.
+func (a *Actor) CanIn(object string, action string, employeeID int, departments ...int) bool {
+	if a.Can(object, action, employeeID) {
+		return true
+	}
+	r, ok := rules[object]
+	if !ok || a.principal == nil || a.principal.APIKeyID != 0 || len(departments) == 0 {
+		return false
+	}
+	granted := r.read
+	if action == ActionWrite {
+		granted = r.write
+	}
+	for _, d := range departments {
+		if !a.holdsIn(granted, d) {
+			return false
+		}
+	}
+	return true
+}
+
+// canAnywhere tells if the actor may access the object at least in some
+// department.
+func (a *Actor) canAnywhere(object string, action string) bool {
+	if a.Can(object, action, 0) {
+		return true
+	}
+	r, ok := rules[object]
+	if !ok || a.principal == nil || a.principal.APIKeyID != 0 {
+		return false
+	}
+	granted := r.read
+	if action == ActionWrite {
+		granted = r.write
+	}
+	for _, g := range granted {
+		if len(a.principal.DepartmentRoles[g]) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// holdsIn tells if the actor holds one of the roles in the department.
+func (a *Actor) holdsIn(roles []string, department int) bool {
+	for _, r := range roles {
+		if _, ok := a.principal.DepartmentRoles[r][department]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns ErrForbidden if the actor may not access the object of the
+// employee.
+func (a *Actor) Check(object string, action string, employeeID int) error {
+	if a.Can(object, action, employeeID) {
+		return nil
+	}
+	subject := "an anonymous caller"
+	if a.principal != nil {
+		subject = fmt.Sprintf("%q", a.principal.Subject)
+	}
+	if employeeID != 0 {
+		return fmt.Errorf("%w: %s may not %s the %s of employee %d", ErrForbidden, subject, action, object, employeeID)
+	}
+	return fmt.Errorf("%w: %s may not %s the %s", ErrForbidden, subject, action, object)
+}
+
+// CheckIn returns ErrForbidden if the actor may not access the object of the
+// employee in all the departments.
+func (a *Actor) CheckIn(object string, action string, employeeID int, departments ...int) error {
+	if a.CanIn(object, action, employeeID, departments...) {
+		return nil
+	}
+	subject := "an anonymous caller"
+	if a.principal != nil {
+		subject = fmt.Sprintf("%q", a.principal.Subject)
+	}
+	return fmt.Errorf("%w: %s may not %s the %s in departments %v", ErrForbidden, subject, action, object, departments)
+}
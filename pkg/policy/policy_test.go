@@ -0,0 +1,421 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
+)
+
+func TestCan(t *testing.T) {
+	employee := &auth.Principal{Subject: "dcooper", EmployeeID: 3}
+	manager := &auth.Principal{Subject: "gcole", EmployeeID: 1, Roles: []string{RoleManager}}
+	managerViewer := &visibility.Viewer{EmployeeID: 1, Reports: map[int]struct{}{3: {}}}
+	cases := []struct {
+		Principal  *auth.Principal
+		Viewer     *visibility.Viewer
+		Object     string
+		Action     string
+		EmployeeID int
+		Expected   bool
+	}{
+		// the employees read the employees but do not change them
+		{
+			Principal:  employee,
+			Object:     EntityEmployee,
+			Action:     ActionRead,
+			EmployeeID: 4,
+			Expected:   true,
+		},
+		{
+			Principal:  employee,
+			Object:     EntityEmployee,
+			Action:     ActionWrite,
+			EmployeeID: 3,
+		},
+		{
+			Principal:  &auth.Principal{Subject: "hr", Roles: []string{RoleHR}},
+			Object:     EntityEmployee,
+			Action:     ActionWrite,
+			EmployeeID: 3,
+			Expected:   true,
+		},
+		// the salary is seen by the employee, the management chain and HR
+		{
+			Principal:  employee,
+			Object:     FieldSalary,
+			Action:     ActionRead,
+			EmployeeID: 3,
+			Expected:   true,
+		},
+		{
+			Principal:  employee,
+			Object:     FieldSalary,
+			Action:     ActionRead,
+			EmployeeID: 4,
+		},
+		{
+			Principal:  manager,
+			Viewer:     managerViewer,
+			Object:     FieldSalary,
+			Action:     ActionRead,
+			EmployeeID: 3,
+			Expected:   true,
+		},
+		{
+			Principal:  manager,
+			Viewer:     managerViewer,
+			Object:     FieldSalary,
+			Action:     ActionRead,
+			EmployeeID: 4,
+		},
+		// the chain is unknown without the viewer
+		{
+			Principal:  manager,
+			Object:     FieldSalary,
+			Action:     ActionRead,
+			EmployeeID: 3,
+		},
+		// the viewer of another employee gives no chain
+		{
+			Principal:  &auth.Principal{Subject: "bob", EmployeeID: 2, Roles: []string{RoleManager}},
+			Viewer:     managerViewer,
+			Object:     FieldSalary,
+			Action:     ActionRead,
+			EmployeeID: 3,
+		},
+		{
+			Principal:  manager,
+			Viewer:     managerViewer,
+			Object:     FieldSalary,
+			Action:     ActionWrite,
+			EmployeeID: 3,
+		},
+		{
+			Principal:  &auth.Principal{Subject: "hr", Roles: []string{RoleHR}},
+			Object:     FieldSalary,
+			Action:     ActionRead,
+			EmployeeID: 3,
+			Expected:   true,
+		},
+		{
+			Principal:  &auth.Principal{Subject: "finance", Roles: []string{RoleFinance}},
+			Object:     FieldSalary,
+			Action:     ActionRead,
+			EmployeeID: 3,
+		},
+		// an object of nobody is not the caller's own
+		{
+			Principal: employee,
+			Object:    FieldSalary,
+			Action:    ActionRead,
+		},
+		{
+			Principal: &auth.Principal{Subject: "finance", Roles: []string{RoleFinance}},
+			Object:    FieldSalaryBands,
+			Action:    ActionRead,
+			Expected:  true,
+		},
+		{
+			Principal: manager,
+			Object:    FieldSalaryBands,
+			Action:    ActionRead,
+		},
+		{
+			Principal: &auth.Principal{Subject: "finance", Roles: []string{RoleFinance}},
+			Object:    EntityBudget,
+			Action:    ActionWrite,
+			Expected:  true,
+		},
+		{
+			Principal: employee,
+			Object:    EntityBudget,
+			Action:    ActionRead,
+		},
+		{
+			Principal: &auth.Principal{Subject: "finance", Roles: []string{RoleFinance}},
+			Object:    FieldPayroll,
+			Action:    ActionRead,
+			Expected:  true,
+		},
+		{
+			Principal: manager,
+			Object:    FieldPayroll,
+			Action:    ActionRead,
+		},
+		{
+			Principal: &auth.Principal{Subject: "root", Roles: []string{RoleAdmin}},
+			Object:    EntityAudit,
+			Action:    ActionRead,
+			Expected:  true,
+		},
+		// nobody writes the read-only objects
+		{
+			Principal: &auth.Principal{Subject: "root", Roles: []string{RoleAdmin}},
+			Object:    EntityAudit,
+			Action:    ActionWrite,
+		},
+		// the callers who are not employees have only their roles
+		{
+			Principal: &auth.Principal{Subject: "ci"},
+			Object:    EntityDirectory,
+			Action:    ActionRead,
+		},
+		{
+			Object: EntityDirectory,
+			Action: ActionRead,
+		},
+		{
+			Principal: &auth.Principal{Subject: "root", Roles: []string{RoleAdmin}},
+			Object:    "bonus",
+			Action:    ActionRead,
+		},
+		// the API keys are granted the accesses by their scopes
+		{
+			Principal:  &auth.Principal{Subject: "api-key:bot", APIKeyID: 1, Scopes: []string{"employees:read"}},
+			Object:     EntityEmployee,
+			Action:     ActionRead,
+			EmployeeID: 3,
+			Expected:   true,
+		},
+		{
+			Principal:  &auth.Principal{Subject: "api-key:bot", APIKeyID: 1, Scopes: []string{"employees:read"}},
+			Object:     FieldSalary,
+			Action:     ActionRead,
+			EmployeeID: 3,
+		},
+		{
+			Principal:  &auth.Principal{Subject: "api-key:bot", APIKeyID: 1, Scopes: []string{"employees:write"}},
+			Object:     FieldSalary,
+			Action:     ActionRead,
+			EmployeeID: 3,
+			Expected:   true,
+		},
+		{
+			Principal: &auth.Principal{Subject: "api-key:bot", APIKeyID: 1, Scopes: []string{"employees:write"}},
+			Object:    EntityBudget,
+			Action:    ActionRead,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			a := NewActor(tc.Principal, tc.Viewer)
+			if can := a.Can(tc.Object, tc.Action, tc.EmployeeID); can != tc.Expected {
+				t.Errorf("expected %v, got %v", tc.Expected, can)
+			}
+			err := a.Check(tc.Object, tc.Action, tc.EmployeeID)
+			if tc.Expected && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tc.Expected && !errors.Is(err, ErrForbidden) {
+				t.Errorf("expected error %v, got %v", ErrForbidden, err)
+			}
+		})
+	}
+}
+
+func TestCanIn(t *testing.T) {
+	partner := &auth.Principal{
+		Subject:         "hr-partner",
+		EmployeeID:      9,
+		DepartmentRoles: map[string]map[int]struct{}{RoleHR: {2: {}, 4: {}, 5: {}}},
+	}
+	cases := []struct {
+		Principal   *auth.Principal
+		Object      string
+		Action      string
+		EmployeeID  int
+		Departments []int
+		Expected    bool
+	}{
+		{
+			Principal:   partner,
+			Object:      EntityEmployee,
+			Action:      ActionWrite,
+			Departments: []int{4},
+			Expected:    true,
+		},
+		// a move must stay within the subtree
+		{
+			Principal:   partner,
+			Object:      EntityAssignment,
+			Action:      ActionWrite,
+			EmployeeID:  3,
+			Departments: []int{2, 5},
+			Expected:    true,
+		},
+		{
+			Principal:   partner,
+			Object:      EntityAssignment,
+			Action:      ActionWrite,
+			EmployeeID:  3,
+			Departments: []int{2, 6},
+		},
+		{
+			Principal: partner,
+			Object:    EntityEmployee,
+			Action:    ActionWrite,
+		},
+		{
+			Principal:   partner,
+			Object:      EntityBudget,
+			Action:      ActionWrite,
+			Departments: []int{4},
+		},
+		// the relations hold in any department
+		{
+			Principal:   partner,
+			Object:      EntityContacts,
+			Action:      ActionWrite,
+			EmployeeID:  9,
+			Departments: []int{7},
+			Expected:    true,
+		},
+		// the company-wide roles hold in every department
+		{
+			Principal:   &auth.Principal{Subject: "hr", Roles: []string{RoleHR}},
+			Object:      EntityEmployee,
+			Action:      ActionWrite,
+			Departments: []int{6},
+			Expected:    true,
+		},
+		{
+			Principal:   &auth.Principal{Subject: "api-key:bot", APIKeyID: 1, Scopes: []string{"employees:write"}},
+			Object:      EntityEmployee,
+			Action:      ActionWrite,
+			Departments: []int{6},
+			Expected:    true,
+		},
+		{
+			Principal:   &auth.Principal{Subject: "api-key:bot", APIKeyID: 1, Scopes: []string{"employees:write"}},
+			Object:      EntityRoleGrant,
+			Action:      ActionWrite,
+			Departments: []int{6},
+		},
+		{
+			Object:      EntityEmployee,
+			Action:      ActionWrite,
+			Departments: []int{4},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			a := NewActor(tc.Principal, nil)
+			if can := a.CanIn(tc.Object, tc.Action, tc.EmployeeID, tc.Departments...); can != tc.Expected {
+				t.Errorf("expected %v, got %v", tc.Expected, can)
+			}
+			err := a.CheckIn(tc.Object, tc.Action, tc.EmployeeID, tc.Departments...)
+			if !tc.Expected && !errors.Is(err, ErrForbidden) {
+				t.Errorf("expected error %v, got %v", ErrForbidden, err)
+			}
+		})
+	}
+}
+
+func TestRequireByMethod(t *testing.T) {
+	handler := RequireByMethod(EntityBudget)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	cases := []struct {
+		Principal        *auth.Principal
+		Method           string
+		ExpectedRespCode int
+	}{
+		{
+			Principal:        &auth.Principal{Subject: "gcole", EmployeeID: 1, Roles: []string{RoleManager}},
+			Method:           "GET",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Principal:        &auth.Principal{Subject: "gcole", EmployeeID: 1, Roles: []string{RoleManager}},
+			Method:           "PUT",
+			ExpectedRespCode: http.StatusForbidden,
+		},
+		{
+			Principal:        &auth.Principal{Subject: "finance", Roles: []string{RoleFinance}},
+			Method:           "PUT",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Principal:        &auth.Principal{Subject: "dcooper", EmployeeID: 3},
+			Method:           "GET",
+			ExpectedRespCode: http.StatusForbidden,
+		},
+		{
+			Method:           "GET",
+			ExpectedRespCode: http.StatusForbidden,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			req, err := http.NewRequest(tc.Method, "/budgets", nil)
+			if err != nil {
+				t.Fatalf("failed to create an http request: %v", err)
+			}
+			if tc.Principal != nil {
+				req = req.WithContext(auth.NewContext(req.Context(), tc.Principal))
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tc.ExpectedRespCode {
+				t.Errorf("expected code: %d, got: %d", tc.ExpectedRespCode, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRequireAnywhere(t *testing.T) {
+	handler := RequireAnywhere(EntityEmployee, ActionWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	cases := []struct {
+		Principal        *auth.Principal
+		ExpectedRespCode int
+	}{
+		{
+			Principal:        &auth.Principal{Subject: "hr", Roles: []string{RoleHR}},
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Principal: &auth.Principal{
+				Subject:         "hr-partner",
+				DepartmentRoles: map[string]map[int]struct{}{RoleHR: {2: {}}},
+			},
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Principal: &auth.Principal{
+				Subject:         "finance-partner",
+				DepartmentRoles: map[string]map[int]struct{}{RoleFinance: {2: {}}},
+			},
+			ExpectedRespCode: http.StatusForbidden,
+		},
+		{
+			ExpectedRespCode: http.StatusForbidden,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/employees", nil)
+			if err != nil {
+				t.Fatalf("failed to create an http request: %v", err)
+			}
+			if tc.Principal != nil {
+				req = req.WithContext(auth.NewContext(req.Context(), tc.Principal))
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tc.ExpectedRespCode {
+				t.Errorf("expected code: %d, got: %d", tc.ExpectedRespCode, rr.Code)
+			}
+		})
+	}
+}
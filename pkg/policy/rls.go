@@ -0,0 +1,66 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
+)
+
+// AppRole is the DB role the connections of the requests run as, the
+// row-level security policies restrict it to the rows of the caller.
+const AppRole = "gopher_app"
+
+// The run-time parameters the row-level security policies of the DB read
+// the caller from.
+const (
+	SessionRole              = "role"
+	SessionUserID            = "app.user_id"
+	SessionEmployeeID        = "app.employee_id"
+	SessionRoles             = "app.roles"
+	SessionDepartments       = "app.departments"
+	SessionScopes            = "app.scopes"
+	SessionDefaultVisibility = "app.default_visibility"
+)
+
+// SessionParams returns the run-time parameters that run the connections as
+// AppRole and restrict them to the rows the principal may see and change. A
+// nil principal is restricted to nothing.
+func SessionParams(p *auth.Principal) map[string]string {
+	params := map[string]string{
+		SessionRole:              AppRole,
+		SessionUserID:            "",
+		SessionEmployeeID:        "0",
+		SessionRoles:             "",
+		SessionDepartments:       "",
+		SessionScopes:            "",
+		SessionDefaultVisibility: visibility.Default(),
+	}
+	if p == nil {
+		return params
+	}
+	params[SessionUserID] = p.Subject
+	params[SessionEmployeeID] = strconv.Itoa(p.EmployeeID)
+	if p.APIKeyID != 0 {
+		params[SessionScopes] = strings.Join(p.Scopes, ",")
+		return params
+	}
+	roles := make([]string, 0, len(p.Roles)+1)
+	for r := range NewActor(p, nil).roles {
+		roles = append(roles, r)
+	}
+	sort.Strings(roles)
+	params[SessionRoles] = strings.Join(roles, ",")
+	departments := make([]string, 0)
+	for r, ds := range p.DepartmentRoles {
+		for d := range ds {
+			departments = append(departments, fmt.Sprintf("%s:%d", r, d))
+		}
+	}
+	sort.Strings(departments)
+	params[SessionDepartments] = strings.Join(departments, ",")
+	return params
+}
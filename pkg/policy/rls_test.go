@@ -0,0 +1,83 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/visibility"
+)
+
+func TestSessionParams(t *testing.T) {
+	cases := []struct {
+		Principal         *auth.Principal
+		DefaultVisibility string
+		ExpectedParams    map[string]string
+	}{
+		{
+			Principal: &auth.Principal{
+				Subject:    "hr-partner",
+				Roles:      []string{RoleManager},
+				EmployeeID: 7,
+				DepartmentRoles: map[string]map[int]struct{}{
+					RoleHR:      {3: {}, 12: {}},
+					RoleFinance: {3: {}},
+				},
+			},
+			DefaultVisibility: visibility.Public,
+			ExpectedParams: map[string]string{
+				SessionRole:              AppRole,
+				SessionUserID:            "hr-partner",
+				SessionEmployeeID:        "7",
+				SessionRoles:             "employee,manager",
+				SessionDepartments:       "finance:3,hr:12,hr:3",
+				SessionScopes:            "",
+				SessionDefaultVisibility: visibility.Public,
+			},
+		},
+		{
+			Principal: &auth.Principal{
+				Subject:  "api-key:payroll-bot",
+				Roles:    []string{RoleAdmin},
+				APIKeyID: 2,
+				Scopes:   []string{"budget:read", "employees:write"},
+			},
+			DefaultVisibility: visibility.Public,
+			ExpectedParams: map[string]string{
+				SessionRole:              AppRole,
+				SessionUserID:            "api-key:payroll-bot",
+				SessionEmployeeID:        "0",
+				SessionRoles:             "",
+				SessionDepartments:       "",
+				SessionScopes:            "budget:read,employees:write",
+				SessionDefaultVisibility: visibility.Public,
+			},
+		},
+		{
+			DefaultVisibility: visibility.Department,
+			ExpectedParams: map[string]string{
+				SessionRole:              AppRole,
+				SessionUserID:            "",
+				SessionEmployeeID:        "0",
+				SessionRoles:             "",
+				SessionDepartments:       "",
+				SessionScopes:            "",
+				SessionDefaultVisibility: visibility.Department,
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			defer visibility.SetDefault(visibility.Default())
+			if err := visibility.SetDefault(tc.DefaultVisibility); err != nil {
+				t.Fatalf("failed to set the default visibility: %v", err)
+			}
+			params := SessionParams(tc.Principal)
+			if !reflect.DeepEqual(params, tc.ExpectedParams) {
+				t.Errorf("expected params %v, got %v", tc.ExpectedParams, params)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/positions/service"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/positions/storage"
+)
+
+func ListPositions(w http.ResponseWriter, r *http.Request) {
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	positions, err := service.ListPositions(db, auth.FromContext(r.Context()))
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, positions)
+}
+
+func GetPosition(w http.ResponseWriter, r *http.Request, positionID string) {
+	id, err := strconv.Atoi(positionID)
+	if err != nil {
+		log.Printf("incorrect position ID %q: %v", positionID, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	p, err := service.GetPosition(db, auth.FromContext(r.Context()), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func CreatePosition(w http.ResponseWriter, r *http.Request) {
+	p := &storage.Position{}
+	if err := json.NewDecoder(r.Body).Decode(p); err != nil {
+		log.Printf("failed to decode the position: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	created, err := service.CreatePosition(db, p)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func UpdatePosition(w http.ResponseWriter, r *http.Request, positionID string) {
+	id, err := strconv.Atoi(positionID)
+	if err != nil {
+		log.Printf("incorrect position ID %q: %v", positionID, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	p := &storage.Position{}
+	if err := json.NewDecoder(r.Body).Decode(p); err != nil {
+		log.Printf("failed to decode the position: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	p.ID = id
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	updated, err := service.UpdatePosition(db, p)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func DeletePosition(w http.ResponseWriter, r *http.Request, positionID string) {
+	id, err := strconv.Atoi(positionID)
+	if err != nil {
+		log.Printf("incorrect position ID %q: %v", positionID, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	if err := service.DeletePosition(db, id); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getDB(w http.ResponseWriter, r *http.Request) (storage.DB, bool) {
+	dbIface := r.Context().Value(storage.ContextKeyDB)
+	if dbIface == nil {
+		log.Println("DB is not found in the request context")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	db, ok := dbIface.(storage.DB)
+	if !ok {
+		log.Println("DB in the request context is not of type storage.DB")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return db, true
+}
+
+func writeErr(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, service.ErrIncorrectPosition):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, policy.ErrForbidden):
+		w.WriteHeader(http.StatusForbidden)
+	case errors.Is(err, service.ErrPositionNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, service.ErrPositionConflict):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("failed to serialize the response to JSON: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(resp); err != nil {
+		log.Printf("failed to write the response body: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/policy"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/positions/storage"
+)
+
+var (
+	ErrIncorrectPosition = fmt.Errorf("got an incorrect position")
+	ErrPositionNotFound  = fmt.Errorf("position not found")
+	ErrPositionConflict  = fmt.Errorf("position conflicts with the existing data")
+	ErrDBRequestFailed   = fmt.Errorf("a request to DB failed")
+)
+
+// ListPositions returns the positions, without the salary bands for the
+// callers who may not see them.
+func ListPositions(db storage.DB, caller *auth.Principal) ([]*storage.Position, error) {
+	positions, err := db.ListPositions(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list positions: %v", ErrDBRequestFailed, err)
+	}
+	hideSalaryBands(caller, positions...)
+	return positions, nil
+}
+
+// GetPosition returns the position, without the salary bands for the callers
+// who may not see them.
+func GetPosition(db storage.DB, caller *auth.Principal, id int) (*storage.Position, error) {
+	p, err := db.GetPosition(context.Background(), id)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to get the position")
+	}
+	hideSalaryBands(caller, p)
+	return p, nil
+}
+
+func CreatePosition(db storage.DB, p *storage.Position) (*storage.Position, error) {
+	if err := normalizePosition(p); err != nil {
+		return nil, err
+	}
+	created, err := db.CreatePosition(context.Background(), p)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to create the position")
+	}
+	return created, nil
+}
+
+func UpdatePosition(db storage.DB, p *storage.Position) (*storage.Position, error) {
+	if err := normalizePosition(p); err != nil {
+		return nil, err
+	}
+	updated, err := db.UpdatePosition(context.Background(), p)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to update the position")
+	}
+	return updated, nil
+}
+
+func DeletePosition(db storage.DB, id int) error {
+	if err := db.DeletePosition(context.Background(), id); err != nil {
+		return wrapDBErr(err, "failed to delete the position")
+	}
+	return nil
+}
+
+// SalaryWithinBand reports whether the salary lies in the [MinSalary,
+// MaxSalary] range of the band. The salary must be in the band's currency.
+func SalaryWithinBand(band *storage.SalaryBand, salary money.Money) (bool, error) {
+	if salary.Currency != band.Currency {
+		return false, fmt.Errorf("%w: the salary is in %s, the band is in %s", money.ErrCurrencyMismatch, salary.Currency, band.Currency)
+	}
+	return salary.Amount >= band.MinSalary && salary.Amount <= band.MaxSalary, nil
+}
+
+func hideSalaryBands(caller *auth.Principal, positions ...*storage.Position) {
+	if policy.NewActor(caller, nil).Can(policy.FieldSalaryBands, policy.ActionRead, 0) {
+		return
+	}
+	for _, p := range positions {
+		p.SalaryBands = nil
+	}
+}
+
+func normalizePosition(p *storage.Position) error {
+	p.Title = strings.TrimSpace(p.Title)
+	if len(p.Title) == 0 {
+		return fmt.Errorf("%w: the title is empty", ErrIncorrectPosition)
+	}
+	if p.Grade <= 0 {
+		return fmt.Errorf("%w: the grade must be positive, got %d", ErrIncorrectPosition, p.Grade)
+	}
+	seen := make(map[string]struct{}, len(p.SalaryBands))
+	for _, b := range p.SalaryBands {
+		b.Currency = strings.ToUpper(strings.TrimSpace(b.Currency))
+		if err := money.ValidateCurrency(b.Currency); err != nil {
+			return fmt.Errorf("%w: %v", ErrIncorrectPosition, err)
+		}
+		if _, ok := seen[b.Currency]; ok {
+			return fmt.Errorf("%w: duplicate salary band for %s", ErrIncorrectPosition, b.Currency)
+		}
+		seen[b.Currency] = struct{}{}
+		if b.MinSalary.Sign() <= 0 {
+			return fmt.Errorf("%w: %s band minimum must be positive", ErrIncorrectPosition, b.Currency)
+		}
+		if b.MinSalary > b.MaxSalary {
+			return fmt.Errorf("%w: %s band minimum exceeds its maximum", ErrIncorrectPosition, b.Currency)
+		}
+	}
+	return nil
+}
+
+func wrapDBErr(err error, msg string) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%w: %s: %v", ErrPositionNotFound, msg, err)
+	}
+	if errors.Is(err, storage.ErrConflict) {
+		return fmt.Errorf("%w: %s: %v", ErrPositionConflict, msg, err)
+	}
+	return fmt.Errorf("%w: %s: %v", ErrDBRequestFailed, msg, err)
+}
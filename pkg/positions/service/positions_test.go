@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/positions/storage"
+)
+
+func TestCreatePosition(t *testing.T) {
+	cases := []struct {
+		Position    *storage.Position
+		MockErr     error
+		ExpectedErr error
+	}{
+		{
+			Position: &storage.Position{
+				Title: "Backend Dev",
+				Grade: 2,
+				SalaryBands: []*storage.SalaryBand{
+					{Currency: "rub", MinSalary: 10000000, MaxSalary: 20000050},
+					{Currency: "USD", MinSalary: 150000, MaxSalary: 150000},
+				},
+			},
+		},
+		{
+			Position:    &storage.Position{Title: "  ", Grade: 1},
+			ExpectedErr: ErrIncorrectPosition,
+		},
+		{
+			Position:    &storage.Position{Title: "QA", Grade: 0},
+			ExpectedErr: ErrIncorrectPosition,
+		},
+		{
+			Position: &storage.Position{
+				Title:       "QA",
+				Grade:       1,
+				SalaryBands: []*storage.SalaryBand{{Currency: "RUBL", MinSalary: 100, MaxSalary: 200}},
+			},
+			ExpectedErr: ErrIncorrectPosition,
+		},
+		{
+			Position: &storage.Position{
+				Title: "QA",
+				Grade: 1,
+				SalaryBands: []*storage.SalaryBand{
+					{Currency: "RUB", MinSalary: 100, MaxSalary: 200},
+					{Currency: "rub", MinSalary: 100, MaxSalary: 200},
+				},
+			},
+			ExpectedErr: ErrIncorrectPosition,
+		},
+		{
+			Position: &storage.Position{
+				Title:       "QA",
+				Grade:       1,
+				SalaryBands: []*storage.SalaryBand{{Currency: "RUB", MinSalary: 300, MaxSalary: 200}},
+			},
+			ExpectedErr: ErrIncorrectPosition,
+		},
+		{
+			Position: &storage.Position{
+				Title:       "QA",
+				Grade:       1,
+				SalaryBands: []*storage.SalaryBand{{Currency: "RUB", MinSalary: 0, MaxSalary: 200}},
+			},
+			ExpectedErr: ErrIncorrectPosition,
+		},
+		{
+			Position:    &storage.Position{Title: "CTO", Grade: 5},
+			MockErr:     fmt.Errorf("%w: duplicate title", storage.ErrConflict),
+			ExpectedErr: ErrPositionConflict,
+		},
+		{
+			Position:    &storage.Position{Title: "CTO", Grade: 5},
+			MockErr:     fmt.Errorf("some err"),
+			ExpectedErr: ErrDBRequestFailed,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{
+				t:             t,
+				expectedError: tc.MockErr,
+			}
+			created, err := CreatePosition(mock, tc.Position)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				if mock.created != nil && tc.MockErr == nil {
+					t.Errorf("an incorrect position was passed to the DB")
+				}
+				return
+			}
+			for _, b := range created.SalaryBands {
+				if err := money.ValidateCurrency(b.Currency); err != nil {
+					t.Errorf("currency %q was not normalized", b.Currency)
+				}
+			}
+		})
+	}
+}
+
+func TestSalaryWithinBand(t *testing.T) {
+	band := &storage.SalaryBand{
+		Currency:  "RUB",
+		MinSalary: 10000000,
+		MaxSalary: 15000050,
+	}
+	cases := []struct {
+		Salary      money.Money
+		ExpectedOK  bool
+		ExpectedErr error
+	}{
+		{Salary: money.New(10000000, "RUB"), ExpectedOK: true},
+		{Salary: money.New(15000050, "RUB"), ExpectedOK: true},
+		{Salary: money.New(15000051, "RUB"), ExpectedOK: false},
+		{Salary: money.New(9999999, "RUB"), ExpectedOK: false},
+		{Salary: money.New(10000000, "USD"), ExpectedErr: money.ErrCurrencyMismatch},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			ok, err := SalaryWithinBand(band, tc.Salary)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if ok != tc.ExpectedOK {
+				t.Errorf("salary %v: expected within band: %v, got: %v", tc.Salary, tc.ExpectedOK, ok)
+			}
+		})
+	}
+}
+
+func compareErrs(expectedErr error, actualErr error) error {
+	if expectedErr == nil && actualErr == nil {
+		return nil
+	}
+	if actualErr == nil {
+		return fmt.Errorf("expected an error \"%v\", got nil", expectedErr)
+	}
+	if !errors.Is(actualErr, expectedErr) {
+		return fmt.Errorf("expected error \"%v\" and actual error \"%v\" are different", expectedErr, actualErr)
+	}
+	return nil
+}
+
+type dbMock struct {
+	t             *testing.T
+	expectedError error
+	created       *storage.Position
+}
+
+func (db *dbMock) ListPositions(ctx context.Context) ([]*storage.Position, error) {
+	return nil, db.expectedError
+}
+
+func (db *dbMock) GetPosition(ctx context.Context, id int) (*storage.Position, error) {
+	return nil, db.expectedError
+}
+
+func (db *dbMock) CreatePosition(ctx context.Context, p *storage.Position) (*storage.Position, error) {
+	db.created = p
+	if db.expectedError != nil {
+		return nil, db.expectedError
+	}
+	return p, nil
+}
+
+func (db *dbMock) UpdatePosition(ctx context.Context, p *storage.Position) (*storage.Position, error) {
+	return p, db.expectedError
+}
+
+func (db *dbMock) DeletePosition(ctx context.Context, id int) error {
+	return db.expectedError
+}
+
+func (db *dbMock) Close() {}
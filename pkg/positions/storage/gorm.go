@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+type gormDB struct {
+	db  *gorm.DB
+	src *auditStorage.Source
+}
+
+func newGormDB(c *database.ConnString, src *auditStorage.Source) (*gormDB, error) {
+	db, err := database.OpenGorm(c)
+	if err != nil {
+		return nil, err
+	}
+	return &gormDB{
+		db:  db,
+		src: src,
+	}, nil
+}
+
+func (g *gormDB) ListPositions(ctx context.Context) ([]*Position, error) {
+	var positions []*Position
+	if err := g.db.WithContext(ctx).Order("id").Find(&positions).Error; err != nil {
+		return nil, fmt.Errorf("failed to query positions: %w", err)
+	}
+	var bands []*SalaryBand
+	if err := g.db.WithContext(ctx).Order("position_id, currency").Find(&bands).Error; err != nil {
+		return nil, fmt.Errorf("failed to query salary bands: %w", err)
+	}
+	byPosition := make(map[int]*Position, len(positions))
+	for _, p := range positions {
+		p.SalaryBands = make([]*SalaryBand, 0)
+		byPosition[p.ID] = p
+	}
+	for _, b := range bands {
+		if p, ok := byPosition[b.PositionID]; ok {
+			p.SalaryBands = append(p.SalaryBands, b)
+		}
+	}
+	return positions, nil
+}
+
+func (g *gormDB) GetPosition(ctx context.Context, id int) (*Position, error) {
+	return getPosition(g.db.WithContext(ctx), id)
+}
+
+func (g *gormDB) CreatePosition(ctx context.Context, p *Position) (*Position, error) {
+	var created *Position
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		row := &Position{
+			Title: p.Title,
+			Grade: p.Grade,
+		}
+		if err := tx.Select("title", "grade").Create(row).Error; err != nil {
+			return wrapWriteErr(err, "failed to insert the position")
+		}
+		if err := insertSalaryBands(tx, row.ID, p.SalaryBands); err != nil {
+			return err
+		}
+		var err error
+		created, err = getPosition(tx, row.ID)
+		if err != nil {
+			return err
+		}
+		return auditStorage.Record(tx, g.src, &auditStorage.Change{
+			Action:     auditStorage.ActionCreate,
+			EntityType: auditStorage.EntityPosition,
+			EntityID:   created.ID,
+			After:      created,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (g *gormDB) UpdatePosition(ctx context.Context, p *Position) (*Position, error) {
+	var updated *Position
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := getPosition(tx.Clauses(clause.Locking{Strength: "UPDATE"}), p.ID)
+		if err != nil {
+			return err
+		}
+		req := tx.Model(&Position{}).
+			Where("id = ?", p.ID).
+			Updates(map[string]interface{}{
+				"title": p.Title,
+				"grade": p.Grade,
+			})
+		if err := req.Error; err != nil {
+			return wrapWriteErr(err, "failed to update the position")
+		}
+		if err := tx.Where("position_id = ?", p.ID).Delete(&SalaryBand{}).Error; err != nil {
+			return fmt.Errorf("failed to delete the old salary bands: %w", err)
+		}
+		if err := insertSalaryBands(tx, p.ID, p.SalaryBands); err != nil {
+			return err
+		}
+		updated, err = getPosition(tx, p.ID)
+		if err != nil {
+			return err
+		}
+		return auditStorage.Record(tx, g.src, &auditStorage.Change{
+			Action:     auditStorage.ActionUpdate,
+			EntityType: auditStorage.EntityPosition,
+			EntityID:   p.ID,
+			Before:     before,
+			After:      updated,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (g *gormDB) DeletePosition(ctx context.Context, id int) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := getPosition(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", id).Delete(&Position{}).Error; err != nil {
+			return wrapWriteErr(err, "failed to delete the position")
+		}
+		return auditStorage.Record(tx, g.src, &auditStorage.Change{
+			Action:     auditStorage.ActionDelete,
+			EntityType: auditStorage.EntityPosition,
+			EntityID:   id,
+			Before:     before,
+		})
+	})
+}
+
+func (g *gormDB) Close() {
+	database.CloseGorm(g.db)
+}
+
+func getPosition(db *gorm.DB, id int) (*Position, error) {
+	p := &Position{}
+	if err := db.Where("id = ?", id).Take(p).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: position %d", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to query the position: %w", err)
+	}
+	p.SalaryBands = make([]*SalaryBand, 0)
+	if err := db.Where("position_id = ?", id).Order("currency").Find(&p.SalaryBands).Error; err != nil {
+		return nil, fmt.Errorf("failed to query salary bands: %w", err)
+	}
+	return p, nil
+}
+
+func insertSalaryBands(tx *gorm.DB, positionID int, bands []*SalaryBand) error {
+	if len(bands) == 0 {
+		return nil
+	}
+	rows := make([]*SalaryBand, len(bands))
+	for i, b := range bands {
+		rows[i] = &SalaryBand{
+			PositionID: positionID,
+			Currency:   b.Currency,
+			MinSalary:  b.MinSalary,
+			MaxSalary:  b.MaxSalary,
+		}
+	}
+	if err := tx.Create(rows).Error; err != nil {
+		return wrapWriteErr(err, "failed to insert salary bands")
+	}
+	return nil
+}
+
+func wrapWriteErr(err error, msg string) error {
+	if database.IsUniqueViolation(err) || database.IsForeignKeyViolation(err) {
+		return fmt.Errorf("%w: %s: %v", ErrConflict, msg, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+)
+
+type ContextKey int
+
+const ContextKeyDB ContextKey = iota + 1
+
+var (
+	ErrNotFound = fmt.Errorf("not found")
+	ErrConflict = fmt.Errorf("conflicts with the existing data")
+)
+
+type Position struct {
+	ID          int           `gorm:"column:id" json:"id"`
+	Title       string        `gorm:"column:title" json:"title"`
+	Grade       int           `gorm:"column:grade" json:"grade"`
+	SalaryBands []*SalaryBand `gorm:"-" json:"salary_bands"`
+}
+
+// SalaryBand holds the allowed salary range of a position for a single
+// currency.
+type SalaryBand struct {
+	PositionID int          `gorm:"column:position_id" json:"-"`
+	Currency   string       `gorm:"column:currency" json:"currency"`
+	MinSalary  money.Amount `gorm:"column:min_salary" json:"min_salary"`
+	MaxSalary  money.Amount `gorm:"column:max_salary" json:"max_salary"`
+}
+
+func (SalaryBand) TableName() string {
+	return "position_salary_bands"
+}
+
+type DB interface {
+	ListPositions(ctx context.Context) ([]*Position, error)
+	GetPosition(ctx context.Context, id int) (*Position, error)
+	CreatePosition(ctx context.Context, p *Position) (*Position, error)
+	UpdatePosition(ctx context.Context, p *Position) (*Position, error)
+	DeletePosition(ctx context.Context, id int) error
+	Close()
+}
+
+// NewDB opens the storage, the changes made through it are recorded in the
+// audit log as made by src.
+func NewDB(connStr *database.ConnString, src *auditStorage.Source) (DB, error) {
+	gormDB, err := newGormDB(connStr, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a gorm connection: %w", err)
+	}
+	return gormDB, nil
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresStore keeps the buckets in the rate_limit_buckets table, so that
+// the instances of the service share the limits. The buckets are refilled by
+// the DB clock, so the clocks of the instances do not matter.
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+type postgresBucket struct {
+	Key       string `gorm:"primaryKey"`
+	Tokens    float64
+	UpdatedAt time.Time
+	// FullAt is when the bucket is full again and may be deleted.
+	FullAt time.Time
+}
+
+func (postgresBucket) TableName() string {
+	return "rate_limit_buckets"
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{
+		db: db,
+	}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, l Limit) (*Result, error) {
+	var res *Result
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var at time.Time
+		if err := tx.Raw("SELECT NOW()").Scan(&at).Error; err != nil {
+			return fmt.Errorf("failed to query the time: %w", err)
+		}
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&postgresBucket{
+			Key:       key,
+			Tokens:    float64(l.Burst),
+			UpdatedAt: at,
+			FullAt:    at,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to create the bucket: %w", err)
+		}
+		stored := &postgresBucket{}
+		req := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).Take(stored)
+		if err := req.Error; err != nil {
+			return fmt.Errorf("failed to query the bucket: %w", err)
+		}
+		b := &bucket{tokens: stored.Tokens, updated: stored.UpdatedAt}
+		res = b.take(l, at)
+		err = tx.Model(stored).Updates(map[string]interface{}{
+			"tokens":     b.tokens,
+			"updated_at": b.updated,
+			"full_at":    at.Add(res.Reset),
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update the bucket: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Run deletes the full buckets at once and then every interval until the
+// context is done.
+func (s *PostgresStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.cleanup(ctx); err != nil {
+			log.Printf("failed to delete the full rate limit buckets: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanup deletes the buckets that have refilled, as they are the same as
+// the missing ones.
+func (s *PostgresStore) cleanup(ctx context.Context) error {
+	req := s.db.WithContext(ctx).Where("full_at <= NOW()").Delete(&postgresBucket{})
+	if err := req.Error; err != nil {
+		return err
+	}
+	return nil
+}
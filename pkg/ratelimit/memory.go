@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the buckets in memory, so the limits are per instance.
+type MemoryStore struct {
+	mux         sync.Mutex
+	buckets     map[string]*memoryBucket
+	lastCleanup time.Time
+}
+
+type memoryBucket struct {
+	bucket
+	limit Limit
+}
+
+// memoryCleanupInterval is how often the full buckets are forgotten.
+const memoryCleanupInterval = time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:     make(map[string]*memoryBucket),
+		lastCleanup: now(),
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, l Limit) (*Result, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	at := now()
+	s.cleanup(at)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{bucket: *newBucket(l, at)}
+		s.buckets[key] = b
+	}
+	b.limit = l
+	return b.take(l, at), nil
+}
+
+// cleanup forgets the buckets that have refilled, as they are the same as
+// the missing ones.
+func (s *MemoryStore) cleanup(at time.Time) {
+	if at.Sub(s.lastCleanup) < memoryCleanupInterval {
+		return
+	}
+	s.lastCleanup = at
+	for key, b := range s.buckets {
+		if at.Sub(b.updated) >= b.limit.Period {
+			delete(s.buckets, key)
+		}
+	}
+}
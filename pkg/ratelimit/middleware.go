@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The kinds of the callers a route is limited for.
+const (
+	KindIP   = "ip"
+	KindUser = "user"
+	KindKey  = "key"
+)
+
+// Rule limits the requests of every caller of a kind separately.
+type Rule struct {
+	Kind  string
+	Limit Limit
+	// Caller identifies the caller of the request, the requests with no caller
+	// are not limited by the rule.
+	Caller func(r *http.Request) string
+}
+
+// ByIP identifies the caller by the remote address of the connection.
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ByHeader identifies the caller by the value of the request header.
+func ByHeader(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// NewMiddleware limits the requests to the route by the rules. The response
+// tells the state of the most exhausted bucket in the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers, and a request exceeding a
+// limit is answered with 429 and Retry-After. The requests are let through if
+// the store fails.
+func NewMiddleware(store Store, route string, rules []Rule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var tightest *Result
+			for _, rule := range rules {
+				caller := rule.Caller(r)
+				if len(caller) == 0 {
+					continue
+				}
+				res, err := store.Take(r.Context(), bucketKey(route, rule.Kind, caller), rule.Limit)
+				if err != nil {
+					log.Printf("[ERR]: failed to check the %s rate limit of %s: %v", rule.Kind, route, err)
+					continue
+				}
+				if tightest == nil || tighter(res, tightest) {
+					tightest = res
+				}
+			}
+			if tightest == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(tightest.Limit.Burst))
+			h.Set("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+			h.Set("RateLimit-Reset", seconds(tightest.Reset))
+			if !tightest.Allowed {
+				h.Set("Retry-After", seconds(tightest.RetryAfter))
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// tighter tells if a is more exhausted than b: a denial waiting longer or an
+// allowance with fewer tokens left.
+func tighter(a *Result, b *Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
+
+// bucketKey hashes the caller, so that the API keys are not stored.
+func bucketKey(route string, kind string, caller string) string {
+	sum := sha256.Sum256([]byte(caller))
+	return route + ":" + kind + ":" + hex.EncodeToString(sum[:16])
+}
+
+// seconds rounds the duration up to whole seconds.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrIncorrectLimit = fmt.Errorf("got an incorrect rate limit")
+
+// Limit is a token bucket holding up to Burst tokens and refilled with Burst
+// tokens every Period. A request takes a token.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// ParseLimit parses a limit given as "<burst>/<period>", e.g. "100/1m". The
+// period may be "s", "m" or "h" for one second, minute or hour.
+func ParseLimit(s string) (Limit, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("%w: %q is not <burst>/<period>", ErrIncorrectLimit, s)
+	}
+	burst, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return Limit{}, fmt.Errorf("%w: incorrect burst %q: %v", ErrIncorrectLimit, parts[0], err)
+	}
+	period := strings.TrimSpace(parts[1])
+	switch period {
+	case "s", "m", "h":
+		period = "1" + period
+	}
+	l := Limit{Burst: burst}
+	if l.Period, err = time.ParseDuration(period); err != nil {
+		return Limit{}, fmt.Errorf("%w: incorrect period %q: %v", ErrIncorrectLimit, parts[1], err)
+	}
+	if err := l.validate(); err != nil {
+		return Limit{}, err
+	}
+	return l, nil
+}
+
+func (l Limit) validate() error {
+	if l.Burst <= 0 || l.Period <= 0 {
+		return fmt.Errorf("%w: the burst and the period must be positive", ErrIncorrectLimit)
+	}
+	return nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Burst, l.Period)
+}
+
+// Result is the state of a bucket after a request tried to take a token.
+type Result struct {
+	Allowed   bool
+	Limit     Limit
+	Remaining int
+	// Reset is when the bucket is full again, RetryAfter when the next token
+	// is available.
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets by their keys.
+type Store interface {
+	// Take takes a token from the bucket of the key with the limit.
+	Take(ctx context.Context, key string, l Limit) (*Result, error)
+}
+
+var now = time.Now
+
+// bucket is a token bucket last refilled at updated.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newBucket(l Limit, at time.Time) *bucket {
+	return &bucket{
+		tokens:  float64(l.Burst),
+		updated: at,
+	}
+}
+
+// take refills the bucket up to the time and takes a token if there is one.
+func (b *bucket) take(l Limit, at time.Time) *Result {
+	rate := float64(l.Burst) / float64(l.Period)
+	if elapsed := at.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(float64(l.Burst), b.tokens+float64(elapsed)*rate)
+		b.updated = at
+	}
+	r := &Result{Limit: l}
+	if b.tokens >= 1 {
+		b.tokens--
+		r.Allowed = true
+	} else {
+		r.RetryAfter = time.Duration(math.Ceil((1 - b.tokens) / rate))
+	}
+	r.Remaining = int(b.tokens)
+	r.Reset = time.Duration(math.Ceil((float64(l.Burst) - b.tokens) / rate))
+	return r
+}
@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	cases := []struct {
+		Limit         string
+		ExpectedLimit Limit
+		ExpectedErr   error
+	}{
+		{
+			Limit:         "100/m",
+			ExpectedLimit: Limit{Burst: 100, Period: time.Minute},
+		},
+		{
+			Limit:         "5 / 10s",
+			ExpectedLimit: Limit{Burst: 5, Period: 10 * time.Second},
+		},
+		{
+			Limit:       "100",
+			ExpectedErr: ErrIncorrectLimit,
+		},
+		{
+			Limit:       "0/m",
+			ExpectedErr: ErrIncorrectLimit,
+		},
+		{
+			Limit:       "10/-1m",
+			ExpectedErr: ErrIncorrectLimit,
+		},
+		{
+			Limit:       "10/day",
+			ExpectedErr: ErrIncorrectLimit,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			l, err := ParseLimit(tc.Limit)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if l != tc.ExpectedLimit {
+				t.Errorf("expected limit %v, got %v", tc.ExpectedLimit, l)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	l := Limit{Burst: 2, Period: time.Minute}
+	type take struct {
+		After              time.Duration
+		Key                string
+		ExpectedAllowed    bool
+		ExpectedRemaining  int
+		ExpectedRetryAfter time.Duration
+	}
+	cases := []struct {
+		Takes []take
+	}{
+		{
+			Takes: []take{
+				{ExpectedAllowed: true, ExpectedRemaining: 1},
+				{ExpectedAllowed: true, ExpectedRemaining: 0},
+				{ExpectedRetryAfter: 30 * time.Second},
+				{After: 10 * time.Second, ExpectedRetryAfter: 20 * time.Second},
+				{After: 20 * time.Second, ExpectedAllowed: true, ExpectedRemaining: 0},
+				{After: time.Hour, ExpectedAllowed: true, ExpectedRemaining: 1},
+			},
+		},
+		// the keys have separate buckets
+		{
+			Takes: []take{
+				{Key: "alice", ExpectedAllowed: true, ExpectedRemaining: 1},
+				{Key: "alice", ExpectedAllowed: true, ExpectedRemaining: 0},
+				{Key: "bob", ExpectedAllowed: true, ExpectedRemaining: 1},
+				{Key: "alice", ExpectedRetryAfter: 30 * time.Second},
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			at := time.Date(2021, time.July, 10, 12, 0, 0, 0, time.UTC)
+			setNow(t, at)
+			s := NewMemoryStore()
+			for j, tk := range tc.Takes {
+				at = at.Add(tk.After)
+				setNow(t, at)
+				res, err := s.Take(context.Background(), tk.Key, l)
+				if err != nil {
+					t.Errorf("take %d: unexpected error: %v", j, err)
+					return
+				}
+				if res.Allowed != tk.ExpectedAllowed || res.Remaining != tk.ExpectedRemaining || res.RetryAfter != tk.ExpectedRetryAfter {
+					t.Errorf("take %d: expected allowed %v, remaining %d, retry after %v, got %+v", j, tk.ExpectedAllowed, tk.ExpectedRemaining, tk.ExpectedRetryAfter, res)
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	setNow(t, time.Date(2021, time.July, 10, 12, 0, 0, 0, time.UTC))
+	rules := []Rule{
+		{Kind: KindIP, Limit: Limit{Burst: 3, Period: time.Minute}, Caller: ByIP},
+		{Kind: KindUser, Limit: Limit{Burst: 1, Period: time.Minute}, Caller: ByHeader("X-Actor")},
+	}
+	handler := NewMiddleware(NewMemoryStore(), "phone", rules)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	cases := []struct {
+		Actor             string
+		ExpectedCode      int
+		ExpectedRemaining string
+		ExpectedRetry     string
+	}{
+		{Actor: "bob", ExpectedCode: http.StatusOK, ExpectedRemaining: "0"},
+		{Actor: "bob", ExpectedCode: http.StatusTooManyRequests, ExpectedRemaining: "0", ExpectedRetry: "60"},
+		{Actor: "alice", ExpectedCode: http.StatusOK, ExpectedRemaining: "0"},
+		// the IP bucket is empty now
+		{ExpectedCode: http.StatusTooManyRequests, ExpectedRemaining: "0", ExpectedRetry: "20"},
+	}
+
+	for i, tc := range cases {
+		req, err := http.NewRequest("GET", "/phone/alidd", nil)
+		if err != nil {
+			t.Fatalf("failed to create an http request: %v", err)
+		}
+		req.RemoteAddr = "10.0.0.1:4242"
+		if len(tc.Actor) != 0 {
+			req.Header.Set("X-Actor", tc.Actor)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.ExpectedCode {
+			t.Fatalf("request %d: expected code: %d, got: %d", i, tc.ExpectedCode, rr.Code)
+		}
+		if remaining := rr.Header().Get("RateLimit-Remaining"); remaining != tc.ExpectedRemaining {
+			t.Errorf("request %d: expected %q remaining, got %q", i, tc.ExpectedRemaining, remaining)
+		}
+		if retry := rr.Header().Get("Retry-After"); retry != tc.ExpectedRetry {
+			t.Errorf("request %d: expected to retry after %q, got %q", i, tc.ExpectedRetry, retry)
+		}
+	}
+}
+
+func TestMiddlewareStoreFailure(t *testing.T) {
+	rules := []Rule{
+		{Kind: KindIP, Limit: Limit{Burst: 1, Period: time.Minute}, Caller: ByIP},
+	}
+	handler := NewMiddleware(failingStore{}, "phone", rules)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req, err := http.NewRequest("GET", "/phone/alidd", nil)
+	if err != nil {
+		t.Fatalf("failed to create an http request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected code: %d, got: %d", http.StatusOK, rr.Code)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, l Limit) (*Result, error) {
+	return nil, fmt.Errorf("some err")
+}
+
+func compareErrs(expectedErr error, actualErr error) error {
+	if expectedErr == nil && actualErr == nil {
+		return nil
+	}
+	if actualErr == nil {
+		return fmt.Errorf("expected an error \"%v\", got nil", expectedErr)
+	}
+	if !errors.Is(actualErr, expectedErr) {
+		return fmt.Errorf("expected error \"%v\" and actual error \"%v\" are different", expectedErr, actualErr)
+	}
+	return nil
+}
+
+func setNow(t *testing.T, at time.Time) {
+	prev := now
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = prev })
+}
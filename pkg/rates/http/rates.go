@@ -0,0 +1,99 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/rates/service"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/rates/storage"
+)
+
+// maxUploadSize limits the size of an uploaded CSV file.
+const maxUploadSize = 1 << 20
+
+type uploadRatesResponse struct {
+	Uploaded int `json:"uploaded"`
+}
+
+// ListRates returns the rates in effect on the date given by the "date" query
+// parameter, today by default.
+func ListRates(w http.ResponseWriter, r *http.Request) {
+	on := time.Now()
+	if date := r.URL.Query().Get("date"); len(date) != 0 {
+		var err error
+		if on, err = time.Parse("2006-01-02", date); err != nil {
+			log.Printf("incorrect date %q: %v", date, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	rates, err := service.ListRates(db, on)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rates)
+}
+
+// UploadRates stores the rates of the CSV request body.
+func UploadRates(w http.ResponseWriter, r *http.Request) {
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	uploaded, err := service.UploadRates(db, http.MaxBytesReader(w, r.Body, maxUploadSize))
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &uploadRatesResponse{
+		Uploaded: uploaded,
+	})
+}
+
+func getDB(w http.ResponseWriter, r *http.Request) (storage.DB, bool) {
+	dbIface := r.Context().Value(storage.ContextKeyDB)
+	if dbIface == nil {
+		log.Println("DB is not found in the request context")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	db, ok := dbIface.(storage.DB)
+	if !ok {
+		log.Println("DB in the request context is not of type storage.DB")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return db, true
+}
+
+func writeErr(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, service.ErrIncorrectRates):
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("failed to serialize the response to JSON: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(resp); err != nil {
+		log.Printf("failed to write the response body: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/rates/storage"
+)
+
+var ErrNoRate = fmt.Errorf("no exchange rate")
+
+type currencyPair struct {
+	from string
+	to   string
+}
+
+// Converter converts money between currencies using a set of rates. A pair
+// without a rate is converted using the rate of the reverse pair or, failing
+// that, through a currency both sides have a rate with.
+type Converter struct {
+	rates map[currencyPair]*big.Rat
+	// currencies are sorted, so that the intermediate currency is chosen
+	// deterministically
+	currencies []string
+}
+
+func NewConverter(rates []*storage.Rate) (*Converter, error) {
+	c := &Converter{
+		rates: make(map[currencyPair]*big.Rat, len(rates)),
+	}
+	currencies := make(map[string]struct{})
+	for _, r := range rates {
+		rate, ok := new(big.Rat).SetString(r.Rate)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("%w: %s/%s rate %q is not a positive number", ErrIncorrectRates, r.From, r.To, r.Rate)
+		}
+		c.rates[currencyPair{r.From, r.To}] = rate
+		currencies[r.From] = struct{}{}
+		currencies[r.To] = struct{}{}
+	}
+	for currency := range currencies {
+		c.currencies = append(c.currencies, currency)
+	}
+	sort.Strings(c.currencies)
+	return c, nil
+}
+
+// Convert expresses m in the currency. The result is rounded to hundredths.
+func (c *Converter) Convert(m money.Money, currency string) (money.Money, error) {
+	if m.Currency == currency {
+		return m, nil
+	}
+	if rate, ok := c.rate(m.Currency, currency); ok {
+		return money.New(m.MulRat(rate).Amount, currency), nil
+	}
+	for _, via := range c.currencies {
+		first, ok := c.rate(m.Currency, via)
+		if !ok {
+			continue
+		}
+		second, ok := c.rate(via, currency)
+		if !ok {
+			continue
+		}
+		return money.New(m.MulRat(new(big.Rat).Mul(first, second)).Amount, currency), nil
+	}
+	return money.Money{}, fmt.Errorf("%w: %s to %s", ErrNoRate, m.Currency, currency)
+}
+
+func (c *Converter) rate(from string, to string) (*big.Rat, bool) {
+	if from == to {
+		return big.NewRat(1, 1), true
+	}
+	if rate, ok := c.rates[currencyPair{from, to}]; ok {
+		return rate, true
+	}
+	if rate, ok := c.rates[currencyPair{to, from}]; ok {
+		return new(big.Rat).Inv(rate), true
+	}
+	return nil, false
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/rates/storage"
+)
+
+var (
+	ErrIncorrectRates  = fmt.Errorf("got incorrect exchange rates")
+	ErrDBRequestFailed = fmt.Errorf("a request to DB failed")
+)
+
+const (
+	dateLayout = "2006-01-02"
+	// maxUploadRates limits the number of rates in a single upload.
+	maxUploadRates = 10000
+)
+
+var (
+	rateRe    = regexp.MustCompile(`^[0-9]{1,10}(\.[0-9]{1,8})?$`)
+	csvHeader = []string{"from", "to", "rate", "effective_date"}
+)
+
+// ListRates returns the rates in effect on the date.
+func ListRates(db storage.DB, on time.Time) ([]*storage.Rate, error) {
+	rates, err := db.ListRates(context.Background(), on)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list exchange rates: %v", ErrDBRequestFailed, err)
+	}
+	return rates, nil
+}
+
+// UploadRates stores the rates read from r, see ParseRatesCSV. Either all of
+// the rates are stored or none of them.
+func UploadRates(db storage.DB, r io.Reader) (int, error) {
+	rates, err := ParseRatesCSV(r)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.UpsertRates(context.Background(), rates); err != nil {
+		return 0, fmt.Errorf("%w: failed to store exchange rates: %v", ErrDBRequestFailed, err)
+	}
+	return len(rates), nil
+}
+
+// ParseRatesCSV reads rates written as "from,to,rate,effective_date" lines,
+// e.g. "USD,RUB,73.5,2021-07-01". The first line may be the header.
+func ParseRatesCSV(r io.Reader) ([]*storage.Rate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(csvHeader)
+	reader.TrimLeadingSpace = true
+	rates := make([]*storage.Rate, 0)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrIncorrectRates, err)
+		}
+		if line == 1 && isHeader(record) {
+			continue
+		}
+		rate, err := parseRate(record)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrIncorrectRates, line, err)
+		}
+		rates = append(rates, rate)
+		if len(rates) > maxUploadRates {
+			return nil, fmt.Errorf("%w: more than %d rates", ErrIncorrectRates, maxUploadRates)
+		}
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("%w: no rates given", ErrIncorrectRates)
+	}
+	return rates, nil
+}
+
+func isHeader(record []string) bool {
+	for i, field := range record {
+		if !strings.EqualFold(strings.TrimSpace(field), csvHeader[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseRate(record []string) (*storage.Rate, error) {
+	from := strings.ToUpper(strings.TrimSpace(record[0]))
+	to := strings.ToUpper(strings.TrimSpace(record[1]))
+	if err := money.ValidateCurrency(from); err != nil {
+		return nil, err
+	}
+	if err := money.ValidateCurrency(to); err != nil {
+		return nil, err
+	}
+	if from == to {
+		return nil, fmt.Errorf("%s is converted to itself", from)
+	}
+	rate := strings.TrimSpace(record[2])
+	if !rateRe.MatchString(rate) {
+		return nil, fmt.Errorf("%q is not a rate with at most 8 decimals", rate)
+	}
+	if r, _ := new(big.Rat).SetString(rate); r.Sign() <= 0 {
+		return nil, fmt.Errorf("rate %s is not positive", rate)
+	}
+	date, err := time.Parse(dateLayout, strings.TrimSpace(record[3]))
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a date like 2021-07-01", record[3])
+	}
+	return &storage.Rate{
+		From:          from,
+		To:            to,
+		Rate:          rate,
+		EffectiveDate: date,
+	}, nil
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/rates/storage"
+)
+
+func TestUploadRates(t *testing.T) {
+	cases := []struct {
+		CSV           string
+		ExpectedRates []*storage.Rate
+		ExpectedErr   error
+	}{
+		{
+			CSV: "from,to,rate,effective_date\nusd,RUB,73.5,2021-07-01\nEUR, RUB, 86.12345678, 2021-07-01\n",
+			ExpectedRates: []*storage.Rate{
+				{From: "USD", To: "RUB", Rate: "73.5", EffectiveDate: time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC)},
+				{From: "EUR", To: "RUB", Rate: "86.12345678", EffectiveDate: time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			CSV: "USD,RUB,73.5,2021-07-01",
+			ExpectedRates: []*storage.Rate{
+				{From: "USD", To: "RUB", Rate: "73.5", EffectiveDate: time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			CSV:         "from,to,rate,effective_date\n",
+			ExpectedErr: ErrIncorrectRates,
+		},
+		{
+			CSV:         "USD,RUB,73.5",
+			ExpectedErr: ErrIncorrectRates,
+		},
+		{
+			CSV:         "USD,USD,1,2021-07-01",
+			ExpectedErr: ErrIncorrectRates,
+		},
+		{
+			CSV:         "USD,RUB,0,2021-07-01",
+			ExpectedErr: ErrIncorrectRates,
+		},
+		{
+			CSV:         "USD,RUB,-73.5,2021-07-01",
+			ExpectedErr: ErrIncorrectRates,
+		},
+		{
+			CSV:         "USD,RUB,73.123456789,2021-07-01",
+			ExpectedErr: ErrIncorrectRates,
+		},
+		{
+			CSV:         "USD,RUB,73.5,01.07.2021",
+			ExpectedErr: ErrIncorrectRates,
+		},
+		{
+			CSV:         "US,RUB,73.5,2021-07-01",
+			ExpectedErr: ErrIncorrectRates,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &dbMock{}
+			uploaded, err := UploadRates(mock, strings.NewReader(tc.CSV))
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				if mock.rates != nil {
+					t.Errorf("rates were stored despite the error")
+				}
+				return
+			}
+			if uploaded != len(tc.ExpectedRates) || len(mock.rates) != len(tc.ExpectedRates) {
+				t.Fatalf("expected %d rates, got %d (stored %d)", len(tc.ExpectedRates), uploaded, len(mock.rates))
+			}
+			for j, e := range tc.ExpectedRates {
+				if *mock.rates[j] != *e {
+					t.Errorf("rate %d: expected %+v, got %+v", j, e, mock.rates[j])
+				}
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	conv, err := NewConverter([]*storage.Rate{
+		{From: "USD", To: "RUB", Rate: "75"},
+		{From: "EUR", To: "USD", Rate: "1.2"},
+	})
+	if err != nil {
+		t.Fatalf("NewConverter failed: %v", err)
+	}
+	cases := []struct {
+		Money       money.Money
+		Currency    string
+		Expected    money.Money
+		ExpectedErr error
+	}{
+		{Money: money.New(10000, "USD"), Currency: "USD", Expected: money.New(10000, "USD")},
+		{Money: money.New(10000, "USD"), Currency: "RUB", Expected: money.New(750000, "RUB")},
+		{Money: money.New(10000, "RUB"), Currency: "USD", Expected: money.New(133, "USD")},
+		{Money: money.New(10000, "EUR"), Currency: "RUB", Expected: money.New(900000, "RUB")},
+		{Money: money.New(900000, "RUB"), Currency: "EUR", Expected: money.New(10000, "EUR")},
+		{Money: money.New(10000, "GBP"), Currency: "RUB", ExpectedErr: ErrNoRate},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			actual, err := conv.Convert(tc.Money, tc.Currency)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr == nil && actual != tc.Expected {
+				t.Errorf("expected %v, got %v", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func compareErrs(expectedErr error, actualErr error) error {
+	if expectedErr == nil && actualErr == nil {
+		return nil
+	}
+	if actualErr == nil {
+		return fmt.Errorf("expected an error \"%v\", got nil", expectedErr)
+	}
+	if !errors.Is(actualErr, expectedErr) {
+		return fmt.Errorf("expected error \"%v\" and actual error \"%v\" are different", expectedErr, actualErr)
+	}
+	return nil
+}
+
+type dbMock struct {
+	rates []*storage.Rate
+}
+
+func (db *dbMock) ListRates(ctx context.Context, on time.Time) ([]*storage.Rate, error) {
+	return db.rates, nil
+}
+
+func (db *dbMock) UpsertRates(ctx context.Context, rates []*storage.Rate) error {
+	db.rates = rates
+	return nil
+}
+
+func (db *dbMock) Close() {}
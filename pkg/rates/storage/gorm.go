@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+const upsertBatchSize = 500
+
+type gormDB struct {
+	db  *gorm.DB
+	src *auditStorage.Source
+}
+
+func newGormDB(c *database.ConnString, src *auditStorage.Source) (*gormDB, error) {
+	db, err := database.OpenGorm(c)
+	if err != nil {
+		return nil, err
+	}
+	return &gormDB{
+		db:  db,
+		src: src,
+	}, nil
+}
+
+func (g *gormDB) ListRates(ctx context.Context, on time.Time) ([]*Rate, error) {
+	var rates []*Rate
+	req := g.db.WithContext(ctx).Raw(
+		`SELECT DISTINCT ON (from_currency, to_currency) from_currency, to_currency, rate, effective_date
+		FROM exchange_rates
+		WHERE effective_date <= ?
+		ORDER BY from_currency, to_currency, effective_date DESC`,
+		on.Format("2006-01-02"),
+	).Scan(&rates)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query exchange rates: %w", err)
+	}
+	return rates, nil
+}
+
+func (g *gormDB) UpsertRates(ctx context.Context, rates []*Rate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "from_currency"}, {Name: "to_currency"}, {Name: "effective_date"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rate"}),
+		}).CreateInBatches(rates, upsertBatchSize).Error
+		if err != nil {
+			return fmt.Errorf("failed to upsert exchange rates: %w", err)
+		}
+		return auditStorage.Record(tx, g.src, &auditStorage.Change{
+			Action:     auditStorage.ActionUpload,
+			EntityType: auditStorage.EntityExchangeRates,
+			EntityID:   datesOf(rates),
+			After:      rates,
+		})
+	})
+}
+
+// datesOf identifies an upload by the range of the effective dates of the
+// rates, like "2021-07-01..2021-07-05".
+func datesOf(rates []*Rate) string {
+	first, last := rates[0].EffectiveDate, rates[0].EffectiveDate
+	for _, r := range rates[1:] {
+		if r.EffectiveDate.Before(first) {
+			first = r.EffectiveDate
+		}
+		if r.EffectiveDate.After(last) {
+			last = r.EffectiveDate
+		}
+	}
+	if first.Equal(last) {
+		return first.Format("2006-01-02")
+	}
+	return first.Format("2006-01-02") + ".." + last.Format("2006-01-02")
+}
+
+func (g *gormDB) Close() {
+	database.CloseGorm(g.db)
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+)
+
+type ContextKey int
+
+const ContextKeyDB ContextKey = iota + 1
+
+// Rate is the price of one unit of the From currency in the To currency. It is
+// valid from EffectiveDate until the next rate of the pair takes effect.
+type Rate struct {
+	From          string    `gorm:"column:from_currency" json:"from"`
+	To            string    `gorm:"column:to_currency" json:"to"`
+	Rate          string    `gorm:"column:rate" json:"rate"`
+	EffectiveDate time.Time `gorm:"column:effective_date" json:"effective_date"`
+}
+
+func (Rate) TableName() string {
+	return "exchange_rates"
+}
+
+type DB interface {
+	// ListRates returns the rate of every currency pair in effect on the date.
+	ListRates(ctx context.Context, on time.Time) ([]*Rate, error)
+	// UpsertRates stores the rates, replacing the rates of the same pairs and
+	// dates.
+	UpsertRates(ctx context.Context, rates []*Rate) error
+	Close()
+}
+
+// NewDB opens the storage, the changes made through it are recorded in the
+// audit log as made by src.
+func NewDB(connStr *database.ConnString, src *auditStorage.Source) (DB, error) {
+	gormDB, err := newGormDB(connStr, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a gorm connection: %w", err)
+	}
+	return gormDB, nil
+}
@@ -0,0 +1,299 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/auth"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/reorg/service"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/reorg/storage"
+)
+
+type reorgRequest struct {
+	Name string `json:"name"`
+}
+
+type moveRequest struct {
+	Department *int `json:"department"`
+	Position   *int `json:"position"`
+	ManagerID  *int `json:"manager_id"`
+}
+
+type departmentChangeRequest struct {
+	Name     *string `json:"name"`
+	ParentID *int    `json:"parent_id"`
+}
+
+func CreateReorg(w http.ResponseWriter, r *http.Request) {
+	req := &reorgRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Printf("failed to decode the reorg: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	created, err := service.CreateReorg(db, &storage.Reorg{
+		Name:      req.Name,
+		CreatedBy: auth.Subject(r.Context()),
+	})
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func ListReorgs(w http.ResponseWriter, r *http.Request) {
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	reorgs, err := service.ListReorgs(db)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reorgs)
+}
+
+func GetReorg(w http.ResponseWriter, r *http.Request, reorgID string) {
+	id, ok := parseID(w, "reorg", reorgID)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	reorg, err := service.GetReorg(db, id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reorg)
+}
+
+// StageMove stages the employee's move in the draft reorg. The fields left
+// out keep the employee's current values.
+func StageMove(w http.ResponseWriter, r *http.Request, reorgID string, employeeID string) {
+	id, ok := parseID(w, "reorg", reorgID)
+	if !ok {
+		return
+	}
+	eID, ok := parseID(w, "employee", employeeID)
+	if !ok {
+		return
+	}
+	req := &moveRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Printf("failed to decode the move: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	err := service.StageMove(db, &storage.Move{
+		ReorgID:    id,
+		EmployeeID: eID,
+		Department: req.Department,
+		Position:   req.Position,
+		ManagerID:  req.ManagerID,
+	})
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func UnstageMove(w http.ResponseWriter, r *http.Request, reorgID string, employeeID string) {
+	id, ok := parseID(w, "reorg", reorgID)
+	if !ok {
+		return
+	}
+	eID, ok := parseID(w, "employee", employeeID)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	if err := service.UnstageMove(db, id, eID); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StageDepartmentChange stages the rename or the reparenting of the
+// department in the draft reorg.
+func StageDepartmentChange(w http.ResponseWriter, r *http.Request, reorgID string, departmentID string) {
+	id, ok := parseID(w, "reorg", reorgID)
+	if !ok {
+		return
+	}
+	dID, ok := parseID(w, "department", departmentID)
+	if !ok {
+		return
+	}
+	req := &departmentChangeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Printf("failed to decode the department change: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	err := service.StageDepartmentChange(db, &storage.DepartmentChange{
+		ReorgID:    id,
+		Department: dID,
+		Name:       req.Name,
+		ParentID:   req.ParentID,
+	})
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func UnstageDepartmentChange(w http.ResponseWriter, r *http.Request, reorgID string, departmentID string) {
+	id, ok := parseID(w, "reorg", reorgID)
+	if !ok {
+		return
+	}
+	dID, ok := parseID(w, "department", departmentID)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	if err := service.UnstageDepartmentChange(db, id, dID); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PreviewReorg returns the org tree and the budget impact of the draft reorg
+// along with the issues preventing its commit.
+func PreviewReorg(w http.ResponseWriter, r *http.Request, reorgID string) {
+	id, ok := parseID(w, "reorg", reorgID)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	preview, err := service.PreviewReorg(db, id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, preview)
+}
+
+func CommitReorg(w http.ResponseWriter, r *http.Request, reorgID string) {
+	id, ok := parseID(w, "reorg", reorgID)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	committed, err := service.CommitReorg(db, id, auth.Subject(r.Context()))
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, committed)
+}
+
+func DiscardReorg(w http.ResponseWriter, r *http.Request, reorgID string) {
+	id, ok := parseID(w, "reorg", reorgID)
+	if !ok {
+		return
+	}
+	db, ok := getDB(w, r)
+	if !ok {
+		return
+	}
+	discarded, err := service.DiscardReorg(db, id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, discarded)
+}
+
+func parseID(w http.ResponseWriter, kind string, s string) (int, bool) {
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		log.Printf("incorrect %s ID %q: %v", kind, s, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func getDB(w http.ResponseWriter, r *http.Request) (storage.DB, bool) {
+	dbIface := r.Context().Value(storage.ContextKeyDB)
+	if dbIface == nil {
+		log.Println("DB is not found in the request context")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	db, ok := dbIface.(storage.DB)
+	if !ok {
+		log.Println("DB in the request context is not of type storage.DB")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return db, true
+}
+
+func writeErr(w http.ResponseWriter, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, service.ErrIncorrectReorg):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, service.ErrReorgNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, service.ErrReorgConflict):
+		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, service.ErrInvalidReorg),
+		errors.Is(err, service.ErrMissingRate):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("failed to serialize the response to JSON: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(resp); err != nil {
+		log.Printf("failed to write the response body: %v", err)
+	}
+}
@@ -0,0 +1,506 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	budgetService "github.com/SergeyShpak/gopher-corp-backend/pkg/budget/service"
+	budgetStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	orgService "github.com/SergeyShpak/gopher-corp-backend/pkg/org/service"
+	orgStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/org/storage"
+	ratesService "github.com/SergeyShpak/gopher-corp-backend/pkg/rates/service"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/reorg/storage"
+)
+
+var (
+	ErrInvalidReorg = fmt.Errorf("reorg does not pass the validation")
+	ErrMissingRate  = fmt.Errorf("an amount cannot be converted to the report currency")
+)
+
+const (
+	IssueMissingEmployee   = "missing_employee"
+	IssueMissingDepartment = "missing_department"
+	IssueManagerCycle      = "manager_cycle"
+	IssueDepartmentCycle   = "department_cycle"
+	IssueOverBudget        = "over_budget"
+)
+
+// now is replaced in tests.
+var now = time.Now
+
+// Issue is a reason the reorg cannot be committed.
+type Issue struct {
+	Kind       string `json:"kind"`
+	EmployeeID int    `json:"employee_id,omitempty"`
+	Department int    `json:"department,omitempty"`
+	Message    string `json:"message"`
+}
+
+// BudgetImpact is the change of a department's own payroll for the current
+// fiscal period, expressed in budgetService.DefaultReportCurrency.
+type BudgetImpact struct {
+	Department    int          `json:"department"`
+	Name          string       `json:"name"`
+	Budget        *money.Money `json:"budget"`
+	PayrollBefore money.Money  `json:"payroll_before"`
+	PayrollAfter  money.Money  `json:"payroll_after"`
+	StatusBefore  string       `json:"status_before"`
+	StatusAfter   string       `json:"status_after"`
+}
+
+// Preview is the org as it would be once the reorg is committed.
+type Preview struct {
+	Reorg        *storage.Reorg       `json:"reorg"`
+	Period       budgetStorage.Period `json:"period"`
+	Units        []*orgService.Unit   `json:"units"`
+	BudgetImpact []*BudgetImpact      `json:"budget_impact"`
+	Issues       []*Issue             `json:"issues"`
+	Valid        bool                 `json:"valid"`
+}
+
+// PreviewReorg applies the draft reorg to the current org in memory and
+// validates the result: the moved employees, their departments and managers
+// must exist, the managers and the departments must not form cycles, and no
+// department may go over its budget because of the reorg.
+func PreviewReorg(db storage.DB, id int) (*Preview, error) {
+	r, err := getDraft(db, id)
+	if err != nil {
+		return nil, err
+	}
+	return preview(db, r)
+}
+
+// CommitReorg applies the draft reorg in a single transaction if it passes
+// the validation of PreviewReorg.
+func CommitReorg(db storage.DB, id int, committedBy string) (*storage.Reorg, error) {
+	r, err := getDraft(db, id)
+	if err != nil {
+		return nil, err
+	}
+	p, err := preview(db, r)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Valid {
+		return nil, fmt.Errorf(
+			"%w: %s, %d issue(s) in total",
+			ErrInvalidReorg, p.Issues[0].Message, len(p.Issues),
+		)
+	}
+	committed, err := db.CommitReorg(context.Background(), r, committedBy)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to commit the reorg")
+	}
+	return committed, nil
+}
+
+func getDraft(db storage.DB, id int) (*storage.Reorg, error) {
+	r, err := GetReorg(db, id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != storage.StatusDraft {
+		return nil, fmt.Errorf("%w: reorg %d is %s", ErrReorgConflict, id, r.Status)
+	}
+	return r, nil
+}
+
+func preview(db storage.DB, r *storage.Reorg) (*Preview, error) {
+	ctx := context.Background()
+	today := truncateToDate(now())
+	period := periodOf(today)
+	employees, err := db.ListEmployees(ctx)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list the employees")
+	}
+	depts, err := db.ListDepartments(ctx)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list the departments")
+	}
+	budgets, err := db.ListBudgets(ctx, period)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list the budgets")
+	}
+	rates, err := db.ListRates(ctx, today)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list exchange rates")
+	}
+	conv, err := ratesService.NewConverter(rates)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDBRequestFailed, err)
+	}
+
+	movedEmployees, movedDepts, issues := applyChanges(r, employees, depts, today)
+	issues = append(issues, findManagerCycles(movedEmployees)...)
+	issues = append(issues, findDepartmentCycles(movedDepts)...)
+	impact, err := budgetImpact(conv, employees, movedEmployees, movedDepts, budgets)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range impact {
+		if i.StatusAfter == budgetService.StatusOverBudget && i.PayrollAfter.Amount > i.PayrollBefore.Amount {
+			issues = append(issues, &Issue{
+				Kind:       IssueOverBudget,
+				Department: i.Department,
+				Message: fmt.Sprintf(
+					"department %d payroll %s exceeds its budget %s",
+					i.Department, i.PayrollAfter, i.Budget,
+				),
+			})
+		}
+	}
+
+	return &Preview{
+		Reorg:        r,
+		Period:       period,
+		Units:        orgService.BuildTree(orgDepartments(movedDepts), orgMembers(movedEmployees)),
+		BudgetImpact: impact,
+		Issues:       issues,
+		Valid:        len(issues) == 0,
+	}, nil
+}
+
+// applyChanges returns copies of the employees and the departments with the
+// reorg applied. The moved employees start their assignments on the date.
+func applyChanges(
+	r *storage.Reorg,
+	employees []*storage.Employee,
+	depts []*storage.Department,
+	on time.Time,
+) ([]*storage.Employee, []*storage.Department, []*Issue) {
+	issues := []*Issue{}
+
+	movedDepts := make([]*storage.Department, 0, len(depts))
+	deptsByID := make(map[int]*storage.Department, len(depts))
+	for _, d := range depts {
+		moved := *d
+		movedDepts = append(movedDepts, &moved)
+		deptsByID[d.ID] = &moved
+	}
+	for _, c := range r.DepartmentChanges {
+		d, ok := deptsByID[c.Department]
+		if !ok {
+			issues = append(issues, &Issue{
+				Kind:       IssueMissingDepartment,
+				Department: c.Department,
+				Message:    fmt.Sprintf("department %d does not exist", c.Department),
+			})
+			continue
+		}
+		if c.Name != nil {
+			d.Name = *c.Name
+		}
+		if c.ParentID != nil {
+			if _, ok := deptsByID[*c.ParentID]; !ok {
+				issues = append(issues, &Issue{
+					Kind:       IssueMissingDepartment,
+					Department: *c.ParentID,
+					Message:    fmt.Sprintf("parent department %d of department %d does not exist", *c.ParentID, c.Department),
+				})
+				continue
+			}
+			d.ParentID = *c.ParentID
+		}
+	}
+
+	movedEmployees := make([]*storage.Employee, 0, len(employees))
+	employeesByID := make(map[int]*storage.Employee, len(employees))
+	for _, e := range employees {
+		moved := *e
+		movedEmployees = append(movedEmployees, &moved)
+		employeesByID[e.ID] = &moved
+	}
+	for _, m := range r.Moves {
+		e, ok := employeesByID[m.EmployeeID]
+		if !ok {
+			issues = append(issues, &Issue{
+				Kind:       IssueMissingEmployee,
+				EmployeeID: m.EmployeeID,
+				Message:    fmt.Sprintf("employee %d does not exist", m.EmployeeID),
+			})
+			continue
+		}
+		moved := *e
+		if m.Department != nil {
+			if _, ok := deptsByID[*m.Department]; !ok {
+				issues = append(issues, &Issue{
+					Kind:       IssueMissingDepartment,
+					EmployeeID: m.EmployeeID,
+					Department: *m.Department,
+					Message:    fmt.Sprintf("department %d of employee %d does not exist", *m.Department, m.EmployeeID),
+				})
+				continue
+			}
+			moved.Department = *m.Department
+		}
+		if m.Position != nil {
+			moved.Position = *m.Position
+		}
+		if m.ManagerID != nil {
+			if _, ok := employeesByID[*m.ManagerID]; !ok {
+				issues = append(issues, &Issue{
+					Kind:       IssueMissingEmployee,
+					EmployeeID: *m.ManagerID,
+					Message:    fmt.Sprintf("manager %d of employee %d does not exist", *m.ManagerID, m.EmployeeID),
+				})
+				continue
+			}
+			moved.ManagerID = *m.ManagerID
+		}
+		if moved.Department != e.Department || moved.Position != e.Position || moved.ManagerID != e.ManagerID {
+			moved.Since = on
+		}
+		*e = moved
+	}
+	return movedEmployees, movedDepts, issues
+}
+
+// findManagerCycles reports the employees who would end up managing
+// themselves through their reports. The employee managing themselves
+// directly is the top of the hierarchy and is not a cycle.
+func findManagerCycles(employees []*storage.Employee) []*Issue {
+	managers := make(map[int]int, len(employees))
+	for _, e := range employees {
+		managers[e.ID] = e.ManagerID
+	}
+	issues := []*Issue{}
+	for _, cycle := range findCycles(managers) {
+		issues = append(issues, &Issue{
+			Kind:       IssueManagerCycle,
+			EmployeeID: cycle[0],
+			Message:    fmt.Sprintf("employees %s manage each other", formatIDs(cycle)),
+		})
+	}
+	return issues
+}
+
+// findDepartmentCycles reports the departments that would end up being their
+// own ancestors. A department that is its own parent is a root.
+func findDepartmentCycles(depts []*storage.Department) []*Issue {
+	parents := make(map[int]int, len(depts))
+	for _, d := range depts {
+		parents[d.ID] = d.ParentID
+	}
+	issues := []*Issue{}
+	for _, cycle := range findCycles(parents) {
+		issues = append(issues, &Issue{
+			Kind:       IssueDepartmentCycle,
+			Department: cycle[0],
+			Message:    fmt.Sprintf("departments %s are each other's ancestors", formatIDs(cycle)),
+		})
+	}
+	return issues
+}
+
+// findCycles returns the cycles of the parents graph longer than one node,
+// each starting with its lowest ID. A node whose parent is missing or is the
+// node itself ends a chain.
+func findCycles(parents map[int]int) [][]int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	ids := make([]int, 0, len(parents))
+	for id := range parents {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	state := make(map[int]int, len(parents))
+	var cycles [][]int
+	for _, id := range ids {
+		var path []int
+		pos := make(map[int]int)
+		for cur := id; ; {
+			if state[cur] == visited {
+				break
+			}
+			if state[cur] == visiting {
+				cycles = append(cycles, rotateToMin(path[pos[cur]:]))
+				break
+			}
+			state[cur] = visiting
+			pos[cur] = len(path)
+			path = append(path, cur)
+			next, ok := parents[cur]
+			if !ok || next == cur {
+				break
+			}
+			cur = next
+		}
+		for _, p := range path {
+			state[p] = visited
+		}
+	}
+	return cycles
+}
+
+func rotateToMin(cycle []int) []int {
+	min := 0
+	for i, id := range cycle {
+		if id < cycle[min] {
+			min = i
+		}
+	}
+	rotated := make([]int, 0, len(cycle))
+	rotated = append(rotated, cycle[min:]...)
+	return append(rotated, cycle[:min]...)
+}
+
+func formatIDs(ids []int) string {
+	s := ""
+	for i, id := range ids {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprint(id)
+	}
+	return s
+}
+
+// budgetImpact compares the departments' own payrolls before and after the
+// reorg. Only the departments whose payroll changes are reported.
+func budgetImpact(
+	conv *ratesService.Converter,
+	before []*storage.Employee,
+	after []*storage.Employee,
+	depts []*storage.Department,
+	budgets []*budgetStorage.Budget,
+) ([]*BudgetImpact, error) {
+	currency := budgetService.DefaultReportCurrency
+	payrollsBefore, err := payrolls(conv, before, currency)
+	if err != nil {
+		return nil, err
+	}
+	payrollsAfter, err := payrolls(conv, after, currency)
+	if err != nil {
+		return nil, err
+	}
+	budgetsByDept := make(map[int]*money.Money, len(budgets))
+	for _, b := range budgets {
+		budget, err := conv.Convert(b.Budget, currency)
+		if err != nil {
+			return nil, fmt.Errorf("%w: department %d budget: %v", ErrMissingRate, b.Department, err)
+		}
+		budgetsByDept[b.Department] = &budget
+	}
+
+	impact := []*BudgetImpact{}
+	for _, d := range depts {
+		payrollBefore := payrollOf(payrollsBefore, d.ID, currency)
+		payrollAfter := payrollOf(payrollsAfter, d.ID, currency)
+		if payrollBefore == payrollAfter {
+			continue
+		}
+		budget := budgetsByDept[d.ID]
+		impact = append(impact, &BudgetImpact{
+			Department:    d.ID,
+			Name:          d.Name,
+			Budget:        budget,
+			PayrollBefore: payrollBefore,
+			PayrollAfter:  payrollAfter,
+			StatusBefore:  compare(budget, payrollBefore),
+			StatusAfter:   compare(budget, payrollAfter),
+		})
+	}
+	return impact, nil
+}
+
+// payrolls sums up the salaries of every department by currency before
+// converting, the way the budget reports do.
+func payrolls(conv *ratesService.Converter, employees []*storage.Employee, currency string) (map[int]money.Money, error) {
+	byCurrency := make(map[int]map[string]money.Amount)
+	for _, e := range employees {
+		if byCurrency[e.Department] == nil {
+			byCurrency[e.Department] = make(map[string]money.Amount)
+		}
+		byCurrency[e.Department][e.Salary.Currency] += e.Salary.Amount
+	}
+	totals := make(map[int]money.Money, len(byCurrency))
+	for dept, amounts := range byCurrency {
+		total := money.New(0, currency)
+		for c, amount := range amounts {
+			converted, err := conv.Convert(money.New(amount, c), currency)
+			if err != nil {
+				return nil, fmt.Errorf("%w: department %d payroll: %v", ErrMissingRate, dept, err)
+			}
+			total.Amount += converted.Amount
+		}
+		totals[dept] = total
+	}
+	return totals, nil
+}
+
+func payrollOf(totals map[int]money.Money, dept int, currency string) money.Money {
+	if total, ok := totals[dept]; ok {
+		return total
+	}
+	return money.New(0, currency)
+}
+
+func compare(budget *money.Money, payroll money.Money) string {
+	if budget == nil {
+		return budgetService.StatusNoBudget
+	}
+	switch {
+	case payroll.Amount < budget.Amount:
+		return budgetService.StatusUnderBudget
+	case payroll.Amount > budget.Amount:
+		return budgetService.StatusOverBudget
+	default:
+		return budgetService.StatusOnBudget
+	}
+}
+
+func orgDepartments(depts []*storage.Department) []*orgStorage.Department {
+	converted := make([]*orgStorage.Department, 0, len(depts))
+	for _, d := range depts {
+		converted = append(converted, &orgStorage.Department{
+			ID:       d.ID,
+			ParentID: d.ParentID,
+			Name:     d.Name,
+		})
+	}
+	return converted
+}
+
+// orgMembers orders the members like the org snapshots do.
+func orgMembers(employees []*storage.Employee) []*orgStorage.Member {
+	members := make([]*orgStorage.Member, 0, len(employees))
+	for _, e := range employees {
+		members = append(members, &orgStorage.Member{
+			EmployeeID: e.ID,
+			FirstName:  e.FirstName,
+			LastName:   e.LastName,
+			Department: e.Department,
+			Position:   e.Position,
+			ManagerID:  e.ManagerID,
+			Since:      e.Since,
+		})
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		if members[i].LastName != members[j].LastName {
+			return members[i].LastName < members[j].LastName
+		}
+		if members[i].FirstName != members[j].FirstName {
+			return members[i].FirstName < members[j].FirstName
+		}
+		return members[i].EmployeeID < members[j].EmployeeID
+	})
+	return members
+}
+
+func periodOf(t time.Time) budgetStorage.Period {
+	return budgetStorage.Period{
+		Year:    t.Year(),
+		Quarter: (int(t.Month())-1)/3 + 1,
+	}
+}
+
+func truncateToDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/reorg/storage"
+)
+
+var (
+	ErrIncorrectReorg  = fmt.Errorf("got an incorrect reorg")
+	ErrReorgNotFound   = fmt.Errorf("reorg not found")
+	ErrReorgConflict   = fmt.Errorf("reorg conflicts with the existing data")
+	ErrDBRequestFailed = fmt.Errorf("a request to DB failed")
+)
+
+const maxNameLength = 200
+
+func CreateReorg(db storage.DB, r *storage.Reorg) (*storage.Reorg, error) {
+	r.Name = strings.TrimSpace(r.Name)
+	if err := validateName(r.Name); err != nil {
+		return nil, err
+	}
+	created, err := db.CreateReorg(context.Background(), r)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to create the reorg")
+	}
+	created.Moves = []*storage.Move{}
+	created.DepartmentChanges = []*storage.DepartmentChange{}
+	return created, nil
+}
+
+func ListReorgs(db storage.DB) ([]*storage.Reorg, error) {
+	reorgs, err := db.ListReorgs(context.Background())
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to list the reorgs")
+	}
+	return reorgs, nil
+}
+
+func GetReorg(db storage.DB, id int) (*storage.Reorg, error) {
+	r, err := db.GetReorg(context.Background(), id)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to get the reorg")
+	}
+	return r, nil
+}
+
+// StageMove stages the change of the employee's department, position or
+// manager in a draft reorg, replacing the move of the employee staged before.
+func StageMove(db storage.DB, m *storage.Move) error {
+	if m.Department == nil && m.Position == nil && m.ManagerID == nil {
+		return fmt.Errorf("%w: no department, position or manager given", ErrIncorrectReorg)
+	}
+	if !positive(m.Department) || !positive(m.Position) || !positive(m.ManagerID) {
+		return fmt.Errorf("%w: incorrect department, position or manager ID", ErrIncorrectReorg)
+	}
+	if err := db.StageMove(context.Background(), m); err != nil {
+		return wrapDBErr(err, "failed to stage the move")
+	}
+	return nil
+}
+
+func UnstageMove(db storage.DB, reorgID int, employeeID int) error {
+	if err := db.UnstageMove(context.Background(), reorgID, employeeID); err != nil {
+		return wrapDBErr(err, "failed to unstage the move")
+	}
+	return nil
+}
+
+// StageDepartmentChange stages the rename or the reparenting of a department
+// in a draft reorg, replacing the change of the department staged before.
+func StageDepartmentChange(db storage.DB, c *storage.DepartmentChange) error {
+	if c.Name == nil && c.ParentID == nil {
+		return fmt.Errorf("%w: no name or parent given", ErrIncorrectReorg)
+	}
+	if c.Name != nil {
+		name := strings.TrimSpace(*c.Name)
+		if err := validateName(name); err != nil {
+			return err
+		}
+		c.Name = &name
+	}
+	if !positive(c.ParentID) {
+		return fmt.Errorf("%w: incorrect parent ID", ErrIncorrectReorg)
+	}
+	if err := db.StageDepartmentChange(context.Background(), c); err != nil {
+		return wrapDBErr(err, "failed to stage the department change")
+	}
+	return nil
+}
+
+func UnstageDepartmentChange(db storage.DB, reorgID int, department int) error {
+	if err := db.UnstageDepartmentChange(context.Background(), reorgID, department); err != nil {
+		return wrapDBErr(err, "failed to unstage the department change")
+	}
+	return nil
+}
+
+// DiscardReorg closes a draft reorg without applying it.
+func DiscardReorg(db storage.DB, id int) (*storage.Reorg, error) {
+	discarded, err := db.DiscardReorg(context.Background(), id)
+	if err != nil {
+		return nil, wrapDBErr(err, "failed to discard the reorg")
+	}
+	return discarded, nil
+}
+
+func validateName(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("%w: empty name", ErrIncorrectReorg)
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return fmt.Errorf("%w: name is longer than %d characters", ErrIncorrectReorg, maxNameLength)
+	}
+	return nil
+}
+
+func positive(id *int) bool {
+	return id == nil || *id > 0
+}
+
+func wrapDBErr(err error, msg string) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%w: %s: %v", ErrReorgNotFound, msg, err)
+	}
+	if errors.Is(err, storage.ErrConflict) {
+		return fmt.Errorf("%w: %s: %v", ErrReorgConflict, msg, err)
+	}
+	return fmt.Errorf("%w: %s: %v", ErrDBRequestFailed, msg, err)
+}
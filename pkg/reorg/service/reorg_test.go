@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	budgetStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	ratesStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/rates/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/reorg/storage"
+)
+
+var today = time.Date(2021, time.August, 10, 0, 0, 0, 0, time.UTC)
+
+func TestPreviewReorg(t *testing.T) {
+	setNow(t, today.Add(time.Hour*10))
+	cases := []struct {
+		moves             []*storage.Move
+		departmentChanges []*storage.DepartmentChange
+		expectedIssues    []string
+	}{
+		{
+			// the manager of the whole company manages themselves
+			moves: []*storage.Move{
+				{EmployeeID: 3, ManagerID: intPtr(1)},
+			},
+		},
+		{
+			moves: []*storage.Move{
+				{EmployeeID: 1, ManagerID: intPtr(3)},
+			},
+			expectedIssues: []string{IssueManagerCycle},
+		},
+		{
+			moves: []*storage.Move{
+				{EmployeeID: 2, ManagerID: intPtr(3)},
+				{EmployeeID: 3, ManagerID: intPtr(2)},
+			},
+			expectedIssues: []string{IssueManagerCycle},
+		},
+		{
+			departmentChanges: []*storage.DepartmentChange{
+				{Department: 1, ParentID: intPtr(3)},
+			},
+			expectedIssues: []string{IssueDepartmentCycle},
+		},
+		{
+			departmentChanges: []*storage.DepartmentChange{
+				{Department: 3, ParentID: intPtr(1), Name: strPtr("Platform")},
+			},
+		},
+		{
+			moves: []*storage.Move{
+				{EmployeeID: 9, Department: intPtr(2)},
+				{EmployeeID: 2, Department: intPtr(9)},
+				{EmployeeID: 3, ManagerID: intPtr(9)},
+			},
+			departmentChanges: []*storage.DepartmentChange{
+				{Department: 9, Name: strPtr("Ghost")},
+			},
+			expectedIssues: []string{
+				IssueMissingDepartment,
+				IssueMissingEmployee,
+				IssueMissingDepartment,
+				IssueMissingEmployee,
+			},
+		},
+		{
+			// department 2 with the budget of 150 gets the salary of 75 in
+			// addition to the payroll of 100
+			moves: []*storage.Move{
+				{EmployeeID: 3, Department: intPtr(2)},
+			},
+			expectedIssues: []string{IssueOverBudget},
+		},
+		{
+			// department 3 was already over its budget, moving the employee
+			// out of it only improves the situation
+			moves: []*storage.Move{
+				{EmployeeID: 4, Department: intPtr(1)},
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		mock := newDBMock()
+		mock.reorg.Moves = tc.moves
+		mock.reorg.DepartmentChanges = tc.departmentChanges
+		p, err := PreviewReorg(mock, 1)
+		if err != nil {
+			t.Fatalf("test case #%d: PreviewReorg failed: %v", i, err)
+		}
+		kinds := make([]string, 0, len(p.Issues))
+		for _, issue := range p.Issues {
+			kinds = append(kinds, issue.Kind)
+		}
+		if fmt.Sprint(kinds) != fmt.Sprint(tc.expectedIssues) {
+			t.Errorf("test case #%d: expected issues %v, got %v", i, tc.expectedIssues, kinds)
+		}
+		if p.Valid != (len(tc.expectedIssues) == 0) {
+			t.Errorf("test case #%d: expected the preview to be valid: %v", i, len(tc.expectedIssues) == 0)
+		}
+	}
+}
+
+func TestPreviewReorgBudgetImpact(t *testing.T) {
+	setNow(t, today)
+	mock := newDBMock()
+	mock.reorg.Moves = []*storage.Move{
+		{EmployeeID: 3, Department: intPtr(2)},
+	}
+	p, err := PreviewReorg(mock, 1)
+	if err != nil {
+		t.Fatalf("PreviewReorg failed: %v", err)
+	}
+	if p.Period != (budgetStorage.Period{Year: 2021, Quarter: 3}) {
+		t.Errorf("expected the period 2021Q3, got %+v", p.Period)
+	}
+	if len(p.BudgetImpact) != 2 {
+		t.Fatalf("expected the impact on 2 departments, got %d", len(p.BudgetImpact))
+	}
+	// the salary of 1 USD is converted at 75 RUB
+	expected := []BudgetImpact{
+		{Department: 1, Name: "root", PayrollBefore: rub(150), PayrollAfter: rub(75), StatusBefore: "no_budget", StatusAfter: "no_budget"},
+		{Department: 2, Name: "Backend", PayrollBefore: rub(100), PayrollAfter: rub(175), StatusBefore: "under_budget", StatusAfter: "over_budget"},
+	}
+	for i, e := range expected {
+		actual := *p.BudgetImpact[i]
+		actual.Budget = nil
+		if actual != e {
+			t.Errorf("expected the impact %+v, got %+v", e, actual)
+		}
+	}
+	var moved bool
+	for _, u := range p.Units[0].Children {
+		for _, m := range u.Members {
+			if m.EmployeeID == 3 {
+				moved = u.ID == 2 && m.Since.Equal(today)
+			}
+		}
+	}
+	if !moved {
+		t.Errorf("expected the employee 3 to be moved to the department 2 today")
+	}
+}
+
+func TestCommitReorg(t *testing.T) {
+	setNow(t, today)
+	cases := []struct {
+		status      string
+		moves       []*storage.Move
+		expectedErr error
+	}{
+		{
+			status: storage.StatusDraft,
+			moves: []*storage.Move{
+				{EmployeeID: 3, ManagerID: intPtr(2)},
+			},
+		},
+		{
+			status: storage.StatusDraft,
+			moves: []*storage.Move{
+				{EmployeeID: 1, ManagerID: intPtr(3)},
+			},
+			expectedErr: ErrInvalidReorg,
+		},
+		{
+			status:      storage.StatusCommitted,
+			expectedErr: ErrReorgConflict,
+		},
+	}
+
+	for i, tc := range cases {
+		mock := newDBMock()
+		mock.reorg.Status = tc.status
+		mock.reorg.Moves = tc.moves
+		_, err := CommitReorg(mock, 1, "alice")
+		if err := compareErrs(tc.expectedErr, err); err != nil {
+			t.Errorf("test case #%d: %v", i, err)
+		}
+		if committed := tc.expectedErr == nil; mock.committed != committed {
+			t.Errorf("test case #%d: expected the reorg to be committed: %v", i, committed)
+		}
+	}
+}
+
+func TestStageMove(t *testing.T) {
+	cases := []struct {
+		move        *storage.Move
+		expectedErr error
+	}{
+		{
+			move: &storage.Move{ReorgID: 1, EmployeeID: 2, ManagerID: intPtr(3)},
+		},
+		{
+			move:        &storage.Move{ReorgID: 1, EmployeeID: 2},
+			expectedErr: ErrIncorrectReorg,
+		},
+		{
+			move:        &storage.Move{ReorgID: 1, EmployeeID: 2, Department: intPtr(-1)},
+			expectedErr: ErrIncorrectReorg,
+		},
+		{
+			move:        &storage.Move{ReorgID: 2, EmployeeID: 2, Position: intPtr(1)},
+			expectedErr: ErrReorgConflict,
+		},
+	}
+
+	for i, tc := range cases {
+		mock := newDBMock()
+		err := StageMove(mock, tc.move)
+		if err := compareErrs(tc.expectedErr, err); err != nil {
+			t.Errorf("test case #%d: %v", i, err)
+		}
+	}
+}
+
+type dbMock struct {
+	reorg     *storage.Reorg
+	committed bool
+}
+
+func newDBMock() *dbMock {
+	return &dbMock{
+		reorg: &storage.Reorg{
+			ID:     1,
+			Name:   "Q3 reorg",
+			Status: storage.StatusDraft,
+		},
+	}
+}
+
+func (db *dbMock) CreateReorg(ctx context.Context, r *storage.Reorg) (*storage.Reorg, error) {
+	return r, nil
+}
+
+func (db *dbMock) ListReorgs(ctx context.Context) ([]*storage.Reorg, error) {
+	return []*storage.Reorg{db.reorg}, nil
+}
+
+func (db *dbMock) GetReorg(ctx context.Context, id int) (*storage.Reorg, error) {
+	if id != db.reorg.ID {
+		return nil, storage.ErrNotFound
+	}
+	return db.reorg, nil
+}
+
+func (db *dbMock) StageMove(ctx context.Context, m *storage.Move) error {
+	if m.ReorgID != db.reorg.ID {
+		return storage.ErrConflict
+	}
+	return nil
+}
+
+func (db *dbMock) UnstageMove(ctx context.Context, reorgID int, employeeID int) error {
+	return nil
+}
+
+func (db *dbMock) StageDepartmentChange(ctx context.Context, c *storage.DepartmentChange) error {
+	return nil
+}
+
+func (db *dbMock) UnstageDepartmentChange(ctx context.Context, reorgID int, department int) error {
+	return nil
+}
+
+// ListEmployees returns the employees 1, 2 and 4 paid in RUB and the employee
+// 3 paid 1 USD, all managed by the employee 1.
+func (db *dbMock) ListEmployees(ctx context.Context) ([]*storage.Employee, error) {
+	return []*storage.Employee{
+		{ID: 1, LastName: "One", Department: 1, ManagerID: 1, Salary: rub(75)},
+		{ID: 2, LastName: "Two", Department: 2, ManagerID: 1, Salary: rub(100)},
+		{ID: 3, LastName: "Three", Department: 1, ManagerID: 1, Salary: money.New(100, "USD")},
+		{ID: 4, LastName: "Four", Department: 3, ManagerID: 1, Salary: rub(50)},
+	}, nil
+}
+
+func (db *dbMock) ListDepartments(ctx context.Context) ([]*storage.Department, error) {
+	return []*storage.Department{
+		{ID: 1, ParentID: 1, Name: "root"},
+		{ID: 2, ParentID: 1, Name: "Backend"},
+		{ID: 3, ParentID: 2, Name: "Storage"},
+	}, nil
+}
+
+func (db *dbMock) ListBudgets(ctx context.Context, period budgetStorage.Period) ([]*budgetStorage.Budget, error) {
+	if period != (budgetStorage.Period{Year: 2021, Quarter: 3}) {
+		return nil, fmt.Errorf("unexpected period %+v", period)
+	}
+	return []*budgetStorage.Budget{
+		{Department: 2, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub(150)},
+		{Department: 3, FiscalYear: 2021, FiscalQuarter: 3, Budget: rub(10)},
+	}, nil
+}
+
+func (db *dbMock) ListRates(ctx context.Context, on time.Time) ([]*ratesStorage.Rate, error) {
+	if !on.Equal(today) {
+		return nil, fmt.Errorf("unexpected date %v", on)
+	}
+	return []*ratesStorage.Rate{
+		{From: "USD", To: "RUB", Rate: "75", EffectiveDate: today},
+	}, nil
+}
+
+func (db *dbMock) CommitReorg(ctx context.Context, r *storage.Reorg, committedBy string) (*storage.Reorg, error) {
+	db.committed = true
+	r.Status = storage.StatusCommitted
+	r.CommittedBy = &committedBy
+	return r, nil
+}
+
+func (db *dbMock) DiscardReorg(ctx context.Context, id int) (*storage.Reorg, error) {
+	return db.reorg, nil
+}
+
+func (db *dbMock) Close() {}
+
+func rub(units int64) money.Money {
+	return money.New(money.Amount(units*100), "RUB")
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func strPtr(v string) *string {
+	return &v
+}
+
+func setNow(t *testing.T, n time.Time) {
+	prev := now
+	now = func() time.Time {
+		return n
+	}
+	t.Cleanup(func() {
+		now = prev
+	})
+}
+
+func compareErrs(expectedErr error, actualErr error) error {
+	if expectedErr == nil && actualErr == nil {
+		return nil
+	}
+	if actualErr == nil {
+		return fmt.Errorf("expected an error \"%v\", got nil", expectedErr)
+	}
+	if !errors.Is(actualErr, expectedErr) {
+		return fmt.Errorf("expected error \"%v\" and actual error \"%v\" are different", expectedErr, actualErr)
+	}
+	return nil
+}
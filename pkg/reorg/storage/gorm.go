@@ -0,0 +1,498 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	budgetStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	employeesStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/employees/storage"
+	ratesStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/rates/storage"
+)
+
+type gormDB struct {
+	db  *gorm.DB
+	src *auditStorage.Source
+}
+
+func newGormDB(c *database.ConnString, src *auditStorage.Source) (*gormDB, error) {
+	db, err := database.OpenGorm(c)
+	if err != nil {
+		return nil, err
+	}
+	return &gormDB{
+		db:  db,
+		src: src,
+	}, nil
+}
+
+func (g *gormDB) CreateReorg(ctx context.Context, r *Reorg) (*Reorg, error) {
+	var created []*Reorg
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(
+			`INSERT INTO reorgs (name, created_by) VALUES (?, ?) RETURNING *`,
+			r.Name,
+			r.CreatedBy,
+		).Scan(&created).Error
+		if err != nil {
+			return err
+		}
+		if len(created) == 0 {
+			return fmt.Errorf("no row returned")
+		}
+		return auditStorage.Record(tx, g.src, &auditStorage.Change{
+			Action:     auditStorage.ActionCreate,
+			EntityType: auditStorage.EntityReorg,
+			EntityID:   created[0].ID,
+			After:      created[0],
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert the reorg: %w", err)
+	}
+	return created[0], nil
+}
+
+func (g *gormDB) ListReorgs(ctx context.Context) ([]*Reorg, error) {
+	var reorgs []*Reorg
+	req := g.db.WithContext(ctx).Order("created_at DESC, id DESC").Find(&reorgs)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query the reorgs: %w", err)
+	}
+	return reorgs, nil
+}
+
+func (g *gormDB) GetReorg(ctx context.Context, id int) (*Reorg, error) {
+	r := &Reorg{}
+	req := g.db.WithContext(ctx).Where("id = ?", id).Take(r)
+	if err := req.Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: reorg %d", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to query the reorg: %w", err)
+	}
+	if err := loadChanges(g.db.WithContext(ctx), r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (g *gormDB) StageMove(ctx context.Context, m *Move) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the draft is locked in the share mode, so that the move cannot slip
+		// in while the reorg is being committed
+		var staged []*Move
+		err := tx.Raw(
+			`INSERT INTO reorg_moves (reorg_id, employee_id, department, position, manager_id)
+			SELECT ?, ?, ?, ?, ?
+			WHERE EXISTS (SELECT 1 FROM reorgs WHERE id = ? AND status = ? FOR SHARE)
+			ON CONFLICT (reorg_id, employee_id) DO UPDATE SET
+				department = EXCLUDED.department,
+				position = EXCLUDED.position,
+				manager_id = EXCLUDED.manager_id
+			RETURNING *`,
+			m.ReorgID,
+			m.EmployeeID,
+			m.Department,
+			m.Position,
+			m.ManagerID,
+			m.ReorgID,
+			StatusDraft,
+		).Scan(&staged).Error
+		if err != nil {
+			return wrapWriteErr(err, "failed to stage the move")
+		}
+		if len(staged) == 0 {
+			return fmt.Errorf("%w: reorg %d is not a draft", ErrConflict, m.ReorgID)
+		}
+		return recordStaged(tx, g.src, auditStorage.ActionStage, m.ReorgID, nil, staged[0])
+	})
+}
+
+func (g *gormDB) UnstageMove(ctx context.Context, reorgID int, employeeID int) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var unstaged []*Move
+		err := tx.Raw(
+			`DELETE FROM reorg_moves
+			WHERE reorg_id = ? AND employee_id = ?
+				AND EXISTS (SELECT 1 FROM reorgs WHERE id = ? AND status = ? FOR SHARE)
+			RETURNING *`,
+			reorgID,
+			employeeID,
+			reorgID,
+			StatusDraft,
+		).Scan(&unstaged).Error
+		if err != nil {
+			return fmt.Errorf("failed to unstage the move: %w", err)
+		}
+		if len(unstaged) == 0 {
+			return fmt.Errorf("%w: move of employee %d in draft reorg %d", ErrNotFound, employeeID, reorgID)
+		}
+		return recordStaged(tx, g.src, auditStorage.ActionUnstage, reorgID, unstaged[0], nil)
+	})
+}
+
+func (g *gormDB) StageDepartmentChange(ctx context.Context, c *DepartmentChange) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var staged []*DepartmentChange
+		err := tx.Raw(
+			`INSERT INTO reorg_department_changes (reorg_id, department, name, parent_id)
+			SELECT ?, ?, ?, ?
+			WHERE EXISTS (SELECT 1 FROM reorgs WHERE id = ? AND status = ? FOR SHARE)
+			ON CONFLICT (reorg_id, department) DO UPDATE SET
+				name = EXCLUDED.name,
+				parent_id = EXCLUDED.parent_id
+			RETURNING *`,
+			c.ReorgID,
+			c.Department,
+			c.Name,
+			c.ParentID,
+			c.ReorgID,
+			StatusDraft,
+		).Scan(&staged).Error
+		if err != nil {
+			return wrapWriteErr(err, "failed to stage the department change")
+		}
+		if len(staged) == 0 {
+			return fmt.Errorf("%w: reorg %d is not a draft", ErrConflict, c.ReorgID)
+		}
+		return recordStaged(tx, g.src, auditStorage.ActionStage, c.ReorgID, nil, staged[0])
+	})
+}
+
+func (g *gormDB) UnstageDepartmentChange(ctx context.Context, reorgID int, department int) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var unstaged []*DepartmentChange
+		err := tx.Raw(
+			`DELETE FROM reorg_department_changes
+			WHERE reorg_id = ? AND department = ?
+				AND EXISTS (SELECT 1 FROM reorgs WHERE id = ? AND status = ? FOR SHARE)
+			RETURNING *`,
+			reorgID,
+			department,
+			reorgID,
+			StatusDraft,
+		).Scan(&unstaged).Error
+		if err != nil {
+			return fmt.Errorf("failed to unstage the department change: %w", err)
+		}
+		if len(unstaged) == 0 {
+			return fmt.Errorf("%w: change of department %d in draft reorg %d", ErrNotFound, department, reorgID)
+		}
+		return recordStaged(tx, g.src, auditStorage.ActionUnstage, reorgID, unstaged[0], nil)
+	})
+}
+
+func (g *gormDB) ListEmployees(ctx context.Context) ([]*Employee, error) {
+	var employees []*Employee
+	// the salaries the caller may not read are left out of employee_salaries
+	req := g.db.WithContext(ctx).Raw(
+		`SELECT e.id, e.first_name, e.last_name, e.department, e.position, e.manager_id,
+			s.salary_amount, s.salary_currency, a.valid_from AS since
+		FROM employees e
+		LEFT JOIN employee_salaries s ON s.employee_id = e.id
+		LEFT JOIN employee_assignments a ON a.employee_id = e.id AND a.valid_to IS NULL
+		WHERE e.terminated_on IS NULL OR e.terminated_on >= CURRENT_DATE
+		ORDER BY e.id`,
+	).Scan(&employees)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query the employees: %w", err)
+	}
+	return employees, nil
+}
+
+func (g *gormDB) ListDepartments(ctx context.Context) ([]*Department, error) {
+	var depts []*Department
+	req := g.db.WithContext(ctx).
+		Table("departments").
+		Select("id, parent_id, name").
+		Order("id").
+		Find(&depts)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query the departments: %w", err)
+	}
+	return depts, nil
+}
+
+func (g *gormDB) ListBudgets(ctx context.Context, period budgetStorage.Period) ([]*budgetStorage.Budget, error) {
+	var budgets []*budgetStorage.Budget
+	req := g.db.WithContext(ctx).
+		Table("departments_budget").
+		Select("department, fiscal_year, fiscal_quarter, budget_amount, budget_currency").
+		Where("fiscal_year = ? AND fiscal_quarter = ?", period.Year, period.Quarter).
+		Order("department").
+		Find(&budgets)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query department budgets: %w", err)
+	}
+	return budgets, nil
+}
+
+func (g *gormDB) ListRates(ctx context.Context, on time.Time) ([]*ratesStorage.Rate, error) {
+	var rates []*ratesStorage.Rate
+	req := g.db.WithContext(ctx).Raw(
+		`SELECT DISTINCT ON (from_currency, to_currency) from_currency, to_currency, rate, effective_date
+		FROM exchange_rates
+		WHERE effective_date <= ?
+		ORDER BY from_currency, to_currency, effective_date DESC`,
+		on.Format("2006-01-02"),
+	).Scan(&rates)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to query exchange rates: %w", err)
+	}
+	return rates, nil
+}
+
+func (g *gormDB) CommitReorg(ctx context.Context, r *Reorg, committedBy string) (*Reorg, error) {
+	var committed []*Reorg
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current, err := lockDraft(tx, r.ID)
+		if err != nil {
+			return err
+		}
+		// the caller has validated r, the reorg must not have changed since
+		if !reflect.DeepEqual(current.Moves, r.Moves) ||
+			!reflect.DeepEqual(current.DepartmentChanges, r.DepartmentChanges) {
+			return fmt.Errorf("%w: reorg %d has changed", ErrConflict, r.ID)
+		}
+		for _, c := range current.DepartmentChanges {
+			if err := applyDepartmentChange(tx, g.src, c); err != nil {
+				return err
+			}
+		}
+		reason := "reorg: " + current.Name
+		for _, m := range current.Moves {
+			if err := applyMove(tx, g.src, m, reason, committedBy); err != nil {
+				return err
+			}
+		}
+		err = tx.Raw(
+			`UPDATE reorgs SET status = ?, committed_by = ?, committed_at = NOW()
+			WHERE id = ?
+			RETURNING *`,
+			StatusCommitted,
+			committedBy,
+			r.ID,
+		).Scan(&committed).Error
+		if err != nil || len(committed) == 0 {
+			return err
+		}
+		return auditStorage.Record(tx, g.src, &auditStorage.Change{
+			Action:     auditStorage.ActionCommit,
+			EntityType: auditStorage.EntityReorg,
+			EntityID:   r.ID,
+			Before:     current,
+			After:      committed[0],
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit the reorg: %w", err)
+	}
+	if len(committed) == 0 {
+		return nil, fmt.Errorf("failed to commit the reorg: no row returned")
+	}
+	committed[0].Moves = r.Moves
+	committed[0].DepartmentChanges = r.DepartmentChanges
+	return committed[0], nil
+}
+
+func (g *gormDB) DiscardReorg(ctx context.Context, id int) (*Reorg, error) {
+	var discarded []*Reorg
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current, err := lockDraft(tx, id)
+		if err != nil {
+			return err
+		}
+		err = tx.Raw(
+			`UPDATE reorgs SET status = ?, discarded_at = NOW() WHERE id = ? RETURNING *`,
+			StatusDiscarded,
+			id,
+		).Scan(&discarded).Error
+		if err != nil || len(discarded) == 0 {
+			return err
+		}
+		return auditStorage.Record(tx, g.src, &auditStorage.Change{
+			Action:     auditStorage.ActionDiscard,
+			EntityType: auditStorage.EntityReorg,
+			EntityID:   id,
+			Before:     current,
+			After:      discarded[0],
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discard the reorg: %w", err)
+	}
+	if len(discarded) == 0 {
+		return nil, fmt.Errorf("failed to discard the reorg: no row returned")
+	}
+	return discarded[0], nil
+}
+
+func (g *gormDB) Close() {
+	database.CloseGorm(g.db)
+}
+
+// lockDraft locks the reorg for the rest of the transaction and loads its
+// changes. It returns ErrConflict if the reorg is not a draft.
+func lockDraft(tx *gorm.DB, id int) (*Reorg, error) {
+	r := &Reorg{}
+	req := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Take(r)
+	if err := req.Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: reorg %d", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to lock the reorg: %w", err)
+	}
+	if r.Status != StatusDraft {
+		return nil, fmt.Errorf("%w: reorg %d is %s", ErrConflict, id, r.Status)
+	}
+	if err := loadChanges(tx, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func loadChanges(db *gorm.DB, r *Reorg) error {
+	r.Moves = []*Move{}
+	req := db.Where("reorg_id = ?", r.ID).Order("employee_id").Find(&r.Moves)
+	if err := req.Error; err != nil {
+		return fmt.Errorf("failed to query the reorg moves: %w", err)
+	}
+	r.DepartmentChanges = []*DepartmentChange{}
+	req = db.Where("reorg_id = ?", r.ID).Order("department").Find(&r.DepartmentChanges)
+	if err := req.Error; err != nil {
+		return fmt.Errorf("failed to query the reorg department changes: %w", err)
+	}
+	return nil
+}
+
+func applyDepartmentChange(tx *gorm.DB, src *auditStorage.Source, c *DepartmentChange) error {
+	before, err := lockDepartment(tx, c.Department)
+	if err != nil {
+		return err
+	}
+	err = tx.Exec(
+		`UPDATE departments SET name = COALESCE(?, name), parent_id = COALESCE(?, parent_id) WHERE id = ?`,
+		c.Name,
+		c.ParentID,
+		c.Department,
+	).Error
+	if err != nil {
+		return wrapWriteErr(err, fmt.Sprintf("failed to change department %d", c.Department))
+	}
+	after, err := lockDepartment(tx, c.Department)
+	if err != nil {
+		return err
+	}
+	return auditStorage.Record(tx, src, &auditStorage.Change{
+		Action:     auditStorage.ActionUpdate,
+		EntityType: auditStorage.EntityDepartment,
+		EntityID:   c.Department,
+		Before:     before,
+		After:      after,
+	})
+}
+
+func lockDepartment(tx *gorm.DB, id int) (*Department, error) {
+	d := &Department{}
+	req := tx.Table("departments").
+		Select("id, parent_id, name").
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		Take(d)
+	if err := req.Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: department %d does not exist", ErrConflict, id)
+		}
+		return nil, fmt.Errorf("failed to lock department %d: %w", id, err)
+	}
+	return d, nil
+}
+
+// applyMove updates the employee and starts a new assignment effective today
+// if the move changes anything.
+func applyMove(tx *gorm.DB, src *auditStorage.Source, m *Move, reason string, changedBy string) error {
+	e := &Employee{}
+	req := tx.Table("employees").
+		Select("id, department, position, manager_id").
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", m.EmployeeID).
+		Take(e)
+	if err := req.Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: employee %d does not exist", ErrConflict, m.EmployeeID)
+		}
+		return fmt.Errorf("failed to lock employee %d: %w", m.EmployeeID, err)
+	}
+	a := &employeesStorage.Assignment{
+		EmployeeID: e.ID,
+		Department: e.Department,
+		Position:   e.Position,
+		ManagerID:  e.ManagerID,
+		Reason:     reason,
+		ChangedBy:  changedBy,
+	}
+	if m.Department != nil {
+		a.Department = *m.Department
+	}
+	if m.Position != nil {
+		a.Position = *m.Position
+	}
+	if m.ManagerID != nil {
+		a.ManagerID = *m.ManagerID
+	}
+	if a.Department == e.Department && a.Position == e.Position && a.ManagerID == e.ManagerID {
+		return nil
+	}
+	before, err := employeesStorage.LoadEmployee(tx, m.EmployeeID)
+	if err != nil {
+		return err
+	}
+	err = tx.Exec(
+		`UPDATE employees SET department = ?, position = ?, manager_id = ? WHERE id = ?`,
+		a.Department,
+		a.Position,
+		a.ManagerID,
+		a.EmployeeID,
+	).Error
+	if err != nil {
+		return wrapWriteErr(err, fmt.Sprintf("failed to move employee %d", m.EmployeeID))
+	}
+	if _, err := employeesStorage.StartAssignment(tx, a, nil); err != nil {
+		if errors.Is(err, employeesStorage.ErrConflict) {
+			return fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		return err
+	}
+	after, err := employeesStorage.LoadEmployee(tx, m.EmployeeID)
+	if err != nil {
+		return err
+	}
+	return employeesStorage.RecordEmployee(tx, src, auditStorage.ActionUpdate, before, after)
+}
+
+// recordStaged records a change of the staged move or department change of
+// the reorg in the audit log.
+func recordStaged(tx *gorm.DB, src *auditStorage.Source, action string, reorgID int, before interface{}, after interface{}) error {
+	return auditStorage.Record(tx, src, &auditStorage.Change{
+		Action:     action,
+		EntityType: auditStorage.EntityReorg,
+		EntityID:   reorgID,
+		Before:     before,
+		After:      after,
+	})
+}
+
+func wrapWriteErr(err error, msg string) error {
+	if database.IsUniqueViolation(err) || database.IsForeignKeyViolation(err) {
+		return fmt.Errorf("%w: %s: %v", ErrConflict, msg, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	auditStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/audit/storage"
+	budgetStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/budget/storage"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/database"
+	"github.com/SergeyShpak/gopher-corp-backend/pkg/money"
+	ratesStorage "github.com/SergeyShpak/gopher-corp-backend/pkg/rates/storage"
+)
+
+type ContextKey int
+
+const ContextKeyDB ContextKey = iota + 1
+
+var (
+	ErrNotFound = fmt.Errorf("not found")
+	ErrConflict = fmt.Errorf("conflicts with the existing data")
+)
+
+const (
+	StatusDraft     = "draft"
+	StatusCommitted = "committed"
+	StatusDiscarded = "discarded"
+)
+
+// Reorg is a set of moves and department changes applied together. Only a
+// draft reorg may be changed, committed or discarded.
+type Reorg struct {
+	ID                int                 `gorm:"column:id" json:"id"`
+	Name              string              `gorm:"column:name" json:"name"`
+	Status            string              `gorm:"column:status" json:"status"`
+	CreatedBy         string              `gorm:"column:created_by" json:"created_by"`
+	CreatedAt         time.Time           `gorm:"column:created_at" json:"created_at"`
+	CommittedBy       *string             `gorm:"column:committed_by" json:"committed_by"`
+	CommittedAt       *time.Time          `gorm:"column:committed_at" json:"committed_at"`
+	DiscardedAt       *time.Time          `gorm:"column:discarded_at" json:"discarded_at"`
+	Moves             []*Move             `gorm:"-" json:"moves"`
+	DepartmentChanges []*DepartmentChange `gorm:"-" json:"department_changes"`
+}
+
+func (Reorg) TableName() string {
+	return "reorgs"
+}
+
+// Move is a staged change of an employee's assignment. A nil field keeps the
+// employee's current value.
+type Move struct {
+	ReorgID    int  `gorm:"column:reorg_id" json:"-"`
+	EmployeeID int  `gorm:"column:employee_id" json:"employee_id"`
+	Department *int `gorm:"column:department" json:"department"`
+	Position   *int `gorm:"column:position" json:"position"`
+	ManagerID  *int `gorm:"column:manager_id" json:"manager_id"`
+}
+
+func (Move) TableName() string {
+	return "reorg_moves"
+}
+
+// DepartmentChange is a staged rename or reparenting of a department. A nil
+// field keeps the department's current value.
+type DepartmentChange struct {
+	ReorgID    int     `gorm:"column:reorg_id" json:"-"`
+	Department int     `gorm:"column:department" json:"department"`
+	Name       *string `gorm:"column:name" json:"name"`
+	ParentID   *int    `gorm:"column:parent_id" json:"parent_id"`
+}
+
+func (DepartmentChange) TableName() string {
+	return "reorg_department_changes"
+}
+
+// Employee is the part of an employee a reorg changes or depends on.
+type Employee struct {
+	ID         int         `gorm:"column:id"`
+	FirstName  string      `gorm:"column:first_name"`
+	LastName   string      `gorm:"column:last_name"`
+	Department int         `gorm:"column:department"`
+	Position   int         `gorm:"column:position"`
+	ManagerID  int         `gorm:"column:manager_id"`
+	Salary     money.Money `gorm:"embedded;embeddedPrefix:salary_"`
+	// Since is the start of the employee's current assignment.
+	Since time.Time `gorm:"column:since"`
+}
+
+type Department struct {
+	ID       int    `gorm:"column:id" json:"id"`
+	ParentID int    `gorm:"column:parent_id" json:"parent_id"`
+	Name     string `gorm:"column:name" json:"name"`
+}
+
+type DB interface {
+	CreateReorg(ctx context.Context, r *Reorg) (*Reorg, error)
+	// ListReorgs returns the reorgs without their moves and department
+	// changes, the latest first.
+	ListReorgs(ctx context.Context) ([]*Reorg, error)
+	// GetReorg returns the reorg with its moves and department changes.
+	GetReorg(ctx context.Context, id int) (*Reorg, error)
+	// StageMove replaces the employee's move staged in the reorg. It returns
+	// ErrConflict if the reorg is not a draft.
+	StageMove(ctx context.Context, m *Move) error
+	// UnstageMove returns ErrNotFound if the reorg is not a draft or has no
+	// move of the employee.
+	UnstageMove(ctx context.Context, reorgID int, employeeID int) error
+	// StageDepartmentChange replaces the department's change staged in the
+	// reorg. It returns ErrConflict if the reorg is not a draft.
+	StageDepartmentChange(ctx context.Context, c *DepartmentChange) error
+	// UnstageDepartmentChange returns ErrNotFound if the reorg is not a draft
+	// or has no change of the department.
+	UnstageDepartmentChange(ctx context.Context, reorgID int, department int) error
+	// ListEmployees returns the employees who have not left ordered by ID.
+	ListEmployees(ctx context.Context) ([]*Employee, error)
+	ListDepartments(ctx context.Context) ([]*Department, error)
+	ListBudgets(ctx context.Context, period budgetStorage.Period) ([]*budgetStorage.Budget, error)
+	// ListRates returns the exchange rate of every currency pair in effect on
+	// the date.
+	ListRates(ctx context.Context, on time.Time) ([]*ratesStorage.Rate, error)
+	// CommitReorg applies the moves and the department changes of r in a
+	// single transaction. Every moved employee starts a new assignment
+	// effective today. ErrConflict is returned if the reorg is no longer a
+	// draft or its changes conflict with the current data.
+	CommitReorg(ctx context.Context, r *Reorg, committedBy string) (*Reorg, error)
+	// DiscardReorg returns ErrConflict if the reorg is not a draft.
+	DiscardReorg(ctx context.Context, id int) (*Reorg, error)
+	Close()
+}
+
+// NewDB opens the storage, the changes made through it are recorded in the
+// audit log as made by src.
+func NewDB(connStr *database.ConnString, src *auditStorage.Source) (DB, error) {
+	gormDB, err := newGormDB(connStr, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a gorm connection: %w", err)
+	}
+	return gormDB, nil
+}
@@ -0,0 +1,46 @@
+package visibility
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// LoadViewer returns the employee as a viewer of the contacts. An unknown
+// employee or one who has left is an anonymous viewer, as is the zero ID.
+func LoadViewer(db *gorm.DB, employeeID int) (*Viewer, error) {
+	v := &Viewer{}
+	if employeeID == 0 {
+		return v, nil
+	}
+	req := db.Table("employees").
+		Select("id AS employee_id, department").
+		Where("id = ?", employeeID).
+		Where("terminated_on IS NULL OR terminated_on >= CURRENT_DATE").
+		Take(v)
+	if err := req.Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Viewer{}, nil
+		}
+		return nil, fmt.Errorf("failed to query the viewer: %w", err)
+	}
+	var reports []int
+	err := db.Raw(
+		`WITH RECURSIVE reports AS (
+			SELECT id FROM employees WHERE manager_id = ? AND id <> manager_id
+			UNION
+			SELECT e.id FROM employees e JOIN reports r ON e.manager_id = r.id WHERE e.id <> e.manager_id
+		)
+		SELECT id FROM reports`,
+		employeeID,
+	).Scan(&reports).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the viewer's reports: %w", err)
+	}
+	v.Reports = make(map[int]struct{}, len(reports))
+	for _, id := range reports {
+		v.Reports[id] = struct{}{}
+	}
+	return v, nil
+}
@@ -0,0 +1,94 @@
+// Package visibility decides who sees the contacts of an employee. Every
+// employee chooses the visibility of their phone and email, the company-wide
+// default applies to the contacts without one.
+package visibility
+
+import (
+	"fmt"
+)
+
+// The visibility levels from the widest to the narrowest. The employee always
+// sees their own contacts.
+const (
+	// Public contacts are seen by everyone.
+	Public = "public"
+	// Department contacts are seen by the colleagues from the department and
+	// by the managers.
+	Department = "department"
+	// Managers contacts are seen by the direct and indirect managers.
+	Managers = "managers"
+	// Hidden contacts are seen by nobody else.
+	Hidden = "hidden"
+)
+
+var ErrIncorrectVisibility = fmt.Errorf("got an incorrect visibility")
+
+var levels = map[string]struct{}{
+	Public:     {},
+	Department: {},
+	Managers:   {},
+	Hidden:     {},
+}
+
+var defaultLevel = Public
+
+// Validate returns ErrIncorrectVisibility if the level is unknown.
+func Validate(level string) error {
+	if _, ok := levels[level]; !ok {
+		return fmt.Errorf("%w: unknown visibility %q", ErrIncorrectVisibility, level)
+	}
+	return nil
+}
+
+// SetDefault sets the company-wide default visibility. It is meant to be
+// called once at startup.
+func SetDefault(level string) error {
+	if err := Validate(level); err != nil {
+		return err
+	}
+	defaultLevel = level
+	return nil
+}
+
+// Default returns the company-wide default visibility.
+func Default() string {
+	return defaultLevel
+}
+
+// Owner is the employee whose contacts are looked at.
+type Owner struct {
+	EmployeeID int
+	Department int
+}
+
+// Viewer is the employee looking at the contacts. Reports are the employees
+// the viewer manages directly or indirectly. The zero Viewer is an anonymous
+// caller who sees only the public contacts.
+type Viewer struct {
+	EmployeeID int              `gorm:"column:employee_id"`
+	Department int              `gorm:"column:department"`
+	Reports    map[int]struct{} `gorm:"-"`
+}
+
+// CanSee tells whether the viewer sees the owner's contact with the
+// visibility level, nil standing for the default one.
+func (v *Viewer) CanSee(o Owner, level *string) bool {
+	l := defaultLevel
+	if level != nil {
+		l = *level
+	}
+	if v.EmployeeID != 0 && v.EmployeeID == o.EmployeeID {
+		return true
+	}
+	_, manages := v.Reports[o.EmployeeID]
+	switch l {
+	case Public:
+		return true
+	case Department:
+		return manages || (v.EmployeeID != 0 && v.Department == o.Department)
+	case Managers:
+		return manages
+	default:
+		return false
+	}
+}
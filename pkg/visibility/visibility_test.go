@@ -0,0 +1,65 @@
+package visibility
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCanSee(t *testing.T) {
+	owner := Owner{EmployeeID: 3, Department: 2}
+	anonymous := &Viewer{}
+	self := &Viewer{EmployeeID: 3, Department: 2}
+	colleague := &Viewer{EmployeeID: 4, Department: 2}
+	manager := &Viewer{EmployeeID: 1, Department: 1, Reports: map[int]struct{}{2: {}, 3: {}}}
+	stranger := &Viewer{EmployeeID: 5, Department: 1, Reports: map[int]struct{}{6: {}}}
+
+	cases := []struct {
+		Level    string
+		Viewer   *Viewer
+		Expected bool
+	}{
+		{Level: Public, Viewer: anonymous, Expected: true},
+		{Level: Public, Viewer: stranger, Expected: true},
+		{Level: Department, Viewer: anonymous, Expected: false},
+		{Level: Department, Viewer: colleague, Expected: true},
+		{Level: Department, Viewer: manager, Expected: true},
+		{Level: Department, Viewer: stranger, Expected: false},
+		{Level: Managers, Viewer: colleague, Expected: false},
+		{Level: Managers, Viewer: manager, Expected: true},
+		{Level: Managers, Viewer: self, Expected: true},
+		{Level: Hidden, Viewer: manager, Expected: false},
+		{Level: Hidden, Viewer: self, Expected: true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			level := tc.Level
+			if actual := tc.Viewer.CanSee(owner, &level); actual != tc.Expected {
+				t.Errorf("expected %v for %s and viewer %+v, got %v", tc.Expected, tc.Level, tc.Viewer, actual)
+			}
+		})
+	}
+}
+
+func TestCanSeeDefault(t *testing.T) {
+	prev := Default()
+	t.Cleanup(func() { defaultLevel = prev })
+
+	owner := Owner{EmployeeID: 3, Department: 2}
+	if !(&Viewer{}).CanSee(owner, nil) {
+		t.Errorf("expected the public default to be seen by everyone")
+	}
+	if err := SetDefault(Hidden); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if (&Viewer{EmployeeID: 4, Department: 2}).CanSee(owner, nil) {
+		t.Errorf("expected the hidden default to be seen by nobody")
+	}
+	if err := SetDefault("friends"); !errors.Is(err, ErrIncorrectVisibility) {
+		t.Errorf("expected error %v, got %v", ErrIncorrectVisibility, err)
+	}
+	if Default() != Hidden {
+		t.Errorf("expected the incorrect default to be ignored, got %s", Default())
+	}
+}
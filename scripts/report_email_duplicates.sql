@@ -0,0 +1,24 @@
+-- Lists the employee emails that are the same once lowercased and trimmed,
+-- together with their owners. The duplicates must be resolved before the
+-- 18_unique_employee_emails migration makes the addresses unique:
+--
+--     psql "$DATABASE_URL" -f scripts/report_email_duplicates.sql
+WITH duplicates AS (
+    SELECT LOWER(TRIM(address)) AS normalized
+    FROM employee_emails
+    GROUP BY LOWER(TRIM(address))
+    HAVING COUNT(*) > 1
+)
+SELECT
+    d.normalized,
+    m.address,
+    m.type,
+    m.is_primary,
+    e.id AS employee_id,
+    e.first_name,
+    e.last_name,
+    e.terminated_on
+FROM duplicates d
+JOIN employee_emails m ON LOWER(TRIM(m.address)) = d.normalized
+JOIN employees e ON e.id = m.employee_id
+ORDER BY d.normalized, e.id, m.ordinal;